@@ -0,0 +1,119 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vapor-ware/k8s-elector/pkg"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// This file holds the client code used by the subcommands which query an
+// elector, either through its HTTP API or directly through the election lock.
+
+// unixPrefix is the prefix of elector addresses which are unix sockets.
+const unixPrefix = "unix:"
+
+// electorClient creates an HTTP client and base URL for the elector HTTP API
+// at the given address. The address may be a URL, a host:port, or a unix
+// socket path prefixed with "unix:".
+func electorClient(address string, timeout time.Duration) (*http.Client, string) {
+	client := &http.Client{Timeout: timeout}
+
+	if strings.HasPrefix(address, unixPrefix) {
+		path := strings.TrimPrefix(address, unixPrefix)
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}
+		return client, "http://unix"
+	}
+
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	return client, strings.TrimSuffix(address, "/")
+}
+
+// getLeaderInfo gets the leadership status of the elector at the given address.
+func getLeaderInfo(address string, timeout time.Duration) (pkg.LeaderInfo, error) {
+	var info pkg.LeaderInfo
+
+	client, url := electorClient(address, timeout)
+	resp, err := client.Get(url + "/")
+	if err != nil {
+		return info, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return info, fmt.Errorf("unexpected response from elector: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return info, fmt.Errorf("invalid response from elector: %v", err)
+	}
+	return info, nil
+}
+
+// newKubeClient creates a Kubernetes client from the given kubeconfig file. If
+// no kubeconfig is given, in-cluster config is used.
+func newKubeClient(kubeconfig string) (kubernetes.Interface, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// getLockHolder gets the identity of the leader of an election from its lock.
+// If the lock is not held, or the holder's lease has expired, an empty string
+// is returned.
+func getLockHolder(client kubernetes.Interface, lockType, namespace, name string, now time.Time) (string, error) {
+	lock, err := resourcelock.New(
+		lockType,
+		namespace,
+		name,
+		client.CoreV1(),
+		client.CoordinationV1(),
+		resourcelock.ResourceLockConfig{},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	record, _, err := lock.Get()
+	if err != nil {
+		return "", err
+	}
+
+	expires := record.RenewTime.Add(time.Duration(record.LeaseDurationSeconds) * time.Second)
+	if record.HolderIdentity == "" || now.After(expires) {
+		return "", nil
+	}
+	return record.HolderIdentity, nil
+}
@@ -0,0 +1,467 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/vapor-ware/k8s-elector/pkg"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	// demoElection is the name of the election run by the demo.
+	demoElection = "demo"
+
+	// demoNamespace is the namespace of the demo nodes and election lock.
+	demoNamespace = "default"
+
+	// demoTick is the interval of real time on which the demo clock is
+	// stepped.
+	demoTick = 10 * time.Millisecond
+)
+
+// discardLogger is a pkg.Logger which drops all messages. It keeps the elector
+// logs from cluttering the demo output.
+type discardLogger struct{}
+
+func (discardLogger) Infof(format string, args ...interface{})    {}
+func (discardLogger) Warningf(format string, args ...interface{}) {}
+func (discardLogger) Errorf(format string, args ...interface{})   {}
+
+// demoCluster runs elector nodes in-process against a fake Kubernetes
+// clientset, so the failover behavior of the elector can be observed without
+// a cluster. The nodes share the simulated clock of the cluster, which is
+// stepped by the demo.
+type demoCluster struct {
+	client  *fake.Clientset
+	clock   *clock.FakeClock
+	ttl     time.Duration
+	verbose bool
+	start   time.Time
+
+	out   io.Writer
+	outMu sync.Mutex
+
+	// mu guards the running nodes. A node which has been killed is kept in
+	// the ids list, but is removed from the nodes map.
+	mu    sync.Mutex
+	ids   []string
+	nodes map[string]*pkg.ElectorNode
+	wg    sync.WaitGroup
+}
+
+// newDemoCluster creates a demo cluster with the given number of nodes. A Pod
+// is created in the fake cluster for each node, so the Pod status labels set
+// by the nodes can be shown.
+func newDemoCluster(count int, ttl time.Duration, out io.Writer) (*demoCluster, error) {
+	clk := clock.NewFakeClock(time.Now())
+	c := &demoCluster{
+		client: fake.NewSimpleClientset(),
+		clock:  clk,
+		ttl:    ttl,
+		start:  clk.Now(),
+		out:    out,
+		nodes:  map[string]*pkg.ElectorNode{},
+	}
+
+	for i := 1; i <= count; i++ {
+		id := fmt.Sprintf("node-%d", i)
+		_, err := c.client.CoreV1().Pods(demoNamespace).Create(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id,
+				Namespace: demoNamespace,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		c.ids = append(c.ids, id)
+	}
+	return c, nil
+}
+
+// printf writes a line to the demo output, prefixed with the simulated time
+// elapsed since the demo started.
+func (c *demoCluster) printf(format string, args ...interface{}) {
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+	fmt.Fprintf(c.out, "[%6.1fs] %s\n", c.clock.Since(c.start).Seconds(), fmt.Sprintf(format, args...))
+}
+
+// startNode starts the elector node with the given ID, if it is not already
+// running.
+func (c *demoCluster) startNode(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, running := c.nodes[id]; running {
+		return
+	}
+
+	var logger pkg.Logger = discardLogger{}
+	if c.verbose {
+		logger = pkg.KlogLogger{}
+	}
+	node := pkg.NewElectorNode(&pkg.ElectorConfig{
+		ID:        id,
+		Name:      demoElection,
+		Namespace: demoNamespace,
+		PodName:   id,
+		LockType:  resourcelock.LeasesResourceLock,
+		TTL:       c.ttl,
+		Client:    c.client,
+		Logger:    logger,
+		Clock:     c.clock,
+	})
+	c.nodes[id] = node
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		if err := node.Run(); err != nil && err != context.Canceled {
+			c.printf("%s: error: %v", id, err)
+		}
+	}()
+	c.printf("%s: started", id)
+}
+
+// stopNode stops the elector node with the given ID, if it is running.
+//
+// The node is stopped outside of the lock: stopping may wait on the clock,
+// which is only stepped while the running nodes can be read.
+func (c *demoCluster) stopNode(id string) {
+	c.mu.Lock()
+	node, running := c.nodes[id]
+	delete(c.nodes, id)
+	c.mu.Unlock()
+
+	if !running {
+		return
+	}
+	node.Stop()
+	c.printf("%s: killed", id)
+}
+
+// stopped gets the IDs of the nodes which are not running.
+func (c *demoCluster) stopped() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var ids []string
+	for _, id := range c.ids {
+		if _, running := c.nodes[id]; !running {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// leader gets the identity of the election leader from the election lock.
+// If there is no leader, an empty string is returned.
+func (c *demoCluster) leader() string {
+	lease, err := c.client.CoordinationV1().Leases(demoNamespace).Get(demoElection, metav1.GetOptions{})
+	if err != nil || lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// electing checks whether the cluster is electing a leader, i.e. there are
+// running nodes but none of them holds the election lock.
+func (c *demoCluster) electing() bool {
+	leader := c.leader()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, running := c.nodes[leader]
+	return len(c.nodes) > 0 && !running
+}
+
+// advance steps the cluster's clock every demoTick of real time, by demoTick
+// scaled by the speed, until the context is cancelled.
+//
+// The leader election client times the election itself with the real clock,
+// so the clock is held while the cluster is electing a leader. A failover
+// takes no simulated time, however fast the clock runs.
+func (c *demoCluster) advance(ctx context.Context, speed float64) {
+	ticker := time.NewTicker(demoTick)
+	defer ticker.Stop()
+
+	step := time.Duration(float64(demoTick) * speed)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !c.electing() {
+			c.clock.Step(step)
+		}
+	}
+}
+
+// killLeader stops the node which is currently the leader.
+func (c *demoCluster) killLeader() {
+	leader := c.leader()
+	if leader == "" {
+		c.printf("no leader to kill")
+		return
+	}
+	c.stopNode(leader)
+}
+
+// restore starts all of the nodes which were killed.
+func (c *demoCluster) restore() {
+	stopped := c.stopped()
+	if len(stopped) == 0 {
+		c.printf("no nodes to restore")
+		return
+	}
+	for _, id := range stopped {
+		c.startNode(id)
+	}
+}
+
+// status prints the state of each node along with its Pod status label.
+func (c *demoCluster) status() {
+	stopped := map[string]bool{}
+	for _, id := range c.stopped() {
+		stopped[id] = true
+	}
+
+	pods, err := c.client.CoreV1().Pods(demoNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		c.printf("failed to list pods: %v", err)
+		return
+	}
+	labels := map[string]string{}
+	for _, pod := range pods.Items {
+		labels[pod.Name] = pod.Labels[pkg.PodLabelKey]
+	}
+
+	lines := make([]string, 0, len(c.ids))
+	for _, id := range c.ids {
+		state := "running"
+		if stopped[id] {
+			state = "killed"
+		}
+		label := labels[id]
+		if label == "" {
+			label = pkg.StatusUnknown
+		}
+		lines = append(lines, fmt.Sprintf("  %s  %-7s  %s=%s", id, state, pkg.PodLabelKey, label))
+	}
+	sort.Strings(lines)
+	c.printf("status:\n%s", strings.Join(lines, "\n"))
+}
+
+// watch prints a line each time the election leader changes, until the context
+// is cancelled.
+func (c *demoCluster) watch(ctx context.Context) {
+	interval := c.ttl / 10
+	if interval < 50*time.Millisecond {
+		interval = 50 * time.Millisecond
+	}
+	ticker := c.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	var current string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		if leader := c.leader(); leader != current {
+			current = leader
+			if leader == "" {
+				c.printf("leader: (none)")
+			} else {
+				c.printf("leader: %s", leader)
+			}
+		}
+	}
+}
+
+// shutdown stops all running nodes and waits for them to terminate.
+func (c *demoCluster) shutdown() {
+	c.mu.Lock()
+	for id, node := range c.nodes {
+		node.Stop()
+		delete(c.nodes, id)
+	}
+	c.mu.Unlock()
+	c.wg.Wait()
+}
+
+// readCommands reads demo commands, one per line, and sends them on the
+// returned channel. The channel is closed once the input is exhausted.
+func readCommands(in io.Reader) <-chan string {
+	commands := make(chan string)
+	go func() {
+		defer close(commands)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			commands <- strings.TrimSpace(scanner.Text())
+		}
+	}()
+	return commands
+}
+
+// runDemo runs the "demo" subcommand.
+//
+// The demo runs a number of elector nodes in-process against a fake cluster
+// and prints the leadership transitions as they happen. Interactively, the
+// leader can be killed and restored with commands read from the input. In
+// headless mode, the leader is killed and restored on an interval instead.
+//
+// The demo runs on a simulated clock shared by the nodes, which runs at the
+// given speed, so a long headless demo can be run in little real time.
+func runDemo(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("demo", flag.ContinueOnError)
+	fs.SetOutput(out)
+	count := fs.Int("nodes", 3, "The number of elector nodes to run.")
+	ttl := fs.Duration("ttl", 2*time.Second, "The TTL for the election.")
+	duration := fs.Duration("duration", 0, "How long to run the demo for. If not set, the demo runs until it is quit.")
+	headless := fs.Bool("headless", false, "Kill and restore the leader on an interval instead of reading commands from stdin.")
+	interval := fs.Duration("kill-interval", 10*time.Second, "The interval to kill and restore the leader on, in headless mode.")
+	verbose := fs.Bool("verbose", false, "Write the elector node logs in addition to the demo output.")
+	speed := fs.Float64("speed", 1, "How many times faster than real time the demo clock runs. Failovers take no simulated time.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *count < 1 {
+		return errors.New("the demo requires at least one node")
+	}
+	if *ttl <= 0 || (*headless && *interval <= 0) {
+		return errors.New("the ttl and kill interval must be positive")
+	}
+	if *speed <= 0 {
+		return errors.New("the speed must be positive")
+	}
+
+	cluster, err := newDemoCluster(*count, *ttl, out)
+	if err != nil {
+		return err
+	}
+	cluster.verbose = *verbose
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The clock is stepped until the nodes have shut down, since they may
+	// wait on it while stopping.
+	clockCtx, stopClock := context.WithCancel(context.Background())
+	defer stopClock()
+	advancing := make(chan struct{})
+	go func() {
+		defer close(advancing)
+		cluster.advance(clockCtx, *speed)
+	}()
+
+	var deadline <-chan time.Time
+	if *duration > 0 {
+		deadline = cluster.clock.After(*duration)
+	}
+
+	// The elector nodes listen for termination signals themselves, so the
+	// demo needs to as well in order to quit on Ctrl-C.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	for _, id := range cluster.ids {
+		cluster.startNode(id)
+	}
+	watching := make(chan struct{})
+	go func() {
+		defer close(watching)
+		cluster.watch(ctx)
+	}()
+
+	var commands <-chan string
+	var ticker <-chan time.Time
+	if *headless {
+		t := cluster.clock.NewTicker(*interval)
+		defer t.Stop()
+		ticker = t.C()
+	} else {
+		commands = readCommands(in)
+		cluster.printf("commands: k (kill the leader), r (restore killed nodes), s (status), q (quit)")
+	}
+
+loop:
+	for {
+		select {
+		case <-deadline:
+			break loop
+		case <-sigs:
+			break loop
+		case <-ticker:
+			if len(cluster.stopped()) > 0 {
+				cluster.restore()
+			} else {
+				cluster.killLeader()
+			}
+		case cmd, ok := <-commands:
+			if !ok {
+				break loop
+			}
+			switch cmd {
+			case "k":
+				cluster.killLeader()
+			case "r":
+				cluster.restore()
+			case "s":
+				cluster.status()
+			case "q":
+				break loop
+			case "":
+			default:
+				cluster.printf("unknown command: %q", cmd)
+			}
+		}
+	}
+
+	cluster.printf("shutting down")
+	cancel()
+	<-watching
+	cluster.shutdown()
+	stopClock()
+	<-advancing
+	return nil
+}
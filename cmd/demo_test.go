@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunDemo_headless(t *testing.T) {
+	// Five minutes of simulated time run in a few seconds.
+	out := &bytes.Buffer{}
+	err := runDemo([]string{
+		"-nodes", "3",
+		"-ttl", "1s",
+		"-headless",
+		"-kill-interval", "30s",
+		"-duration", "5m",
+		"-speed", "200",
+	}, strings.NewReader(""), out)
+	assert.NoError(t, err)
+
+	output := out.String()
+	assert.Contains(t, output, "node-1: started")
+	assert.Contains(t, output, "shutting down")
+	assert.Regexp(t, `\[ *30\d\.\ds\] shutting down`, output)
+
+	// The leader is killed and restored every kill interval.
+	assert.True(t, strings.Count(output, "killed") >= 4, "expected the leader to be killed repeatedly:\n%s", output)
+
+	// The killed leader is replaced by another node.
+	leaders := map[string]bool{}
+	for _, match := range regexp.MustCompile(`leader: (node-\d+)`).FindAllStringSubmatch(output, -1) {
+		leaders[match[1]] = true
+	}
+	assert.True(t, len(leaders) >= 2, "expected leadership to fail over:\n%s", output)
+}
+
+func TestRunDemo_commands(t *testing.T) {
+	out := &bytes.Buffer{}
+	err := runDemo([]string{
+		"-nodes", "2",
+		"-ttl", "1s",
+		"-duration", "5s",
+	}, strings.NewReader("s\nx\nq\n"), out)
+	assert.NoError(t, err)
+
+	output := out.String()
+	assert.Contains(t, output, "status:")
+	assert.Contains(t, output, `unknown command: "x"`)
+	assert.Contains(t, output, "shutting down")
+}
+
+func TestRunDemo_error(t *testing.T) {
+	cases := []struct {
+		description string
+		args        []string
+	}{
+		{
+			description: "no nodes",
+			args:        []string{"-nodes", "0"},
+		},
+		{
+			description: "non-positive ttl",
+			args:        []string{"-ttl", "0s"},
+		},
+		{
+			description: "non-positive kill interval",
+			args:        []string{"-headless", "-kill-interval", "0s"},
+		},
+		{
+			description: "non-positive speed",
+			args:        []string{"-speed", "0"},
+		},
+		{
+			description: "unknown flag",
+			args:        []string{"-unknown"},
+		},
+	}
+
+	for _, c := range cases {
+		err := runDemo(c.args, strings.NewReader(""), &bytes.Buffer{})
+		assert.Error(t, err, c.description)
+	}
+}
@@ -17,12 +17,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/vapor-ware/k8s-elector/pkg"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/klog"
 )
 
@@ -41,61 +46,304 @@ var (
 // Command line configuration flag values. The command line values are
 // bound on elector start.
 var (
-	address    string
-	id         string
-	kubeconfig string
-	lockType   string
-	name       string
-	namespace  string
-	ttl        time.Duration
+	address                    string
+	canaryElection             string
+	authToken                  string
+	accessLog                  bool
+	invertLeaderStatus         bool
+	chaos                      bool
+	eventIDHeader              string
+	maxWatchers                int
+	watchWriteTimeout          time.Duration
+	id                         string
+	kubeconfig                 string
+	external                   bool
+	clientMaxIdleConns         int
+	clientMaxIdleConnsPerHost  int
+	lockType                   string
+	lockNamespace              string
+	verifyNamespace            bool
+	podCache                   bool
+	respectForeignLabels       bool
+	lockResource               string
+	lockTypeMigrateFrom        string
+	metricsNamespace           string
+	metricsIdentityLabel       string
+	logPrefix                  string
+	logRolePrefix              bool
+	quiet                      bool
+	logRepeatEvery             int
+	logRepeatInterval          time.Duration
+	logFormat                  string
+	name                       string
+	electionNameTemplate       string
+	roleName                   string
+	namespace                  string
+	ttl                        time.Duration
+	postDemotionCooldown       time.Duration
+	publishDebounce            time.Duration
+	publishers                 string
+	statusObject               string
+	statusObjectNamespace      string
+	externalDNSService         string
+	externalDNSHostname        string
+	disabledPublishers         string
+	adoptExistingLeaseDuration bool
+	releaseOnShutdown          bool
+	repairCorruptLock          bool
+	singleNode                 bool
+	pureElection               bool
+	resolveIDCollisions        bool
+	structuredID               bool
+	startupFailureGracePeriod  time.Duration
+	staleThreshold             time.Duration
+	staleExpiry                time.Duration
+	maxClockSkew               time.Duration
+	onElected                  string
+	recordOutages              bool
+	recordHistory              bool
+	terminationMessagePath     string
+	reconcileInterval          time.Duration
+	slowRenewalFraction        float64
+	outageThreshold            time.Duration
+	onDemoted                  string
+	onDemotedTimeout           time.Duration
+	candidacyCheckCmd          string
+	stepDownOnCandidacyLoss    bool
+	commandEnv                 = envFlag{}
 )
 
+// envFlag is a flag.Value which collects KEY=VALUE pairs. The flag may be
+// specified multiple times.
+type envFlag map[string]string
+
+func (f envFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for k, v := range f {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, v))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f envFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("invalid environment variable %q: must be in KEY=VALUE format", value)
+	}
+	f[parts[0]] = parts[1]
+	return nil
+}
+
 func init() {
 	// Set logging output to stdout to prevent the logger from crashing when
 	// attempting to create log files within the container.
 	klog.SetOutput(os.Stdout)
 }
 
-// logVersion is a helper function to log the build-time version information
-// for the elector.
-func logVersion() {
-	klog.Info("k8s-elector")
-	klog.Infof("  version    : %s", Version)
-	klog.Infof("  commit     : %s", Commit)
-	klog.Infof("  tag        : %s", Tag)
-	klog.Infof("  go version : %s", GoVersion)
-	klog.Infof("  build date : %s", BuildDate)
-	klog.Infof("  os         : %s", Arch)
-	klog.Infof("  arch       : %s", OS)
+// buildInfo gets the build-time version information for the elector. If the
+// Go version was not set at build time, the version of the running binary is
+// used.
+func buildInfo() pkg.BuildInfo {
+	goVersion := GoVersion
+	if goVersion == "" {
+		goVersion = runtime.Version()
+	}
+	return pkg.BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		Tag:       Tag,
+		GoVersion: goVersion,
+		OS:        OS,
+		Arch:      Arch,
+		BuildDate: BuildDate,
+	}
+}
+
+// logVersion logs the build-time version information for the elector as a
+// single line, unless the -quiet flag is set. It is logged once the flags
+// are parsed, so the klog flags apply to it.
+func logVersion(build pkg.BuildInfo) {
+	if quiet {
+		return
+	}
+	klog.V(0).Infof("k8s-elector %s", build)
 }
 
 func main() {
-	klog.InitFlags(nil)
-
-	// Bind the flags to variables.
-	flag.StringVar(&address, "http", "", "The HTTP address (host:port) which leader state will be reported on.")
-	flag.StringVar(&id, "id", "", "The ID of the election participant. If not set, the hostname, as reported by the kernel, is used.")
-	flag.StringVar(&kubeconfig, "kubeconfig", "", "The kubeconfig file to use. If not set, in-cluster config will be used.")
-	flag.StringVar(&lockType, "lock-type", "leases", "The type of Kubernetes object to use for the lock (leases, endpoints, configmaps)")
-	flag.StringVar(&name, "election", "", "The name of the election. This is required.")
-	flag.StringVar(&namespace, "namespace", "default", "The Kubernetes namespace to run the election in. If not set, elections will run in the default namespace.")
-	flag.DurationVar(&ttl, "ttl", 10*time.Second, "The TTL for the election.")
-	flag.Parse()
+	// Subcommands have their own flags, so they are dispatched before the
+	// elector flags are parsed.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "demo":
+			// The demo runs a self-contained demonstration of the elector
+			// against a fake cluster.
+			if err := runDemo(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+				if err == flag.ErrHelp {
+					return
+				}
+				klog.Fatalf("error running demo: %v", err)
+			}
+			return
+		case "is-leader":
+			os.Exit(runIsLeader(os.Args[2:], nil, os.Stdout, os.Stderr))
+		case "healthcheck":
+			os.Exit(runHealthcheck(os.Args[2:], os.Getenv, os.Stdout, os.Stderr))
+		}
+	}
+
+	// Bind the flags to variables, and parse them along with the environment.
+	fs, defs := newFlagSet(os.Args[0], flag.ExitOnError)
+	if err := parseFlags(fs, defs, os.Args[1:]); err != nil {
+		klog.Fatalf("%v", err)
+	}
 
 	// Log elector version info before doing anything else.
-	logVersion()
+	build := buildInfo()
+	logVersion(build)
+
+	var lockGVR schema.GroupVersionResource
+	if lockResource != "" {
+		gvr, err := pkg.ParseGroupVersionResource(lockResource)
+		if err != nil {
+			klog.Fatalf("error parsing -lock-resource: %v", err)
+		}
+		lockGVR = gvr
+	}
+
+	var statusResource schema.GroupVersionResource
+	var statusName string
+	if statusObject != "" {
+		gvr, objName, err := pkg.ParseStatusObject(statusObject)
+		if err != nil {
+			klog.Fatalf("error parsing -status-object: %v", err)
+		}
+		statusResource, statusName = gvr, objName
+	}
+
+	enabled, err := pkg.ParsePublishers(publishers)
+	if err != nil {
+		klog.Fatalf("error parsing -publishers: %v", err)
+	}
+	disabled, err := pkg.ParsePublishers(disabledPublishers)
+	if err != nil {
+		klog.Fatalf("error parsing -disable-publishers: %v", err)
+	}
+
+	var identityLabel bool
+	switch metricsIdentityLabel {
+	case "on":
+		identityLabel = true
+	case "off":
+		identityLabel = false
+	default:
+		klog.Fatalf("invalid -metrics-identity-label %q: must be on or off", metricsIdentityLabel)
+	}
+
+	var logger pkg.Logger
+	switch logFormat {
+	case "text":
+		logger = pkg.KlogLogger{}
+	case "cloud":
+		logger = pkg.NewCloudLogger(os.Stderr)
+	default:
+		klog.Fatalf("invalid -log-format %q: must be text or cloud", logFormat)
+	}
+
+	var candidacyCheck pkg.CandidacyCheck
+	if candidacyCheckCmd != "" {
+		candidacyCheck = pkg.CommandCandidacyCheck(candidacyCheckCmd)
+	}
 
 	elector := pkg.NewElectorNode(&pkg.ElectorConfig{
-		Address:    address,
-		ID:         id,
-		KubeConfig: kubeconfig,
-		LockType:   lockType,
-		Namespace:  namespace,
-		Name:       name,
-		TTL:        ttl,
+		Address:                    address,
+		AuthToken:                  authToken,
+		AccessLog:                  accessLog,
+		InvertLeaderStatus:         invertLeaderStatus,
+		EventIDHeader:              eventIDHeader,
+		MaxWatchers:                maxWatchers,
+		WatchWriteTimeout:          watchWriteTimeout,
+		CanaryElection:             canaryElection,
+		ID:                         id,
+		ResolveIDCollisions:        resolveIDCollisions,
+		StructuredID:               structuredID,
+		KubeConfig:                 kubeconfig,
+		External:                   external,
+		ClientMaxIdleConns:         clientMaxIdleConns,
+		ClientMaxIdleConnsPerHost:  clientMaxIdleConnsPerHost,
+		LockType:                   lockType,
+		LockNamespace:              lockNamespace,
+		VerifyNamespace:            verifyNamespace,
+		PodCache:                   podCache,
+		RespectForeignLabels:       respectForeignLabels,
+		LockResource:               lockGVR,
+		LockTypeMigrateFrom:        lockTypeMigrateFrom,
+		LogPrefix:                  logPrefix,
+		LogRolePrefix:              logRolePrefix,
+		LogRepeatEvery:             logRepeatEvery,
+		LogRepeatInterval:          logRepeatInterval,
+		Logger:                     logger,
+		Build:                      build,
+		Namespace:                  namespace,
+		Name:                       name,
+		ElectionNameTemplate:       electionNameTemplate,
+		RoleName:                   roleName,
+		TTL:                        ttl,
+		OnElected:                  onElected,
+		OnDemoted:                  onDemoted,
+		OnDemotedTimeout:           onDemotedTimeout,
+		CommandEnv:                 commandEnv,
+		AdoptExistingLeaseDuration: adoptExistingLeaseDuration,
+		KeepLeaseOnShutdown:        !releaseOnShutdown,
+		RepairCorruptLock:          repairCorruptLock,
+		SingleNode:                 singleNode,
+		Chaos:                      chaos,
+		PureElection:               pureElection,
+		StartupFailureGracePeriod:  startupFailureGracePeriod,
+		StaleThreshold:             staleThreshold,
+		StaleExpiry:                staleExpiry,
+		MetricsNamespace:           metricsNamespace,
+		MetricsIdentityLabel:       identityLabel,
+		MaxClockSkew:               maxClockSkew,
+		PostDemotionCooldown:       postDemotionCooldown,
+		PublishDebounce:            publishDebounce,
+		StatusObjectResource:       statusResource,
+		StatusObjectName:           statusName,
+		StatusObjectNamespace:      statusObjectNamespace,
+		ExternalDNSService:         externalDNSService,
+		ExternalDNSHostname:        externalDNSHostname,
+		Publishers:                 enabled,
+		DisabledPublishers:         disabled,
+		ReconcileInterval:          reconcileInterval,
+		SlowRenewalFraction:        slowRenewalFraction,
+		RecordOutages:              recordOutages,
+		OutageThreshold:            outageThreshold,
+		RecordHistory:              recordHistory,
+		TerminationMessagePath:     terminationMessagePath,
+		CandidacyCheck:             candidacyCheck,
+		StepDownOnCandidacyLoss:    stepDownOnCandidacyLoss,
 	})
 
-	if err := elector.Run(); err != nil {
+	if err := runElector(elector); err != nil {
 		klog.Fatalf("error running elector: %v", err)
 	}
 }
+
+// runElector runs the elector node until it stops, and gets the error it
+// stopped with, if any.
+//
+// A graceful shutdown (e.g. on SIGTERM, or a request to /shutdown) cancels
+// the node's context, so the node stops with context.Canceled. That is not a
+// failure, so it is only logged, and the elector exits with a zero exit code.
+// The same goes for a shutdown before the election started.
+func runElector(elector interface{ Run() error }) error {
+	err := elector.Run()
+	if errors.Is(err, pkg.ErrTerminatedDuringStartup) {
+		klog.Info("elector terminated during startup")
+		return nil
+	}
+	if errors.Is(err, context.Canceled) {
+		klog.Info("elector shut down gracefully")
+		return nil
+	}
+	return err
+}
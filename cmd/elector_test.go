@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vapor-ware/k8s-elector/pkg"
+)
+
+// testElector is an elector which stops with the given error.
+type testElector struct {
+	err error
+}
+
+func (e testElector) Run() error {
+	return e.err
+}
+
+func TestRunElector(t *testing.T) {
+	cases := []struct {
+		description string
+		err         error
+		expected    error
+	}{
+		{
+			description: "stopped",
+			err:         nil,
+			expected:    nil,
+		},
+		{
+			description: "shut down gracefully",
+			err:         context.Canceled,
+			expected:    nil,
+		},
+		{
+			description: "shut down gracefully, wrapped",
+			err:         fmt.Errorf("stopping: %w", context.Canceled),
+			expected:    nil,
+		},
+		{
+			description: "terminated during startup",
+			err:         pkg.ErrTerminatedDuringStartup,
+			expected:    nil,
+		},
+		{
+			description: "error",
+			err:         errors.New("test error"),
+			expected:    errors.New("test error"),
+		},
+		{
+			description: "deadline exceeded",
+			err:         context.DeadlineExceeded,
+			expected:    context.DeadlineExceeded,
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, runElector(testElector{err: c.err}), c.description)
+	}
+}
@@ -0,0 +1,68 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envPrefix is the prefix of the environment variables which configure the
+// elector.
+const envPrefix = "ELECTOR_"
+
+// envName gets the name of the environment variable for a flag. The flag name
+// is upper-cased, has dashes replaced by underscores, and is prefixed with
+// ELECTOR_, e.g. "lock-type" is configured by ELECTOR_LOCK_TYPE.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// bindEnv sets the defined flags in the flag set from their environment
+// variables. Values are parsed the same way as on the command line, so
+// durations such as ELECTOR_TTL=15s are accepted.
+//
+// This must be called before the flags are parsed, so that values given on
+// the command line override those from the environment. Flags which may be
+// specified multiple times, such as -command-env, take a comma-separated list.
+func bindEnv(fs *flag.FlagSet, defs []flagDef) error {
+	for _, d := range defs {
+		f := fs.Lookup(d.name)
+		if f == nil {
+			continue
+		}
+
+		name := d.env()
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		values := []string{value}
+		if _, repeatable := f.Value.(envFlag); repeatable {
+			values = strings.Split(value, ",")
+		}
+		for _, v := range values {
+			if err := fs.Set(f.Name, v); err != nil {
+				return fmt.Errorf("invalid value %q for %s: %v", value, name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvName(t *testing.T) {
+	cases := []struct {
+		flag     string
+		expected string
+	}{
+		{flag: "ttl", expected: "ELECTOR_TTL"},
+		{flag: "election", expected: "ELECTOR_ELECTION"},
+		{flag: "lock-type", expected: "ELECTOR_LOCK_TYPE"},
+		{flag: "http-auth-token", expected: "ELECTOR_HTTP_AUTH_TOKEN"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, envName(c.flag), c.flag)
+	}
+}
+
+func TestBindEnv(t *testing.T) {
+	defer os.Unsetenv("ELECTOR_TTL")
+	defer os.Unsetenv("ELECTOR_ELECTION")
+	defer os.Unsetenv("ELECTOR_RECORD_OUTAGES")
+	defer os.Unsetenv("ELECTOR_COMMAND_ENV")
+	os.Setenv("ELECTOR_TTL", "2m")
+	os.Setenv("ELECTOR_ELECTION", "from-env")
+	os.Setenv("ELECTOR_RECORD_OUTAGES", "true")
+	os.Setenv("ELECTOR_COMMAND_ENV", "A=1,B=2")
+
+	var (
+		ttl       time.Duration
+		election  string
+		outages   bool
+		namespace string
+		env       = envFlag{}
+	)
+	defs := []flagDef{
+		durationFlag(&ttl, "ttl", 10*time.Second, groupElection, ""),
+		stringFlag(&election, "election", "", groupElection, ""),
+		boolFlag(&outages, "record-outages", false, groupPublication, ""),
+		stringFlag(&namespace, "namespace", "default", groupKubernetes, ""),
+		varFlag(env, "command-env", groupPublication, ""),
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	defineFlags(fs, defs)
+
+	assert.NoError(t, bindEnv(fs, defs))
+	assert.NoError(t, fs.Parse([]string{"-election", "from-flag"}))
+
+	assert.Equal(t, 2*time.Minute, ttl)
+	assert.Equal(t, "from-flag", election)
+	assert.True(t, outages)
+	assert.Equal(t, "default", namespace)
+	assert.Equal(t, envFlag{"A": "1", "B": "2"}, env)
+}
+
+func TestBindEnv_invalid(t *testing.T) {
+	defer os.Unsetenv("ELECTOR_TTL")
+	os.Setenv("ELECTOR_TTL", "fifteen")
+
+	var ttl time.Duration
+	defs := []flagDef{
+		durationFlag(&ttl, "ttl", 10*time.Second, groupElection, ""),
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	defineFlags(fs, defs)
+
+	err := bindEnv(fs, defs)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "ELECTOR_TTL")
+}
+
+func TestBindEnv_onlyDefinedFlags(t *testing.T) {
+	defer os.Unsetenv("ELECTOR_V")
+	os.Setenv("ELECTOR_V", "5")
+
+	// Flags in the flag set which are not defined by the elector, such as
+	// those for klog, are not set from the environment.
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	defineFlags(fs, nil)
+	v := fs.Int("v", 0, "")
+
+	assert.NoError(t, bindEnv(fs, nil))
+	assert.Equal(t, 0, *v)
+}
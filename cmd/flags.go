@@ -0,0 +1,297 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vapor-ware/k8s-elector/pkg"
+	"k8s.io/klog"
+)
+
+// The groups which the elector flags are listed under in the usage output,
+// in the order they are listed.
+const (
+	groupElection      = "Election"
+	groupKubernetes    = "Kubernetes"
+	groupHTTP          = "HTTP"
+	groupPublication   = "Status publication"
+	groupObservability = "Observability"
+)
+
+var flagGroups = []string{
+	groupElection,
+	groupKubernetes,
+	groupHTTP,
+	groupPublication,
+	groupObservability,
+}
+
+// flagDef defines an elector flag. The flag definitions are the single source
+// of the elector's flags, their environment variables, and the usage output.
+type flagDef struct {
+	name  string
+	group string
+	usage string
+
+	// define defines the flag, with its default value, in a flag set.
+	define func(fs *flag.FlagSet)
+}
+
+// env gets the name of the environment variable which sets the flag.
+func (d flagDef) env() string {
+	return envName(d.name)
+}
+
+func stringFlag(p *string, name, value, group, usage string) flagDef {
+	return flagDef{name: name, group: group, usage: usage, define: func(fs *flag.FlagSet) {
+		fs.StringVar(p, name, value, usage)
+	}}
+}
+
+func boolFlag(p *bool, name string, value bool, group, usage string) flagDef {
+	return flagDef{name: name, group: group, usage: usage, define: func(fs *flag.FlagSet) {
+		fs.BoolVar(p, name, value, usage)
+	}}
+}
+
+func durationFlag(p *time.Duration, name string, value time.Duration, group, usage string) flagDef {
+	return flagDef{name: name, group: group, usage: usage, define: func(fs *flag.FlagSet) {
+		fs.DurationVar(p, name, value, usage)
+	}}
+}
+
+func intFlag(p *int, name string, value int, group, usage string) flagDef {
+	return flagDef{name: name, group: group, usage: usage, define: func(fs *flag.FlagSet) {
+		fs.IntVar(p, name, value, usage)
+	}}
+}
+
+func float64Flag(p *float64, name string, value float64, group, usage string) flagDef {
+	return flagDef{name: name, group: group, usage: usage, define: func(fs *flag.FlagSet) {
+		fs.Float64Var(p, name, value, usage)
+	}}
+}
+
+func varFlag(v flag.Value, name, group, usage string) flagDef {
+	return flagDef{name: name, group: group, usage: usage, define: func(fs *flag.FlagSet) {
+		fs.Var(v, name, usage)
+	}}
+}
+
+// flagDefs gets the definitions of the elector flags, bound to the command
+// line configuration variables.
+func flagDefs() []flagDef {
+	return []flagDef{
+		// Election
+		stringFlag(&name, "election", "", groupElection, "The name of the election. This is required."),
+		stringFlag(&electionNameTemplate, "election-name-template", pkg.DefaultElectionNameTemplate, groupElection, "A template for the name of the election loop, used in the leader election client's logs. It may use the {{.Namespace}}, {{.Election}}, and {{.ID}} variables."),
+		stringFlag(&roleName, "role-name", "", groupElection, "A human-readable name for the role of the elector's application, used in logs, payloads, and metrics. It is never used for election decisions. If not set, the election name is used."),
+		stringFlag(&id, "id", "", groupElection, "The ID of the election participant. If not set, the hostname, as reported by the kernel, is used. It may be up to 253 characters, and may not contain control characters."),
+		boolFlag(&resolveIDCollisions, "resolve-id-collisions", false, groupElection, "If the ID is not set and the Pod name differs from the hostname, use <hostname>-<pod name> as the ID, so Pods sharing a hostname have unique IDs."),
+		boolFlag(&structuredID, "structured-id", false, groupElection, "If the ID is not set, use <hostname>/<pod IP>/<node name> as the ID, with the Pod IP from ELECTOR_POD_IP and the node name from ELECTOR_NODE_NAME. The leader's metadata is then reported at / as leader_meta."),
+		durationFlag(&ttl, "ttl", 10*time.Second, groupElection, "The TTL for the election."),
+		boolFlag(&adoptExistingLeaseDuration, "adopt-lease-duration", false, groupElection, "Use the lease duration of an existing election lock, if any, instead of the TTL."),
+		boolFlag(&releaseOnShutdown, "release-on-shutdown", true, groupElection, "Release the election lock when the leader shuts down. Disable to keep leadership through a quick restart."),
+		durationFlag(&postDemotionCooldown, "post-demotion-cooldown", 0, groupElection, "The duration to wait after being demoted before re-joining the election."),
+		durationFlag(&reconcileInterval, "reconcile-interval", 0, groupElection, "The interval on which the leader verifies it still holds the election lock, stepping down if it does not. If not set, leadership is not reconciled."),
+		stringFlag(&candidacyCheckCmd, "candidacy-check-cmd", "", groupElection, "A command which gates standing for election: the node only stands while the command exits with a zero exit code."),
+		boolFlag(&stepDownOnCandidacyLoss, "candidacy-step-down", false, groupElection, "Step down as leader when the candidacy check stops passing, rather than keep leading."),
+		stringFlag(&canaryElection, "canary-election", "", groupElection, "The name of a secondary canary election to participate in. Its state is reported at /canary."),
+		boolFlag(&singleNode, "single-node", false, groupElection, "Run without an election, as the leader, for deployments with a single replica."),
+		boolFlag(&pureElection, "pure-election", false, groupElection, "Run the election without side effects on the Pod or external systems: the Pod status label, subscriptions, the status object, the external-dns service, the on-elected and on-demoted commands, outage and history records, and the termination message are disabled."),
+		durationFlag(&startupFailureGracePeriod, "startup-failure-grace-period", 0, groupElection, "How long to stay up, reporting the error at /healthz, after the election fails to start before exiting. If not set, the elector exits immediately."),
+
+		// Kubernetes
+		boolFlag(&external, "external", pkg.DetectExternal(), groupKubernetes, "Run outside of Kubernetes against a remote cluster (e.g. with -kubeconfig), disabling the Pod status label, Pod name detection, and the termination message. The ID defaults to <user>@<hostname>. Enabled by default when neither KUBERNETES_SERVICE_HOST nor ELECTOR_POD_NAME is set."),
+		stringFlag(&kubeconfig, "kubeconfig", "", groupKubernetes, "The kubeconfig file to use. If not set, in-cluster config will be used."),
+		intFlag(&clientMaxIdleConns, "client-max-idle-conns", pkg.DefaultClientMaxIdleConns, groupKubernetes, "The maximum number of idle connections kept by the Kubernetes client, across all hosts."),
+		intFlag(&clientMaxIdleConnsPerHost, "client-max-idle-conns-per-host", 0, groupKubernetes, "The maximum number of idle connections kept by the Kubernetes client for each host. If not set, GOMAXPROCS is used, with a minimum of 2."),
+		stringFlag(&namespace, "namespace", "", groupKubernetes, "The Kubernetes namespace to run the election in. If not set, the namespace of the Pod's service account is used, falling back to the default namespace."),
+		stringFlag(&lockType, "lock-type", "leases", groupKubernetes, "The type of Kubernetes object to use for the lock (leases, endpoints, configmaps, dynamic)"),
+		stringFlag(&lockNamespace, "lock-namespace", "", groupKubernetes, "The Kubernetes namespace to create the election lock in. If not set, the -namespace value is used."),
+		boolFlag(&verifyNamespace, "verify-namespace", false, groupKubernetes, "Check that the namespace and lock namespace exist when starting, and exit with an error if either does not. Requires permission to get namespaces."),
+		stringFlag(&lockTypeMigrateFrom, "lock-type-migrate-from", "", groupKubernetes, "The lock type to migrate the election lock from (configmaps, endpoints). The lock record is kept in the objects of both lock types, so nodes using either agree on the leader while the migration rolls out. Only migrating to -lock-type=leases is supported."),
+		stringFlag(&lockResource, "lock-resource", "", groupKubernetes, "The resource of an existing object, named after the election, to use as the lock with -lock-type=dynamic, as group/version/resource (or version/resource for the core group)."),
+		boolFlag(&podCache, "pod-cache", false, groupKubernetes, "Read the elector's Pod from an informer which watches it, and restore the Pod status label if it is changed outside of the elector. Requires permission to list and watch Pods; if they can not be watched, the Pod is read directly."),
+		boolFlag(&respectForeignLabels, "respect-foreign-labels", false, groupKubernetes, "Do not overwrite a Pod status label which was set by something other than the elector, e.g. another controller using the same label key. Without -pod-cache, requires permission to get Pods."),
+		boolFlag(&repairCorruptLock, "repair-corrupt-lock", false, groupKubernetes, "Overwrite an election lock record which can not be parsed once it has gone unchanged for a lease duration."),
+
+		// HTTP
+		boolFlag(&chaos, "chaos", false, groupHTTP, "Enable the chaos hooks, which inject failures into lease renewals and Pod label patches on request at /chaos/fail-renewals and /chaos/fail-patches. For resilience testing in staging only: refused unless ELECTOR_CHAOS_ALLOWED=true is also set."),
+		stringFlag(&address, "http", "", groupHTTP, "The HTTP address (host:port) which leader state will be reported on."),
+		boolFlag(&accessLog, "http-access-log", false, groupHTTP, "Log each HTTP request as a JSON access log entry."),
+		boolFlag(&invertLeaderStatus, "http-invert-leader-status", false, groupHTTP, "Invert the status codes of /leader/status, so it returns 200 on standby nodes and 503 on the leader."),
+		intFlag(&maxWatchers, "http-max-watchers", pkg.DefaultMaxWatchers, groupHTTP, "The maximum number of /watch streams which may be open at once. Once reached, new streams are rejected with a 503."),
+		durationFlag(&staleExpiry, "http-stale-expiry", 0, groupHTTP, "How long the elector may go without reading the election lock before / stops reporting the stale leader. If not set, three times the stale threshold is used."),
+		durationFlag(&staleThreshold, "http-stale-threshold", 0, groupHTTP, "How long the elector may go without reading the election lock, e.g. during an API server outage, before / flags the leader as stale. If not set, the lease duration is used."),
+		durationFlag(&watchWriteTimeout, "http-watch-write-timeout", pkg.DefaultWatchWriteTimeout, groupHTTP, "How long a /watch stream may fall behind, with its buffer of leadership events full, before it is closed."),
+		stringFlag(&eventIDHeader, "http-event-id-header", pkg.DefaultEventIDHeader, groupHTTP, "The header which carries the ID of the last leadership event on HTTP responses and subscription deliveries."),
+		stringFlag(&authToken, "http-auth-token", "", groupHTTP, "The bearer token required by HTTP endpoints which change elector state (e.g. /shutdown). If not set, those endpoints are disabled."),
+
+		// Status publication
+		durationFlag(&publishDebounce, "publish-debounce", pkg.DefaultPublishDebounce, groupPublication, "The window in which bursts of leadership changes are collapsed before the Pod label is updated."),
+		stringFlag(&publishers, "publishers", "", groupPublication, "A comma-separated list of the built-in publishers to run ("+strings.Join(pkg.PublisherNames, ", ")+"). If not set, all of them run."),
+		stringFlag(&disabledPublishers, "disable-publishers", "", groupPublication, "A comma-separated list of the built-in publishers not to run, even if they are listed in -publishers."),
+		stringFlag(&statusObject, "status-object", "", groupPublication, "An existing object whose status the leader maintains with its leadership, as group/version/resource/name (or version/resource/name for the core group), e.g. apps.example.com/v1/elections/my-election. The object is never created."),
+		stringFlag(&statusObjectNamespace, "status-object-namespace", "", groupPublication, "The namespace of the -status-object. If not set, the -namespace value is used."),
+		stringFlag(&externalDNSService, "external-dns-service", "", groupPublication, "An existing Service in the election namespace whose external-dns annotations and selector the leader maintains, so a DNS name follows the leader. The Service is never created."),
+		stringFlag(&externalDNSHostname, "external-dns-hostname", "", groupPublication, "The DNS name the leader annotates the -external-dns-service with, for external-dns to publish. If not set, the Service's own hostname annotation is used."),
+		stringFlag(&onElected, "on-elected", "", groupPublication, "A command to run when the node becomes the leader."),
+		stringFlag(&onDemoted, "on-demoted", "", groupPublication, "A command to run when the node stops being the leader."),
+		durationFlag(&onDemotedTimeout, "on-demoted-timeout", 0, groupPublication, "How long the on-demoted command may run before it, and any processes it started, are killed. If not set, the command may run indefinitely."),
+		varFlag(commandEnv, "command-env", groupPublication, "An environment variable (KEY=VALUE) to pass to the on-elected/on-demoted commands. May be specified multiple times."),
+		boolFlag(&recordOutages, "record-outages", false, groupPublication, "Record windows of time without a leader to the <election>-outages ConfigMap."),
+		durationFlag(&outageThreshold, "outage-threshold", 0, groupPublication, "The minimum duration of a window without a leader for it to be recorded as an outage."),
+		boolFlag(&recordHistory, "record-history", false, groupPublication, "Record a history of leader transitions to the k8s-elector/history annotation of the election lock."),
+		stringFlag(&terminationMessagePath, "termination-message-path", "", groupPublication, "The file to write the leadership state to when the elector stops, e.g. "+pkg.DefaultTerminationMessagePath+". If not set, no termination message is written."),
+
+		// Observability
+		stringFlag(&logFormat, "log-format", "text", groupObservability, "The format of the elector's log messages (text, cloud). The cloud format writes JSON with the severity, message, and labels keys parsed by managed cloud logging."),
+		intFlag(&logRepeatEvery, "log-repeat-every", pkg.DefaultLogRepeatEvery, groupObservability, "Log an error message which keeps repeating only every this many occurrences, e.g. a pod label update which keeps failing. Set both this and -log-repeat-interval to 0 to log every occurrence."),
+		durationFlag(&logRepeatInterval, "log-repeat-interval", pkg.DefaultLogRepeatInterval, groupObservability, "Log an error message which keeps repeating at most once per this interval, unless -log-repeat-every occurrences come first."),
+		stringFlag(&logPrefix, "log-prefix", "", groupObservability, "A prefix to add to all elector log messages, e.g. the election name."),
+		boolFlag(&logRolePrefix, "log-role-prefix", false, groupObservability, "Prefix all elector log messages with the node's current leadership role ([leader], [standby], or [lame-duck] while stepping down)."),
+		boolFlag(&quiet, "quiet", false, groupObservability, "Do not log the startup banner with the elector's version. The version is still reported at /version and by the elector_build_info metric."),
+		stringFlag(&metricsNamespace, "metrics-namespace", "", groupObservability, "A prefix for the names of the elector's metrics, e.g. myapp for myapp_elector_is_leader."),
+		stringFlag(&metricsIdentityLabel, "metrics-identity-label", "off", groupObservability, "Whether the node identity is added as a label to the elector's metrics (on, off). The identity is always reported by the elector_info metric."),
+		float64Flag(&slowRenewalFraction, "slow-renewal-fraction", pkg.DefaultSlowRenewalFraction, groupObservability, "Warn when a renewal of the leader's lease takes longer than this fraction of the renew deadline."),
+		durationFlag(&maxClockSkew, "max-clock-skew", 0, groupObservability, "Warn on start if the clock skew with the API server exceeds this. If not set, clock skew is not checked."),
+	}
+}
+
+// newFlagSet creates the flag set of the elector command, with the elector
+// flags bound to the command line configuration variables and klog's flags
+// registered alongside them. Each call creates a new flag set, rather than
+// registering on the global one, so it can be built more than once, e.g. in
+// tests, without a duplicate registration.
+func newFlagSet(name string, errorHandling flag.ErrorHandling) (*flag.FlagSet, []flagDef) {
+	fs := flag.NewFlagSet(name, errorHandling)
+	defs := flagDefs()
+	defineFlags(fs, defs)
+	klog.InitFlags(fs)
+	return fs, defs
+}
+
+// parseFlags parses the command line arguments with the flag set. Flags may
+// also be set via environment variables. Only the elector's own flags are
+// bound, not those for klog. This is done before parsing, so values given on
+// the command line take precedence.
+func parseFlags(fs *flag.FlagSet, defs []flagDef, args []string) error {
+	if err := bindEnv(fs, defs); err != nil {
+		return fmt.Errorf("error reading configuration from environment: %w", err)
+	}
+	return fs.Parse(args)
+}
+
+// defineFlags defines the flags in the flag set, and sets the flag set to
+// print the grouped usage output.
+func defineFlags(fs *flag.FlagSet, defs []flagDef) {
+	for _, d := range defs {
+		d.define(fs)
+	}
+	fs.Usage = func() {
+		writeUsage(fs.Output(), fs, defs)
+	}
+}
+
+// writeUsage writes the usage output for the flag set. The elector flags are
+// listed under their groups, with the environment variable which sets each
+// flag. Any other flags in the flag set (e.g. those for klog) are listed
+// last.
+func writeUsage(w io.Writer, fs *flag.FlagSet, defs []flagDef) {
+	fmt.Fprintf(w, "Usage of %s:\n", fs.Name())
+
+	defined := map[string]bool{}
+	for _, group := range flagGroups {
+		var grouped []flagDef
+		for _, d := range defs {
+			if d.group == group {
+				grouped = append(grouped, d)
+			}
+		}
+		if len(grouped) == 0 {
+			continue
+		}
+		sort.Slice(grouped, func(i, j int) bool {
+			return grouped[i].name < grouped[j].name
+		})
+
+		fmt.Fprintf(w, "\n%s:\n", group)
+		for _, d := range grouped {
+			defined[d.name] = true
+			if f := fs.Lookup(d.name); f != nil {
+				writeFlagUsage(w, f, d.env())
+			}
+		}
+	}
+
+	var other []*flag.Flag
+	fs.VisitAll(func(f *flag.Flag) {
+		if !defined[f.Name] {
+			other = append(other, f)
+		}
+	})
+	if len(other) > 0 {
+		fmt.Fprint(w, "\nLogging:\n")
+		for _, f := range other {
+			writeFlagUsage(w, f, "")
+		}
+	}
+}
+
+// writeFlagUsage writes the usage of a flag in the same format as the flag
+// package, followed by the environment variable which sets it, if any.
+func writeFlagUsage(w io.Writer, f *flag.Flag, env string) {
+	s := "  -" + f.Name
+	typ, usage := flag.UnquoteUsage(f)
+	if typ != "" {
+		s += " " + typ
+	}
+	s += "\n    \t" + strings.Replace(usage, "\n", "\n    \t", -1)
+
+	switch f.DefValue {
+	case "", "false", "0", "0s":
+	default:
+		if isStringFlag(f) {
+			s += fmt.Sprintf(" (default %q)", f.DefValue)
+		} else {
+			s += fmt.Sprintf(" (default %v)", f.DefValue)
+		}
+	}
+	if env != "" {
+		s += fmt.Sprintf(" [$%s]", env)
+	}
+	fmt.Fprintln(w, s)
+}
+
+// isStringFlag checks whether the flag holds a string, whose default is quoted
+// in the usage. The type name in the usage can not be used, since it may be
+// replaced with a name from the usage text.
+func isStringFlag(f *flag.Flag) bool {
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return false
+	}
+	_, ok = getter.Get().(string)
+	return ok
+}
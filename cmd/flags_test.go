@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/klog"
+)
+
+// renderUsage renders the usage output for the elector flags, along with a
+// flag which is not defined by the elector, as klog's are.
+func renderUsage(defs []flagDef) string {
+	fs := flag.NewFlagSet("elector", flag.ContinueOnError)
+	defineFlags(fs, defs)
+	fs.Int("v", 0, "number for the log level verbosity")
+
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	fs.Usage()
+	return buf.String()
+}
+
+func TestFlagDefs(t *testing.T) {
+	defs := flagDefs()
+	usage := renderUsage(defs)
+
+	groups := map[string]bool{}
+	for _, group := range flagGroups {
+		groups[group] = true
+	}
+	names := map[string]bool{}
+	envs := map[string]bool{}
+
+	for _, d := range defs {
+		assert.False(t, names[d.name], "flag defined more than once: %s", d.name)
+		names[d.name] = true
+
+		assert.True(t, groups[d.group], "flag has no known group: %s", d.name)
+		assert.NotEmpty(t, d.usage, "flag has no description: %s", d.name)
+
+		env := d.env()
+		assert.True(t, strings.HasPrefix(env, envPrefix), "flag has no environment variable: %s", d.name)
+		assert.False(t, envs[env], "environment variable used by more than one flag: %s", env)
+		envs[env] = true
+
+		// Each flag is listed exactly once, along with its environment
+		// variable.
+		line := "\n  -" + d.name
+		count := strings.Count(usage, line+" ") + strings.Count(usage, line+"\n")
+		assert.Equal(t, 1, count, "flag not listed exactly once in the usage: %s", d.name)
+		assert.Equal(t, 1, strings.Count(usage, "[$"+env+"]"), "environment variable not listed exactly once in the usage: %s", env)
+	}
+}
+
+func TestWriteUsage(t *testing.T) {
+	var (
+		ttl      time.Duration
+		election string
+		release  bool
+		address  string
+	)
+	defs := []flagDef{
+		durationFlag(&ttl, "ttl", 0, groupElection, "The TTL for the election."),
+		stringFlag(&election, "election", "", groupElection, "The name of the election."),
+		boolFlag(&release, "release-on-shutdown", true, groupElection, "Release the lock on shutdown."),
+		stringFlag(&address, "http", "0.0.0.0:5002", groupHTTP, "The `address` to serve HTTP on."),
+	}
+
+	expected := `Usage of elector:
+
+Election:
+  -election string
+    	The name of the election. [$ELECTOR_ELECTION]
+  -release-on-shutdown
+    	Release the lock on shutdown. (default true) [$ELECTOR_RELEASE_ON_SHUTDOWN]
+  -ttl duration
+    	The TTL for the election. [$ELECTOR_TTL]
+
+HTTP:
+  -http address
+    	The address to serve HTTP on. (default "0.0.0.0:5002") [$ELECTOR_HTTP]
+
+Logging:
+  -v int
+    	number for the log level verbosity
+`
+	assert.Equal(t, expected, renderUsage(defs))
+}
+
+func TestNewFlagSet(t *testing.T) {
+	// The flag set can be built more than once, since it does not register
+	// on the global flag set.
+	var sets []*flag.FlagSet
+	assert.NotPanics(t, func() {
+		for i := 0; i < 2; i++ {
+			fs, defs := newFlagSet("elector", flag.ContinueOnError)
+			assert.Len(t, defs, len(flagDefs()))
+			sets = append(sets, fs)
+		}
+	})
+
+	for _, fs := range sets {
+		for _, d := range flagDefs() {
+			assert.NotNil(t, fs.Lookup(d.name), "elector flag not registered: %s", d.name)
+		}
+		for _, name := range []string{"v", "logtostderr", "vmodule"} {
+			assert.NotNil(t, fs.Lookup(name), "klog flag not registered: %s", name)
+		}
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	fs, defs := newFlagSet("elector", flag.ContinueOnError)
+	defer func() {
+		// The klog flags set its global state, so it is reset for the
+		// other tests.
+		assert.NoError(t, fs.Set("v", "0"))
+	}()
+
+	assert.NoError(t, os.Setenv("ELECTOR_ROLE_NAME", "from-env"))
+	defer os.Unsetenv("ELECTOR_ROLE_NAME")
+
+	err := parseFlags(fs, defs, []string{"-election", "test-election", "-v", "2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "test-election", name)
+	assert.Equal(t, "from-env", roleName)
+
+	// klog's -v flag is honored.
+	assert.True(t, bool(klog.V(2)))
+	assert.False(t, bool(klog.V(3)))
+}
+
+func TestParseFlags_invalidEnv(t *testing.T) {
+	fs, defs := newFlagSet("elector", flag.ContinueOnError)
+
+	assert.NoError(t, os.Setenv("ELECTOR_TTL", "invalid"))
+	defer os.Unsetenv("ELECTOR_TTL")
+
+	err := parseFlags(fs, defs, nil)
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "error reading configuration from environment: "))
+}
@@ -0,0 +1,117 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vapor-ware/k8s-elector/pkg"
+)
+
+// Exit codes for the healthcheck subcommand. These are the codes expected by
+// the Docker HEALTHCHECK instruction.
+const (
+	exitHealthy   = 0
+	exitUnhealthy = 1
+)
+
+// runHealthcheck runs the "healthcheck" subcommand, returning its exit code.
+//
+// The subcommand checks the health of the elector running locally through its
+// /healthz endpoint, for use as a container health check outside Kubernetes.
+// The address is taken from the -http flag or the ELECTOR_HTTP environment
+// variable, as for the elector itself, so the health check can share the
+// elector's configuration. The health status is printed either way.
+//
+// It does not require access to the Kubernetes API.
+func runHealthcheck(args []string, getenv func(string) string, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	address := fs.String("http", getenv(envName("http")), "The address of the elector HTTP API (host:port, URL, or unix:<path>).")
+	timeout := fs.Duration("timeout", 2*time.Second, "The timeout for checking the elector's health.")
+	if err := fs.Parse(args); err != nil {
+		return exitUnhealthy
+	}
+
+	status, err := checkHealth(*address, *timeout)
+	if err != nil {
+		fmt.Fprintf(errOut, "error: %v\n", err)
+		return exitUnhealthy
+	}
+
+	fmt.Fprintln(out, status)
+	if status != "ok" {
+		return exitUnhealthy
+	}
+	return exitHealthy
+}
+
+// checkHealth gets the health status of the elector at the given address. A
+// failed status includes the reason the elector is unhealthy.
+func checkHealth(address string, timeout time.Duration) (string, error) {
+	if address == "" {
+		return "", errors.New("no elector HTTP address is configured; set -http or " + envName("http"))
+	}
+
+	client, url := electorClient(localAddress(address), timeout)
+	resp, err := client.Get(url + "/healthz")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var health pkg.HealthInfo
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil || health.Status == "" {
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("unexpected response from elector: %s", resp.Status)
+		}
+		return "", fmt.Errorf("invalid response from elector: %v", err)
+	}
+
+	switch {
+	case health.StartupError != "":
+		return fmt.Sprintf("%s: %s", health.Status, health.StartupError), nil
+	case resp.StatusCode != http.StatusOK && health.Status == "ok":
+		return "", fmt.Errorf("unexpected response from elector: %s", resp.Status)
+	}
+	return health.Status, nil
+}
+
+// localAddress gets the address to reach a server listening on the given
+// address locally. A server listening on all interfaces (e.g. "0.0.0.0:5002"
+// or ":5002") is reached through localhost.
+func localAddress(address string) string {
+	if strings.HasPrefix(address, unixPrefix) || strings.Contains(address, "://") {
+		return address
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		return net.JoinHostPort("localhost", port)
+	}
+	return address
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vapor-ware/k8s-elector/pkg"
+)
+
+// healthHandler serves the given health info at the elector's health endpoint.
+func healthHandler(status int, health pkg.HealthInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(health)
+	})
+}
+
+// noEnv is an environment with no variables set.
+func noEnv(string) string {
+	return ""
+}
+
+func TestRunHealthcheck(t *testing.T) {
+	cases := []struct {
+		description string
+		status      int
+		health      pkg.HealthInfo
+		code        int
+		out         string
+	}{
+		{
+			description: "healthy",
+			status:      http.StatusOK,
+			health:      pkg.HealthInfo{Status: "ok"},
+			code:        exitHealthy,
+			out:         "ok\n",
+		},
+		{
+			description: "startup failed",
+			status:      http.StatusServiceUnavailable,
+			health:      pkg.HealthInfo{Status: "failed", StartupError: "test error"},
+			code:        exitUnhealthy,
+			out:         "failed: test error\n",
+		},
+	}
+
+	for _, c := range cases {
+		server := httptest.NewServer(healthHandler(c.status, c.health))
+
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		code := runHealthcheck([]string{"-http", strings.TrimPrefix(server.URL, "http://")}, noEnv, out, errOut)
+
+		assert.Equal(t, c.code, code, c.description)
+		assert.Equal(t, c.out, out.String(), c.description)
+		assert.Empty(t, errOut.String(), c.description)
+		server.Close()
+	}
+}
+
+func TestRunHealthcheck_env(t *testing.T) {
+	server := httptest.NewServer(healthHandler(http.StatusOK, pkg.HealthInfo{Status: "ok"}))
+	defer server.Close()
+
+	// The address is taken from the same environment variable as the elector's.
+	getenv := func(name string) string {
+		if name == "ELECTOR_HTTP" {
+			return server.URL
+		}
+		return ""
+	}
+
+	out := &bytes.Buffer{}
+	code := runHealthcheck(nil, getenv, out, &bytes.Buffer{})
+	assert.Equal(t, exitHealthy, code)
+	assert.Equal(t, "ok\n", out.String())
+}
+
+func TestRunHealthcheck_noServer(t *testing.T) {
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	code := runHealthcheck(nil, noEnv, out, errOut)
+
+	assert.Equal(t, exitUnhealthy, code)
+	assert.Empty(t, out.String())
+	assert.Contains(t, errOut.String(), "no elector HTTP address is configured")
+}
+
+func TestRunHealthcheck_unreachable(t *testing.T) {
+	server := httptest.NewServer(healthHandler(http.StatusOK, pkg.HealthInfo{Status: "ok"}))
+	server.Close()
+
+	errOut := &bytes.Buffer{}
+	code := runHealthcheck([]string{"-http", server.URL}, noEnv, &bytes.Buffer{}, errOut)
+	assert.Equal(t, exitUnhealthy, code)
+	assert.Contains(t, errOut.String(), "error: ")
+}
+
+func TestLocalAddress(t *testing.T) {
+	cases := []struct {
+		address  string
+		expected string
+	}{
+		{address: "0.0.0.0:5002", expected: "localhost:5002"},
+		{address: ":5002", expected: "localhost:5002"},
+		{address: "[::]:5002", expected: "localhost:5002"},
+		{address: "10.0.0.1:5002", expected: "10.0.0.1:5002"},
+		{address: "http://0.0.0.0:5002", expected: "http://0.0.0.0:5002"},
+		{address: "unix:/tmp/elector.sock", expected: "unix:/tmp/elector.sock"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, localAddress(c.address), c.address)
+	}
+}
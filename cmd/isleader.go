@@ -0,0 +1,120 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Exit codes for the is-leader subcommand.
+const (
+	exitLeader  = 0
+	exitStandby = 1
+	exitError   = 2
+)
+
+// runIsLeader runs the "is-leader" subcommand, returning its exit code.
+//
+// The subcommand checks whether an elector node is the leader. With -address,
+// the elector's HTTP API is queried. With -election, the election lock is read
+// directly through the Kubernetes API, and the node with the -id is checked.
+// Either way, the identity of the leader is printed.
+//
+// The client is used to read the election lock. If nil, a client is built from
+// the -kubeconfig.
+func runIsLeader(args []string, client kubernetes.Interface, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("is-leader", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	address := fs.String("address", "", "The address of the elector HTTP API (host:port, URL, or unix:<path>).")
+	timeout := fs.Duration("timeout", 5*time.Second, "The timeout for querying the elector.")
+	election := fs.String("election", "", "The name of the election to read the lock of, instead of querying the elector HTTP API.")
+	namespace := fs.String("namespace", "default", "The Kubernetes namespace of the election lock.")
+	lockType := fs.String("lock-type", resourcelock.LeasesResourceLock, "The type of Kubernetes object used for the lock (leases, endpoints, configmaps).")
+	kubeconfig := fs.String("kubeconfig", "", "The kubeconfig file to use. If not set, in-cluster config will be used.")
+	id := fs.String("id", "", "The ID of the node to check, when reading the election lock. If not set, the hostname is used.")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	var leader string
+	var isLeader bool
+	var err error
+	switch {
+	case *address != "" && *election != "":
+		err = errors.New("only one of -address and -election may be set")
+	case *address != "":
+		leader, isLeader, err = leaderFromHTTP(*address, *timeout)
+	case *election != "":
+		leader, isLeader, err = leaderFromLock(client, *kubeconfig, *lockType, *namespace, *election, *id)
+	default:
+		err = errors.New("one of -address or -election must be set")
+	}
+	if err != nil {
+		fmt.Fprintf(errOut, "error: %v\n", err)
+		return exitError
+	}
+
+	fmt.Fprintln(out, leader)
+	if isLeader {
+		return exitLeader
+	}
+	return exitStandby
+}
+
+// leaderFromHTTP gets the leader of the election and whether the elector at
+// the given address is the leader.
+func leaderFromHTTP(address string, timeout time.Duration) (string, bool, error) {
+	info, err := getLeaderInfo(address, timeout)
+	if err != nil {
+		return "", false, err
+	}
+	return info.Leader, info.IsLeader, nil
+}
+
+// leaderFromLock gets the leader of the election from its lock, and whether
+// the node with the given ID is the leader.
+func leaderFromLock(client kubernetes.Interface, kubeconfig, lockType, namespace, election, id string) (string, bool, error) {
+	if id == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return "", false, err
+		}
+		id = hostname
+	}
+
+	if client == nil {
+		var err error
+		client, err = newKubeClient(kubeconfig)
+		if err != nil {
+			return "", false, err
+		}
+	}
+
+	leader, err := getLockHolder(client, lockType, namespace, election, time.Now())
+	if err != nil {
+		return "", false, err
+	}
+	return leader, leader != "" && leader == id, nil
+}
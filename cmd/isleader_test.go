@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vapor-ware/k8s-elector/pkg"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// leaderInfoHandler serves the given leader info at the elector's root endpoint.
+func leaderInfoHandler(status int, info pkg.LeaderInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(info)
+	})
+}
+
+func TestRunIsLeader_http(t *testing.T) {
+	cases := []struct {
+		description string
+		status      int
+		info        pkg.LeaderInfo
+		code        int
+		out         string
+	}{
+		{
+			description: "node is the leader",
+			status:      http.StatusOK,
+			info:        pkg.LeaderInfo{Node: "node-1", Leader: "node-1", IsLeader: true},
+			code:        exitLeader,
+			out:         "node-1\n",
+		},
+		{
+			description: "node is on standby",
+			status:      http.StatusOK,
+			info:        pkg.LeaderInfo{Node: "node-1", Leader: "node-2"},
+			code:        exitStandby,
+			out:         "node-2\n",
+		},
+		{
+			description: "elector returns an error",
+			status:      http.StatusInternalServerError,
+			code:        exitError,
+			out:         "",
+		},
+	}
+
+	for _, c := range cases {
+		server := httptest.NewServer(leaderInfoHandler(c.status, c.info))
+
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		// Addresses may be given with or without a scheme.
+		address := strings.TrimPrefix(server.URL, "http://")
+		code := runIsLeader([]string{"-address", address}, nil, out, errOut)
+
+		assert.Equal(t, c.code, code, c.description)
+		assert.Equal(t, c.out, out.String(), c.description)
+		server.Close()
+	}
+}
+
+func TestRunIsLeader_unixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elector")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "elector.sock")
+	listener, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+	server := &http.Server{Handler: leaderInfoHandler(http.StatusOK, pkg.LeaderInfo{
+		Node:     "node-1",
+		Leader:   "node-1",
+		IsLeader: true,
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	code := runIsLeader([]string{"-address", "unix:" + path}, nil, out, &bytes.Buffer{})
+	assert.Equal(t, exitLeader, code)
+	assert.Equal(t, "node-1\n", out.String())
+}
+
+// newTestLease creates a lease for the election held by the given holder,
+// last renewed at the given time.
+func newTestLease(holder string, renewed time.Time) *coordinationv1.Lease {
+	duration := int32(10)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-election",
+			Namespace: "test-ns",
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			AcquireTime:          &metav1.MicroTime{Time: renewed},
+			RenewTime:            &metav1.MicroTime{Time: renewed},
+		},
+	}
+}
+
+func TestRunIsLeader_lock(t *testing.T) {
+	args := []string{"-election", "test-election", "-namespace", "test-ns", "-id", "node-1"}
+
+	cases := []struct {
+		description string
+		client      *fake.Clientset
+		code        int
+		out         string
+	}{
+		{
+			description: "node holds the lock",
+			client:      fake.NewSimpleClientset(newTestLease("node-1", time.Now())),
+			code:        exitLeader,
+			out:         "node-1\n",
+		},
+		{
+			description: "another node holds the lock",
+			client:      fake.NewSimpleClientset(newTestLease("node-2", time.Now())),
+			code:        exitStandby,
+			out:         "node-2\n",
+		},
+		{
+			description: "the node's lease expired",
+			client:      fake.NewSimpleClientset(newTestLease("node-1", time.Now().Add(-time.Minute))),
+			code:        exitStandby,
+			out:         "\n",
+		},
+		{
+			description: "the lock does not exist",
+			client:      fake.NewSimpleClientset(),
+			code:        exitError,
+			out:         "",
+		},
+	}
+
+	for _, c := range cases {
+		out := &bytes.Buffer{}
+		code := runIsLeader(args, c.client, out, &bytes.Buffer{})
+		assert.Equal(t, c.code, code, c.description)
+		assert.Equal(t, c.out, out.String(), c.description)
+	}
+}
+
+func TestRunIsLeader_usage(t *testing.T) {
+	cases := []struct {
+		description string
+		args        []string
+	}{
+		{
+			description: "no target",
+			args:        []string{},
+		},
+		{
+			description: "both targets",
+			args:        []string{"-address", "localhost:5002", "-election", "test-election"},
+		},
+		{
+			description: "unknown flag",
+			args:        []string{"-unknown"},
+		},
+	}
+
+	for _, c := range cases {
+		code := runIsLeader(c.args, fake.NewSimpleClientset(), &bytes.Buffer{}, &bytes.Buffer{})
+		assert.Equal(t, exitError, code, c.description)
+	}
+}
@@ -0,0 +1,22 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package e2e holds the elector's end-to-end tests, which run elector nodes
+// against the API server of a real cluster. The tests are built with the e2e
+// build tag, and are skipped unless KUBECONFIG is set:
+//
+//	KUBECONFIG=~/.kube/config go test -tags e2e -v ./e2e/...
+package e2e
@@ -0,0 +1,122 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vapor-ware/k8s-elector/e2e/framework"
+	"github.com/vapor-ware/k8s-elector/pkg"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	testNodes = 3
+	testTTL   = 6 * time.Second
+)
+
+// startTestNodes starts the nodes of a test election, which do not release the
+// lock on shutdown, so stopping the leader is the same as it crashing.
+func startTestNodes(cluster *framework.Cluster, election string) []*framework.Node {
+	pods := cluster.PodNames(testNodes)
+
+	var nodes []*framework.Node
+	for i := 0; i < testNodes; i++ {
+		config := pkg.ElectorConfig{
+			ID:       fmt.Sprintf("e2e-node-%d", i+1),
+			Name:     election,
+			LockType: resourcelock.LeasesResourceLock,
+			TTL:      testTTL,
+		}
+		if pods != nil {
+			config.PodName = pods[i]
+		}
+		nodes = append(nodes, cluster.StartNode(config))
+	}
+	return nodes
+}
+
+func TestElection_failover(t *testing.T) {
+	cluster := framework.NewCluster(t)
+	defer cluster.Cleanup()
+
+	nodes := startTestNodes(cluster, "e2e-failover")
+	leader, err := framework.WaitForLeader(nodes, 3*testTTL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	t.Logf("%s was elected", leader.ID)
+
+	// All of the nodes agree on the leader, and there is only one for as long
+	// as the leader renews its lease.
+	err = framework.Poll(testTTL, func() bool {
+		for _, node := range nodes {
+			info, err := node.Info()
+			if err != nil || info.Leader != leader.ID {
+				return false
+			}
+		}
+		return true
+	})
+	assert.NoError(t, err, "nodes did not agree on the leader")
+	for i := 0; i < 10; i++ {
+		leaders := 0
+		for _, node := range nodes {
+			if node.IsLeader() {
+				leaders++
+			}
+		}
+		assert.Equal(t, 1, leaders)
+		time.Sleep(testTTL / 10)
+	}
+
+	// Once the leader is gone, another node takes over once its lease
+	// expires, within a retry period of it.
+	killed := time.Now()
+	assert.NoError(t, leader.Kill())
+	var remaining []*framework.Node
+	for _, node := range nodes {
+		if node != leader {
+			remaining = append(remaining, node)
+		}
+	}
+	next, err := framework.WaitForLeader(remaining, 3*testTTL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	failover := time.Since(killed)
+	t.Logf("%s took over after %v", next.ID, failover)
+	assert.True(t, failover < testTTL+testTTL/2, "failover took %v, with a TTL of %v", failover, testTTL)
+}
+
+func TestElection_podLabels(t *testing.T) {
+	cluster := framework.NewCluster(t)
+	defer cluster.Cleanup()
+	pods := cluster.PodNames(testNodes)
+	if pods == nil {
+		t.Skipf("%s is not set, skipping Pod label checks", framework.EnvPodNames)
+	}
+
+	nodes := startTestNodes(cluster, "e2e-pod-labels")
+	leader, err := framework.WaitForLeader(nodes, 3*testTTL)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The leader's Pod is labelled as the leader, and the others as standby.
+	for i, node := range nodes {
+		expected := pkg.StatusStandby
+		if node == leader {
+			expected = pkg.StatusLeader
+		}
+		err := framework.Poll(testTTL, func() bool {
+			label, err := cluster.PodLabel(pods[i])
+			return err == nil && label == expected
+		})
+		assert.NoError(t, err, "Pod %s of node %s was not labelled %s", pods[i], node.ID, expected)
+	}
+}
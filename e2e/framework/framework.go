@@ -0,0 +1,254 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package framework provides helpers for the elector's end-to-end tests, which
+// run elector nodes in-process against the API server of a real cluster, such
+// as one created with kind.
+package framework
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vapor-ware/k8s-elector/pkg"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// EnvKubeConfig is the environment variable with the kubeconfig of the
+	// cluster to run the tests against. If it is not set, the tests are
+	// skipped.
+	EnvKubeConfig = "KUBECONFIG"
+
+	// EnvNamespace is the environment variable with an existing namespace to
+	// run the tests in. If it is not set, a namespace is created for the
+	// tests, and deleted once they are done.
+	EnvNamespace = "E2E_NAMESPACE"
+
+	// EnvPodNames is the environment variable with a comma-separated list of
+	// Pods in the test namespace for the nodes to publish their status to,
+	// one for each node. If it is not set, the Pod labels are not checked.
+	EnvPodNames = pkg.EnvPodName
+)
+
+// pollInterval is the interval on which the framework polls for a condition.
+const pollInterval = 100 * time.Millisecond
+
+// Cluster is a connection to the cluster which the end-to-end tests run
+// against, and the namespace they run in.
+type Cluster struct {
+	Client     kubernetes.Interface
+	KubeConfig string
+	Namespace  string
+
+	t                *testing.T
+	createdNamespace bool
+	nodes            []*Node
+}
+
+// NewCluster connects to the cluster given by the kubeconfig in the
+// environment. If no kubeconfig is given, the test is skipped.
+//
+// Unless an existing namespace is given, a namespace is created for the test.
+// Cleanup must be called once the test is done, to stop its nodes and delete
+// the namespace.
+func NewCluster(t *testing.T) *Cluster {
+	kubeconfig := os.Getenv(EnvKubeConfig)
+	if kubeconfig == "" {
+		t.Skipf("%s is not set, skipping end-to-end tests", EnvKubeConfig)
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		t.Fatalf("failed to load kubeconfig: %v", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	cluster := &Cluster{
+		Client:     client,
+		KubeConfig: kubeconfig,
+		Namespace:  os.Getenv(EnvNamespace),
+		t:          t,
+	}
+	if cluster.Namespace == "" {
+		ns, err := client.CoreV1().Namespaces().Create(&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "k8s-elector-e2e-"},
+		})
+		if err != nil {
+			t.Fatalf("failed to create test namespace: %v", err)
+		}
+		cluster.Namespace = ns.Name
+		cluster.createdNamespace = true
+	}
+	t.Logf("running in namespace %s", cluster.Namespace)
+	return cluster
+}
+
+// Cleanup stops the nodes which are still running, and deletes the test
+// namespace if it was created for the test.
+func (c *Cluster) Cleanup() {
+	for _, node := range c.nodes {
+		if err := node.Kill(); err != nil {
+			c.t.Logf("node %s stopped with error: %v", node.ID, err)
+		}
+	}
+	if c.createdNamespace {
+		err := c.Client.CoreV1().Namespaces().Delete(c.Namespace, &metav1.DeleteOptions{})
+		if err != nil {
+			c.t.Errorf("failed to delete test namespace %s: %v", c.Namespace, err)
+		}
+	}
+}
+
+// PodNames gets the names of the Pods for the nodes to publish their status
+// to, if they are given in the environment. If they are not, or there are
+// not as many as there are nodes, nil is returned.
+func (c *Cluster) PodNames(nodes int) []string {
+	value := os.Getenv(EnvPodNames)
+	if value == "" {
+		return nil
+	}
+	names := strings.Split(value, ",")
+	if len(names) != nodes {
+		c.t.Logf("%s has %d Pods rather than %d, not checking Pod labels", EnvPodNames, len(names), nodes)
+		return nil
+	}
+	return names
+}
+
+// PodLabel gets the elector status label of a Pod in the test namespace.
+func (c *Cluster) PodLabel(pod string) (string, error) {
+	p, err := c.Client.CoreV1().Pods(c.Namespace).Get(pod, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return p.Labels[pkg.PodLabelKey], nil
+}
+
+// Node is an elector node run by the framework.
+type Node struct {
+	*pkg.ElectorNode
+	ID string
+
+	done chan error
+	err  error
+}
+
+// StartNode starts an elector node with the given configuration, and waits
+// for its HTTP server to come up. The node runs in the test namespace, using
+// the cluster's kubeconfig, and serves HTTP on a free local port.
+func (c *Cluster) StartNode(config pkg.ElectorConfig) *Node {
+	config.KubeConfig = c.KubeConfig
+	config.Namespace = c.Namespace
+	config.LockNamespace = c.Namespace
+	config.Address = "127.0.0.1:0"
+
+	node := &Node{
+		ElectorNode: pkg.NewElectorNode(&config),
+		ID:          config.ID,
+		done:        make(chan error, 1),
+	}
+	go func() {
+		node.done <- node.Run()
+	}()
+	c.nodes = append(c.nodes, node)
+
+	err := Poll(10*time.Second, func() bool {
+		return node.HTTPAddr() != ""
+	})
+	if err != nil {
+		c.t.Fatalf("HTTP server of node %s did not start: %v", node.ID, err)
+	}
+	return node
+}
+
+// Kill stops the node and waits for it to finish. If the node is not
+// configured to release the lock on shutdown, this is the same as the node
+// crashing as far as the other nodes can tell.
+func (n *Node) Kill() error {
+	if n.done == nil {
+		return n.err
+	}
+	n.Stop()
+	select {
+	case n.err = <-n.done:
+	case <-time.After(30 * time.Second):
+		n.err = errors.New("timed out waiting for the node to stop")
+	}
+	n.done = nil
+	return n.err
+}
+
+// Info gets the leadership status of the node through its HTTP API.
+func (n *Node) Info() (pkg.LeaderInfo, error) {
+	var info pkg.LeaderInfo
+	res, err := http.Get(fmt.Sprintf("http://%s/", n.HTTPAddr()))
+	if err != nil {
+		return info, err
+	}
+	defer res.Body.Close()
+	err = json.NewDecoder(res.Body).Decode(&info)
+	return info, err
+}
+
+// WaitForLeader waits until exactly one of the nodes is the leader, and gets
+// the leader.
+func WaitForLeader(nodes []*Node, timeout time.Duration) (*Node, error) {
+	var leader *Node
+	err := Poll(timeout, func() bool {
+		leader = nil
+		for _, node := range nodes {
+			if node.IsLeader() {
+				if leader != nil {
+					return false
+				}
+				leader = node
+			}
+		}
+		return leader != nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("no single leader was elected: %v", err)
+	}
+	return leader, nil
+}
+
+// Poll waits for the condition to be met, checking it on a short interval,
+// until the timeout.
+func Poll(timeout time.Duration, condition func() bool) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if condition() {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %v", timeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
@@ -4,10 +4,11 @@ go 1.13
 
 require (
 	github.com/imdario/mergo v0.3.8 // indirect
+	github.com/prometheus/client_golang v1.5.1
 	github.com/stretchr/testify v1.4.0
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d // indirect
 	golang.org/x/time v0.0.0-20191024005414-555d28b269f0 // indirect
-	k8s.io/api v0.17.3 // indirect
+	k8s.io/api v0.17.3
 	k8s.io/apimachinery v0.17.3
 	k8s.io/client-go v0.17.0
 	k8s.io/klog v1.0.0
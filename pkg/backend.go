@@ -0,0 +1,143 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"net/http"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// BackendInfo describes the backend of the election lock: the type of the
+// lock, the object it is held in, and whether the API it uses is available.
+type BackendInfo struct {
+	LockType  string            `json:"lock_type" description:"The type of Kubernetes object used as the election lock."`
+	Namespace string            `json:"namespace" description:"The namespace of the election lock object."`
+	Name      string            `json:"name" description:"The name of the election lock object."`
+	Resources []BackendResource `json:"resources" description:"The API resources which back the election lock."`
+	CheckedAt Timestamp         `json:"checked_at" description:"The timestamp for when the availability of the resources was last checked, when a run of the election started. Empty until it has been checked."`
+}
+
+// BackendResource describes an API resource which backs the election lock.
+type BackendResource struct {
+	GroupVersion string `json:"group_version" description:"The API group and version of the resource."`
+	Resource     string `json:"resource" description:"The name of the resource."`
+	Available    bool   `json:"available" description:"Whether the API server serves the resource. False until it has been checked."`
+	Error        string `json:"error,omitempty" description:"The error from checking whether the resource is available, if any."`
+}
+
+// lockResources gets the API resources which back the election lock. While
+// the lock type is being migrated, the resources of both lock types back it.
+func lockResources(conf *ElectorConfig) []schema.GroupVersionResource {
+	if conf.LockTypeMigrateFrom != "" {
+		from := *conf
+		from.LockTypeMigrateFrom = ""
+		from.LockType = conf.LockTypeMigrateFrom
+		to := from
+		to.LockType = conf.LockType
+		return append(lockResources(&from), lockResources(&to)...)
+	}
+
+	switch conf.LockType {
+	case resourcelock.LeasesResourceLock:
+		return []schema.GroupVersionResource{coordinationv1.SchemeGroupVersion.WithResource("leases")}
+	case resourcelock.EndpointsResourceLock:
+		return []schema.GroupVersionResource{corev1.SchemeGroupVersion.WithResource("endpoints")}
+	case resourcelock.ConfigMapsResourceLock:
+		return []schema.GroupVersionResource{corev1.SchemeGroupVersion.WithResource("configmaps")}
+	case DynamicResourceLock:
+		return []schema.GroupVersionResource{conf.LockResource}
+	}
+	return nil
+}
+
+// checkBackend checks whether the API server serves the resources which back
+// the election lock, through the discovery API.
+//
+// This is done when a run of the election starts, so /backend can report it
+// without making requests. An unavailable resource is only logged, since the
+// election itself reports a failure to use the lock.
+func (node *ElectorNode) checkBackend(client kubernetes.Interface) {
+	var resources []BackendResource
+	for _, gvr := range lockResources(node.config) {
+		resource := BackendResource{
+			GroupVersion: gvr.GroupVersion().String(),
+			Resource:     gvr.Resource,
+		}
+
+		list, err := client.Discovery().ServerResourcesForGroupVersion(resource.GroupVersion)
+		if err != nil {
+			resource.Error = err.Error()
+		} else if list != nil {
+			for _, r := range list.APIResources {
+				if r.Name == gvr.Resource {
+					resource.Available = true
+					break
+				}
+			}
+		}
+		if !resource.Available {
+			node.log.Warningf("the %s resource (%s) of the election lock is not available", resource.Resource, resource.GroupVersion)
+		}
+		resources = append(resources, resource)
+	}
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.backendResources = resources
+	node.backendChecked = node.clock.Now()
+}
+
+// backendInfo gets the details of the backend of the election lock.
+func (node *ElectorNode) backendInfo() BackendInfo {
+	node.mu.RLock()
+	checked := node.backendChecked
+	resources := node.backendResources
+	node.mu.RUnlock()
+
+	// Until the backend is checked, the resources are reported as they are
+	// configured.
+	if checked.IsZero() {
+		for _, gvr := range lockResources(node.config) {
+			resources = append(resources, BackendResource{
+				GroupVersion: gvr.GroupVersion().String(),
+				Resource:     gvr.Resource,
+			})
+		}
+	}
+	if resources == nil {
+		resources = []BackendResource{}
+	}
+
+	return BackendInfo{
+		LockType:  node.config.LockType,
+		Namespace: node.config.LockNamespace,
+		Name:      node.config.Name,
+		Resources: resources,
+		CheckedAt: Timestamp(checked),
+	}
+}
+
+// httpBackend is the handler for the endpoint which provides the details of
+// the backend of the election lock.
+func (node *ElectorNode) httpBackend(res http.ResponseWriter, req *http.Request) {
+	node.writeJSON(res, http.StatusOK, node.backendInfo())
+}
@@ -0,0 +1,122 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestLockResources(t *testing.T) {
+	cases := []struct {
+		lockType string
+		expected string
+	}{
+		{lockType: resourcelock.LeasesResourceLock, expected: "coordination.k8s.io/v1, Resource=leases"},
+		{lockType: resourcelock.EndpointsResourceLock, expected: "/v1, Resource=endpoints"},
+		{lockType: resourcelock.ConfigMapsResourceLock, expected: "/v1, Resource=configmaps"},
+		{lockType: DynamicResourceLock, expected: testLockResource.String()},
+	}
+
+	for _, c := range cases {
+		resources := lockResources(&ElectorConfig{LockType: c.lockType, LockResource: testLockResource})
+		if assert.Len(t, resources, 1, c.lockType) {
+			assert.Equal(t, c.expected, resources[0].String(), c.lockType)
+		}
+	}
+
+	assert.Empty(t, lockResources(&ElectorConfig{LockType: "unknown"}))
+}
+
+func TestElectorNode_checkBackend(t *testing.T) {
+	cases := []struct {
+		description string
+		resources   []*metav1.APIResourceList
+		available   bool
+	}{
+		{
+			description: "available",
+			resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "coordination.k8s.io/v1",
+					APIResources: []metav1.APIResource{{Name: "leases"}},
+				},
+			},
+			available: true,
+		},
+		{
+			description: "group not served",
+			resources:   nil,
+			available:   false,
+		},
+		{
+			description: "resource not served",
+			resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "coordination.k8s.io/v1",
+					APIResources: []metav1.APIResource{{Name: "other"}},
+				},
+			},
+			available: false,
+		},
+	}
+
+	for _, c := range cases {
+		client := fake.NewSimpleClientset()
+		client.Resources = c.resources
+		log := &testLogger{}
+		node := NewElectorNode(&ElectorConfig{
+			ID:            "test-node-1",
+			Name:          "test-election",
+			LockNamespace: "test-ns",
+			LockType:      resourcelock.LeasesResourceLock,
+			Logger:        log,
+		})
+		now := time.Date(2019, 5, 2, 18, 28, 51, 0, time.UTC)
+		node.clock = clock.NewFakeClock(now)
+
+		node.checkBackend(client)
+
+		info := node.backendInfo()
+		assert.Equal(t, resourcelock.LeasesResourceLock, info.LockType, c.description)
+		assert.Equal(t, "test-ns", info.Namespace, c.description)
+		assert.Equal(t, "test-election", info.Name, c.description)
+		assert.Equal(t, Timestamp(now), info.CheckedAt, c.description)
+		if assert.Len(t, info.Resources, 1, c.description) {
+			assert.Equal(t, "coordination.k8s.io/v1", info.Resources[0].GroupVersion, c.description)
+			assert.Equal(t, "leases", info.Resources[0].Resource, c.description)
+			assert.Equal(t, c.available, info.Resources[0].Available, c.description)
+		}
+		if c.available {
+			assert.NotContains(t, log.String(), "not available", c.description)
+		} else {
+			assert.Contains(t, log.String(), "WARNING [election=test-election id=test-node-1] the leases resource (coordination.k8s.io/v1) of the election lock is not available", c.description)
+		}
+	}
+}
+
+func TestElectorNode_httpBackend_notChecked(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		LockNamespace: "test-ns",
+		LockType:      resourcelock.ConfigMapsResourceLock,
+	})
+
+	data := getJSON(t, node, "/backend")
+	assert.Equal(t, "configmaps", data["lock_type"])
+	assert.Equal(t, "test-ns", data["namespace"])
+	assert.Equal(t, "test-election", data["name"])
+	assert.Equal(t, "", data["checked_at"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"group_version": "v1",
+			"resource":      "configmaps",
+			"available":     false,
+		},
+	}, data["resources"])
+}
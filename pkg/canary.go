@@ -0,0 +1,65 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"context"
+	"net/http"
+)
+
+// newCanaryNode creates the elector node which participates in the canary
+// election.
+//
+// The canary node shares the configuration of its parent node, but runs the
+// election named by CanaryElection. Its context is derived from the parent's,
+// so it is stopped when the parent is. It does not serve HTTP or listen for
+// signals itself, and is passive, so it has no side effects on the Pod. The
+// chaos hooks only inject failures into the parent's election.
+func (node *ElectorNode) newCanaryNode() *ElectorNode {
+	config := *node.config
+	config.Name = node.config.CanaryElection
+	config.CanaryElection = ""
+	config.Address = ""
+	config.Chaos = false
+
+	canary := newElectorNode(node.ctx, &config)
+	canary.clock = node.clock
+	canary.passive = true
+	canary.operations = node.operations
+	return canary
+}
+
+// runCanary runs the canary election until the node is stopped. A failure of
+// the canary election does not stop the primary election.
+func (node *ElectorNode) runCanary() {
+	node.log.Infof("joining canary election: %s", node.canary.config.Name)
+	if err := node.canary.runUntilError(); err != nil && err != context.Canceled {
+		node.log.Errorf("canary election terminated: %v", err)
+	}
+}
+
+// httpCanaryInfo is the handler for the endpoint which provides leader info
+// for the canary election.
+func (node *ElectorNode) httpCanaryInfo(res http.ResponseWriter, req *http.Request) {
+	if node.canary == nil {
+		node.writeJSON(res, http.StatusNotFound, MessageResponse{
+			Message: "no canary election configured",
+		})
+		return
+	}
+	node.writeJSON(res, http.StatusOK, node.canary.leaderInfo())
+}
@@ -0,0 +1,159 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestElectorNode_newCanaryNode(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:             "test-node-1",
+		Name:           "test-election",
+		Namespace:      "test-ns",
+		Address:        "localhost:5001",
+		CanaryElection: "test-canary",
+	})
+
+	canary := node.newCanaryNode()
+
+	assert.True(t, canary.passive)
+	assert.NotNil(t, canary.quit)
+	assert.Equal(t, "test-canary", canary.config.Name)
+	assert.Equal(t, "test-node-1", canary.config.ID)
+	assert.Equal(t, "test-ns", canary.config.Namespace)
+	assert.Equal(t, "", canary.config.Address)
+	assert.Equal(t, "", canary.config.CanaryElection)
+	assert.Equal(t, node.clock, canary.clock)
+	assert.NotNil(t, canary.chaos)
+	assert.NotNil(t, canary.terminate)
+
+	// The parent node's config is not modified.
+	assert.Equal(t, "test-election", node.config.Name)
+
+	// Stopping the parent node stops the canary node.
+	node.cancel()
+	assert.Error(t, canary.ctx.Err())
+}
+
+func TestElectorNode_httpCanaryInfo_notConfigured(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+
+	req := httptest.NewRequest("GET", "/canary", nil)
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, req)
+
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestElectorNode_httpCanaryInfo(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:             "test-node-1",
+		Name:           "test-election",
+		CanaryElection: "test-canary",
+	})
+	node.canary = node.newCanaryNode()
+	node.setLeader("test-node-2")
+	node.canary.setLeader("test-node-1")
+
+	req := httptest.NewRequest("GET", "/canary", nil)
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+
+	data := map[string]interface{}{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&data))
+	assert.Equal(t, "test-node-1", data["leader"])
+	assert.Equal(t, true, data["is_leader"])
+}
+
+func TestElectorNode_newCanaryNode_chaos(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:             "test-node-1",
+		Name:           "test-election",
+		Namespace:      "test-ns",
+		LockNamespace:  "test-ns",
+		PodName:        "test-pod",
+		LockType:       resourcelock.LeasesResourceLock,
+		TTL:            1 * time.Second,
+		Client:         client,
+		Logger:         &testLogger{},
+		CanaryElection: "test-canary",
+		Chaos:          true,
+	})
+	canary := node.newCanaryNode()
+	assert.False(t, canary.config.Chaos)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- canary.runUntilError()
+	}()
+
+	// The canary acquires and renews its lock without the chaos hooks.
+	waitFor(t, 5*time.Second, func() bool {
+		return canary.IsLeader() && canary.renewCount() > 1
+	})
+
+	// Stopping the parent node stops the canary node.
+	node.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "canary node did not stop")
+	}
+}
+
+func TestElectorNode_newCanaryNode_noSideEffects(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:             "test-node-1",
+		Name:           "test-election",
+		Namespace:      "test-ns",
+		LockNamespace:  "test-ns",
+		PodName:        "test-pod",
+		LockType:       resourcelock.LeasesResourceLock,
+		TTL:            1 * time.Second,
+		Client:         client,
+		Logger:         &testLogger{},
+		CanaryElection: "test-canary",
+		PodCache:       true,
+	})
+	canary := node.newCanaryNode()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- canary.runUntilError()
+	}()
+	waitFor(t, 5*time.Second, func() bool {
+		return canary.IsLeader()
+	})
+
+	// The canary does not publish its status, cache the Pod or check its
+	// permissions.
+	canary.mu.RLock()
+	assert.Nil(t, canary.publishers)
+	assert.Nil(t, canary.pods)
+	canary.mu.RUnlock()
+
+	node.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "canary node did not stop")
+	}
+
+	for _, action := range client.Actions() {
+		resource := action.GetResource().Resource
+		assert.NotEqual(t, "pods", resource, action.GetVerb())
+		assert.NotEqual(t, "selfsubjectaccessreviews", resource, action.GetVerb())
+	}
+}
@@ -0,0 +1,150 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CandidacyCheck checks whether an elector node may stand for election.
+type CandidacyCheck func(ctx context.Context) (bool, error)
+
+// CommandCandidacyCheck creates a CandidacyCheck which runs the given command.
+// The node may stand for election if the command exits with a zero exit code.
+//
+// The command is split on whitespace into the executable and its arguments; it
+// is not run in a shell. If the command can not be run at all, the check
+// returns an error.
+func CommandCandidacyCheck(command string) CandidacyCheck {
+	return func(ctx context.Context) (bool, error) {
+		args := strings.Fields(command)
+		if len(args) == 0 {
+			return false, errors.New("no command specified")
+		}
+
+		err := exec.CommandContext(ctx, args[0], args[1:]...).Run()
+		if err != nil {
+			if _, ok := err.(*exec.ExitError); ok {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+// isCandidate checks whether the node may currently stand for election. A node
+// without a candidacy check is always a candidate. If the check fails, the node
+// is not a candidate.
+func (node *ElectorNode) isCandidate(ctx context.Context) bool {
+	if node.config.CandidacyCheck == nil {
+		return true
+	}
+
+	ok, err := node.config.CandidacyCheck(ctx)
+	if err != nil {
+		node.log.Warningf("candidacy check failed: %v", err)
+		return false
+	}
+	return ok
+}
+
+// waitForCandidacy blocks until the node may stand for election, re-evaluating
+// the candidacy check on the given interval. If the context is cancelled before
+// then, false is returned.
+func (node *ElectorNode) waitForCandidacy(ctx context.Context, interval time.Duration) bool {
+	if node.isCandidate(ctx) {
+		return true
+	}
+	node.log.Info("not standing for election: candidacy check did not pass")
+
+	ticker := node.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C():
+		}
+
+		if node.isCandidate(ctx) {
+			node.log.Info("candidacy check passed, standing for election")
+			return true
+		}
+	}
+}
+
+// watchCandidacy re-evaluates the candidacy check on the given interval while
+// the node stands for election. Once the check no longer passes, withdraw is
+// called to stop the node from standing.
+//
+// If the node is the leader when the check stops passing, it only withdraws if
+// it is configured to step down; otherwise it keeps leading and the check
+// continues to be evaluated. A leader which steps down publishes its standby
+// status first and hands off the lock, as when it is paused.
+func (node *ElectorNode) watchCandidacy(ctx context.Context, interval time.Duration, withdraw func()) {
+	ticker := node.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	keptLeading := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		if node.isCandidate(ctx) {
+			keptLeading = false
+			continue
+		}
+
+		if node.IsLeader() {
+			if !node.config.StepDownOnCandidacyLoss {
+				if !keptLeading {
+					node.log.Warningf("candidacy check did not pass while leading, keeping leadership")
+					keptLeading = true
+				}
+				continue
+			}
+			node.log.Info("stepping down: candidacy check did not pass")
+			node.stepDown()
+			node.mu.Lock()
+			node.candidacyLost = true
+			node.mu.Unlock()
+		} else {
+			node.log.Info("withdrawing from the election: candidacy check did not pass")
+		}
+		withdraw()
+		return
+	}
+}
+
+// takeCandidacyLost checks whether the node stepped down as the leader since
+// its candidacy check stopped passing, and clears it for the next run of the
+// election.
+func (node *ElectorNode) takeCandidacyLost() bool {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	lost := node.candidacyLost
+	node.candidacyLost = false
+	return lost
+}
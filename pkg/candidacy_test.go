@@ -0,0 +1,275 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// testCandidacy is a candidacy check whose result can be changed by a test.
+type testCandidacy struct {
+	mu  sync.Mutex
+	ok  bool
+	err error
+}
+
+func (c *testCandidacy) set(ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ok = ok
+}
+
+func (c *testCandidacy) check(ctx context.Context) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ok, c.err
+}
+
+func TestCommandCandidacyCheck(t *testing.T) {
+	cases := []struct {
+		description string
+		command     string
+		expected    bool
+		err         bool
+	}{
+		{
+			description: "command exits successfully",
+			command:     "true",
+			expected:    true,
+		},
+		{
+			description: "command exits with an error code",
+			command:     "false",
+			expected:    false,
+		},
+		{
+			description: "command does not exist",
+			command:     "/does/not/exist",
+			err:         true,
+		},
+		{
+			description: "no command",
+			command:     " ",
+			err:         true,
+		},
+	}
+
+	for _, c := range cases {
+		ok, err := CommandCandidacyCheck(c.command)(context.Background())
+		if c.err {
+			assert.Error(t, err, c.description)
+		} else {
+			assert.NoError(t, err, c.description)
+		}
+		assert.Equal(t, c.expected, ok, c.description)
+	}
+}
+
+func TestElectorNode_isCandidate(t *testing.T) {
+	cases := []struct {
+		description string
+		check       CandidacyCheck
+		expected    bool
+	}{
+		{
+			description: "no candidacy check",
+			check:       nil,
+			expected:    true,
+		},
+		{
+			description: "check passes",
+			check:       (&testCandidacy{ok: true}).check,
+			expected:    true,
+		},
+		{
+			description: "check does not pass",
+			check:       (&testCandidacy{ok: false}).check,
+			expected:    false,
+		},
+		{
+			description: "check errors",
+			check:       (&testCandidacy{ok: true, err: errors.New("test")}).check,
+			expected:    false,
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			CandidacyCheck: c.check,
+			Logger:         &testLogger{},
+		})
+		assert.Equal(t, c.expected, node.isCandidate(context.Background()), c.description)
+	}
+}
+
+func TestElectorNode_waitForCandidacy(t *testing.T) {
+	candidacy := &testCandidacy{}
+	node := NewElectorNode(&ElectorConfig{
+		CandidacyCheck: candidacy.check,
+		Logger:         &testLogger{},
+	})
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 28, 51, 0, time.UTC))
+	node.clock = clk
+
+	done := make(chan bool, 1)
+	go func() {
+		done <- node.waitForCandidacy(context.Background(), 10*time.Second)
+	}()
+
+	// The check is re-evaluated on the node's clock.
+	waitFor(t, 3*time.Second, clk.HasWaiters)
+	clk.Step(10 * time.Second)
+	select {
+	case <-done:
+		assert.Fail(t, "stopped waiting before the check passed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	candidacy.set(true)
+	clk.Step(10 * time.Second)
+	select {
+	case ok := <-done:
+		assert.True(t, ok)
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "did not stop waiting once the check passed")
+	}
+}
+
+func TestElectorNode_waitForCandidacy_cancelled(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		CandidacyCheck: (&testCandidacy{}).check,
+		Logger:         &testLogger{},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, node.waitForCandidacy(ctx, 10*time.Millisecond))
+}
+
+func TestElectorNode_watchCandidacy(t *testing.T) {
+	cases := []struct {
+		description string
+		candidate   bool
+		leader      bool
+		stepDown    bool
+		withdrawn   bool
+	}{
+		{
+			description: "standby node which is a candidate",
+			candidate:   true,
+			withdrawn:   false,
+		},
+		{
+			description: "standby node which is not a candidate",
+			candidate:   false,
+			withdrawn:   true,
+		},
+		{
+			description: "leader which is a candidate",
+			candidate:   true,
+			leader:      true,
+			stepDown:    true,
+			withdrawn:   false,
+		},
+		{
+			description: "leader which is not a candidate keeps leading",
+			candidate:   false,
+			leader:      true,
+			stepDown:    false,
+			withdrawn:   false,
+		},
+		{
+			description: "leader which is not a candidate steps down",
+			candidate:   false,
+			leader:      true,
+			stepDown:    true,
+			withdrawn:   true,
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:                      "test-node-1",
+			CandidacyCheck:          (&testCandidacy{ok: c.candidate}).check,
+			StepDownOnCandidacyLoss: c.stepDown,
+			Logger:                  &testLogger{},
+		})
+		if c.leader {
+			node.setLeader("test-node-1")
+		} else {
+			node.setLeader("test-node-2")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		withdrawn := false
+		node.watchCandidacy(ctx, 10*time.Millisecond, func() {
+			withdrawn = true
+		})
+		cancel()
+
+		assert.Equal(t, c.withdrawn, withdrawn, c.description)
+	}
+}
+
+func TestElectorNode_run_candidacy(t *testing.T) {
+	candidacy := &testCandidacy{}
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:                      "test-node-1",
+		Name:                    "test-election",
+		Namespace:               "test-ns",
+		LockNamespace:           "test-ns",
+		PodName:                 "test-pod",
+		LockType:                resourcelock.LeasesResourceLock,
+		TTL:                     1 * time.Second,
+		Client:                  client,
+		CandidacyCheck:          candidacy.check,
+		StepDownOnCandidacyLoss: true,
+		Logger:                  &testLogger{},
+		// The lock is released when stepping down even if it is kept on
+		// shutdown.
+		KeepLeaseOnShutdown: true,
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.run()
+	}()
+
+	holder := func() string {
+		lease, err := client.CoordinationV1().Leases("test-ns").Get("test-election", metav1.GetOptions{})
+		if err != nil || lease.Spec.HolderIdentity == nil {
+			return ""
+		}
+		return *lease.Spec.HolderIdentity
+	}
+
+	// The node does not stand for election until it is a candidate.
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, "", holder())
+
+	candidacy.set(true)
+	waitFor(t, 5*time.Second, func() bool {
+		return holder() == "test-node-1"
+	})
+
+	// Once it is no longer a candidate, the leader steps down and releases
+	// the lease.
+	candidacy.set(false)
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node did not step down")
+	}
+	assert.Equal(t, "", holder())
+	node.cancel()
+}
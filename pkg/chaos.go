@@ -0,0 +1,197 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// EnvChaosAllowed is the environment variable which must be set to "true"
+// for the chaos hooks to be enabled. It guards against enabling them by
+// accident outside of a staging environment.
+const EnvChaosAllowed = "ELECTOR_CHAOS_ALLOWED"
+
+// errChaosInjected is the error injected by the chaos hooks.
+var errChaosInjected = errors.New("failure injected by chaos hooks")
+
+// faultInjector holds the failures injected by the chaos hooks, which are
+// consulted by the lock and Pod label patch paths. With nothing injected, it
+// has no effect.
+type faultInjector struct {
+	mu              sync.Mutex
+	renewalFailures int
+	patchesFailTill time.Time
+}
+
+// failRenewals injects failures into the next count renewals of the node's
+// lease, on top of any which are still pending.
+func (f *faultInjector) failRenewals(count int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.renewalFailures += count
+}
+
+// failPatches injects failures into the patches of the node's Pod label until
+// the given time.
+func (f *faultInjector) failPatches(until time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.patchesFailTill = until
+}
+
+// renewalFault gets the failure injected into a renewal of the node's lease,
+// if any. Each renewal failure is only injected once.
+func (f *faultInjector) renewalFault() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.renewalFailures <= 0 {
+		return nil
+	}
+	f.renewalFailures--
+	return fmt.Errorf("lease renewal failed: %w", errChaosInjected)
+}
+
+// patchFault gets the failure injected into a patch of the node's Pod label at
+// the given time, if any.
+func (f *faultInjector) patchFault(now time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !now.Before(f.patchesFailTill) {
+		return nil
+	}
+	return fmt.Errorf("pod label patch failed: %w", errChaosInjected)
+}
+
+// pendingRenewalFailures gets the number of renewal failures which are still
+// to be injected.
+func (f *faultInjector) pendingRenewalFailures() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.renewalFailures
+}
+
+// chaosLock wraps a resourcelock.Interface to inject failures into the
+// renewals of the node's lease, i.e. the updates of the lock record which
+// name the node as the holder.
+type chaosLock struct {
+	resourcelock.Interface
+
+	id     string
+	faults *faultInjector
+}
+
+// Update updates the lock record, unless a renewal failure is injected.
+func (l *chaosLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	if ler.HolderIdentity == l.id {
+		if err := l.faults.renewalFault(); err != nil {
+			return err
+		}
+	}
+	return l.Interface.Update(ler)
+}
+
+// checkChaos checks that the chaos hooks may be enabled. They are refused
+// unless the environment also allows them.
+func (node *ElectorNode) checkChaos() error {
+	if os.Getenv(EnvChaosAllowed) != "true" {
+		return fmt.Errorf("chaos hooks may only be enabled with %s=true set in the environment", EnvChaosAllowed)
+	}
+	node.log.Warningf("chaos hooks are enabled: failures may be injected into lease renewals and pod label patches")
+	return nil
+}
+
+// chaosLock wraps the lock to inject renewal failures, if the chaos hooks are
+// enabled. Otherwise, the lock is returned as is.
+func (node *ElectorNode) chaosLock(lock resourcelock.Interface) resourcelock.Interface {
+	if !node.config.Chaos {
+		return lock
+	}
+	return &chaosLock{
+		Interface: lock,
+		id:        node.config.ID,
+		faults:    node.chaos,
+	}
+}
+
+// patchFault gets the failure injected into a patch of the node's Pod label,
+// if the chaos hooks are enabled and one is injected.
+func (node *ElectorNode) patchFault() error {
+	if !node.config.Chaos {
+		return nil
+	}
+	return node.chaos.patchFault(node.clock.Now())
+}
+
+// requireChaos wraps a handler so it is only served while the chaos hooks are
+// enabled. Otherwise, the endpoint does not exist.
+func (node *ElectorNode) requireChaos(handler http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if !node.config.Chaos {
+			node.writeJSON(res, http.StatusNotFound, MessageResponse{
+				Message: "chaos hooks are disabled",
+			})
+			return
+		}
+		handler(res, req)
+	}
+}
+
+// httpChaosFailRenewals is the handler for the endpoint which injects
+// failures into the next renewals of the node's lease. The number of
+// failures is given by the 'count' query parameter.
+func (node *ElectorNode) httpChaosFailRenewals(res http.ResponseWriter, req *http.Request) {
+	count, err := strconv.Atoi(req.URL.Query().Get("count"))
+	if err != nil || count <= 0 {
+		node.writeJSON(res, http.StatusBadRequest, MessageResponse{
+			Message: "the 'count' query parameter must be a positive integer",
+		})
+		return
+	}
+
+	node.log.Warningf("chaos: failing the next %d lease renewals, requested by %s", count, req.RemoteAddr)
+	node.chaos.failRenewals(count)
+	node.writeJSON(res, http.StatusOK, MessageResponse{
+		Message: fmt.Sprintf("failing the next %d lease renewals", count),
+	})
+}
+
+// httpChaosFailPatches is the handler for the endpoint which injects failures
+// into the patches of the node's Pod label. How long they fail for is given
+// by the 'duration' query parameter.
+func (node *ElectorNode) httpChaosFailPatches(res http.ResponseWriter, req *http.Request) {
+	duration, err := time.ParseDuration(req.URL.Query().Get("duration"))
+	if err != nil || duration <= 0 {
+		node.writeJSON(res, http.StatusBadRequest, MessageResponse{
+			Message: "the 'duration' query parameter must be a positive duration, e.g. 30s",
+		})
+		return
+	}
+
+	node.log.Warningf("chaos: failing pod label patches for %v, requested by %s", duration, req.RemoteAddr)
+	node.chaos.failPatches(node.clock.Now().Add(duration))
+	node.writeJSON(res, http.StatusOK, MessageResponse{
+		Message: fmt.Sprintf("failing pod label patches for %v", duration),
+	})
+}
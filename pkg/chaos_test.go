@@ -0,0 +1,231 @@
+package pkg
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestElectorNode_checkConfig_chaos(t *testing.T) {
+	cases := []struct {
+		description string
+		chaos       bool
+		env         string
+		err         string
+	}{
+		{
+			description: "chaos disabled",
+			env:         "true",
+		},
+		{
+			description: "chaos allowed",
+			chaos:       true,
+			env:         "true",
+		},
+		{
+			description: "chaos not allowed",
+			chaos:       true,
+			err:         "chaos hooks may only be enabled with ELECTOR_CHAOS_ALLOWED=true set in the environment",
+		},
+		{
+			description: "chaos allowed with another value",
+			chaos:       true,
+			env:         "yes",
+			err:         "chaos hooks may only be enabled with ELECTOR_CHAOS_ALLOWED=true set in the environment",
+		},
+	}
+
+	defer os.Unsetenv(EnvChaosAllowed)
+	for _, c := range cases {
+		assert.NoError(t, os.Setenv(EnvChaosAllowed, c.env), c.description)
+		node := NewElectorNode(&ElectorConfig{
+			Name:    "test-election",
+			PodName: "test-pod",
+			Chaos:   c.chaos,
+			Logger:  &testLogger{},
+		})
+
+		err := node.checkConfig()
+		if c.err == "" {
+			assert.NoError(t, err, c.description)
+		} else {
+			assert.EqualError(t, err, c.err, c.description)
+		}
+	}
+}
+
+func TestElectorNode_httpChaos(t *testing.T) {
+	cases := []struct {
+		description string
+		chaos       bool
+		path        string
+		status      int
+		message     string
+	}{
+		{
+			description: "chaos disabled",
+			path:        "/chaos/fail-renewals?count=3",
+			status:      http.StatusNotFound,
+			message:     "chaos hooks are disabled",
+		},
+		{
+			description: "fail renewals",
+			chaos:       true,
+			path:        "/chaos/fail-renewals?count=3",
+			status:      http.StatusOK,
+			message:     "failing the next 3 lease renewals",
+		},
+		{
+			description: "fail renewals without a count",
+			chaos:       true,
+			path:        "/chaos/fail-renewals",
+			status:      http.StatusBadRequest,
+			message:     "the 'count' query parameter must be a positive integer",
+		},
+		{
+			description: "fail renewals with a negative count",
+			chaos:       true,
+			path:        "/chaos/fail-renewals?count=-1",
+			status:      http.StatusBadRequest,
+			message:     "the 'count' query parameter must be a positive integer",
+		},
+		{
+			description: "fail patches",
+			chaos:       true,
+			path:        "/chaos/fail-patches?duration=30s",
+			status:      http.StatusOK,
+			message:     "failing pod label patches for 30s",
+		},
+		{
+			description: "fail patches with an invalid duration",
+			chaos:       true,
+			path:        "/chaos/fail-patches?duration=30",
+			status:      http.StatusBadRequest,
+			message:     "the 'duration' query parameter must be a positive duration, e.g. 30s",
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:        "test-node-1",
+			AuthToken: "secret",
+			Chaos:     c.chaos,
+			Logger:    &testLogger{},
+		})
+
+		w := postAuthorized(node, c.path)
+		assert.Equal(t, c.status, w.Code, c.description)
+		assert.JSONEq(t, `{"message":"`+c.message+`"}`, w.Body.String(), c.description)
+	}
+}
+
+func TestChaosLock_Update(t *testing.T) {
+	faults := &faultInjector{}
+	lock := &chaosLock{
+		Interface: &fakeLock{},
+		id:        "test-node-1",
+		faults:    faults,
+	}
+	faults.failRenewals(2)
+
+	// Only the renewals of the node's own lease fail.
+	assert.NoError(t, lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2"}))
+	for i := 0; i < 2; i++ {
+		err := lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+		assert.True(t, errors.Is(err, errChaosInjected))
+	}
+	assert.NoError(t, lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+	assert.Zero(t, faults.pendingRenewalFailures())
+}
+
+func TestElectorNode_chaos_failRenewals(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		PodName:       "test-pod",
+		LockType:      resourcelock.LeasesResourceLock,
+		TTL:           1 * time.Second,
+		Client:        client,
+		Logger:        log,
+		AuthToken:     "secret",
+		Chaos:         true,
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.runUntilError()
+	}()
+	waitFor(t, 5*time.Second, func() bool {
+		return node.IsLeader()
+	})
+
+	// Failing more renewals than fit in the renew deadline makes the leader
+	// step down.
+	w := postAuthorized(node, "/chaos/fail-renewals?count=6")
+	assert.Equal(t, http.StatusOK, w.Code)
+	waitFor(t, 5*time.Second, func() bool {
+		return strings.Contains(log.String(), "stepping down as leader")
+	})
+
+	// Once the failures are used up, the node leads again.
+	waitFor(t, 10*time.Second, func() bool {
+		return strings.Contains(log.String(), "started leading (epoch 2)")
+	})
+	assert.True(t, node.IsLeader())
+	assert.Zero(t, node.chaos.pendingRenewalFailures())
+
+	node.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node did not stop")
+	}
+}
+
+func TestElectorNode_chaos_failPatches(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:        "test-node-1",
+		Namespace: "test-ns",
+		PodName:   "test-pod",
+		AuthToken: "secret",
+		Chaos:     true,
+		Logger:    log,
+	})
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	node.clock = clk
+
+	podLabel := func() string {
+		pod, err := client.CoreV1().Pods("test-ns").Get("test-pod", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return pod.Labels[PodLabelKey]
+	}
+
+	w := postAuthorized(node, "/chaos/fail-patches?duration=30s")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// While the patches fail, the status is not published.
+	p := node.newPublishers(client)[0]
+	p.publishNow(StatusLeader)
+	assert.Equal(t, "", podLabel())
+	assert.Contains(t, log.String(), "failed to publish leader status (pod label): pod label patch failed: failure injected by chaos hooks")
+
+	// Once the duration has passed, the status is published again.
+	clk.Step(30 * time.Second)
+	p.publishNow(StatusLeader)
+	assert.Equal(t, StatusLeader, podLabel())
+}
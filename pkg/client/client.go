@@ -0,0 +1,358 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package client provides a client for the HTTP API of an elector node.
+//
+// The client decodes responses into the same types which the elector's HTTP
+// handlers encode, so it can not drift from the API it consumes.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vapor-ware/k8s-elector/pkg"
+)
+
+const (
+	// DefaultRetries is the default number of times a failed request is
+	// retried.
+	DefaultRetries = 3
+
+	// DefaultBackoff is the default delay before the first retry of a failed
+	// request. The delay doubles for each further retry.
+	DefaultBackoff = 250 * time.Millisecond
+
+	// DefaultTimeout is the default timeout for a request. It does not apply
+	// to the stream opened by Watch.
+	DefaultTimeout = 5 * time.Second
+)
+
+// maxBackoff caps the delay between retries.
+const maxBackoff = 5 * time.Second
+
+// StatusError is returned when the elector responds with an unexpected
+// status code.
+type StatusError struct {
+	// StatusCode is the status code of the response.
+	StatusCode int
+
+	// Message is the message of the response, if it has one.
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("unexpected response from elector: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("unexpected response from elector: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// temporary checks whether the status code may succeed on a retry.
+func (e *StatusError) temporary() bool {
+	switch e.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// WithHTTPClient sets the HTTP client used to make requests, e.g. to connect
+// to an elector over a unix socket. The HTTP client should not set a timeout,
+// since it would also end the stream opened by Watch; use WithTimeout instead.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.http = client
+	}
+}
+
+// WithAuthToken sets the auth token sent as a bearer token with each request,
+// for the endpoints of an elector configured with an auth token.
+func WithAuthToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRetries sets the number of times a failed request is retried, and the
+// delay before the first retry. The delay doubles for each further retry.
+// Requests are retried when the elector can not be reached, or responds with
+// a 502, 503, or 504 status.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithTimeout sets the timeout for each request.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// Client is a client for the HTTP API of an elector node.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	token   string
+	retries int
+	backoff time.Duration
+	timeout time.Duration
+}
+
+// New creates a client for the elector HTTP API at the base URL. The base URL
+// may also be given as a host:port, e.g. localhost:5001.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid elector URL: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid elector URL: unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("invalid elector URL: no host")
+	}
+
+	c := &Client{
+		baseURL: strings.TrimSuffix(u.String(), "/"),
+		http:    &http.Client{},
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+		timeout: DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Leader gets the leadership status of the elector node.
+func (c *Client) Leader(ctx context.Context) (pkg.LeaderInfo, error) {
+	var info pkg.LeaderInfo
+	err := c.getJSON(ctx, "/", &info)
+	return info, err
+}
+
+// IsLeader checks whether the elector node is the leader.
+func (c *Client) IsLeader(ctx context.Context) (bool, error) {
+	info, err := c.Leader(ctx)
+	if err != nil {
+		return false, err
+	}
+	return info.IsLeader, nil
+}
+
+// History gets the most recent leader transitions observed by the elector
+// node, oldest first.
+func (c *Client) History(ctx context.Context) ([]pkg.LeaderTransition, error) {
+	var info pkg.HistoryInfo
+	if err := c.getJSON(ctx, "/history", &info); err != nil {
+		return nil, err
+	}
+	return info.Transitions, nil
+}
+
+// Watch watches the leadership status of the elector node. The node's current
+// status is delivered first, followed by an event for each leadership
+// transition.
+//
+// If the stream is interrupted, it is re-opened, with retries, and the
+// node's current status is delivered again. The channel is closed once the
+// context is done, or if the stream can not be re-opened.
+func (c *Client) Watch(ctx context.Context) (<-chan pkg.LeadershipEvent, error) {
+	body, err := c.openWatch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan pkg.LeadershipEvent)
+	go func() {
+		defer close(events)
+		for {
+			readEvents(ctx, body, events)
+			body.Close()
+			if ctx.Err() != nil {
+				return
+			}
+
+			body, err = c.openWatch(ctx)
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// openWatch opens the stream of leadership events, with retries.
+func (c *Client) openWatch(ctx context.Context) (io.ReadCloser, error) {
+	var body io.ReadCloser
+	err := c.retry(ctx, func() error {
+		resp, err := c.do(ctx, "/watch")
+		if err != nil {
+			return err
+		}
+		body = resp.Body
+		return nil
+	})
+	return body, err
+}
+
+// readEvents reads server-sent leadership events from the stream and
+// delivers them to the channel, until the stream ends or the context is
+// done. Events which can not be decoded are skipped.
+func readEvents(ctx context.Context, r io.Reader, events chan<- pkg.LeadershipEvent) {
+	var data bytes.Buffer
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			var event pkg.LeadershipEvent
+			err := json.Unmarshal(data.Bytes(), &event)
+			data.Reset()
+			if err != nil {
+				continue
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// getJSON gets the path and decodes the JSON response into v, with retries.
+func (c *Client) getJSON(ctx context.Context, path string, v interface{}) error {
+	return c.retry(ctx, func() error {
+		reqCtx := ctx
+		if c.timeout > 0 {
+			var cancel context.CancelFunc
+			reqCtx, cancel = context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+		}
+
+		resp, err := c.do(reqCtx, path)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return permanent{fmt.Errorf("invalid response from elector: %v", err)}
+		}
+		return nil
+	})
+}
+
+// do makes a GET request for the path. Responses with a status other than
+// 200 are returned as a StatusError.
+func (c *Client) do(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, permanent{err}
+	}
+	req = req.WithContext(ctx)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		statusErr := &StatusError{StatusCode: resp.StatusCode}
+		var msg pkg.MessageResponse
+		if json.NewDecoder(resp.Body).Decode(&msg) == nil {
+			statusErr.Message = msg.Message
+		}
+		if statusErr.temporary() {
+			return nil, statusErr
+		}
+		return nil, permanent{statusErr}
+	}
+	return resp, nil
+}
+
+// permanent wraps an error which is not retried.
+type permanent struct {
+	err error
+}
+
+func (p permanent) Error() string {
+	return p.err.Error()
+}
+
+// retry calls fn until it succeeds, fails with a permanent error, or the
+// retries are used up, backing off between attempts. The last error is
+// returned.
+func (c *Client) retry(ctx context.Context, fn func() error) error {
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		var p permanent
+		if errors.As(err, &p) {
+			return p.err
+		}
+		if attempt >= c.retries || ctx.Err() != nil {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vapor-ware/k8s-elector/pkg"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// discardLogger is a pkg.Logger which discards the elector's logs.
+type discardLogger struct{}
+
+func (discardLogger) Infof(format string, args ...interface{})    {}
+func (discardLogger) Warningf(format string, args ...interface{}) {}
+func (discardLogger) Errorf(format string, args ...interface{})   {}
+
+// startNode runs a single elector node, serving its HTTP API on a random
+// port, and waits for it to lead. The returned function stops the node.
+func startNode(t *testing.T) (*pkg.ElectorNode, func()) {
+	node := pkg.NewElectorNode(&pkg.ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		PodName:       "test-pod",
+		Address:       "127.0.0.1:0",
+		LockType:      resourcelock.LeasesResourceLock,
+		TTL:           1 * time.Second,
+		Client: fake.NewSimpleClientset(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"},
+		}),
+		Logger:     discardLogger{},
+		SingleNode: true,
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.Run()
+	}()
+	waitFor(t, func() bool {
+		return node.HTTPAddr() != "" && node.IsLeader()
+	})
+
+	return node, func() {
+		node.Stop()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "node did not stop")
+		}
+	}
+}
+
+// waitFor waits for the condition to be met, failing the test if it is not
+// met within a few seconds.
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Fail(t, "timed out waiting for condition")
+}
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		description string
+		baseURL     string
+		expected    string
+	}{
+		{
+			description: "url",
+			baseURL:     "http://localhost:5001",
+			expected:    "http://localhost:5001",
+		},
+		{
+			description: "url with trailing slash",
+			baseURL:     "https://elector.example.com/",
+			expected:    "https://elector.example.com",
+		},
+		{
+			description: "host and port",
+			baseURL:     "localhost:5001",
+			expected:    "http://localhost:5001",
+		},
+	}
+
+	for _, c := range cases {
+		client, err := New(c.baseURL)
+		if assert.NoError(t, err, c.description) {
+			assert.Equal(t, c.expected, client.baseURL, c.description)
+		}
+	}
+}
+
+func TestNew_error(t *testing.T) {
+	cases := []struct {
+		description string
+		baseURL     string
+	}{
+		{
+			description: "unsupported scheme",
+			baseURL:     "ftp://localhost:5001",
+		},
+		{
+			description: "no host",
+			baseURL:     "http://",
+		},
+	}
+
+	for _, c := range cases {
+		_, err := New(c.baseURL)
+		assert.Error(t, err, c.description)
+	}
+}
+
+func TestClient_Leader(t *testing.T) {
+	node, stop := startNode(t)
+	defer stop()
+
+	client, err := New(node.HTTPAddr())
+	assert.NoError(t, err)
+
+	info, err := client.Leader(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "test-node-1", info.Node)
+	assert.Equal(t, "test-node-1", info.Leader)
+	assert.True(t, info.IsLeader)
+
+	isLeader, err := client.IsLeader(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, isLeader)
+}
+
+func TestClient_History(t *testing.T) {
+	node, stop := startNode(t)
+	defer stop()
+
+	client, err := New(node.HTTPAddr())
+	assert.NoError(t, err)
+
+	history, err := client.History(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, "test-node-1", history[0].Leader)
+		assert.Equal(t, "", history[0].Previous)
+	}
+}
+
+func TestClient_Watch(t *testing.T) {
+	node, stop := startNode(t)
+
+	client, err := New(node.HTTPAddr(), WithRetries(1, 10*time.Millisecond))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events, err := client.Watch(ctx)
+	if !assert.NoError(t, err) {
+		stop()
+		return
+	}
+
+	// The current status is sent when the stream opens.
+	event := <-events
+	assert.Equal(t, pkg.StatusLeader, event.Status)
+	assert.Equal(t, "test-node-1", event.Node)
+	assert.Equal(t, "test-node-1", event.Leader)
+
+	// Stopping the node steps it down before its HTTP server stops.
+	go stop()
+	event = <-events
+	assert.Equal(t, pkg.StatusStandby, event.Status)
+
+	// The stream can not be re-opened once the node has stopped.
+	for range events {
+	}
+	assert.NoError(t, ctx.Err())
+}
+
+func TestClient_Watch_cancel(t *testing.T) {
+	node, stop := startNode(t)
+	defer stop()
+
+	client, err := New(node.HTTPAddr())
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := client.Watch(ctx)
+	assert.NoError(t, err)
+	<-events
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "watch did not stop")
+	}
+}
+
+func TestClient_authToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(pkg.MessageResponse{Message: "unauthorized"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(pkg.LeaderInfo{Node: "test-node-1"})
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL)
+	assert.NoError(t, err)
+	_, err = client.Leader(context.Background())
+	if assert.IsType(t, &StatusError{}, err) {
+		assert.Equal(t, http.StatusUnauthorized, err.(*StatusError).StatusCode)
+		assert.Equal(t, "unauthorized", err.(*StatusError).Message)
+	}
+
+	client, err = New(server.URL, WithAuthToken("secret"))
+	assert.NoError(t, err)
+	info, err := client.Leader(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "test-node-1", info.Node)
+}
+
+func TestClient_retry(t *testing.T) {
+	cases := []struct {
+		description string
+		status      int
+		retries     int
+		requests    int32
+		ok          bool
+	}{
+		{
+			description: "retried until it succeeds",
+			status:      http.StatusServiceUnavailable,
+			retries:     3,
+			requests:    3,
+			ok:          true,
+		},
+		{
+			description: "retries used up",
+			status:      http.StatusServiceUnavailable,
+			retries:     1,
+			requests:    2,
+			ok:          false,
+		},
+		{
+			description: "not retried",
+			status:      http.StatusNotFound,
+			retries:     3,
+			requests:    1,
+			ok:          false,
+		},
+	}
+
+	for _, c := range cases {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) < 3 {
+				w.WriteHeader(c.status)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(pkg.LeaderInfo{Node: "test-node-1"})
+		}))
+
+		client, err := New(server.URL, WithRetries(c.retries, time.Millisecond))
+		assert.NoError(t, err, c.description)
+		_, err = client.Leader(context.Background())
+		if c.ok {
+			assert.NoError(t, err, c.description)
+		} else {
+			assert.Error(t, err, c.description)
+		}
+		assert.Equal(t, c.requests, atomic.LoadInt32(&requests), c.description)
+		server.Close()
+	}
+}
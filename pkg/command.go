@@ -0,0 +1,136 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// EventElected is the event name passed to the on-elected command.
+	EventElected = "elected"
+
+	// EventDemoted is the event name passed to the on-demoted command.
+	EventDemoted = "demoted"
+)
+
+// Environment variables which are set for the on-elected/on-demoted
+// commands so they have context on the election they are run for.
+const (
+	EnvCommandEvent    = "ELECTOR_EVENT"
+	EnvCommandLeader   = "ELECTOR_LEADER"
+	EnvCommandElection = "ELECTOR_ELECTION"
+	EnvCommandRole     = "ELECTOR_ROLE"
+	EnvCommandNode     = "ELECTOR_NODE"
+)
+
+// commandEnv builds the environment for an on-elected/on-demoted command.
+//
+// The command inherits the elector's environment. The user-specified
+// CommandEnv values are added on top of that, and the elector-provided
+// values are set last so they can not be overridden.
+func (node *ElectorNode) commandEnv(event string) []string {
+	env := os.Environ()
+
+	// Sort the keys so the generated environment is deterministic.
+	keys := make([]string, 0, len(node.config.CommandEnv))
+	for k := range node.config.CommandEnv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, fmt.Sprintf("%s=%s", k, node.config.CommandEnv[k]))
+	}
+
+	return append(
+		env,
+		fmt.Sprintf("%s=%s", EnvCommandEvent, event),
+		fmt.Sprintf("%s=%s", EnvCommandLeader, node.leader()),
+		fmt.Sprintf("%s=%s", EnvCommandElection, node.config.Name),
+		fmt.Sprintf("%s=%s", EnvCommandRole, node.config.role()),
+		fmt.Sprintf("%s=%s", EnvCommandNode, node.config.ID),
+	)
+}
+
+// runCommand runs the given command for an election event. The command is
+// split on whitespace into the executable and its arguments; it is not run
+// in a shell.
+//
+// If the timeout is set, the command is killed once it has run for that long,
+// along with any processes it started, and an error is returned. Otherwise,
+// the command may run indefinitely.
+//
+// The command output is logged once it completes.
+func (node *ElectorNode) runCommand(command, event string, timeout time.Duration) error {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return errors.New("no command specified")
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// The command is run in its own process group, so the processes it
+	// starts can be killed along with it.
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = node.commandEnv(event)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	node.log.Infof("running %s command: %s", event, command)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// The context only kills the command itself. Its children may still hold
+	// the output open, which would keep the command from completing, so the
+	// whole process group is killed.
+	exited := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-exited:
+		}
+	}()
+	err := cmd.Wait()
+	close(exited)
+
+	if out.Len() > 0 {
+		node.log.Infof("%s command output:\n%s", event, out.Bytes())
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		node.log.Warningf("%s command timed out after %v and was killed", event, timeout)
+		return fmt.Errorf("%s command timed out after %v", event, timeout)
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElectorNode_commandEnv(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:   "test-node-1",
+		Name: "test-election",
+		CommandEnv: map[string]string{
+			"FOO": "bar",
+		},
+	})
+	node.currentLeader = "test-node-2"
+
+	env := node.commandEnv(EventElected)
+
+	assert.Contains(t, env, "FOO=bar")
+	assert.Contains(t, env, "ELECTOR_EVENT=elected")
+	assert.Contains(t, env, "ELECTOR_LEADER=test-node-2")
+	assert.Contains(t, env, "ELECTOR_ELECTION=test-election")
+	assert.Contains(t, env, "ELECTOR_ROLE=test-election")
+	assert.Contains(t, env, "ELECTOR_NODE=test-node-1")
+}
+
+func TestElectorNode_commandEnv_noOverride(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+		CommandEnv: map[string]string{
+			"ELECTOR_NODE": "other",
+		},
+	})
+
+	env := node.commandEnv(EventDemoted)
+
+	// The elector-provided value is set last, so it takes precedence.
+	assert.Equal(t, "ELECTOR_NODE=test-node-1", env[len(env)-1])
+}
+
+func TestElectorNode_runCommand(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{})
+
+	err := node.runCommand("true", EventElected, 0)
+	assert.NoError(t, err)
+}
+
+func TestElectorNode_runCommand_error(t *testing.T) {
+	cases := []struct {
+		description string
+		command     string
+	}{
+		{
+			description: "empty command",
+			command:     "",
+		},
+		{
+			description: "command exits non-zero",
+			command:     "false",
+		},
+		{
+			description: "command does not exist",
+			command:     "not-a-real-command-123",
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{})
+
+		err := node.runCommand(c.command, EventElected, 0)
+		assert.Error(t, err, c.description)
+	}
+}
+
+func TestElectorNode_runCommand_timeout(t *testing.T) {
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		Logger: log,
+	})
+
+	start := time.Now()
+	err := node.runCommand("sleep 10", EventDemoted, 100*time.Millisecond)
+	assert.EqualError(t, err, "demoted command timed out after 100ms")
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assert.Contains(t, log.String(), "WARNING demoted command timed out after 100ms and was killed")
+}
+
+func TestElectorNode_runCommand_timeoutNotReached(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		Logger: &testLogger{},
+	})
+
+	assert.NoError(t, node.runCommand("true", EventDemoted, 5*time.Second))
+	assert.Error(t, node.runCommand("false", EventDemoted, 5*time.Second))
+}
+
+func TestElectorNode_runCommand_timeoutKillsProcessGroup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elector-command")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// The script starts a child which would outlive it, holding its output
+	// open, so the command would not complete unless the child is killed too.
+	script := filepath.Join(dir, "demoted.sh")
+	err = ioutil.WriteFile(script, []byte("#!/bin/sh\nsleep 30 &\nwait\n"), 0755)
+	assert.NoError(t, err)
+
+	node := NewElectorNode(&ElectorConfig{
+		Logger: &testLogger{},
+	})
+
+	start := time.Now()
+	err = node.runCommand(script, EventDemoted, 500*time.Millisecond)
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
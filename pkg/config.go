@@ -17,9 +17,14 @@
 package pkg
 
 import (
+	"strings"
 	"time"
 
-	"k8s.io/klog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 )
 
 // ElectorConfig contains the configuration values for the elector node.
@@ -29,59 +34,565 @@ type ElectorConfig struct {
 	// not set, an HTTP endpoint will not be set up.
 	Address string
 
+	// AccessLog enables logging of HTTP requests. Each request is logged as a
+	// JSON entry containing the method, path, remote address, response status,
+	// and latency.
+	AccessLog bool
+
+	// InvertLeaderStatus inverts the status codes of the '/leader/status' HTTP
+	// endpoint, so it returns 200 OK when the node is not the leader and 503
+	// Service Unavailable when it is. This allows load balancers to route to
+	// standby nodes instead of the leader.
+	InvertLeaderStatus bool
+
+	// EventIDHeader is the name of the header which carries the ID of the
+	// node's last leadership event, on the responses of the HTTP endpoints and
+	// on the requests which deliver leadership events to subscriptions. If not
+	// set, DefaultEventIDHeader is used.
+	EventIDHeader string
+
+	// MaxWatchers is the maximum number of streams which may watch the node's
+	// leadership transitions at once. Once reached, new streams are rejected.
+	// If not set, DefaultMaxWatchers is used.
+	MaxWatchers int
+
+	// WatchWriteTimeout is how long a stream watching the node's leadership
+	// transitions may fall behind, with its buffer of events full, before it
+	// is closed. If not set, DefaultWatchWriteTimeout is used.
+	WatchWriteTimeout time.Duration
+
+	// AuthToken is the bearer token which clients must provide to use the
+	// HTTP endpoints which change the elector's state (e.g. /shutdown). If not
+	// set, those endpoints are disabled.
+	AuthToken string
+
 	// The ID of the elector node participating in the election. This is required
 	// for an election and must be unique. If not specified, the elector will try
 	// using the HOSTNAME as its ID.
 	ID string
 
-	// PodName is the name of the Pod which the elector is running in. This is
-	// found via the ELECTOR_POD_NAME environment variable. If not set, this defaults
-	// to the hostname.
+	// ResolveIDCollisions specifies whether the default ID is made unique to
+	// the Pod. Pods may share a hostname, e.g. with hostNetwork, which gives
+	// them the same default ID. If set and the Pod name differs from the
+	// hostname, the ID defaults to "<hostname>-<pod name>" instead. It has no
+	// effect if the ID is set.
+	ResolveIDCollisions bool
+
+	// StructuredID specifies whether the default ID is a structured ID, as
+	// "<hostname>/<pod IP>/<node name>". Other participants parse it into the
+	// metadata of the leader, so consumers can get the leader's Pod IP and
+	// node without looking up its Pod. The Pod IP is found via the
+	// ELECTOR_POD_IP environment variable, if PodIP is not set. It has no
+	// effect if the ID is set, and takes precedence over ResolveIDCollisions,
+	// since the Pod IP already tells Pods sharing a hostname apart.
+	StructuredID bool
+
+	// PodIP is the IP of the Pod which the elector is running in, used in its
+	// structured ID. If not set, this is found via the ELECTOR_POD_IP
+	// environment variable.
+	PodIP string
+
+	// PodName is the name of the Pod which the elector is running in. If not set,
+	// this is found via the ELECTOR_POD_NAME environment variable, falling back to
+	// the hostname.
 	PodName string
 
+	// NodeName is the name of the Kubernetes node which the elector's Pod is
+	// scheduled on. It is reported in the leader info and as a label of the
+	// info metric, so leadership can be correlated with the node it runs on.
+	// If not set, this is found via the ELECTOR_NODE_NAME environment
+	// variable; if that is not set either, it is not reported.
+	NodeName string
+
+	// PodCache serves reads of the node's Pod from an informer which watches
+	// it, rather than with a request for each read. Updates to the Pod are
+	// noticed, so a Pod label changed outside of the elector is restored. If
+	// the Pod can not be watched, e.g. because the node is not permitted to
+	// watch Pods, it is read directly instead. It has no effect if the Pod
+	// label is not published.
+	PodCache bool
+
+	// RespectForeignLabels stops the elector from overwriting a Pod status
+	// label which was set by something else, e.g. another controller using the
+	// same label key. The elector marks the label as its own with the
+	// PodLabelOwnerAnnotationKey annotation, so a label with a value other
+	// than the one it recorded is foreign. Such a label is never patched or
+	// restored, and publishing the status to it fails. Without the Pod cache,
+	// the Pod is read before each patch to check it. If not set, a foreign
+	// label is overwritten with a warning.
+	RespectForeignLabels bool
+
+	// External runs the elector outside of Kubernetes, e.g. on a developer's
+	// machine against a remote cluster with a KubeConfig. The Pod-coupled
+	// features (the Pod status label and role annotation, Pod name detection,
+	// and the termination message) are disabled, the ID defaults to
+	// "<user>@<hostname>", and the namespace may come from the KubeConfig's
+	// current context. DetectExternal can be used to set this.
+	External bool
+
+	// PureElection runs the election without any side effects on the Pod or
+	// external systems: the Pod status label, subscriptions, the status
+	// object, the on-elected and on-demoted commands, outage and history
+	// records, and the termination message are disabled. Only the election
+	// and its HTTP and metrics reporting remain, so the node does not need
+	// permission to patch its Pod.
+	PureElection bool
+
 	// KubeConfig is the path to the kubeconfig file to use for setting up the
 	// elector node's Kubernetes client. If no kubeconfig is specified, the node
-	// will default to using in-cluster configuration.
+	// will default to using in-cluster configuration. If the base64-encoded
+	// content of a kubeconfig is set in the ELECTOR_KUBECONFIG_DATA environment
+	// variable, it takes precedence over the file.
 	KubeConfig string
 
+	// ClientMaxIdleConns is the maximum number of idle (keep-alive) connections
+	// kept in the connection pool of the node's Kubernetes client, across all
+	// hosts. If not set, DefaultClientMaxIdleConns is used.
+	ClientMaxIdleConns int
+
+	// ClientMaxIdleConnsPerHost is the maximum number of idle (keep-alive)
+	// connections kept in the connection pool of the node's Kubernetes client
+	// for each host. If not set, a default scaled to GOMAXPROCS is used, so
+	// the pool keeps up with the client's concurrency.
+	ClientMaxIdleConnsPerHost int
+
 	// LockType specifies the kind of Kubernetes object to use as the lock mechanism
 	// to determine node leadership. If not specified, the node will use "leases"
 	// by default.
 	//
-	// The valid LockTypes are: "endpoints", "configmaps", and "leases".
+	// The valid LockTypes are: "endpoints", "configmaps", "leases", and
+	// "dynamic". The "dynamic" lock type uses an annotation on an existing
+	// object of the LockResource as the lock, rather than a built-in lock
+	// object.
 	LockType string
 
+	// LockResource is the resource of the object used as the election lock
+	// when the LockType is "dynamic". The object, named after the election,
+	// must already exist in the LockNamespace; it is never created or deleted
+	// by the elector. This is required for the "dynamic" lock type.
+	LockResource schema.GroupVersionResource
+
+	// LockTypeMigrateFrom is the lock type the election is being migrated
+	// from, if any. While migrating, the election uses a multi-lock which
+	// reads the lock record from the object of the old lock type and keeps
+	// the objects of both lock types up to date, so nodes using either lock
+	// type agree on the leader while the migration rolls out. Only migrating
+	// from "configmaps" or "endpoints" to "leases" is supported.
+	LockTypeMigrateFrom string
+
 	// The Name of the election. The election name gets used as the name for the
 	// Kubernetes object used as the election lock. This is required by the node
 	// to join or create an election.
 	Name string
 
-	// The Namespace in Kubernetes to run the election in. The Kubernetes object
-	// used as the election lock will be created in this namespace. If not specified,
-	// "default" is used.
+	// ElectionNameTemplate is a text/template for the name of the election
+	// loop, which identifies the node's election in the logs of the leader
+	// election client. It is distinct from the lock identity, which is always
+	// the ID. The template may use the {{.Namespace}} (the LockNamespace),
+	// {{.Election}}, and {{.ID}} variables. If not set, the
+	// DefaultElectionNameTemplate is used.
+	ElectionNameTemplate string
+
+	// RoleName is a human-readable name for the role of the elector's
+	// application (e.g. "payments-primary"), so logs and dashboards can refer
+	// to it rather than to an identity such as a Pod name. It is purely
+	// cosmetic: it is included in the log prefix, the HTTP and subscription
+	// payloads, the Pod's role annotation, the leader history, and the info
+	// metric, but is never used for election decisions, so it need not be
+	// unique. If not set, the Name is used.
+	RoleName string
+
+	// The Namespace in Kubernetes to run the election in. This is the namespace
+	// of the elector's Pod. Unless a LockNamespace is specified, the Kubernetes
+	// object used as the election lock will be created in this namespace. If not
+	// specified, it is resolved from the ELECTOR_NAMESPACE environment variable,
+	// then the namespace of the Pod's service account, falling back to
+	// "default".
 	Namespace string
 
+	// LockNamespace is the Kubernetes namespace which the election lock object
+	// is created in. This allows the election lock to live in a different
+	// namespace than the elector's Pod. If not specified, the Namespace is used.
+	LockNamespace string
+
+	// VerifyNamespace specifies whether the node checks that its Namespace and
+	// LockNamespace exist when it starts, failing with a clear error if either
+	// does not exist or can not be read. This catches typos which would
+	// otherwise only surface as a failure to create the election lock. It
+	// requires permission to get namespaces.
+	VerifyNamespace bool
+
 	// The TTL for the election determines the lease duration (the time non-leader
 	// candidates will wait to force acquire leadership), the renew deadline (the
 	// duration that the acting master will retry refreshing leadership), and the
 	// retry period (the duration that elector nodes should wait between retry
 	// actions).
 	TTL time.Duration
+
+	// MaxClockSkew is the maximum tolerated skew between the elector node's clock
+	// and the API server's clock. When the node starts, it compares its clock to
+	// the Date header of an API server response and logs a warning if the skew
+	// exceeds this. The Date header has a resolution of one second, so this
+	// should be at least a second. If not set, the check is not run.
+	MaxClockSkew time.Duration
+
+	// SlowRenewalFraction is the fraction of the renew deadline which a renewal
+	// of the leader's lease may take before a warning is logged, as an early
+	// warning before the leader fails to renew in time. If not set,
+	// DefaultSlowRenewalFraction is used.
+	SlowRenewalFraction float64
+
+	// PublishDebounce is the debounce window for publishing the node's status
+	// (e.g. to its Pod label). Bursts of status changes within the window are
+	// collapsed into the final status, which prevents redundant API requests
+	// when leadership observations catch up in bursts. The node's internal
+	// state and HTTP endpoints are always updated immediately. If not set,
+	// status changes are published immediately.
+	PublishDebounce time.Duration
+
+	// StatusObjectResource and StatusObjectName identify an existing object,
+	// e.g. a custom resource, whose status the leader maintains with its
+	// leadership: the leader, when it became the leader, and the
+	// participants it has observed. The status is written with server-side
+	// apply via the dynamic client when leadership changes. The object is
+	// never created; if it does not exist, that is logged once and retried
+	// occasionally. If the name is not set, no status object is maintained.
+	StatusObjectResource schema.GroupVersionResource
+	StatusObjectName     string
+
+	// StatusObjectNamespace is the namespace of the status object. If not
+	// set, the Namespace is used.
+	StatusObjectNamespace string
+
+	// ExternalDNSService is the name of an existing Service in the Namespace
+	// which external-dns publishes a DNS record for. The leader maintains its
+	// external-dns hostname annotation, annotations naming the leader, and a
+	// selector on the leader status of the Pod label, with server-side apply.
+	// Standbys never touch the Service, and a leader which steps down leaves
+	// it for its successor. The Service is never created. If not set, no
+	// Service is maintained.
+	ExternalDNSService string
+
+	// ExternalDNSHostname is the DNS name the leader annotates the
+	// ExternalDNSService with, for external-dns to publish. If not set, the
+	// Service's own hostname annotation, if any, is left as is.
+	ExternalDNSHostname string
+
+	// Publishers are the names of the built-in publishers which publish the
+	// node's status (see PublisherNames): "pod-label", "subscriptions",
+	// "watch", "status-object", and "external-dns". Publishers which are not
+	// listed are not registered, so they never receive a status change. If not
+	// set, all of them run.
+	Publishers []string
+
+	// DisabledPublishers are the names of the built-in publishers which do
+	// not run, even if they are listed in Publishers.
+	DisabledPublishers []string
+
+	// ReconcileInterval is the interval on which the leader re-reads the
+	// election lock to verify it still holds it. If the lock is held by another
+	// identity, which indicates a split brain or an external takeover, the node
+	// logs a warning and steps down without releasing the lock, and the split
+	// brain is reported at the '/healthz' HTTP endpoint. If not set, the
+	// leadership claim is not reconciled.
+	ReconcileInterval time.Duration
+
+	// SingleNode specifies whether the elector node runs without an election,
+	// for deployments with a single replica. The node becomes the leader as
+	// soon as it starts, with the same side effects as being elected, and
+	// leads until it stops. No election lock is used, so leadership is not
+	// exclusive if more than one node runs. Recording outages and history
+	// are not supported.
+	SingleNode bool
+
+	// Chaos enables the chaos hooks, which inject failures into the renewals
+	// of the node's lease and the patches of its Pod label on request, at the
+	// '/chaos/fail-renewals' and '/chaos/fail-patches' HTTP endpoints. This is
+	// for resilience testing in staging environments only; it is refused
+	// unless the ELECTOR_CHAOS_ALLOWED environment variable is also "true".
+	Chaos bool
+
+	// StartupFailureGracePeriod is how long the elector node stays up after
+	// its election fails to start, e.g. because its kubeconfig is invalid,
+	// before Run returns the error. In the meantime, the node reports the
+	// "initializing" state and the error is reported at the '/healthz' HTTP
+	// endpoint, so the failure can be inspected. If not set, the error is
+	// returned immediately.
+	StartupFailureGracePeriod time.Duration
+
+	// StaleThreshold is how long the elector node may go without reading the
+	// election lock, e.g. during an outage of the API server, before the
+	// leader it reports at the '/' HTTP endpoint is flagged as stale. If not
+	// set, the lease duration is used.
+	StaleThreshold time.Duration
+
+	// StaleExpiry is how long the elector node may go without reading the
+	// election lock before it stops reporting the stale leader at the '/' HTTP
+	// endpoint altogether. It must be longer than the stale threshold. If not
+	// set, three times the stale threshold is used.
+	StaleExpiry time.Duration
+
+	// RepairCorruptLock specifies whether the elector node may overwrite an
+	// election lock record which can not be parsed, e.g. because the lock
+	// object's annotation was edited by hand. The record is only overwritten
+	// once it has gone unchanged for a lease duration, as it would have expired
+	// by then. If false, a corrupt record is reported but must be fixed by
+	// hand before a leader can be elected.
+	RepairCorruptLock bool
+
+	// CanaryElection is the name of a secondary election which the elector node
+	// participates in alongside the primary election. This allows coordinating
+	// a canary rollout in the same process as the primary workload. The canary
+	// election state is reported at the '/canary' HTTP endpoint; it does not
+	// update the Pod label or run commands. If not set, no canary election is
+	// run.
+	CanaryElection string
+
+	// PostDemotionCooldown is the duration the elector node waits after being
+	// demoted before it re-joins the election. This dampens oscillation of
+	// leadership between nodes. If not set, the node re-joins the election
+	// without a cooldown.
+	PostDemotionCooldown time.Duration
+
+	// CandidacyCheck gates whether the elector node stands for election. It is
+	// evaluated before the node joins the election and re-evaluated every retry
+	// period while the node stands. If it does not pass while the node is on
+	// standby, the node withdraws from the election until it passes again. If
+	// not set, the node always stands for election.
+	CandidacyCheck CandidacyCheck
+
+	// StepDownOnCandidacyLoss specifies whether the leader steps down when its
+	// CandidacyCheck stops passing. If false, the leader keeps leading.
+	StepDownOnCandidacyLoss bool
+
+	// SelectionPolicy is consulted each time the elector node tries to acquire
+	// leadership, and may delay the attempt to influence which candidate wins.
+	// If not set, the NoopSelectionPolicy is used, so the first candidate to
+	// see that the lock is free takes it.
+	SelectionPolicy SelectionPolicy
+
+	// AdoptExistingLeaseDuration specifies whether the elector node should use
+	// the lease duration of an existing election lock, if one exists, rather
+	// than the duration derived from its configured TTL. This is useful when
+	// rolling out a TTL change, as it prevents the new node from disrupting an
+	// in-progress election with mismatched timings.
+	AdoptExistingLeaseDuration bool
+
+	// KeepLeaseOnShutdown specifies whether the leader keeps the election lock
+	// when the elector node stops, rather than releasing it so another node can
+	// take over without waiting for the lease to expire. Keeping the lease
+	// through a quick restart of the process lets the node reclaim leadership
+	// immediately, at the cost of the election having no active leader until
+	// the lease expires if the node does not come back. The lock is still
+	// handed off when the node withdraws from the election for a pre-stop hook,
+	// when it is paused, or when it steps down on losing candidacy.
+	KeepLeaseOnShutdown bool
+
+	// RecordOutages enables recording windows of time in which the election had
+	// no leader. When a node acquires leadership after an outage longer than the
+	// OutageThreshold, it appends a record of the outage to the
+	// "<election>-outages" ConfigMap. Failing to record an outage never affects
+	// the election.
+	RecordOutages bool
+
+	// OutageThreshold is the minimum duration of a leaderless window for it to be
+	// recorded as an outage.
+	OutageThreshold time.Duration
+
+	// OutageRecordLimit is the maximum number of outages kept in the outages
+	// ConfigMap. Once the limit is reached, the oldest records are pruned. If
+	// not set, DefaultOutageRecordLimit is used.
+	OutageRecordLimit int
+
+	// RecordHistory enables recording a history of leader transitions to the
+	// "k8s-elector/history" annotation of the election lock object. Each time a
+	// node acquires leadership, it appends the transition to the annotation.
+	// Failing to record the history never affects the election.
+	RecordHistory bool
+
+	// HistoryLimit is the maximum number of leader transitions kept in the
+	// history annotation. Once the limit is reached, the oldest transitions are
+	// pruned. The annotation is also pruned to stay within a few kilobytes. It
+	// also limits the leader transitions which the node keeps in memory for
+	// its history endpoint. If not set, DefaultHistoryLimit is used.
+	HistoryLimit int
+
+	// TerminationMessagePath is the path of the file which the elector node
+	// writes its termination message to when it stops. The message describes
+	// whether the node was the leader and why it stopped. Kubernetes reads the
+	// termination message from DefaultTerminationMessagePath by default. If
+	// not set, no termination message is written.
+	TerminationMessagePath string
+
+	// Client is the Kubernetes client used by the elector node. If not set, a
+	// client is built from the KubeConfig (or in-cluster config).
+	Client kubernetes.Interface
+
+	// DynamicClient is the dynamic Kubernetes client used for the "dynamic"
+	// lock type. If not set, a client is built from the KubeConfig (or
+	// in-cluster config).
+	DynamicClient dynamic.Interface
+
+	// MetricsNamespace is prefixed to the names of the elector's metrics, e.g.
+	// a namespace of "myapp" gives "myapp_elector_is_leader". If not set, the
+	// metric names are not prefixed.
+	MetricsNamespace string
+
+	// MetricsIdentityLabel adds the identity of the node as a label to each of
+	// the elector's metrics. Since every node then has its own series, this is
+	// disabled by default; the identity is always reported by the
+	// elector_info metric.
+	MetricsIdentityLabel bool
+
+	// Registerer is the Prometheus registerer which the elector's metrics are
+	// registered with, for applications which already expose metrics. If it is
+	// also a Gatherer (e.g. a *prometheus.Registry), the metrics are served at
+	// /metrics as well. If not set, the metrics are registered with a registry
+	// of the elector's own, which is served at /metrics.
+	Registerer prometheus.Registerer
+
+	// Logger is the logger which the elector node writes its logs to. If not
+	// set, logs are written to klog.
+	Logger Logger
+
+	// Clock is the clock the elector node times its own work with, e.g. its
+	// status timestamps, debounced publishing and candidacy checks, so the
+	// node can be run on simulated time. The leader election client times the
+	// election itself with the real clock. If not set, the real clock is used.
+	Clock clock.Clock
+
+	// LogPrefix is prepended to all log messages emitted by the elector node.
+	// This makes it easier to attribute log lines when the logs of multiple
+	// electors are aggregated. If not set, no prefix is added.
+	LogPrefix string
+
+	// LogRolePrefix specifies whether every log message emitted by the elector
+	// node is prefixed with its current leadership role: [leader], [standby],
+	// or [lame-duck] while the leader is stepping down. This makes it possible
+	// to tell which lines came from the leader at the time when the logs of
+	// all replicas are merged.
+	LogRolePrefix bool
+
+	// LogRepeatEvery is the number of occurrences after which a repeated
+	// error message is logged again. When an error path of the elector node,
+	// e.g. updating the pod label or delivering to a subscription, keeps
+	// failing with the same message, the message is logged the first time,
+	// then only every LogRepeatEvery occurrences or every LogRepeatInterval,
+	// whichever comes first. If neither is set, repeats are not suppressed.
+	LogRepeatEvery int
+
+	// LogRepeatInterval is the interval after which a repeated error message
+	// is logged again. See LogRepeatEvery.
+	LogRepeatInterval time.Duration
+
+	// Build describes the build of the application running the elector node.
+	// It is reported at /version and by the elector_build_info metric. If not
+	// set, its fields are reported empty.
+	Build BuildInfo
+
+	// OnElected is a command which is run when the elector node becomes the
+	// leader. The command is split on whitespace and is not run in a shell.
+	// If not set, no command is run.
+	OnElected string
+
+	// OnDemoted is a command which is run when the elector node stops being
+	// the leader. The command is split on whitespace and is not run in a shell.
+	// If not set, no command is run.
+	OnDemoted string
+
+	// OnDemotedTimeout bounds how long the OnDemoted command may run, so a
+	// stuck command does not hold up a handoff or the shutdown of the node.
+	// Once it has run for this long, the command and any processes it started
+	// are killed. If not set, the command may run indefinitely.
+	OnDemotedTimeout time.Duration
+
+	// CommandEnv holds additional environment variables which are passed to
+	// the OnElected and OnDemoted commands, along with the ELECTOR_EVENT,
+	// ELECTOR_LEADER, ELECTOR_ELECTION, and ELECTOR_NODE variables which are
+	// always set by the elector.
+	CommandEnv map[string]string
+
+	// OnNoLeader is called when the elector node observes that the election
+	// lock has gone unheld beyond its lease duration, i.e. the leader has
+	// stopped renewing its lease and no node has acquired it yet. It is called
+	// with the ID of the last leader. If not set, leaderless windows are only
+	// logged and reported through the HTTP API.
+	OnNoLeader func(lastLeader string)
+
+	// OnNewLeader is called when the elector node observes a new leader,
+	// including itself, with the leader's ID and its metadata. The metadata
+	// is parsed from the ID if it is a structured ID (see StructuredID), and
+	// is nil otherwise.
+	OnNewLeader func(leader string, meta *LeaderMeta)
+}
+
+// role gets the role name of the elector node, which defaults to the name of
+// the election.
+func (conf *ElectorConfig) role() string {
+	if conf.RoleName != "" {
+		return conf.RoleName
+	}
+	return conf.Name
 }
 
 // Log logs the ElectorConfig values at INFO level.
 func (conf *ElectorConfig) Log() {
 	if conf == nil {
-		klog.Info("elector config: nil")
+		newLogger(nil).Info("elector config: nil")
 	} else {
-		klog.Info("elector config")
-		klog.Infof("  ID:         %s", conf.ID)
-		klog.Infof("  Name:       %s", conf.Name)
-		klog.Infof("  Namespace:  %s", conf.Namespace)
-		klog.Infof("  PodName:    %s", conf.PodName)
-		klog.Infof("  Address:    %s", conf.Address)
-		klog.Infof("  LockType:   %s", conf.LockType)
-		klog.Infof("  KubeConfig: %s", conf.KubeConfig)
-		klog.Infof("  TTL:        %v", conf.TTL)
+		log := newLogger(conf)
+		log.Info("elector config")
+		log.Infof("  ID:         %s", conf.ID)
+		log.Infof("  ResolveIDCollisions: %v", conf.ResolveIDCollisions)
+		log.Infof("  StructuredID: %v", conf.StructuredID)
+		log.Infof("  Name:       %s", conf.Name)
+		log.Infof("  ElectionNameTemplate: %s", conf.ElectionNameTemplate)
+		log.Infof("  RoleName:   %s", conf.RoleName)
+		log.Infof("  Namespace:  %s", conf.Namespace)
+		log.Infof("  LockNamespace: %s", conf.LockNamespace)
+		log.Infof("  VerifyNamespace: %v", conf.VerifyNamespace)
+		log.Infof("  PodName:    %s", conf.PodName)
+		log.Infof("  NodeName:   %s", conf.NodeName)
+		log.Infof("  PodIP:      %s", conf.PodIP)
+		log.Infof("  PodCache:   %v", conf.PodCache)
+		log.Infof("  RespectForeignLabels: %v", conf.RespectForeignLabels)
+		log.Infof("  External:   %v", conf.External)
+		log.Infof("  Address:    %s", conf.Address)
+		log.Infof("  EventIDHeader: %s", conf.EventIDHeader)
+		log.Infof("  MaxWatchers: %d", conf.MaxWatchers)
+		log.Infof("  WatchWriteTimeout: %v", conf.WatchWriteTimeout)
+		log.Infof("  LockType:   %s", conf.LockType)
+		log.Infof("  LockResource: %s", lockResource(conf))
+		log.Infof("  LockTypeMigrateFrom: %s", conf.LockTypeMigrateFrom)
+		log.Infof("  KubeConfig: %s", conf.KubeConfig)
+		log.Infof("  ClientMaxIdleConns: %d", conf.ClientMaxIdleConns)
+		log.Infof("  ClientMaxIdleConnsPerHost: %d", conf.ClientMaxIdleConnsPerHost)
+		log.Infof("  TTL:        %v", conf.TTL)
+		log.Infof("  MaxClockSkew: %v", conf.MaxClockSkew)
+		log.Infof("  CanaryElection: %s", conf.CanaryElection)
+		log.Infof("  AdoptExistingLeaseDuration: %v", conf.AdoptExistingLeaseDuration)
+		log.Infof("  KeepLeaseOnShutdown: %v", conf.KeepLeaseOnShutdown)
+		log.Infof("  RepairCorruptLock: %v", conf.RepairCorruptLock)
+		log.Infof("  SingleNode: %v", conf.SingleNode)
+		log.Infof("  Chaos:      %v", conf.Chaos)
+		log.Infof("  StartupFailureGracePeriod: %v", conf.StartupFailureGracePeriod)
+		log.Infof("  StaleThreshold: %v", conf.StaleThreshold)
+		log.Infof("  StaleExpiry: %v", conf.StaleExpiry)
+		log.Infof("  StatusObject: %s", statusObject(conf))
+		log.Infof("  StatusObjectNamespace: %s", conf.StatusObjectNamespace)
+		log.Infof("  ExternalDNSService: %s", conf.ExternalDNSService)
+		log.Infof("  ExternalDNSHostname: %s", conf.ExternalDNSHostname)
+		log.Infof("  Publishers: %s", strings.Join(conf.activePublishers(), ","))
+		log.Infof("  MetricsNamespace: %s", conf.MetricsNamespace)
+		log.Infof("  MetricsIdentityLabel: %v", conf.MetricsIdentityLabel)
+		log.Infof("  CandidacyCheck: %v", conf.CandidacyCheck != nil)
+		log.Infof("  StepDownOnCandidacyLoss: %v", conf.StepDownOnCandidacyLoss)
+		log.Infof("  OnElected:  %s", conf.OnElected)
+		log.Infof("  OnDemoted:  %s", conf.OnDemoted)
+		log.Infof("  OnDemotedTimeout: %v", conf.OnDemotedTimeout)
+		log.Infof("  LogPrefix:  %s", conf.LogPrefix)
+		log.Infof("  LogRolePrefix: %v", conf.LogRolePrefix)
+		log.Infof("  LogRepeatEvery: %d", conf.LogRepeatEvery)
+		log.Infof("  LogRepeatInterval: %v", conf.LogRepeatInterval)
 	}
 }
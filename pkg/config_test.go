@@ -1,7 +1,6 @@
 package pkg
 
 import (
-	"bytes"
 	"flag"
 	"testing"
 	"time"
@@ -20,19 +19,19 @@ func init() {
 func TestElectorConfig_Log_nil(t *testing.T) {
 	var conf *ElectorConfig
 
-	var buf bytes.Buffer
-	klog.SetOutput(&buf)
+	out := &testLogger{}
+	defer setDefaultLogger(out)()
 
 	conf.Log()
 
-	assert.Contains(t, buf.String(), "elector config: nil")
+	assert.Equal(t, "INFO elector config: nil\n", out.String())
 }
 
 func TestElectorConfig_Log_empty(t *testing.T) {
-	conf := ElectorConfig{}
-
-	var buf bytes.Buffer
-	klog.SetOutput(&buf)
+	buf := &testLogger{}
+	conf := ElectorConfig{
+		Logger: buf,
+	}
 
 	conf.Log()
 
@@ -46,15 +45,14 @@ func TestElectorConfig_Log_empty(t *testing.T) {
 }
 
 func TestElectorConfig_Log(t *testing.T) {
+	buf := &testLogger{}
 	conf := ElectorConfig{
-		ID:   "123",
-		Name: "election",
-		TTL:  1 * time.Second,
+		ID:     "123",
+		Name:   "election",
+		TTL:    1 * time.Second,
+		Logger: buf,
 	}
 
-	var buf bytes.Buffer
-	klog.SetOutput(&buf)
-
 	conf.Log()
 
 	assert.Contains(t, buf.String(), "ID:         123")
@@ -0,0 +1,216 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// StatusCorrupt is the state reported by a node which can not parse the
+// record on the election lock, so the leader is unknown.
+const StatusCorrupt = "corrupt"
+
+// errCorruptLock is returned when the record on the election lock can not be
+// parsed, e.g. because the lock object's annotation was edited by hand.
+var errCorruptLock = errors.New("the election lock record is corrupt")
+
+// corruptLockError is the error returned when reading a corrupt election lock
+// record. It is errCorruptLock, and carries the parse error.
+type corruptLockError struct {
+	err error
+}
+
+func (e *corruptLockError) Error() string {
+	return fmt.Sprintf("%v: %v", errCorruptLock, e.err)
+}
+
+// Is reports whether the target is errCorruptLock.
+func (e *corruptLockError) Is(target error) bool {
+	return target == errCorruptLock
+}
+
+// Unwrap gets the parse error.
+func (e *corruptLockError) Unwrap() error {
+	return e.err
+}
+
+// isRecordParseError checks whether an error from reading the election lock
+// is from parsing its record, rather than from getting the lock object.
+func isRecordParseError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return errors.As(err, &syntaxErr) || errors.As(err, &typeErr)
+}
+
+// rawRecordReader reads the raw record on the election lock object, for
+// reporting a record which can not be parsed.
+type rawRecordReader func() (string, error)
+
+// tolerantLock wraps the resourcelock.Interface used for the election to
+// tolerate a corrupt lock record.
+//
+// A corrupt record fails the read with a corruptLockError, which the election
+// retries, and the raw record is logged once for each corrupt value seen. If
+// a repair delay is set and the corrupt record is left unchanged for that
+// long, no holder can be renewing it, so the record is read as empty and the
+// node may overwrite it when it acquires the lock.
+type tolerantLock struct {
+	resourcelock.Interface
+
+	clock       clock.Clock
+	log         logger
+	readRaw     rawRecordReader
+	repairAfter time.Duration
+	report      func(corrupt bool)
+
+	mu           sync.Mutex
+	corrupt      bool
+	corruptRaw   string
+	corruptSince time.Time
+}
+
+// newTolerantLock wraps the given lock to tolerate a corrupt lock record. The
+// raw record is read with readRaw when the record is corrupt; it may be nil if
+// the lock does not store its record as JSON. If repairAfter is zero, the
+// corrupt record is never repaired. Each read reports whether the record was
+// corrupt.
+func newTolerantLock(lock resourcelock.Interface, clk clock.Clock, log logger, readRaw rawRecordReader, repairAfter time.Duration, report func(corrupt bool)) *tolerantLock {
+	return &tolerantLock{
+		Interface:   lock,
+		clock:       clk,
+		log:         log,
+		readRaw:     readRaw,
+		repairAfter: repairAfter,
+		report:      report,
+	}
+}
+
+// Get gets the lock record, tolerating a corrupt record.
+func (l *tolerantLock) Get() (*resourcelock.LeaderElectionRecord, []byte, error) {
+	record, raw, err := l.Interface.Get()
+	if err == nil {
+		l.setCorrupt(false, "")
+		return record, raw, nil
+	}
+	if !isRecordParseError(err) {
+		return record, raw, err
+	}
+
+	corruptRaw := l.rawRecord()
+	since := l.setCorrupt(true, corruptRaw)
+	if l.repairAfter > 0 && l.clock.Since(since) >= l.repairAfter {
+		l.log.Warningf("the corrupt election lock record was not changed for %v; reading it as empty so it can be repaired", l.repairAfter)
+		return &resourcelock.LeaderElectionRecord{}, []byte(corruptRaw), nil
+	}
+	return nil, nil, &corruptLockError{err: err}
+}
+
+// rawRecord reads the raw lock record. If it can not be read, a placeholder
+// describing the error is returned, since it is only logged.
+func (l *tolerantLock) rawRecord() string {
+	if l.readRaw == nil {
+		return ""
+	}
+	raw, err := l.readRaw()
+	if err != nil {
+		return fmt.Sprintf("<failed to read the raw record: %v>", err)
+	}
+	return raw
+}
+
+// setCorrupt records whether the lock record is corrupt, and reports it. The
+// raw record is logged the first time each corrupt value is seen. The time
+// the current corrupt value was first seen is returned.
+func (l *tolerantLock) setCorrupt(corrupt bool, raw string) time.Time {
+	l.mu.Lock()
+	if !corrupt {
+		l.corrupt = false
+		l.corruptRaw = ""
+		l.corruptSince = time.Time{}
+	} else if !l.corrupt || raw != l.corruptRaw {
+		l.corrupt = true
+		l.corruptRaw = raw
+		l.corruptSince = l.clock.Now()
+		l.log.Errorf("the election lock %s has a corrupt record, so its holder is unknown: %q", l.Describe(), raw)
+	}
+	since := l.corruptSince
+	l.mu.Unlock()
+
+	if l.report != nil {
+		l.report(corrupt)
+	}
+	return since
+}
+
+// rawRecordReader gets the reader for the raw record on the node's election
+// lock. Only the lock types which store their record as a JSON annotation can
+// have a corrupt record, so for other lock types, nil is returned.
+func (node *ElectorNode) rawRecordReader(client kubernetes.Interface, lock resourcelock.Interface) rawRecordReader {
+	namespace, name := node.config.LockNamespace, node.config.Name
+	switch node.config.LockType {
+	case resourcelock.EndpointsResourceLock:
+		return func() (string, error) {
+			obj, err := client.CoreV1().Endpoints(namespace).Get(name, metav1.GetOptions{})
+			if err != nil {
+				return "", err
+			}
+			return obj.Annotations[resourcelock.LeaderElectionRecordAnnotationKey], nil
+		}
+	case resourcelock.ConfigMapsResourceLock:
+		return func() (string, error) {
+			obj, err := client.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+			if err != nil {
+				return "", err
+			}
+			return obj.Annotations[resourcelock.LeaderElectionRecordAnnotationKey], nil
+		}
+	case DynamicResourceLock:
+		// The dynamic lock keeps the object it last read, even if its record
+		// could not be parsed.
+		if l, ok := lock.(*dynamicLock); ok {
+			return l.rawRecord
+		}
+		return nil
+	default:
+		return nil
+	}
+}
+
+// setLockCorrupt records whether the node found the election lock record to
+// be corrupt on its last read of it.
+func (node *ElectorNode) setLockCorrupt(corrupt bool) {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.lockCorrupt = corrupt
+}
+
+// isLockCorrupt checks whether the node found the election lock record to be
+// corrupt on its last read of it.
+func (node *ElectorNode) isLockCorrupt() bool {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.lockCorrupt
+}
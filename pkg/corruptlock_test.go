@@ -0,0 +1,217 @@
+package pkg
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const testCorruptRecord = `{"holderIdentity":"test-node-2",`
+
+// newTestCorruptLockNode creates a node for the given lock type, with a lock
+// object which has a corrupt record.
+func newTestCorruptLockNode(lockType string) (*ElectorNode, *fake.Clientset, *testLogger) {
+	meta := metav1.ObjectMeta{
+		Namespace: "test-ns",
+		Name:      "test-election",
+		Annotations: map[string]string{
+			resourcelock.LeaderElectionRecordAnnotationKey: testCorruptRecord,
+		},
+	}
+	client := fake.NewSimpleClientset(
+		newTestPod("test-ns", "test-pod"),
+		&corev1.ConfigMap{ObjectMeta: meta},
+		&corev1.Endpoints{ObjectMeta: meta},
+	)
+	widget := newTestWidget("test-ns", "test-election")
+	widget.SetAnnotations(meta.Annotations)
+
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		PodName:       "test-pod",
+		LockType:      lockType,
+		LockResource:  testLockResource,
+		TTL:           1 * time.Second,
+		Client:        client,
+		DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), widget),
+		Logger:        log,
+	})
+	return node, client, log
+}
+
+func TestTolerantLock_corrupt(t *testing.T) {
+	cases := []struct {
+		description string
+		lockType    string
+	}{
+		{
+			description: "configmaps",
+			lockType:    resourcelock.ConfigMapsResourceLock,
+		},
+		{
+			description: "endpoints",
+			lockType:    resourcelock.EndpointsResourceLock,
+		},
+		{
+			description: "dynamic",
+			lockType:    DynamicResourceLock,
+		},
+	}
+
+	for _, c := range cases {
+		node, client, log := newTestCorruptLockNode(c.lockType)
+		lock, err := node.newLock(client)
+		assert.NoError(t, err, c.description)
+		tolerant := newTolerantLock(lock, clock.RealClock{}, node.log, node.rawRecordReader(client, lock), 0, node.setLockCorrupt)
+
+		for i := 0; i < 3; i++ {
+			record, _, err := tolerant.Get()
+			assert.Nil(t, record, c.description)
+			assert.True(t, errors.Is(err, errCorruptLock), c.description)
+		}
+
+		// The raw record is only logged once.
+		assert.Equal(t, 1, strings.Count(log.String(), strconv.Quote(testCorruptRecord)), c.description)
+		assert.True(t, node.isLockCorrupt(), c.description)
+		assert.True(t, node.healthInfo().LockCorrupt, c.description)
+		assert.Equal(t, StatusCorrupt, node.state(), c.description)
+	}
+}
+
+func TestTolerantLock_repair(t *testing.T) {
+	node, client, _ := newTestCorruptLockNode(resourcelock.ConfigMapsResourceLock)
+	lock, err := node.newLock(client)
+	assert.NoError(t, err)
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	tolerant := newTolerantLock(lock, clk, node.log, node.rawRecordReader(client, lock), 10*time.Second, node.setLockCorrupt)
+
+	_, _, err = tolerant.Get()
+	assert.True(t, errors.Is(err, errCorruptLock))
+
+	// The corrupt record is not repaired until it has gone unchanged for the
+	// repair delay.
+	clk.Step(9 * time.Second)
+	_, _, err = tolerant.Get()
+	assert.True(t, errors.Is(err, errCorruptLock))
+
+	clk.Step(1 * time.Second)
+	record, raw, err := tolerant.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, &resourcelock.LeaderElectionRecord{}, record)
+	assert.Equal(t, testCorruptRecord, string(raw))
+	assert.True(t, node.isLockCorrupt())
+
+	// The record is overwritten when the lock is acquired.
+	assert.NoError(t, tolerant.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+	record, _, err = tolerant.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "test-node-1", record.HolderIdentity)
+	assert.False(t, node.isLockCorrupt())
+}
+
+func TestTolerantLock_repair_changed(t *testing.T) {
+	node, client, log := newTestCorruptLockNode(resourcelock.ConfigMapsResourceLock)
+	lock, err := node.newLock(client)
+	assert.NoError(t, err)
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	tolerant := newTolerantLock(lock, clk, node.log, node.rawRecordReader(client, lock), 10*time.Second, node.setLockCorrupt)
+
+	_, _, err = tolerant.Get()
+	assert.True(t, errors.Is(err, errCorruptLock))
+	clk.Step(9 * time.Second)
+
+	// A change to the corrupt record restarts the repair delay.
+	cm, err := client.CoreV1().ConfigMaps("test-ns").Get("test-election", metav1.GetOptions{})
+	assert.NoError(t, err)
+	cm.Annotations[resourcelock.LeaderElectionRecordAnnotationKey] = "not json"
+	_, err = client.CoreV1().ConfigMaps("test-ns").Update(cm)
+	assert.NoError(t, err)
+
+	_, _, err = tolerant.Get()
+	assert.True(t, errors.Is(err, errCorruptLock))
+	assert.Contains(t, log.String(), `"not json"`)
+
+	clk.Step(1 * time.Second)
+	_, _, err = tolerant.Get()
+	assert.True(t, errors.Is(err, errCorruptLock))
+
+	clk.Step(9 * time.Second)
+	_, _, err = tolerant.Get()
+	assert.NoError(t, err)
+}
+
+func TestTolerantLock_getError(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Logger: &testLogger{},
+	})
+	tolerant := newTolerantLock(&fakeLock{identity: "test-node-1", err: errors.New("test error")}, clock.RealClock{}, node.log, nil, 0, node.setLockCorrupt)
+
+	// Errors other than a corrupt record are returned as they are.
+	_, _, err := tolerant.Get()
+	assert.EqualError(t, err, "test error")
+	assert.False(t, errors.Is(err, errCorruptLock))
+	assert.False(t, node.isLockCorrupt())
+}
+
+func TestElectorNode_run_repairCorruptLock(t *testing.T) {
+	cases := []struct {
+		description string
+		repair      bool
+	}{
+		{
+			description: "repair",
+			repair:      true,
+		},
+		{
+			description: "no repair",
+			repair:      false,
+		},
+	}
+
+	for _, c := range cases {
+		node, _, _ := newTestCorruptLockNode(resourcelock.ConfigMapsResourceLock)
+		node.config.RepairCorruptLock = c.repair
+
+		done := make(chan error, 1)
+		go func() {
+			done <- node.run()
+		}()
+
+		if c.repair {
+			waitFor(t, 5*time.Second, func() bool {
+				return node.IsLeader()
+			})
+		} else {
+			waitFor(t, 5*time.Second, func() bool {
+				return node.state() == StatusCorrupt
+			})
+			time.Sleep(2 * time.Second)
+			assert.False(t, node.IsLeader(), c.description)
+			assert.Equal(t, StatusCorrupt, node.state(), c.description)
+		}
+
+		node.Stop()
+		select {
+		case err := <-done:
+			assert.NoError(t, err, c.description)
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "election did not stop", c.description)
+		}
+	}
+}
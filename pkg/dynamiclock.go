@@ -0,0 +1,217 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// DynamicResourceLock is the lock type which uses an annotation on an existing
+// object of an arbitrary resource as the election lock.
+const DynamicResourceLock = "dynamic"
+
+// ParseGroupVersionResource parses a resource in the form
+// "group/version/resource", or "version/resource" for resources in the core
+// group (e.g. "apps/v1/deployments", "v1/services").
+func ParseGroupVersionResource(s string) (schema.GroupVersionResource, error) {
+	parts := strings.Split(s, "/")
+	var gvr schema.GroupVersionResource
+	switch len(parts) {
+	case 2:
+		gvr = schema.GroupVersionResource{Version: parts[0], Resource: parts[1]}
+	case 3:
+		gvr = schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]}
+	default:
+		return gvr, fmt.Errorf("invalid resource %q: expected group/version/resource or version/resource", s)
+	}
+	if err := validateLockResource(gvr); err != nil {
+		return gvr, fmt.Errorf("invalid resource %q: %v", s, err)
+	}
+	return gvr, nil
+}
+
+// formatGroupVersionResource formats a resource in the form parsed by
+// ParseGroupVersionResource.
+func formatGroupVersionResource(gvr schema.GroupVersionResource) string {
+	if gvr.Group == "" {
+		return gvr.Version + "/" + gvr.Resource
+	}
+	return gvr.Group + "/" + gvr.Version + "/" + gvr.Resource
+}
+
+// lockResource gets the resource of the election lock object for reporting.
+// It is only set for the dynamic lock type.
+func lockResource(conf *ElectorConfig) string {
+	if conf.LockType != DynamicResourceLock {
+		return ""
+	}
+	return formatGroupVersionResource(conf.LockResource)
+}
+
+// validateLockResource checks that a resource can be used for a dynamic lock.
+func validateLockResource(gvr schema.GroupVersionResource) error {
+	if gvr.Version == "" {
+		return errors.New("resource version is not set")
+	}
+	if gvr.Resource == "" {
+		return errors.New("resource name is not set")
+	}
+	return nil
+}
+
+// dynamicLock is an election lock which stores the leader election record in
+// an annotation on an existing object, accessed via the dynamic client. This
+// allows an object which is already designated for coordination (e.g. a
+// custom resource) to be used as the lock.
+//
+// The object is never created or deleted by the lock; it must exist before
+// the election runs. Only its annotation is updated.
+type dynamicLock struct {
+	client    dynamic.Interface
+	resource  schema.GroupVersionResource
+	namespace string
+	name      string
+	config    resourcelock.ResourceLockConfig
+
+	obj *unstructured.Unstructured
+}
+
+// newDynamicLock creates a new dynamic lock on the named object of the resource.
+func newDynamicLock(client dynamic.Interface, resource schema.GroupVersionResource, namespace, name string, config resourcelock.ResourceLockConfig) (*dynamicLock, error) {
+	if client == nil {
+		return nil, errors.New("dynamic lock: no dynamic client specified")
+	}
+	if err := validateLockResource(resource); err != nil {
+		return nil, fmt.Errorf("dynamic lock: %v", err)
+	}
+	if name == "" {
+		return nil, errors.New("dynamic lock: no object name specified")
+	}
+	if config.Identity == "" {
+		return nil, errors.New("dynamic lock: no identity specified")
+	}
+	return &dynamicLock{
+		client:    client,
+		resource:  resource,
+		namespace: namespace,
+		name:      name,
+		config:    config,
+	}, nil
+}
+
+func (l *dynamicLock) objects() dynamic.ResourceInterface {
+	return l.client.Resource(l.resource).Namespace(l.namespace)
+}
+
+// Get returns the election record from the lock object's annotation. If the
+// object exists but has no record, an empty record is returned so the lock
+// can be acquired.
+func (l *dynamicLock) Get() (*resourcelock.LeaderElectionRecord, []byte, error) {
+	obj, err := l.objects().Get(l.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	l.obj = obj
+
+	var record resourcelock.LeaderElectionRecord
+	raw, ok := obj.GetAnnotations()[resourcelock.LeaderElectionRecordAnnotationKey]
+	if ok {
+		if err := json.Unmarshal([]byte(raw), &record); err != nil {
+			return nil, nil, err
+		}
+	}
+	return &record, []byte(raw), nil
+}
+
+// rawRecord gets the raw election record on the lock object as of the last
+// read of it.
+func (l *dynamicLock) rawRecord() (string, error) {
+	if l.obj == nil {
+		return "", errors.New("dynamic lock: the lock object has not been read")
+	}
+	return l.obj.GetAnnotations()[resourcelock.LeaderElectionRecordAnnotationKey], nil
+}
+
+// Create sets the election record on the lock object. Since the lock object
+// is never created, this fails if the object does not exist.
+func (l *dynamicLock) Create(ler resourcelock.LeaderElectionRecord) error {
+	obj, err := l.objects().Get(l.name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("dynamic lock: the lock object %s %s must exist: %v", formatGroupVersionResource(l.resource), l.Describe(), err)
+		}
+		return err
+	}
+	l.obj = obj
+	return l.Update(ler)
+}
+
+// Update sets the election record on the lock object.
+func (l *dynamicLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	if l.obj == nil {
+		return errors.New("dynamic lock: object not initialized, call get or create first")
+	}
+	raw, err := json.Marshal(ler)
+	if err != nil {
+		return err
+	}
+
+	obj := l.obj.DeepCopy()
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[resourcelock.LeaderElectionRecordAnnotationKey] = string(raw)
+	obj.SetAnnotations(annotations)
+
+	updated, err := l.objects().Update(obj, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	l.obj = updated
+	return nil
+}
+
+// RecordEvent records an event on the lock object.
+func (l *dynamicLock) RecordEvent(s string) {
+	if l.config.EventRecorder == nil || l.obj == nil {
+		return
+	}
+	events := fmt.Sprintf("%v %v", l.config.Identity, s)
+	l.config.EventRecorder.Eventf(l.obj, corev1.EventTypeNormal, "LeaderElection", events)
+}
+
+// Describe is used to convert details on the current resource lock into a string.
+func (l *dynamicLock) Describe() string {
+	return fmt.Sprintf("%v/%v", l.namespace, l.name)
+}
+
+// Identity returns the identity of the lock holder.
+func (l *dynamicLock) Identity() string {
+	return l.config.Identity
+}
@@ -0,0 +1,277 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+var testLockResource = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+
+// newTestWidget creates an object of the testLockResource for use as a dynamic lock.
+func newTestWidget(namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("example.com/v1")
+	obj.SetKind("Widget")
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func TestParseGroupVersionResource(t *testing.T) {
+	cases := []struct {
+		description string
+		value       string
+		expected    schema.GroupVersionResource
+	}{
+		{
+			description: "group, version, and resource",
+			value:       "example.com/v1/widgets",
+			expected:    testLockResource,
+		},
+		{
+			description: "core group",
+			value:       "v1/services",
+			expected:    schema.GroupVersionResource{Version: "v1", Resource: "services"},
+		},
+	}
+
+	for _, c := range cases {
+		gvr, err := ParseGroupVersionResource(c.value)
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, c.expected, gvr, c.description)
+		assert.Equal(t, c.value, formatGroupVersionResource(gvr), c.description)
+	}
+}
+
+func TestParseGroupVersionResource_error(t *testing.T) {
+	cases := []struct {
+		description string
+		value       string
+	}{
+		{
+			description: "empty",
+			value:       "",
+		},
+		{
+			description: "resource only",
+			value:       "widgets",
+		},
+		{
+			description: "too many parts",
+			value:       "example.com/v1/widgets/status",
+		},
+		{
+			description: "missing version",
+			value:       "example.com//widgets",
+		},
+		{
+			description: "missing resource",
+			value:       "v1/",
+		},
+	}
+
+	for _, c := range cases {
+		_, err := ParseGroupVersionResource(c.value)
+		assert.Error(t, err, c.description)
+	}
+}
+
+func TestNewDynamicLock_error(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	lockConfig := resourcelock.ResourceLockConfig{Identity: "test-node-1"}
+
+	cases := []struct {
+		description string
+		lock        func() (*dynamicLock, error)
+	}{
+		{
+			description: "no client",
+			lock: func() (*dynamicLock, error) {
+				return newDynamicLock(nil, testLockResource, "test-ns", "test-election", lockConfig)
+			},
+		},
+		{
+			description: "no resource",
+			lock: func() (*dynamicLock, error) {
+				return newDynamicLock(client, schema.GroupVersionResource{}, "test-ns", "test-election", lockConfig)
+			},
+		},
+		{
+			description: "no name",
+			lock: func() (*dynamicLock, error) {
+				return newDynamicLock(client, testLockResource, "test-ns", "", lockConfig)
+			},
+		},
+		{
+			description: "no identity",
+			lock: func() (*dynamicLock, error) {
+				return newDynamicLock(client, testLockResource, "test-ns", "test-election", resourcelock.ResourceLockConfig{})
+			},
+		},
+	}
+
+	for _, c := range cases {
+		lock, err := c.lock()
+		assert.Error(t, err, c.description)
+		assert.Nil(t, lock, c.description)
+	}
+}
+
+func TestDynamicLock(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newTestWidget("test-ns", "test-election"))
+	lock, err := newDynamicLock(client, testLockResource, "test-ns", "test-election", resourcelock.ResourceLockConfig{
+		Identity: "test-node-1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "test-node-1", lock.Identity())
+	assert.Equal(t, "test-ns/test-election", lock.Describe())
+
+	// Updating before the object is read fails.
+	assert.Error(t, lock.Update(resourcelock.LeaderElectionRecord{}))
+
+	// An object without a record has an empty record.
+	record, raw, err := lock.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, &resourcelock.LeaderElectionRecord{}, record)
+	assert.Empty(t, raw)
+
+	assert.NoError(t, lock.Update(resourcelock.LeaderElectionRecord{
+		HolderIdentity:       "test-node-1",
+		LeaseDurationSeconds: 10,
+	}))
+
+	record, raw, err = lock.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "test-node-1", record.HolderIdentity)
+	assert.Equal(t, 10, record.LeaseDurationSeconds)
+	assert.NotEmpty(t, raw)
+
+	obj, err := client.Resource(testLockResource).Namespace("test-ns").Get("test-election", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, obj.GetAnnotations(), resourcelock.LeaderElectionRecordAnnotationKey)
+}
+
+func TestDynamicLock_Create(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newTestWidget("test-ns", "test-election"))
+	lock, err := newDynamicLock(client, testLockResource, "test-ns", "test-election", resourcelock.ResourceLockConfig{
+		Identity: "test-node-1",
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, lock.Create(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+
+	record, _, err := lock.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "test-node-1", record.HolderIdentity)
+}
+
+func TestDynamicLock_Create_noObject(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	lock, err := newDynamicLock(client, testLockResource, "test-ns", "test-election", resourcelock.ResourceLockConfig{
+		Identity: "test-node-1",
+	})
+	assert.NoError(t, err)
+
+	err = lock.Create(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "example.com/v1/widgets test-ns/test-election must exist")
+
+	// The lock object is never created.
+	_, err = client.Resource(testLockResource).Namespace("test-ns").Get("test-election", metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func TestElectorNode_checkConfig_dynamicLock(t *testing.T) {
+	cases := []struct {
+		description string
+		resource    schema.GroupVersionResource
+		history     bool
+		valid       bool
+	}{
+		{
+			description: "valid resource",
+			resource:    testLockResource,
+			valid:       true,
+		},
+		{
+			description: "missing resource",
+			resource:    schema.GroupVersionResource{},
+			valid:       false,
+		},
+		{
+			description: "history not supported",
+			resource:    testLockResource,
+			history:     true,
+			valid:       false,
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:            "test-node-1",
+			Name:          "test-election",
+			Namespace:     "test-ns",
+			LockType:      DynamicResourceLock,
+			LockResource:  c.resource,
+			RecordHistory: c.history,
+			Logger:        &testLogger{},
+		})
+
+		err := node.checkConfig()
+		if c.valid {
+			assert.NoError(t, err, c.description)
+		} else {
+			assert.Error(t, err, c.description)
+		}
+	}
+}
+
+func TestElectorNode_run_dynamicLock(t *testing.T) {
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newTestWidget("test-ns", "test-election"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		PodName:       "test-pod",
+		LockType:      DynamicResourceLock,
+		LockResource:  testLockResource,
+		TTL:           1 * time.Second,
+		Client:        fake.NewSimpleClientset(newTestPod("test-ns", "test-pod")),
+		DynamicClient: dynamicClient,
+		Logger:        &testLogger{},
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.run()
+	}()
+	waitFor(t, 5*time.Second, func() bool {
+		return node.IsLeader()
+	})
+
+	lock, err := newDynamicLock(dynamicClient, testLockResource, "test-ns", "test-election", resourcelock.ResourceLockConfig{
+		Identity: "test-reader",
+	})
+	assert.NoError(t, err)
+	record, _, err := lock.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "test-node-1", record.HolderIdentity)
+
+	node.Stop()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "election did not stop")
+	}
+}
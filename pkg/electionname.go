@@ -0,0 +1,67 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// DefaultElectionNameTemplate is the default template for the name of the
+// election loop, which identifies the node's election in the logs of the
+// leader election client.
+const DefaultElectionNameTemplate = "{{.Namespace}}/{{.Election}}-{{.ID}}"
+
+// electionNameVars holds the variables available to the election name
+// template.
+type electionNameVars struct {
+	Namespace string
+	Election  string
+	ID        string
+}
+
+// renderElectionName renders the election name template with the given
+// config. If the template is not set, the DefaultElectionNameTemplate is used.
+//
+// The template is validated by rendering it, so references to unknown
+// variables are reported as errors.
+func renderElectionName(conf *ElectorConfig) (string, error) {
+	text := conf.ElectionNameTemplate
+	if text == "" {
+		text = DefaultElectionNameTemplate
+	}
+
+	tmpl, err := template.New("election-name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid election name template %q: %v", text, err)
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, electionNameVars{
+		Namespace: conf.LockNamespace,
+		Election:  conf.Name,
+		ID:        conf.ID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("invalid election name template %q: %v", text, err)
+	}
+	if buf.Len() == 0 {
+		return "", fmt.Errorf("invalid election name template %q: renders an empty name", text)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderElectionName(t *testing.T) {
+	cases := []struct {
+		description string
+		template    string
+		expected    string
+		valid       bool
+	}{
+		{
+			description: "default",
+			template:    "",
+			expected:    "test-lock-ns/test-election-test-node-1",
+			valid:       true,
+		},
+		{
+			description: "default, explicitly set",
+			template:    DefaultElectionNameTemplate,
+			expected:    "test-lock-ns/test-election-test-node-1",
+			valid:       true,
+		},
+		{
+			description: "election and ID",
+			template:    "{{.Election}}@{{.ID}}",
+			expected:    "test-election@test-node-1",
+			valid:       true,
+		},
+		{
+			description: "literal",
+			template:    "my-election",
+			expected:    "my-election",
+			valid:       true,
+		},
+		{
+			description: "unclosed action",
+			template:    "{{.Election",
+			valid:       false,
+		},
+		{
+			description: "unknown variable",
+			template:    "{{.Pod}}",
+			valid:       false,
+		},
+		{
+			description: "empty name",
+			template:    "{{if false}}x{{end}}",
+			valid:       false,
+		},
+	}
+
+	for _, c := range cases {
+		name, err := renderElectionName(&ElectorConfig{
+			ID:                   "test-node-1",
+			Name:                 "test-election",
+			Namespace:            "test-ns",
+			LockNamespace:        "test-lock-ns",
+			ElectionNameTemplate: c.template,
+		})
+		if c.valid {
+			assert.NoError(t, err, c.description)
+			assert.Equal(t, c.expected, name, c.description)
+		} else {
+			assert.Error(t, err, c.description)
+			assert.Contains(t, err.Error(), "invalid election name template", c.description)
+			assert.Empty(t, name, c.description)
+		}
+	}
+}
+
+func TestElectorNode_checkConfig_electionNameTemplate(t *testing.T) {
+	cases := []struct {
+		description string
+		template    string
+		valid       bool
+	}{
+		{
+			description: "valid template",
+			template:    "{{.Election}}-{{.ID}}",
+			valid:       true,
+		},
+		{
+			description: "parse error",
+			template:    "{{.Election",
+			valid:       false,
+		},
+		{
+			description: "unknown variable",
+			template:    "{{.Unknown}}",
+			valid:       false,
+		},
+	}
+
+	for _, c := range cases {
+		log := &testLogger{}
+		node := NewElectorNode(&ElectorConfig{
+			ID:                   "test-node-1",
+			Name:                 "test-election",
+			Namespace:            "test-ns",
+			ElectionNameTemplate: c.template,
+			Logger:               log,
+		})
+
+		err := node.checkConfig()
+		if c.valid {
+			assert.NoError(t, err, c.description)
+			assert.Contains(t, log.String(), "using election name: test-election-test-node-1", c.description)
+			assert.Equal(t, "test-election-test-node-1", node.configInfo().ElectionName, c.description)
+		} else {
+			assert.Error(t, err, c.description)
+		}
+	}
+}
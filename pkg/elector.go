@@ -18,67 +18,329 @@ package pkg
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
+	"io/ioutil"
 	"os"
-	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
-	"k8s.io/client-go/transport"
-	"k8s.io/klog"
 )
 
 const (
 	// EnvPodName is the environment variable which is checked for the Pod name.
 	EnvPodName = "ELECTOR_POD_NAME"
 
-	// ResourcePathPodLabel is the path to the Pod label metadata which is used
-	// for updating Pod label values for election status.
-	//
-	// Note that the label key is the last element in the path. Since the key
-	// contains a "/", it is escaped as "~1".
-	ResourcePathPodLabel = "/metadata/labels/k8s-elector~1status"
+	// EnvNodeName is the environment variable which is checked for the name
+	// of the Kubernetes node the Pod is scheduled on. It is typically set from
+	// the Pod's spec.nodeName with the downward API.
+	EnvNodeName = "ELECTOR_NODE_NAME"
+
+	// EnvNamespace is the environment variable which is checked for the
+	// namespace of the election, if it is not configured.
+	EnvNamespace = "ELECTOR_NAMESPACE"
+
+	// DefaultNamespace is the namespace of the election if it is not configured
+	// and can not be determined from the environment.
+	DefaultNamespace = "default"
+
+	// EnvKubeConfigData is the environment variable which is checked for the
+	// base64-encoded content of a kubeconfig file.
+	EnvKubeConfigData = "ELECTOR_KUBECONFIG_DATA"
+
+	// PodLabelKey is the key of the Pod label which is used to designate the
+	// election status of the elector's Pod.
+	PodLabelKey = "k8s-elector/status"
+
+	// PodRoleAnnotationKey is the key of the Pod annotation which holds the
+	// role name of the elector, set along with its status label.
+	PodRoleAnnotationKey = "k8s-elector/role"
+
+	// PodLabelOwnerAnnotationKey is the key of the Pod annotation which holds
+	// the value the elector last set its status label to. It marks the label
+	// as owned by the elector: a label with any other value was set by
+	// something else.
+	PodLabelOwnerAnnotationKey = "k8s-elector/owned-status"
 
 	// StatusStandby is the standby status annotation value.
 	StatusStandby = "standby"
 
 	// StatusLeader is the leader status annotation value.
 	StatusLeader = "leader"
+
+	// rejoinDelay is the time the elector node waits before re-joining the
+	// election after it exits, giving the topology time to settle.
+	rejoinDelay = 1 * time.Second
 )
 
+// serviceAccountNamespaceFile is the file which holds the namespace of the
+// Pod's service account when running in a cluster.
+var serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
 // ElectorNode is a participant node in an election.
 type ElectorNode struct {
-	cancel        context.CancelFunc
-	config        *ElectorConfig
-	ctx           context.Context
+	cancel context.CancelFunc
+	clock  clock.Clock
+	config *ElectorConfig
+	ctx    context.Context
+	log    logger
+	quit   chan os.Signal
+
+	// httpCtx is the context of the node's HTTP server. It is separate from the
+	// node's context, so the HTTP server can be stopped without cancelling the
+	// election, and keeps serving while the election winds down.
+	httpCtx    context.Context
+	httpCancel context.CancelFunc
+
+	// electionCtx is the context the node stands for election in. It is
+	// cancelled to withdraw the node from the election for a pre-stop hook,
+	// without shutting the node down. drained is closed once the node has
+	// withdrawn and released the election lock.
+	electionCtx   context.Context
+	drainElection context.CancelFunc
+	drained       chan struct{}
+
+	// passive nodes participate in the election without any side effects:
+	// they do not publish their status, e.g. to the Pod label, or run the
+	// on-elected/on-demoted commands. So they do not cache the Pod or check
+	// the permissions for those either.
+	passive bool
+
+	// canary is the node participating in the canary election, if one is
+	// configured.
+	canary *ElectorNode
+
+	// mu guards the election state below, which is updated by the election
+	// callbacks and read by the HTTP handlers.
+	mu            sync.RWMutex
 	currentLeader string
-	quit          chan os.Signal
+	stateSince    time.Time
+	restarts      int
+	restartReason string
+	runErrors     map[string]int
+	leaseDuration time.Duration
+	demoted       bool
+	lock          *observedLock
+
+	// leaseRead is the forced read of the election lock in flight for the
+	// /lease endpoint, if any.
+	leaseRead *leaseRead
+
+	// acquisitions counts the times the node has acquired leadership. It is
+	// kept across runs of the election, so it covers the process lifetime.
+	acquisitions int
+
+	// ledFor is the total time the node was the leader, up to the start of
+	// its current term if it is the leader.
+	ledFor time.Duration
+
+	// renewals counts the renewals made through the locks of previous runs of
+	// the election. The renewals of the current run are counted by its lock.
+	renewals int64
+
+	// slowRenewals counts the renewals which took longer than the configured
+	// fraction of the renew deadline.
+	slowRenewals int
+
+	// lockAuthErrors counts the operations on the election lock which were
+	// rejected as unauthorized or forbidden, by reason.
+	lockAuthErrors map[string]int
+
+	// lockDeletions counts the times the election lock object was found
+	// deleted externally.
+	lockDeletions int
+
+	// started is when the node was created. timeToFirstLeader is the time it
+	// took from then for the node to first observe a leader, or zero if it
+	// has not yet observed one.
+	started           time.Time
+	timeToFirstLeader time.Duration
+
+	// publishers publish the node's status for the current run of the
+	// election. collapsedPublishes counts the status changes collapsed by the
+	// publishers of previous runs, and patchErrors the failures of their Pod
+	// label publisher.
+	publishers         []*debouncedPublisher
+	collapsedPublishes int
+	patchErrors        int
+
+	// subscriptions are the callback URLs which leadership transitions are
+	// delivered to. They are kept across runs of the election.
+	subscriptions subscriptions
 
-	servingHTTP bool
+	// watchers are the streams watching leadership transitions through the
+	// HTTP API. They are kept across runs of the election.
+	watchers watchers
+
+	// splitBrainDetected is set once the node has found the election lock
+	// held by another identity while it believed it was the leader.
+	splitBrainDetected bool
+
+	// previousLeader is the last leader other than the current one.
+	// leaderChanges counts the changes of leader, by change.
+	previousLeader string
+	leaderChanges  map[leaderChange]int
+
+	// transitions are the most recent leader transitions observed by the
+	// node, up to the history limit. transitionCount counts all of the
+	// transitions observed.
+	transitions     transitionRing
+	transitionCount int
+
+	// leaderlessSince is when the node found the election lock unheld beyond
+	// its lease duration, or zero unless the election is leaderless.
+	// observedHolder and observedRenew are the holder and renew time of the
+	// last lock record observed, and observedAt is when they last changed.
+	// observedDuration is the lease duration of the last lock record observed.
+	// lastObserved is when a lock record was last observed at all, which
+	// stops while the API server can not be reached.
+	leaderlessSince  time.Time
+	observedHolder   string
+	observedRenew    time.Time
+	observedAt       time.Time
+	observedDuration time.Duration
+	lastObserved     time.Time
+
+	// lockCorrupt is set while the election lock has a record which can not
+	// be parsed.
+	lockCorrupt bool
+
+	// leaseMismatch is set while the lease duration recorded by the leader
+	// differs from the node's own lease duration.
+	leaseMismatch *LeaseDurationMismatch
+
+	// backendResources holds the availability of the resources which back
+	// the election lock, as of backendChecked.
+	backendResources []BackendResource
+	backendChecked   time.Time
+
+	// permissions holds the results of checking the permissions the node
+	// needs, as of permissionsChecked. The client is kept so they can be
+	// checked again once they are stale.
+	permissions        []Permission
+	permissionsChecked time.Time
+	permissionsClient  kubernetes.Interface
+
+	// pods is the cache of the node's Pod for the current run of the
+	// election, if enabled.
+	pods *podCache
+
+	// chaos holds the failures injected by the chaos hooks, if enabled.
+	chaos *faultInjector
+
+	// initializing is set while the node is running but has not yet started
+	// its election. startupErr is the error which prevented the election
+	// from starting, if any.
+	initializing bool
+	startupErr   error
+
+	// paused is set while the node's participation in the election is paused.
+	// withdraw withdraws the node from the current run of the election, and
+	// resumed is closed once the node is resumed.
+	paused   bool
+	withdraw context.CancelFunc
+	resumed  chan struct{}
+
+	// candidacyLost is set when the node stepped down as the leader since its
+	// candidacy check stopped passing, so the run hands off the lock.
+	candidacyLost bool
+
+	// stopReason describes why the node is stopping.
+	stopReason string
+
+	// leaderPayload caches the JSON encoding of the node's LeaderInfo, without
+	// the timestamp. It is invalidated when the leader changes, and is only
+	// valid for the renewal count and staleness it was encoded with.
+	leaderPayload          []byte
+	leaderPayloadRenewals  int64
+	leaderPayloadStaleness staleness
+
+	// gatherer gathers the node's metrics to serve them at /metrics. It is
+	// not set if the metrics are registered with a Registerer which can not
+	// be gathered from.
+	gatherer prometheus.Gatherer
+
+	// serverMetrics are the metrics of the node's HTTP server. They are only
+	// set once the node's metrics are registered.
+	serverMetrics *httpServerMetrics
+
+	// httpAddr is the address the HTTP server is bound to. It is only set
+	// while the HTTP server is serving.
+	httpAddr string
+
+	// eventID is the ID of the node's last leadership event. newEventID
+	// generates event IDs; if not set, random IDs are generated.
+	eventID    string
+	newEventID func() string
+
+	// signals relays the signals which the node handles to its quit channel,
+	// and terminate shuts the node down on a termination signal. Both may be
+	// replaced by tests, to drive the node's shutdown without signalling the
+	// process.
+	signals   signalNotifier
+	terminate func()
+
+	// operations tracks the node's in-flight side-effect operations, so they
+	// can finish before the node stops.
+	operations *operations
+
+	// clientMu guards the Kubernetes clients built by the node, which are
+	// reused by each run of the election.
+	clientMu  sync.Mutex
+	client    kubernetes.Interface
+	dynClient dynamic.Interface
 }
 
 // NewElectorNode creates a new instance of an elector node which will
 // participate in an election.
 func NewElectorNode(config *ElectorConfig) *ElectorNode {
+	return newElectorNode(context.Background(), config)
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
+// newElectorNode creates a new elector node whose context is derived from
+// the given parent context, so the node is stopped when the parent is.
+func newElectorNode(parent context.Context, config *ElectorConfig) *ElectorNode {
 
-	return &ElectorNode{
-		cancel: cancel,
-		config: config,
-		ctx:    ctx,
-		quit:   make(chan os.Signal, 1),
+	ctx, cancel := context.WithCancel(parent)
+	httpCtx, httpCancel := context.WithCancel(context.Background())
+	electionCtx, drainElection := context.WithCancel(ctx)
+	var clk clock.Clock = clock.RealClock{}
+	if config != nil && config.Clock != nil {
+		clk = config.Clock
 	}
+
+	node := &ElectorNode{
+		cancel:        cancel,
+		clock:         clk,
+		config:        config,
+		ctx:           ctx,
+		log:           newLogger(config),
+		quit:          make(chan os.Signal, 1),
+		signals:       osSignals{},
+		httpCtx:       httpCtx,
+		httpCancel:    httpCancel,
+		electionCtx:   electionCtx,
+		drainElection: drainElection,
+		drained:       make(chan struct{}),
+		started:       clk.Now(),
+		operations:    &operations{},
+		chaos:         &faultInjector{},
+	}
+	node.terminate = node.shutdown
+	return node
 }
 
 // Run the elector node.
@@ -86,28 +348,90 @@ func NewElectorNode(config *ElectorConfig) *ElectorNode {
 // This is the entry point that kicks off all of the elector node setup
 // and run logic.
 func (node *ElectorNode) Run() error {
+	// Once the election has stopped, stop the HTTP server. This is deferred
+	// first so it runs last, keeping the endpoints up while the lock is
+	// released.
+	defer node.httpCancel()
+
 	// If anything goes wrong, cancel the elector nodes context. This ensures
 	// that it will clean up properly and release the lock in a timely manner.
 	defer node.cancel()
 
+	// Termination signals are caught before anything else is set up, so a
+	// signal delivered during setup stops the node gracefully rather than
+	// killing it. Signals are buffered until the listener starts.
+	node.notifySignals()
+	defer node.signals.Stop(node.quit)
+
 	// Verify the elector node configuration is valid.
 	if err := node.checkConfig(); err != nil {
 		return err
 	}
 
+	// Checking the config may have filled in default values, such as the
+	// node ID, so rebuild the logger to pick them up.
+	// The role is kept, so loggers already handed out keep showing the
+	// node's current role, as are the repeated messages already suppressed.
+	rebuilt := newLogger(node.config)
+	if node.log.role != nil {
+		rebuilt.role = node.log.role
+	}
+	if node.log.repeats != nil {
+		rebuilt.repeats = node.log.repeats
+	}
+	node.log = rebuilt
 	node.config.Log()
 
-	// Run the signal exiter and HTTP server in separate goroutines. The
-	// election logic will run in the foreground and block until it is
-	// cancelled.
-	go node.listenForSignal()
+	// Run the signal exiter in a separate goroutine, so that the remaining
+	// setup is cancelled by a termination signal. A signal delivered before
+	// the node ran is handled first, so the setup does not race with it.
+	if !node.handlePendingSignals() {
+		go node.listenForSignal()
+	}
+
+	node.checkClockSkew()
+	if err := node.registerMetrics(); err != nil {
+		return fmt.Errorf("failed to register metrics: %v", err)
+	}
+	if node.startupCancelled() {
+		node.writeTerminationMessage(ErrTerminatedDuringStartup)
+		node.logShutdownSummary(ErrTerminatedDuringStartup)
+		return ErrTerminatedDuringStartup
+	}
+
+	// Run the HTTP server in a separate goroutine. The election logic will
+	// run in the foreground and block until it is cancelled. The HTTP server
+	// is started before the election, so the node can be inspected while it
+	// initializes, or if its election fails to start.
+	//
+	// The canary node is created before the HTTP server is started, since
+	// the server reports it.
+	if node.config.CanaryElection != "" {
+		node.canary = node.newCanaryNode()
+	}
+	node.setInitializing(true)
 	go node.serveHTTP()
 
-	if err := node.runUntilError(); err != nil {
+	if node.canary != nil {
+		go node.runCanary()
+	}
+
+	err := node.runUntilError()
+	node.drainOperations()
+	if err != nil && node.ctx.Err() == nil && node.isInitializing() {
+		err = node.failStartup(err)
+	}
+	if errors.Is(err, context.Canceled) && node.isInitializing() {
+		err = ErrTerminatedDuringStartup
+	}
+	node.writeTerminationMessage(err)
+	if err != nil {
+		node.logShutdownSummary(err)
 		return err
 	}
 
-	klog.Info("done")
+	node.log.Info("done")
+	node.logShutdownSummary(nil)
 	return nil
 }
 
@@ -116,15 +440,198 @@ func (node *ElectorNode) IsLeader() bool {
 	if node.config == nil {
 		return false
 	}
+	node.mu.RLock()
+	defer node.mu.RUnlock()
 	return node.config.ID == node.currentLeader
 }
 
-// buildConfig builds the config for the Kubernetes client used by the elector node.
+// Stop stops the elector node. If the node is the leader and is configured to
+// release on shutdown, the lease is released so another node can take over
+// without waiting for it to expire.
+//
+// Stop waits for the node's in-flight operations, such as publishing its final
+// status, to finish, up to the drain timeout.
+func (node *ElectorNode) Stop() {
+	node.setStopReason("Stop() called")
+	node.shutdown()
+	node.drainOperations()
+}
+
+// shutdown voluntarily stops the elector node.
+//
+// If the node is the leader, it publishes that it is stepping down before the
+// election is cancelled and the lease is released, so there is no window in
+// which the lease is free while the node is still published as the leader.
+// When leadership is lost involuntarily (e.g. the lease could not be renewed),
+// the standby status is only published once the election stops.
+func (node *ElectorNode) shutdown() {
+	node.stepDown()
+	node.cancel()
+}
+
+// leader gets the identity of the current leader.
+func (node *ElectorNode) leader() string {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.currentLeader
+}
+
+// setLeader sets the identity of the current leader. If this changes whether
+// the node is the leader, the time of the state change is recorded.
+//
+// The previous leader is the last leader other than the current one, and is
+// returned. A change to a leader other than the previous leader is counted as
+// a leader change; a leader which re-acquires leadership after releasing it
+// is not. Each new leader is recorded as a leader transition.
+func (node *ElectorNode) setLeader(identity string) (previous string) {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+
+	wasLeader := node.currentLeader == node.config.ID
+	if identity != node.currentLeader {
+		node.newEvent()
+		if identity != "" {
+			node.observeTransition(identity)
+			node.leaderlessSince = time.Time{}
+		}
+		if node.currentLeader != "" {
+			node.previousLeader = node.currentLeader
+		}
+		if identity != "" && identity != node.previousLeader {
+			if node.leaderChanges == nil {
+				node.leaderChanges = map[leaderChange]int{}
+			}
+			node.leaderChanges[leaderChange{From: node.previousLeader, To: identity}]++
+		}
+	}
+	node.currentLeader = identity
+	isLeader := identity == node.config.ID
+	if isLeader != wasLeader || node.stateSince.IsZero() {
+		if wasLeader && !node.stateSince.IsZero() {
+			node.ledFor += node.clock.Since(node.stateSince)
+		}
+		node.stateSince = node.clock.Now()
+	}
+	if isLeader {
+		node.log.setRole(logRoleLeader)
+	} else {
+		node.log.setRole(logRoleStandby)
+	}
+	return node.previousLeader
+}
+
+// previousLeaderID gets the identity of the last leader other than the
+// current one. It is empty until leadership has changed hands.
+func (node *ElectorNode) previousLeaderID() string {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.previousLeader
+}
+
+// leaderChange is a change of the election's leader, from the previous leader
+// to the new one. The previous leader is empty for the first leader observed.
+type leaderChange struct {
+	From string
+	To   string
+}
+
+// leaderChangeCounts gets the number of times the leader changed, by change.
+func (node *ElectorNode) leaderChangeCounts() map[leaderChange]int {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	counts := make(map[leaderChange]int, len(node.leaderChanges))
+	for change, count := range node.leaderChanges {
+		counts[change] = count
+	}
+	return counts
+}
+
+// recordAcquisition records that the node acquired leadership.
+func (node *ElectorNode) recordAcquisition() {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.acquisitions++
+	node.leaderPayload = nil
+}
+
+// recordFirstLeader records the time it took for the node to observe the
+// first leader after it started. Only the first non-empty leader is recorded.
+func (node *ElectorNode) recordFirstLeader(identity string) {
+	if identity == "" {
+		return
+	}
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	if node.timeToFirstLeader == 0 {
+		node.timeToFirstLeader = node.clock.Since(node.started)
+		node.log.Infof("first leader observed %v after start: %s", node.timeToFirstLeader, identity)
+	}
+}
+
+// firstLeaderTime gets the time it took for the node to observe the first
+// leader after it started, and whether it has observed one.
+func (node *ElectorNode) firstLeaderTime() (time.Duration, bool) {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.timeToFirstLeader, node.timeToFirstLeader != 0
+}
+
+// leadership gets whether the node has held leadership during the lifetime of
+// its process, and the number of times it has acquired leadership.
+func (node *ElectorNode) leadership() (hasLed bool, acquisitions int) {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.acquisitions > 0, node.acquisitions
+}
+
+// renewCount gets the number of times the node has successfully written its
+// leadership to the election lock during the lifetime of its process.
+func (node *ElectorNode) renewCount() int64 {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+
+	count := node.renewals
+	if node.lock != nil {
+		count += node.lock.renewCount()
+	}
+	return count
+}
+
+// buildClientConfig builds the config for the Kubernetes client used by the
+// elector node, with its connection pool sized from the node's config.
 func (node *ElectorNode) buildClientConfig() (*rest.Config, error) {
+	cfg, err := node.loadClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	node.wrapClientConfig(cfg)
+	return cfg, nil
+}
+
+// wrapClientConfig wraps the transport of the config for the node's Kubernetes
+// client. The connection pool is sized from the node's config and, if the
+// client reads its token from a file, requests rejected while the token is
+// rotated are retried with the new token.
+func (node *ElectorNode) wrapClientConfig(cfg *rest.Config) {
+	cfg.Wrap(node.poolTransport)
+	if cfg.BearerTokenFile != "" {
+		cfg.Wrap(node.refreshToken(cfg.BearerTokenFile))
+	}
+}
+
+// loadClientConfig loads the config for the node's Kubernetes client from
+// the kubeconfig data, the kubeconfig file, or the in-cluster config.
+func (node *ElectorNode) loadClientConfig() (*rest.Config, error) {
 	if node.config == nil {
 		return nil, errors.New("no config specified for the elector")
 	}
 
+	// Kubeconfig content passed via the environment takes precedence over a
+	// kubeconfig file, for environments which can not mount files.
+	if data := os.Getenv(EnvKubeConfigData); data != "" {
+		return kubeConfigFromData(data)
+	}
+
 	if node.config.KubeConfig != "" {
 		cfg, err := clientcmd.BuildConfigFromFlags("", node.config.KubeConfig)
 		if err != nil {
@@ -141,113 +648,511 @@ func (node *ElectorNode) buildClientConfig() (*rest.Config, error) {
 	return cfg, err
 }
 
+// kubeConfigFromData builds the config for a Kubernetes client from the
+// base64-encoded content of a kubeconfig file.
+//
+// The content may hold credentials, so it is never included in errors or logs.
+func kubeConfigFromData(encoded string) (*rest.Config, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: failed to decode base64: %v", EnvKubeConfigData, err)
+	}
+
+	clientConfig, err := clientcmd.NewClientConfigFromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: failed to parse kubeconfig: %v", EnvKubeConfigData, err)
+	}
+	cfg, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: failed to load kubeconfig: %v", EnvKubeConfigData, err)
+	}
+	return cfg, nil
+}
+
 // runUntilError runs the elector node and will keep re-running it until an error
 // is returned or the context is cancelled.
 func (node *ElectorNode) runUntilError() error {
 	for {
+		// The run of the election is tracked as an in-flight operation, since
+		// the node's final status is published as the election stops.
 		errChan := make(chan error, 1)
+		done := node.operations.start()
 		go func() {
+			defer done()
 			errChan <- node.run()
 		}()
 
 		select {
 		case <-node.ctx.Done():
-			klog.Info("terminating: context cancelled")
+			node.log.Info("terminating: context cancelled")
 			return node.ctx.Err()
 		case err := <-errChan:
 			if err != nil {
-				klog.Infof("terminating: run error  (%v)", err)
+				node.recordRunError(err)
+				node.log.Infof("terminating: run error  (%v)", err)
 				return err
 			}
 		}
-		// Sleep a short period of time so the topology has a little
+
+		// If the node withdrew from the election for a pre-stop hook, it
+		// keeps running as a standby until it is shut down.
+		if node.electionCtx.Err() != nil {
+			node.log.Info("withdrawn from the election, waiting for shutdown")
+			if node.IsLeader() {
+				node.setLeader("")
+			}
+			close(node.drained)
+			<-node.ctx.Done()
+			node.log.Info("terminating: context cancelled")
+			return node.ctx.Err()
+		}
+
+		reason := node.rerunReason()
+
+		// If the node's participation was paused, it does not re-join the
+		// election until it is resumed. It no longer observes the leader in
+		// the meantime.
+		if node.isPaused() {
+			if node.IsLeader() {
+				node.setLeader("")
+			}
+			node.waitWhilePaused()
+		}
+
+		// Wait a short period of time so the topology has a little
 		// bit of time to settle.
-		time.Sleep(1 * time.Second)
-		klog.Info("re-running election")
+		select {
+		case <-node.ctx.Done():
+			node.log.Info("terminating: context cancelled")
+			return node.ctx.Err()
+		case <-node.clock.After(node.rerunDelay()):
+		}
+		node.recordRerun(reason)
+		node.log.Infof("re-running election (epoch %d): %s", node.electionEpoch(), reason)
 	}
 }
 
-// run the election.
-func (node *ElectorNode) run() error {
+// kubeClient gets the Kubernetes client used by the elector node. If the node
+// was configured with a client, that client is used. Otherwise, a client is
+// built from the node's kubeconfig.
+//
+// The client is built once and reused by each run of the election, so its
+// transport, and the token it has read, carry over between runs.
+func (node *ElectorNode) kubeClient() (kubernetes.Interface, error) {
+	if node.config.Client != nil {
+		return node.config.Client, nil
+	}
+
+	node.clientMu.Lock()
+	defer node.clientMu.Unlock()
+	if node.client != nil {
+		return node.client, nil
+	}
+
 	config, err := node.buildClientConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	node.client = client
+	return client, nil
+}
+
+// dynamicClient gets the dynamic Kubernetes client for the elector node,
+// used by the dynamic lock type. As with kubeClient, it is built once and
+// reused by each run of the election.
+func (node *ElectorNode) dynamicClient() (dynamic.Interface, error) {
+	if node.config.DynamicClient != nil {
+		return node.config.DynamicClient, nil
 	}
 
-	// Once the context is cancelled, prevent the client from making more requests.
-	config.Wrap(transport.ContextCanceller(node.ctx, errors.New("the node is shutting down")))
+	node.clientMu.Lock()
+	defer node.clientMu.Unlock()
+	if node.dynClient != nil {
+		return node.dynClient, nil
+	}
 
-	// Create the lock object which will be used to determine leadership in the election.
-	lock, err := resourcelock.New(
-		node.config.LockType,
-		node.config.Namespace,
+	config, err := node.buildClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	node.dynClient = client
+	return client, nil
+}
+
+// newLock creates the election lock for the configured lock type.
+func (node *ElectorNode) newLock(client kubernetes.Interface) (resourcelock.Interface, error) {
+	lockConfig := resourcelock.ResourceLockConfig{
+		Identity:      node.config.ID,
+		EventRecorder: &lockRecorder{log: node.log},
+	}
+
+	if node.config.LockType == DynamicResourceLock {
+		dynamicClient, err := node.dynamicClient()
+		if err != nil {
+			return nil, err
+		}
+		return newDynamicLock(
+			dynamicClient,
+			node.config.LockResource,
+			node.config.LockNamespace,
+			node.config.Name,
+			lockConfig,
+		)
+	}
+
+	return resourcelock.New(
+		node.electionLockType(),
+		node.config.LockNamespace,
 		node.config.Name,
 		client.CoreV1(),
 		client.CoordinationV1(),
-		resourcelock.ResourceLockConfig{
-			Identity:      node.config.ID,
-			EventRecorder: &lockRecorder{},
-		},
+		lockConfig,
 	)
+}
+
+// rerunDelay gets the time to wait before re-running the election.
+//
+// If the node was demoted in the last run of the election, it waits for the
+// post-demotion cooldown as well. This gives the new leader time to stabilize
+// and prevents two equally fast nodes from repeatedly taking leadership from
+// each other.
+func (node *ElectorNode) rerunDelay() time.Duration {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+
+	delay := rejoinDelay
+	if node.demoted {
+		node.demoted = false
+		if node.config.PostDemotionCooldown > 0 {
+			node.log.Infof("waiting %v after demotion before re-joining the election", node.config.PostDemotionCooldown)
+			delay += node.config.PostDemotionCooldown
+		}
+	}
+	return delay
+}
+
+// run the election.
+func (node *ElectorNode) run() error {
+	client, err := node.kubeClient()
 	if err != nil {
-		return err
+		return &runError{category: runErrorClient, err: err}
+	}
+	if node.startupCancelled() {
+		return nil
+	}
+
+	// The namespaces are only verified when the node starts; once the
+	// election has started, they are known to exist.
+	if node.config.VerifyNamespace && node.isInitializing() {
+		if err := node.verifyNamespaces(client); err != nil {
+			return &runError{category: runErrorConfig, err: err}
+		}
+		if node.startupCancelled() {
+			return nil
+		}
+	}
+
+	if node.config.SingleNode {
+		node.setInitializing(false)
+		return node.runSingleNode(client)
+	}
+	node.checkBackend(client)
+	if !node.passive {
+		go node.checkPermissions(client)
+	}
+	node.checkMigration(client)
+	if node.startupCancelled() {
+		return nil
+	}
+
+	// Create the lock object which will be used to determine leadership in the election.
+	lock, err := node.newLock(client)
+	if err != nil {
+		return &runError{category: runErrorLock, err: err}
+	}
+	if node.startupCancelled() {
+		return nil
+	}
+	electionName, err := renderElectionName(node.config)
+	if err != nil {
+		return &runError{category: runErrorConfig, err: err}
+	}
+	node.setInitializing(false)
+	tolerant := newTolerantLock(node.chaosLock(lock), node.clock, node.log, node.rawRecordReader(client, lock), 0, node.setLockCorrupt)
+	observed := newObservedLock(newInstrumentedLock(tolerant, node.clock, node.observeSlowRenewals, node.observeAuthErrors), node.clock)
+
+	// If configured to, use the lease duration of an in-progress election so
+	// joining it does not disrupt the election with mismatched timings.
+	leaseDuration := node.config.TTL
+	if node.config.AdoptExistingLeaseDuration {
+		if d, ok := existingLeaseDuration(observed); ok {
+			node.log.Infof("adopting lease duration from existing lock: %v", d)
+			leaseDuration = d
+		}
+	}
+	node.mu.Lock()
+	node.leaseDuration = leaseDuration
+	if node.lock != nil {
+		node.renewals += node.lock.renewCount()
+	}
+	node.lock = observed
+	node.mu.Unlock()
+	timings := node.timings()
+
+	// Once the node's lease duration is settled, each observed record is
+	// checked against it, so mismatched participants and leaderless windows
+	// are noticed.
+	observed.observe = node.observeRecord
+	observed.deleted = node.lockDeleted
+	observed.recreated = node.lockRecreated(client)
+
+	// A corrupt lock record can only be repaired once it has gone unchanged
+	// for a lease duration, since no holder can have renewed it in that time.
+	if node.config.RepairCorruptLock {
+		tolerant.repairAfter = timings.LeaseDuration
+	}
+
+	// Only stand for election while the candidacy check passes. Once it stops
+	// passing, the election is cancelled, which withdraws the node until it is
+	// re-run.
+	if !node.waitForCandidacy(node.electionCtx, timings.RetryPeriod) {
+		return nil
+	}
+	ctx, withdraw := context.WithCancel(node.electionCtx)
+	defer withdraw()
+	if !node.standFor(withdraw) {
+		return nil
+	}
+	if node.config.CandidacyCheck != nil {
+		go node.watchCandidacy(ctx, timings.RetryPeriod, withdraw)
 	}
 
+	if node.config.ReconcileInterval > 0 {
+		go node.reconcileLeadership(ctx, lock, observed, node.config.ReconcileInterval, withdraw)
+	}
+
+	policy := node.config.SelectionPolicy
+	if policy == nil {
+		policy = NoopSelectionPolicy{}
+	}
+	selection := newSelectionLock(ctx, observed, policy, Candidate{
+		ID:        node.config.ID,
+		Election:  node.config.Name,
+		Namespace: node.config.Namespace,
+		PodName:   node.config.PodName,
+	}, node.log)
+
+	// The node's Pod is cached for this run of the election, if enabled, and
+	// the status is published by the publishers for it. Once the election
+	// stops, any pending status is published.
+	pods := node.startPodCache(client)
+	defer node.stopPodCache(pods)
+	publishers := node.newPublishers(client)
+	node.setPublishers(publishers)
+	defer node.stopPublishers(publishers)
+
 	// Start the election.
-	leaderelection.RunOrDie(node.ctx, leaderelection.LeaderElectionConfig{
-		Lock:            lock,
-		Name:            fmt.Sprintf("%s/%s-%s", node.config.Namespace, node.config.Name, node.config.ID),
-		ReleaseOnCancel: true,
-		LeaseDuration:   node.config.TTL,
-		RenewDeadline:   node.config.TTL / 3,
-		RetryPeriod:     node.config.TTL / 6,
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            selection,
+		Name:            electionName,
+		ReleaseOnCancel: !node.config.KeepLeaseOnShutdown,
+		LeaseDuration:   timings.LeaseDuration,
+		RenewDeadline:   timings.RenewDeadline,
+		RetryPeriod:     timings.RetryPeriod,
 		Callbacks: leaderelection.LeaderCallbacks{
+			// The election runs this callback in its own goroutine, so it
+			// is tracked to finish before the node stops.
 			OnStartedLeading: func(i context.Context) {
-				klog.Infof("[%s] started leading", node.config.ID)
-
-				// Add/update Pod label marking this instance as the leader.
-				if err := updatePodLabel(node.config, client, StatusLeader); err != nil {
-					klog.Errorf("failed to set leader annotation: %v", err)
-				}
+				node.track(func() {
+					node.startedLeading(client, observed.previousRecord())
+				})
 			},
+			// The election stops leading even if the node never acquired
+			// the lock, e.g. when it is shut down, paused or withdrawn as a
+			// standby. There is no leadership to step down from then.
 			OnStoppedLeading: func() {
-				klog.Infof("[%s] stepping down as leader", node.config.ID)
-
-				// Add/update Pod label marking this instance as not the leader.
-				if err := updatePodLabel(node.config, client, StatusStandby); err != nil {
-					klog.Errorf("failed to set standby annotation: %v", err)
+				if observed.renewCount() == 0 {
+					return
 				}
+				node.stoppedLeading()
 			},
 			OnNewLeader: func(identity string) {
-				node.currentLeader = identity
+				previous := node.setLeader(identity)
+				node.recordFirstLeader(identity)
+				if previous != "" && previous != identity {
+					node.log.Infof("leadership moved from %s to %s", previous, identity)
+				}
+				if node.config.OnNewLeader != nil {
+					node.config.OnNewLeader(identity, parseLeaderMeta(identity))
+				}
 
 				if node.IsLeader() {
 					// This node was elected. Nothing to do here since this node will
 					// also call the OnStartedLeading callback.
 					return
 				}
-				klog.Infof("new leader elected: %s", identity)
+				if previous == "" || previous == identity {
+					node.log.Infof("new leader elected: %s (epoch %d)", identity, node.electionEpoch())
+				}
+
+				if node.passive {
+					return
+				}
 
 				// Add/update Pod label marking this instance as a standby node.
-				if err := updatePodLabel(node.config, client, StatusStandby); err != nil {
-					klog.Errorf("failed to set standby annotation: %v", err)
-				}
+				node.publishStatus(StatusStandby)
 			},
 		},
 	})
 
+	// When withdrawing for a pre-stop hook, when paused, or when stepping
+	// down after the candidacy check stopped passing, the lock is handed off
+	// even if the node is not configured to release it on shutdown.
+	candidacyLost := node.takeCandidacyLost()
+	if node.ctx.Err() == nil && (node.electionCtx.Err() != nil || node.isPaused() || candidacyLost) {
+		node.releaseLock(observed)
+	}
 	return nil
 }
 
-// patchLabel specifies a patch operation for a label string.
-type patchLabel struct {
-	Op    string `json:"op"`
-	Path  string `json:"path"`
-	Value string `json:"value"`
+// newPublishers creates the publishers of the node's status for a run of the
+// election. A passive node has no publishers.
+func (node *ElectorNode) newPublishers(client kubernetes.Interface) []*debouncedPublisher {
+	if node.passive {
+		return nil
+	}
+
+	// Only the publishers which are enabled are registered, so the disabled
+	// ones never see a status change.
+	var publishers []*debouncedPublisher
+	add := func(name string, p publisher) {
+		if node.config.publisherEnabled(name) {
+			publishers = append(publishers, newDebouncedPublisher(p, node.clock, node.config.PublishDebounce, node.log, node.operations))
+		}
+	}
+	labels := &podLabelPublisher{config: node.config, client: client, pods: node.podCache(), log: node.log, fault: node.patchFault}
+	if labels.pods != nil && node.config.publisherEnabled(PublisherPodLabel) {
+		labels.pods.onUpdate(labels.heal)
+	}
+	add(PublisherPodLabel, labels)
+	add(PublisherSubscriptions, newSubscriptionPublisher(node))
+	add(PublisherWatch, &watchPublisher{node: node})
+	if node.config.publisherEnabled(PublisherStatusObject) {
+		dynamicClient, err := node.dynamicClient()
+		if err != nil {
+			node.log.Errorf("not publishing to the status object: failed to create dynamic client: %v", err)
+		} else {
+			add(PublisherStatusObject, &statusObjectPublisher{node: node, client: dynamicClient})
+		}
+	}
+	if node.config.publisherEnabled(PublisherExternalDNS) {
+		dynamicClient, err := node.dynamicClient()
+		if err != nil {
+			node.log.Errorf("not publishing to the external-dns service: failed to create dynamic client: %v", err)
+		} else {
+			add(PublisherExternalDNS, &externalDNSPublisher{node: node, client: dynamicClient})
+		}
+	}
+	return publishers
+}
+
+// startedLeading runs the side effects of the node acquiring leadership. The
+// previous record is the lock record which was in place before the node
+// acquired the lock, if any.
+func (node *ElectorNode) startedLeading(client kubernetes.Interface, previous *resourcelock.LeaderElectionRecord) {
+	node.log.Infof("[%s] started leading (epoch %d)", node.config.ID, node.electionEpoch())
+	node.recordAcquisition()
+
+	if node.passive {
+		return
+	}
+
+	// Add/update Pod label marking this instance as the leader.
+	node.publishStatus(StatusLeader)
+
+	if node.config.OnElected != "" {
+		if err := node.runCommand(node.config.OnElected, EventElected, 0); err != nil {
+			node.log.repeatedErrorf("failed to run on-elected command: %v", err)
+		}
+	}
+
+	if node.config.RecordOutages {
+		node.recordOutage(client, previous)
+	}
+
+	if node.config.RecordHistory {
+		node.recordHistory(client, previous)
+	}
+}
+
+// stoppedLeading runs the side effects of the node losing leadership.
+func (node *ElectorNode) stoppedLeading() {
+	node.log.Infof("[%s] stepping down as leader (epoch %d)", node.config.ID, node.electionEpoch())
+
+	node.mu.Lock()
+	node.demoted = true
+	node.mu.Unlock()
+	node.log.setRole(logRoleStandby)
+
+	if node.passive {
+		return
+	}
+
+	// Add/update Pod label marking this instance as not the leader.
+	node.publishStatus(StatusStandby)
+
+	if node.config.OnDemoted != "" {
+		if err := node.runCommand(node.config.OnDemoted, EventDemoted, node.config.OnDemotedTimeout); err != nil {
+			node.log.repeatedErrorf("failed to run on-demoted command: %v", err)
+		}
+	}
+}
+
+// electionTimings holds the timings used by the election.
+type electionTimings struct {
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// timings gets the effective timings for the election.
+//
+// The timings are derived from the lease duration: the renew deadline is a
+// third of the lease duration and the retry period is a sixth of it. The lease
+// duration is the node's TTL, unless the lease duration of an existing lock
+// was adopted when the election started.
+func (node *ElectorNode) timings() electionTimings {
+	node.mu.RLock()
+	leaseDuration := node.leaseDuration
+	node.mu.RUnlock()
+
+	if leaseDuration == 0 {
+		leaseDuration = node.config.TTL
+	}
+	return electionTimings{
+		LeaseDuration: leaseDuration,
+		RenewDeadline: leaseDuration / 3,
+		RetryPeriod:   leaseDuration / 6,
+	}
+}
+
+// existingLeaseDuration gets the lease duration recorded on an existing election
+// lock. If the lock does not exist or does not have a lease duration set, false
+// is returned.
+func existingLeaseDuration(lock resourcelock.Interface) (time.Duration, bool) {
+	record, _, err := lock.Get()
+	if err != nil || record == nil || record.LeaseDurationSeconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(record.LeaseDurationSeconds) * time.Second, true
 }
 
 // updatePodLabel updates the label for the k8s-elector Pod to designate its
@@ -255,33 +1160,35 @@ type patchLabel struct {
 //
 // If the elector instance becomes the leader, a value of "leader" is set. Otherwise, a
 // value of "standby" is set.
-func updatePodLabel(cfg *ElectorConfig, clientset *kubernetes.Clientset, value string) error {
-
-	// First, get the Pod. We want to first check whether or not the Pod has the
-	// label key or not. If not, add it; if so, update it.
-	pod, err := clientset.CoreV1().Pods(cfg.Namespace).Get(cfg.PodName, metav1.GetOptions{})
+//
+// The label is set with a merge patch, which adds the label if it does not exist
+// and replaces it if it does. This means the update only takes a single request,
+// without needing to first get the Pod. The annotation marking the label as
+// owned by the elector, and the role name of the elector, if any, are set in
+// the same patch.
+func updatePodLabel(cfg *ElectorConfig, clientset kubernetes.Interface, value string) error {
+	annotations := map[string]string{
+		PodLabelOwnerAnnotationKey: value,
+	}
+	if role := cfg.role(); role != "" {
+		annotations[PodRoleAnnotationKey] = role
+	}
+	metadata := map[string]interface{}{
+		"labels": map[string]string{
+			PodLabelKey: value,
+		},
+		"annotations": annotations,
+	}
+	payload := map[string]interface{}{
+		"metadata": metadata,
+	}
+	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
-
-	// Determine the operation by whether or not the label key exists.
-	var operation string
-	if _, ok := pod.Labels[ResourcePathPodLabel]; ok {
-		operation = "replace"
-	} else {
-		operation = "add"
-	}
-
-	// Patch the Pod label.
-	payload := []patchLabel{{
-		Op:    operation,
-		Path:  ResourcePathPodLabel,
-		Value: value,
-	}}
-	payloadBytes, _ := json.Marshal(payload)
 	_, err = clientset.CoreV1().Pods(cfg.Namespace).Patch(
 		cfg.PodName,
-		types.JSONPatchType,
+		types.MergePatchType,
 		payloadBytes,
 	)
 	return err
@@ -305,87 +1212,272 @@ func (node *ElectorNode) checkConfig() error {
 		)
 	}
 
+	// The role name is cosmetic, so it defaults to the election name.
+	if node.config.RoleName == "" {
+		node.config.RoleName = node.config.Name
+	}
+
 	hostname, err := os.Hostname()
 	if err != nil {
 		return err
 	}
 
+	if node.config.External {
+		node.configureExternal()
+	}
+	if node.config.PureElection {
+		node.configurePureElection()
+	}
+	if node.config.Chaos {
+		if err := node.checkChaos(); err != nil {
+			return err
+		}
+	}
+
 	// Get the name of the Pod. This is used to assign the leadership status
-	// annotation. If the Pod name is not set via Env, it will default to the
-	// hostname.
-	if val := os.Getenv(EnvPodName); val != "" {
-		node.config.PodName = val
-	} else {
-		klog.Infof("pod name not specified, using hostname: %s", hostname)
-		node.config.PodName = hostname
+	// annotation. If the Pod name is not set in the config or via Env, it will
+	// default to the hostname. A node running outside of Kubernetes has no Pod.
+	if node.config.PodName == "" && !node.config.External {
+		if val := os.Getenv(EnvPodName); val != "" {
+			node.config.PodName = val
+		} else {
+			node.log.Infof("pod name not specified, using hostname: %s", hostname)
+			node.config.PodName = hostname
+		}
+	}
+
+	// Get the name of the Kubernetes node the Pod runs on, so leadership can
+	// be correlated with the node. It is only reported if it is set.
+	if node.config.NodeName == "" && !node.config.External {
+		node.config.NodeName = os.Getenv(EnvNodeName)
+	}
+	if node.config.PodIP == "" && !node.config.External {
+		node.config.PodIP = os.Getenv(EnvPodIP)
+	}
+
+	// Resolve the namespace of the election, logging where it came from so it
+	// is clear where the election runs.
+	namespace, source := node.resolveNamespace()
+	node.log.Infof("using namespace %s (from %s)", namespace, source)
+	node.config.Namespace = namespace
+
+	// Unless otherwise specified, the election lock lives in the same namespace
+	// as the Pod.
+	if node.config.LockNamespace == "" {
+		node.config.LockNamespace = node.config.Namespace
+	}
+
+	// The dynamic lock type needs the resource of the lock object. The lock
+	// history is recorded via the built-in lock objects, so it is not supported.
+	if node.config.LockType == DynamicResourceLock {
+		if err := validateLockResource(node.config.LockResource); err != nil {
+			return fmt.Errorf("invalid lock resource for the %s lock type: %v", DynamicResourceLock, err)
+		}
+		if node.config.RecordHistory {
+			return fmt.Errorf("recording leader history is not supported with the %s lock type", DynamicResourceLock)
+		}
+	}
+
+	// Only the lock types which have a multi-lock can be migrated from.
+	if node.config.LockTypeMigrateFrom != "" {
+		if _, err := migrationLockType(node.config.LockTypeMigrateFrom, node.config.LockType); err != nil {
+			return err
+		}
+	}
+
+	// Outages and history are recorded to the election lock and its
+	// ConfigMap, which a single node does not use.
+	if node.config.SingleNode {
+		if node.config.RecordOutages {
+			return errors.New("recording outages is not supported for a single node")
+		}
+		if node.config.RecordHistory {
+			return errors.New("recording leader history is not supported for a single node")
+		}
+	}
+
+	// The status object lives in the namespace of the election unless
+	// otherwise specified.
+	if node.config.StatusObjectName != "" {
+		if err := validateLockResource(node.config.StatusObjectResource); err != nil {
+			return fmt.Errorf("invalid status object resource: %v", err)
+		}
+		if node.config.StatusObjectNamespace == "" {
+			node.config.StatusObjectNamespace = node.config.Namespace
+		}
+	}
+
+	// A hostname is only applied to the external-dns Service.
+	if node.config.ExternalDNSHostname != "" && node.config.ExternalDNSService == "" {
+		return errors.New("an external-dns hostname requires an external-dns service")
+	}
+
+	// Unknown publisher names are rejected, rather than silently leaving a
+	// publisher running which was meant to be disabled.
+	if err := validatePublishers(node.config.Publishers); err != nil {
+		return fmt.Errorf("invalid publishers: %v", err)
+	}
+	if err := validatePublishers(node.config.DisabledPublishers); err != nil {
+		return fmt.Errorf("invalid disabled publishers: %v", err)
+	}
+
+	if node.config.OutageRecordLimit <= 0 {
+		node.config.OutageRecordLimit = DefaultOutageRecordLimit
+	}
+
+	if node.config.HistoryLimit <= 0 {
+		node.config.HistoryLimit = DefaultHistoryLimit
+	}
+
+	if err := node.checkStaleThresholds(); err != nil {
+		return err
+	}
+
+	if node.config.SlowRenewalFraction <= 0 {
+		node.config.SlowRenewalFraction = DefaultSlowRenewalFraction
+	}
+
+	if node.config.ClientMaxIdleConns < 0 {
+		return fmt.Errorf("invalid client max idle connections %d: must not be negative", node.config.ClientMaxIdleConns)
+	}
+	if node.config.ClientMaxIdleConns == 0 {
+		node.config.ClientMaxIdleConns = DefaultClientMaxIdleConns
+	}
+
+	if node.config.ClientMaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("invalid client max idle connections per host %d: must not be negative", node.config.ClientMaxIdleConnsPerHost)
+	}
+	if node.config.ClientMaxIdleConnsPerHost == 0 {
+		node.config.ClientMaxIdleConnsPerHost = defaultClientMaxIdleConnsPerHost()
 	}
 
 	// If the elector node was not provided with an ID, use the machine's
-	// hostname as the default ID value.
+	// hostname as the default ID value. Outside of Kubernetes, the user is
+	// added, since developers may share a machine or a hostname.
+	if node.config.ID == "" && node.config.External {
+		node.config.ID = externalID(hostname)
+		node.log.Infof("no ID specified for elector node, using user@hostname: %s", node.config.ID)
+	}
+	if node.config.ID == "" && node.config.StructuredID {
+		node.config.ID = structuredID(hostname, node.config.PodIP, node.config.NodeName)
+		node.log.Infof("no ID specified for elector node, using structured ID: %s", node.config.ID)
+	}
 	if node.config.ID == "" {
-		klog.Infof("no ID specified for elector node, using hostname: %s", hostname)
+		node.log.Infof("no ID specified for elector node, using hostname: %s", hostname)
 		node.config.ID = hostname
+
+		// Pods can share a hostname (e.g. with hostNetwork, or a fixed
+		// hostname in the Pod spec), which would give them the same ID. If
+		// configured to, the Pod name is added to the ID to tell them apart.
+		if node.config.ResolveIDCollisions && node.config.PodName != hostname {
+			node.config.ID = hostname + "-" + node.config.PodName
+			node.log.Infof("pod name differs from hostname, using composite ID: %s", node.config.ID)
+		}
+	}
+
+	if err := validateID(node.config.ID); err != nil {
+		return err
+	}
+
+	// The election name is rendered once the namespace and ID are resolved,
+	// so an invalid template fails before the election starts.
+	electionName, err := renderElectionName(node.config)
+	if err != nil {
+		return err
 	}
+	node.log.Infof("using election name: %s", electionName)
 
 	return nil
 }
 
-// listenForSignal sets up the elector node's termination channel to listen for
-// system signals which designate that the node should terminate.
-//
-// The signals that are listened for are: SIGINT, SIGKILL, SIGTERM. Any of these
-// will cause the node to terminate gracefully.
-func (node *ElectorNode) listenForSignal() {
-	signal.Notify(node.quit, os.Interrupt, os.Kill, syscall.SIGTERM)
-
-	klog.Info("listening for shutdown signals...")
+// resolveNamespace resolves the namespace of the election and describes the
+// source it was resolved from. The namespace is resolved, in order of
+// precedence, from:
+//   - the config (e.g. the -namespace flag)
+//   - the ELECTOR_NAMESPACE environment variable
+//   - the namespace of the Pod's service account, when running in a cluster
+//   - the namespace of the kubeconfig's current context, in external mode
+//   - the "default" namespace
+func (node *ElectorNode) resolveNamespace() (namespace, source string) {
+	if node.config.Namespace != "" {
+		return node.config.Namespace, "config"
+	}
+	if val := os.Getenv(EnvNamespace); val != "" {
+		return val, "env " + EnvNamespace
+	}
+	if data, err := ioutil.ReadFile(serviceAccountNamespaceFile); err == nil {
+		if ns := strings.TrimSpace(string(data)); ns != "" {
+			return ns, "service account"
+		}
+	}
+	if node.config.External && node.config.KubeConfig != "" {
+		if ns := kubeConfigNamespace(node.config.KubeConfig); ns != "" {
+			return ns, "kubeconfig"
+		}
+	}
+	return DefaultNamespace, "default"
+}
 
-	sig := <-node.quit
-	klog.Infof("shutting down: received termination signal %v", sig)
-	node.cancel()
-	close(node.quit)
+// notifySignals relays the signals which the node handles to its quit
+// channel, where they are buffered until the node listens for them.
+func (node *ElectorNode) notifySignals() {
+	node.signals.Notify(node.quit, handledSignals...)
 }
 
-// serveHTTP starts the HTTP server which exposes the leader information.
+// listenForSignal sets up the elector node's signal channel to listen for
+// system signals which designate that the node should terminate or dump
+// its status.
 //
-// If the elector is not configured with an address (via the -http flag), the
-// HTTP server will not be started.
-func (node *ElectorNode) serveHTTP() {
-	if node.config.Address == "" {
-		klog.Info("http server will not be started: no address given")
-		return
-	}
+// The termination signals that are listened for are: SIGINT, SIGKILL, SIGTERM.
+// Any of these will cause the node to terminate gracefully. A SIGUSR1 causes the
+// node to log a snapshot of its status.
+//
+// The listener stops once the node's context is cancelled.
+func (node *ElectorNode) listenForSignal() {
+	node.notifySignals()
+	defer node.signals.Stop(node.quit)
 
-	klog.Infof("starting HTTP server on %v", node.config.Address)
-	http.HandleFunc("/", node.httpLeaderInfo)
-	node.servingHTTP = true
-	err := http.ListenAndServe(node.config.Address, nil)
-	if err != nil {
-		klog.Fatalf("failed to start the HTTP server: %v", err)
+	node.log.Info("listening for shutdown signals...")
+
+	for {
+		select {
+		case <-node.ctx.Done():
+			return
+		case sig := <-node.quit:
+			if node.handleSignal(sig) {
+				return
+			}
+		}
 	}
 }
 
-// httpLeaderInfo is the handler for the endpoint which provides leader info.
-func (node *ElectorNode) httpLeaderInfo(res http.ResponseWriter, req *http.Request) {
-	klog.Infof("received incoming http request: %s %s (%s)", req.Method, req.URL, req.RemoteAddr)
-	data, err := json.Marshal(map[string]interface{}{
-		"node":      node.config.ID,
-		"leader":    node.currentLeader,
-		"is_leader": node.IsLeader(),
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-	})
-	if err != nil {
-		res.WriteHeader(http.StatusInternalServerError)
-		if _, e := res.Write([]byte(err.Error())); e != nil {
-			klog.Errorf("failed writing http error response (%v): %v", err, e)
+// handlePendingSignals handles the signals which were buffered before the
+// node listened for them, without waiting for more. It returns whether the
+// node is terminating.
+func (node *ElectorNode) handlePendingSignals() bool {
+	for {
+		select {
+		case sig := <-node.quit:
+			if node.handleSignal(sig) {
+				return true
+			}
+		default:
+			return false
 		}
-		return
 	}
+}
 
-	res.Header()["Content-Type"] = []string{"application/json"}
-	res.WriteHeader(http.StatusOK)
-	_, err = res.Write(data)
-	if err != nil {
-		klog.Errorf("failed to write leader info http response: %v", err)
+// handleSignal handles a signal received by the node. A SIGUSR1 logs the
+// node's status; any other signal terminates the node, in which case true
+// is returned.
+func (node *ElectorNode) handleSignal(sig os.Signal) bool {
+	if sig == syscall.SIGUSR1 {
+		node.logStatus()
+		return false
 	}
+
+	node.log.Infof("shutting down: received termination signal %v", sig)
+	node.setStopReason("received termination signal %v", sig)
+	node.terminate()
+	return true
 }
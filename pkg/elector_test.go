@@ -1,16 +1,24 @@
 package pkg
 
 import (
-	"bytes"
+	"encoding/base64"
 	"encoding/json"
-	"net/http/httptest"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
-	"k8s.io/klog"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 )
 
 func TestNewElectorNode(t *testing.T) {
@@ -43,9 +51,18 @@ func TestNewElectorNode(t *testing.T) {
 		assert.NotNil(t, node.cancel, c.description)
 		assert.NotNil(t, node.ctx, c.description)
 		assert.NotNil(t, node.quit, c.description)
+		assert.Equal(t, clock.RealClock{}, node.clock, c.description)
 	}
 }
 
+func TestNewElectorNode_clock(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	node := NewElectorNode(&ElectorConfig{Clock: clk})
+
+	assert.Equal(t, clk, node.clock)
+	assert.Equal(t, clk.Now(), node.started)
+}
+
 func TestElectorNode_Run_badConfig(t *testing.T) {
 	node := NewElectorNode(&ElectorConfig{})
 	assert.Nil(t, node.ctx.Err())
@@ -106,6 +123,20 @@ func TestElectorNode_checkConfig_error(t *testing.T) {
 			description: "config missing required name",
 			config:      &ElectorConfig{},
 		},
+		{
+			description: "negative client max idle connections",
+			config: &ElectorConfig{
+				Name:               "test-name",
+				ClientMaxIdleConns: -1,
+			},
+		},
+		{
+			description: "negative client max idle connections per host",
+			config: &ElectorConfig{
+				Name:                      "test-name",
+				ClientMaxIdleConnsPerHost: -1,
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -151,6 +182,13 @@ func TestElectorNode_checkConfig_ok(t *testing.T) {
 
 		err := node.checkConfig()
 		assert.NoError(t, err, c.description)
+
+		// The role name defaults to the election name.
+		assert.Equal(t, c.config.Name, c.config.RoleName, c.description)
+
+		// The client connection pool is sized by default.
+		assert.Equal(t, DefaultClientMaxIdleConns, c.config.ClientMaxIdleConns, c.description)
+		assert.Equal(t, defaultClientMaxIdleConnsPerHost(), c.config.ClientMaxIdleConnsPerHost, c.description)
 	}
 }
 
@@ -239,97 +277,899 @@ func TestElectorNode_buildClientConfig_ok(t *testing.T) {
 	assert.NotNil(t, cfg)
 }
 
-func TestElectorNode_serveHTTP_noAddress(t *testing.T) {
+func TestElectorNode_buildClientConfig_kubeConfigData(t *testing.T) {
+	data, err := ioutil.ReadFile("./testdata/config")
+	assert.NoError(t, err)
+
+	defer os.Unsetenv(EnvKubeConfigData)
+	assert.NoError(t, os.Setenv(EnvKubeConfigData, base64.StdEncoding.EncodeToString(data)))
+
+	// The kubeconfig data takes precedence over the kubeconfig file.
 	node := ElectorNode{
 		config: &ElectorConfig{
-			Address: "",
+			KubeConfig: "./testdata/does-not-exist",
 		},
 	}
+	cfg, err := node.buildClientConfig()
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) {
+		assert.Equal(t, "https://localhost:6443", cfg.Host)
+	}
+	assert.True(t, NewElectorNode(&ElectorConfig{}).configInfo().KubeConfigData)
+}
 
-	var buf bytes.Buffer
-	klog.SetOutput(&buf)
+func TestKubeConfigFromData_error(t *testing.T) {
+	cases := []struct {
+		description string
+		data        string
+		err         string
+	}{
+		{
+			description: "invalid base64",
+			data:        "not base64!",
+			err:         "failed to decode base64",
+		},
+		{
+			description: "invalid kubeconfig",
+			data:        base64.StdEncoding.EncodeToString([]byte("token: secret-value\n\tnot: yaml")),
+			err:         "failed to parse kubeconfig",
+		},
+		{
+			description: "kubeconfig without a cluster",
+			data:        base64.StdEncoding.EncodeToString([]byte("apiVersion: v1\nkind: Config\n")),
+			err:         "failed to load kubeconfig",
+		},
+	}
 
-	node.serveHTTP()
-	assert.False(t, node.servingHTTP)
-	assert.Contains(t, buf.String(), "no address given")
+	for _, c := range cases {
+		cfg, err := kubeConfigFromData(c.data)
+		assert.Nil(t, cfg, c.description)
+		if assert.Error(t, err, c.description) {
+			assert.Contains(t, err.Error(), EnvKubeConfigData, c.description)
+			assert.Contains(t, err.Error(), c.err, c.description)
+			assert.NotContains(t, err.Error(), "secret-value", c.description)
+		}
+	}
 }
 
-func TestElectorNode_httpHandler_noLeader(t *testing.T) {
-	node := NewElectorNode(&ElectorConfig{
-		ID: "test-node-1",
+func TestUpdatePodLabel(t *testing.T) {
+	cases := []struct {
+		description string
+		labels      map[string]string
+	}{
+		{
+			description: "pod has no labels",
+			labels:      nil,
+		},
+		{
+			description: "pod has other labels",
+			labels:      map[string]string{"app": "test"},
+		},
+		{
+			description: "pod already has the status label",
+			labels:      map[string]string{"app": "test", PodLabelKey: StatusStandby},
+		},
+	}
+
+	for _, c := range cases {
+		client := fake.NewSimpleClientset(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-pod",
+				Namespace: "test-ns",
+				Labels:    c.labels,
+			},
+		})
+		cfg := &ElectorConfig{
+			Namespace: "test-ns",
+			PodName:   "test-pod",
+		}
+
+		err := updatePodLabel(cfg, client, StatusLeader)
+		assert.NoError(t, err, c.description)
+
+		// The label update should only take a single API request.
+		actions := client.Actions()
+		assert.Len(t, actions, 1, c.description)
+		assert.Equal(t, "patch", actions[0].GetVerb(), c.description)
+
+		pod, err := client.CoreV1().Pods("test-ns").Get("test-pod", metav1.GetOptions{})
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, StatusLeader, pod.Labels[PodLabelKey], c.description)
+		for k, v := range c.labels {
+			if k != PodLabelKey {
+				assert.Equal(t, v, pod.Labels[k], c.description)
+			}
+		}
+	}
+}
+
+func TestUpdatePodLabel_noPod(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	cfg := &ElectorConfig{
+		Namespace: "test-ns",
+		PodName:   "test-pod",
+	}
+
+	err := updatePodLabel(cfg, client, StatusLeader)
+	assert.Error(t, err)
+}
+
+func TestExistingLeaseDuration(t *testing.T) {
+	holder := "test-node-2"
+	duration := int32(30)
+	client := fake.NewSimpleClientset(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-election",
+			Namespace: "test-ns",
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+		},
 	})
+	lock, err := resourcelock.New(
+		resourcelock.LeasesResourceLock,
+		"test-ns",
+		"test-election",
+		client.CoreV1(),
+		client.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: "test-node-1"},
+	)
+	assert.NoError(t, err)
 
-	req := httptest.NewRequest("GET", "localhost:3333/", nil)
-	w := httptest.NewRecorder()
+	d, ok := existingLeaseDuration(lock)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, d)
+}
 
-	node.httpLeaderInfo(w, req)
+func TestExistingLeaseDuration_noLock(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	lock, err := resourcelock.New(
+		resourcelock.LeasesResourceLock,
+		"test-ns",
+		"test-election",
+		client.CoreV1(),
+		client.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: "test-node-1"},
+	)
+	assert.NoError(t, err)
 
-	resp := w.Result()
+	d, ok := existingLeaseDuration(lock)
+	assert.False(t, ok)
+	assert.Equal(t, time.Duration(0), d)
+}
+
+// waitFor waits for the condition to be true, failing the test if it does
+// not become true before the timeout.
+func waitFor(t *testing.T, timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return assert.Fail(t, "timed out waiting for condition")
+}
+
+// newTestPod creates a Pod for the elector node to run in.
+func newTestPod(namespace, name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
 
-	data := map[string]interface{}{}
-	d := json.NewDecoder(resp.Body)
-	err := d.Decode(&data)
+func TestElectorNode_run_lockNamespace(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("pod-ns", "test-pod"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "pod-ns",
+		LockNamespace: "lock-ns",
+		PodName:       "test-pod",
+		LockType:      resourcelock.LeasesResourceLock,
+		TTL:           1 * time.Second,
+		Client:        client,
+		Logger:        &testLogger{},
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.run()
+	}()
+
+	waitFor(t, 5*time.Second, func() bool {
+		pod, err := client.CoreV1().Pods("pod-ns").Get("test-pod", metav1.GetOptions{})
+		return err == nil && pod.Labels[PodLabelKey] == StatusLeader
+	})
+
+	// The lease is created in the lock namespace, not the Pod namespace.
+	lease, err := client.CoordinationV1().Leases("lock-ns").Get("test-election", metav1.GetOptions{})
 	assert.NoError(t, err)
+	assert.Equal(t, "test-node-1", *lease.Spec.HolderIdentity)
+	_, err = client.CoordinationV1().Leases("pod-ns").Get("test-election", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	node.cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "election did not stop")
+	}
+}
+
+func TestElectorNode_checkConfig_lockNamespace(t *testing.T) {
+	cases := []struct {
+		description   string
+		namespace     string
+		lockNamespace string
+		expected      string
+	}{
+		{
+			description: "lock namespace defaults to the namespace",
+			namespace:   "test-ns",
+			expected:    "test-ns",
+		},
+		{
+			description:   "lock namespace is set",
+			namespace:     "test-ns",
+			lockNamespace: "lock-ns",
+			expected:      "lock-ns",
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			Name:          "test-election",
+			Namespace:     c.namespace,
+			LockNamespace: c.lockNamespace,
+		})
+
+		err := node.checkConfig()
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, c.expected, node.config.LockNamespace, c.description)
+	}
+}
 
-	assert.Equal(t, 200, resp.StatusCode)
-	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+func TestElectorNode_rerunDelay(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		PostDemotionCooldown: 5 * time.Second,
+		Logger:               &testLogger{},
+	})
 
-	assert.NotNil(t, data["timestamp"])
-	assert.Equal(t, "test-node-1", data["node"])
-	assert.Equal(t, "", data["leader"])
-	assert.Equal(t, false, data["is_leader"])
+	// Without a demotion, there is no cooldown.
+	assert.Equal(t, 1*time.Second, node.rerunDelay())
+
+	// After a demotion, the cooldown is applied once.
+	node.demoted = true
+	assert.Equal(t, 6*time.Second, node.rerunDelay())
+	assert.Equal(t, 1*time.Second, node.rerunDelay())
 }
 
-func TestElectorNode_httpHandler_otherNodeIsLeader(t *testing.T) {
+func TestElectorNode_rerunDelay_noCooldown(t *testing.T) {
 	node := NewElectorNode(&ElectorConfig{
-		ID: "test-node-1",
+		Logger: &testLogger{},
 	})
-	node.currentLeader = "test-node-2"
 
-	req := httptest.NewRequest("GET", "localhost:3333/", nil)
-	w := httptest.NewRecorder()
+	node.demoted = true
+	assert.Equal(t, 1*time.Second, node.rerunDelay())
+	assert.False(t, node.demoted)
+}
+
+func TestElectorNode_Stop(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{})
+	assert.Nil(t, node.ctx.Err())
+
+	node.Stop()
+	assert.Error(t, node.ctx.Err())
+}
+
+func TestElectorNode_listenForSignal_cancelled(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		Logger: &testLogger{},
+	})
 
-	node.httpLeaderInfo(w, req)
+	done := make(chan struct{})
+	go func() {
+		node.listenForSignal()
+		close(done)
+	}()
 
-	resp := w.Result()
+	node.Stop()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "failed to stop listening for signals")
+	}
+}
 
-	data := map[string]interface{}{}
-	d := json.NewDecoder(resp.Body)
-	err := d.Decode(&data)
+func TestElectorNode_resolveNamespace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "namespace")
 	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	saFile := filepath.Join(dir, "namespace")
+	assert.NoError(t, ioutil.WriteFile(saFile, []byte("sa-ns\n"), 0644))
 
-	assert.Equal(t, 200, resp.StatusCode)
-	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	defer func(f string) { serviceAccountNamespaceFile = f }(serviceAccountNamespaceFile)
+	defer os.Unsetenv(EnvNamespace)
 
-	assert.NotNil(t, data["timestamp"])
-	assert.Equal(t, "test-node-1", data["node"])
-	assert.Equal(t, "test-node-2", data["leader"])
-	assert.Equal(t, false, data["is_leader"])
+	cases := []struct {
+		description string
+		namespace   string
+		env         string
+		saFile      string
+		expected    string
+		source      string
+	}{
+		{
+			description: "namespace set in config",
+			namespace:   "config-ns",
+			env:         "env-ns",
+			saFile:      saFile,
+			expected:    "config-ns",
+			source:      "config",
+		},
+		{
+			description: "namespace set via env",
+			env:         "env-ns",
+			saFile:      saFile,
+			expected:    "env-ns",
+			source:      "env ELECTOR_NAMESPACE",
+		},
+		{
+			description: "namespace from the service account",
+			saFile:      saFile,
+			expected:    "sa-ns",
+			source:      "service account",
+		},
+		{
+			description: "namespace defaults to default",
+			saFile:      filepath.Join(dir, "does-not-exist"),
+			expected:    "default",
+			source:      "default",
+		},
+	}
+
+	for _, c := range cases {
+		assert.NoError(t, os.Setenv(EnvNamespace, c.env), c.description)
+		serviceAccountNamespaceFile = c.saFile
+
+		log := &testLogger{}
+		node := NewElectorNode(&ElectorConfig{
+			Name:      "test-election",
+			Namespace: c.namespace,
+			Logger:    log,
+		})
+
+		err := node.checkConfig()
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, c.expected, node.config.Namespace, c.description)
+		assert.Equal(t, c.expected, node.config.LockNamespace, c.description)
+		assert.Contains(t, log.String(), fmt.Sprintf("using namespace %s (from %s)", c.expected, c.source), c.description)
+	}
 }
 
-func TestElectorNode_httpHandler_isLeader(t *testing.T) {
+func TestElectorNode_checkConfig_podName(t *testing.T) {
+	hostname, err := os.Hostname()
+	assert.NoError(t, err)
+
+	cases := []struct {
+		description string
+		podName     string
+		env         string
+		expected    string
+	}{
+		{
+			description: "pod name defaults to the hostname",
+			expected:    hostname,
+		},
+		{
+			description: "pod name set via env",
+			env:         "env-pod",
+			expected:    "env-pod",
+		},
+		{
+			description: "pod name set in config",
+			podName:     "config-pod",
+			env:         "env-pod",
+			expected:    "config-pod",
+		},
+	}
+
+	defer os.Unsetenv(EnvPodName)
+	for _, c := range cases {
+		assert.NoError(t, os.Setenv(EnvPodName, c.env), c.description)
+		node := NewElectorNode(&ElectorConfig{
+			Name:    "test-election",
+			PodName: c.podName,
+			Logger:  &testLogger{},
+		})
+
+		err := node.checkConfig()
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, c.expected, node.config.PodName, c.description)
+	}
+}
+
+func TestElectorNode_checkConfig_nodeName(t *testing.T) {
+	cases := []struct {
+		description string
+		nodeName    string
+		env         string
+		external    bool
+		expected    string
+	}{
+		{
+			description: "node name not set",
+			expected:    "",
+		},
+		{
+			description: "node name set via env",
+			env:         "env-node",
+			expected:    "env-node",
+		},
+		{
+			description: "node name set in config",
+			nodeName:    "config-node",
+			env:         "env-node",
+			expected:    "config-node",
+		},
+		{
+			description: "node name not detected in external mode",
+			env:         "env-node",
+			external:    true,
+			expected:    "",
+		},
+	}
+
+	defer os.Unsetenv(EnvNodeName)
+	for _, c := range cases {
+		assert.NoError(t, os.Setenv(EnvNodeName, c.env), c.description)
+		node := NewElectorNode(&ElectorConfig{
+			Name:     "test-election",
+			PodName:  "test-pod",
+			NodeName: c.nodeName,
+			External: c.external,
+			Logger:   &testLogger{},
+		})
+
+		err := node.checkConfig()
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, c.expected, node.config.NodeName, c.description)
+		assert.Equal(t, c.expected, node.leaderInfo().NodeName, c.description)
+	}
+}
+
+func TestElectorNode_run_keepLeaseOnShutdown(t *testing.T) {
+	cases := []struct {
+		description string
+		keep        bool
+		holder      string
+	}{
+		{
+			description: "lease released on shutdown",
+			keep:        false,
+			holder:      "",
+		},
+		{
+			description: "lease kept on shutdown",
+			keep:        true,
+			holder:      "test-node-1",
+		},
+	}
+
+	for _, c := range cases {
+		client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+		node := NewElectorNode(&ElectorConfig{
+			ID:                  "test-node-1",
+			Name:                "test-election",
+			Namespace:           "test-ns",
+			LockNamespace:       "test-ns",
+			PodName:             "test-pod",
+			LockType:            resourcelock.LeasesResourceLock,
+			TTL:                 1 * time.Second,
+			Client:              client,
+			Logger:              &testLogger{},
+			KeepLeaseOnShutdown: c.keep,
+		})
+
+		holder := func() string {
+			lease, err := client.CoordinationV1().Leases("test-ns").Get("test-election", metav1.GetOptions{})
+			if err != nil || lease.Spec.HolderIdentity == nil {
+				return ""
+			}
+			return *lease.Spec.HolderIdentity
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- node.run()
+		}()
+		waitFor(t, 5*time.Second, func() bool {
+			return holder() == "test-node-1"
+		})
+
+		node.Stop()
+		select {
+		case err := <-done:
+			assert.NoError(t, err, c.description)
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "election did not stop", c.description)
+		}
+		assert.Equal(t, c.holder, holder(), c.description)
+	}
+}
+
+func TestElectorNode_recordAcquisition(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{ID: "test-node-1"})
+	hasLed, acquisitions := node.leadership()
+	assert.False(t, hasLed)
+	assert.Equal(t, 0, acquisitions)
+
+	// Acquisitions are recorded concurrently by the callbacks of the primary
+	// election, and are read by the HTTP handlers.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			node.recordAcquisition()
+			node.leaderInfo()
+		}()
+	}
+	wg.Wait()
+
+	hasLed, acquisitions = node.leadership()
+	assert.True(t, hasLed)
+	assert.Equal(t, 10, acquisitions)
+}
+
+func TestElectorNode_run_acquisitions(t *testing.T) {
+	candidacy := &testCandidacy{ok: true}
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:                      "test-node-1",
+		Name:                    "test-election",
+		Namespace:               "test-ns",
+		LockNamespace:           "test-ns",
+		PodName:                 "test-pod",
+		LockType:                resourcelock.LeasesResourceLock,
+		TTL:                     1 * time.Second,
+		Client:                  client,
+		CandidacyCheck:          candidacy.check,
+		StepDownOnCandidacyLoss: true,
+		Logger:                  &testLogger{},
+	})
+	defer node.Stop()
+
+	// Each run of the election acquires leadership, then steps down once the
+	// node is no longer a candidate. The acquisitions are kept across runs.
+	for i := 1; i <= 2; i++ {
+		candidacy.set(true)
+		done := make(chan error, 1)
+		go func() {
+			done <- node.run()
+		}()
+		waitFor(t, 5*time.Second, func() bool {
+			_, acquisitions := node.leadership()
+			return acquisitions == i
+		})
+
+		candidacy.set(false)
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "election did not stop")
+		}
+		node.setLeader("")
+	}
+
+	info := node.leaderInfo()
+	assert.False(t, info.IsLeader)
+	assert.True(t, info.HasLed)
+	assert.Equal(t, 2, info.Acquisitions)
+}
+
+func TestElectorNode_renewCount(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{ID: "test-node-1"})
+	assert.Equal(t, int64(0), node.renewCount())
+
+	node.lock = newObservedLock(&fakeLock{identity: "test-node-1"}, clock.RealClock{})
+	assert.NoError(t, node.lock.Create(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+	assert.NoError(t, node.lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+	assert.Equal(t, int64(2), node.renewCount())
+
+	// The renewals of previous runs are kept.
+	node.renewals = 5
+	assert.Equal(t, int64(7), node.renewCount())
+}
+
+func TestElectorNode_recordFirstLeader(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Logger: &testLogger{},
+	})
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	node.clock = clk
+	node.started = clk.Now()
+
+	cases := []struct {
+		description string
+		step        time.Duration
+		identity    string
+		expected    time.Duration
+		observed    bool
+	}{
+		{
+			description: "no leader is not recorded",
+			step:        time.Second,
+			identity:    "",
+			expected:    0,
+			observed:    false,
+		},
+		{
+			description: "first leader is recorded",
+			step:        2 * time.Second,
+			identity:    "test-node-2",
+			expected:    3 * time.Second,
+			observed:    true,
+		},
+		{
+			description: "later leaders are not recorded",
+			step:        5 * time.Second,
+			identity:    "test-node-1",
+			expected:    3 * time.Second,
+			observed:    true,
+		},
+	}
+
+	for _, c := range cases {
+		clk.Step(c.step)
+		node.recordFirstLeader(c.identity)
+		d, ok := node.firstLeaderTime()
+		assert.Equal(t, c.expected, d, c.description)
+		assert.Equal(t, c.observed, ok, c.description)
+	}
+}
+
+// testLockHolder gets the holder recorded on the election lock object of the
+// given lock type. If the lock object does not exist, false is returned.
+func testLockHolder(t *testing.T, client *fake.Clientset, lockType, namespace, name string) (string, bool) {
+	if lockType == resourcelock.LeasesResourceLock {
+		lease, err := client.CoordinationV1().Leases(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return "", false
+		}
+		if lease.Spec.HolderIdentity == nil {
+			return "", true
+		}
+		return *lease.Spec.HolderIdentity, true
+	}
+
+	var annotations map[string]string
+	switch lockType {
+	case resourcelock.EndpointsResourceLock:
+		obj, err := client.CoreV1().Endpoints(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return "", false
+		}
+		annotations = obj.Annotations
+	case resourcelock.ConfigMapsResourceLock:
+		obj, err := client.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return "", false
+		}
+		annotations = obj.Annotations
+	default:
+		t.Fatalf("unsupported lock type: %s", lockType)
+	}
+
+	var record resourcelock.LeaderElectionRecord
+	if err := json.Unmarshal([]byte(annotations[resourcelock.LeaderElectionRecordAnnotationKey]), &record); err != nil {
+		t.Fatalf("failed to parse lock record: %v", err)
+	}
+	return record.HolderIdentity, true
+}
+
+func TestElectorNode_run_lockTypes(t *testing.T) {
+	lockTypes := []string{
+		resourcelock.LeasesResourceLock,
+		resourcelock.EndpointsResourceLock,
+		resourcelock.ConfigMapsResourceLock,
+	}
+
+	for _, lockType := range lockTypes {
+		client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+		node := NewElectorNode(&ElectorConfig{
+			ID:            "test-node-1",
+			Name:          "test-election",
+			Namespace:     "test-ns",
+			LockNamespace: "test-ns",
+			PodName:       "test-pod",
+			LockType:      lockType,
+			TTL:           1 * time.Second,
+			Client:        client,
+			Logger:        &testLogger{},
+		})
+
+		podLabel := func() string {
+			pod, err := client.CoreV1().Pods("test-ns").Get("test-pod", metav1.GetOptions{})
+			if err != nil {
+				return ""
+			}
+			return pod.Labels[PodLabelKey]
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- node.run()
+		}()
+		waitFor(t, 5*time.Second, func() bool {
+			return node.IsLeader() && podLabel() == StatusLeader
+		})
+
+		// Only the lock object of the lock type is created, and it is held
+		// by the node.
+		for _, other := range lockTypes {
+			holder, exists := testLockHolder(t, client, other, "test-ns", "test-election")
+			if other == lockType {
+				assert.True(t, exists, lockType)
+				assert.Equal(t, "test-node-1", holder, lockType)
+			} else {
+				assert.False(t, exists, "%s: %s lock object was created", lockType, other)
+			}
+		}
+
+		// The Pod label is not affected by the lock object, which is a
+		// different object even when it is in the Pod's namespace.
+		pod, err := client.CoreV1().Pods("test-ns").Get("test-pod", metav1.GetOptions{})
+		assert.NoError(t, err, lockType)
+		assert.NotContains(t, pod.Annotations, resourcelock.LeaderElectionRecordAnnotationKey, lockType)
+
+		node.Stop()
+		select {
+		case err := <-done:
+			assert.NoError(t, err, lockType)
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "election did not stop", lockType)
+		}
+
+		// On shutdown, the lock is released in place and the Pod label is
+		// updated.
+		holder, exists := testLockHolder(t, client, lockType, "test-ns", "test-election")
+		assert.True(t, exists, lockType)
+		assert.Equal(t, "", holder, lockType)
+		assert.Equal(t, StatusStandby, podLabel(), lockType)
+	}
+}
+
+func TestElectorNode_setLeader_previous(t *testing.T) {
 	node := NewElectorNode(&ElectorConfig{
-		ID: "test-node-1",
+		ID:     "test-node-1",
+		Logger: &testLogger{},
 	})
-	node.currentLeader = "test-node-1"
 
-	req := httptest.NewRequest("GET", "localhost:3333/", nil)
-	w := httptest.NewRecorder()
+	cases := []struct {
+		description string
+		identity    string
+		previous    string
+	}{
+		{
+			description: "first election",
+			identity:    "test-node-2",
+			previous:    "",
+		},
+		{
+			description: "leadership moves to the node",
+			identity:    "test-node-1",
+			previous:    "test-node-2",
+		},
+		{
+			description: "same leader",
+			identity:    "test-node-1",
+			previous:    "test-node-2",
+		},
+		{
+			description: "leader released",
+			identity:    "",
+			previous:    "test-node-1",
+		},
+		{
+			description: "leadership moves from the released leader",
+			identity:    "test-node-3",
+			previous:    "test-node-1",
+		},
+		{
+			description: "leadership moves back",
+			identity:    "test-node-1",
+			previous:    "test-node-3",
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.previous, node.setLeader(c.identity), c.description)
+		assert.Equal(t, c.previous, node.previousLeaderID(), c.description)
+		assert.Equal(t, c.previous, node.leaderInfo().PreviousLeader, c.description)
+	}
+
+	assert.Equal(t, map[leaderChange]int{
+		{From: "", To: "test-node-2"}:            1,
+		{From: "test-node-2", To: "test-node-1"}: 1,
+		{From: "test-node-1", To: "test-node-3"}: 1,
+		{From: "test-node-3", To: "test-node-1"}: 1,
+	}, node.leaderChangeCounts())
+}
 
-	node.httpLeaderInfo(w, req)
+func TestElectorNode_setLeader_reacquired(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Logger: &testLogger{},
+	})
 
-	resp := w.Result()
+	// A leader which re-acquires leadership after releasing it is not a
+	// leader change.
+	node.setLeader("test-node-1")
+	node.setLeader("")
+	assert.Equal(t, "test-node-1", node.setLeader("test-node-1"))
+	assert.Equal(t, map[leaderChange]int{
+		{From: "", To: "test-node-1"}: 1,
+	}, node.leaderChangeCounts())
+}
 
-	data := map[string]interface{}{}
-	d := json.NewDecoder(resp.Body)
-	err := d.Decode(&data)
+func TestElectorNode_checkConfig_resolveIDCollisions(t *testing.T) {
+	hostname, err := os.Hostname()
 	assert.NoError(t, err)
 
-	assert.Equal(t, 200, resp.StatusCode)
-	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	cases := []struct {
+		description string
+		id          string
+		podName     string
+		resolve     bool
+		expected    string
+	}{
+		{
+			description: "not resolved",
+			podName:     "test-pod",
+			resolve:     false,
+			expected:    hostname,
+		},
+		{
+			description: "pod name differs from hostname",
+			podName:     "test-pod",
+			resolve:     true,
+			expected:    hostname + "-test-pod",
+		},
+		{
+			description: "pod name is the hostname",
+			podName:     hostname,
+			resolve:     true,
+			expected:    hostname,
+		},
+		{
+			description: "id is set",
+			id:          "test-node-1",
+			podName:     "test-pod",
+			resolve:     true,
+			expected:    "test-node-1",
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:                  c.id,
+			Name:                "test-election",
+			Namespace:           "test-ns",
+			PodName:             c.podName,
+			ResolveIDCollisions: c.resolve,
+			Logger:              &testLogger{},
+		})
 
-	assert.NotNil(t, data["timestamp"])
-	assert.Equal(t, "test-node-1", data["node"])
-	assert.Equal(t, "test-node-1", data["leader"])
-	assert.Equal(t, true, data["is_leader"])
+		assert.NoError(t, node.checkConfig(), c.description)
+		assert.Equal(t, c.expected, node.config.ID, c.description)
+	}
 }
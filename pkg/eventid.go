@@ -0,0 +1,81 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// DefaultEventIDHeader is the default name of the header which carries the ID
+// of the node's last leadership event, on HTTP responses and webhook requests.
+const DefaultEventIDHeader = "X-Elector-Event-ID"
+
+// randomEventID generates a random event ID.
+func randomEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// The system's random number generator is not expected to fail, and
+		// an event without an ID is still delivered.
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// newEvent records a new leadership event, e.g. a change of leader, giving it
+// a unique ID. Consumers use the ID to deduplicate and correlate the event
+// across the HTTP API, watchers, and subscriptions.
+//
+// The caller must hold the node's lock.
+func (node *ElectorNode) newEvent() {
+	generate := node.newEventID
+	if generate == nil {
+		generate = randomEventID
+	}
+	node.eventID = generate()
+	node.leaderPayload = nil
+}
+
+// lastEventID gets the ID of the node's last leadership event. It is empty
+// until the node has observed a leader.
+func (node *ElectorNode) lastEventID() string {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.eventID
+}
+
+// eventIDHeader gets the name of the header which carries the ID of the
+// node's last leadership event.
+func (node *ElectorNode) eventIDHeader() string {
+	if node.config.EventIDHeader != "" {
+		return node.config.EventIDHeader
+	}
+	return DefaultEventIDHeader
+}
+
+// withEventID wraps a handler to set the ID of the node's last leadership
+// event as a header on each response, once the node has one.
+func (node *ElectorNode) withEventID(handler http.Handler) http.Handler {
+	header := node.eventIDHeader()
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if id := node.lastEventID(); id != "" {
+			res.Header().Set(header, id)
+		}
+		handler.ServeHTTP(res, req)
+	})
+}
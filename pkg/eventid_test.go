@@ -0,0 +1,107 @@
+package pkg
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sequentialEventIDs gets an event ID generator which counts up from 1.
+func sequentialEventIDs() func() string {
+	var n int
+	return func() string {
+		n++
+		return fmt.Sprintf("test-event-%d", n)
+	}
+}
+
+func TestRandomEventID(t *testing.T) {
+	a := randomEventID()
+	b := randomEventID()
+	assert.Len(t, a, 32)
+	assert.NotEqual(t, a, b)
+}
+
+func TestElectorNode_newEvent(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{ID: "test-node-1"})
+	node.newEventID = sequentialEventIDs()
+	assert.Equal(t, "", node.lastEventID())
+
+	node.setLeader("test-node-2")
+	assert.Equal(t, "test-event-1", node.lastEventID())
+	assert.Equal(t, "test-event-1", getJSON(t, node, "/")["event_id"])
+
+	// Observing the same leader is not a new event.
+	node.setLeader("test-node-2")
+	assert.Equal(t, "test-event-1", node.lastEventID())
+
+	node.setLeader("test-node-1")
+	assert.Equal(t, "test-event-2", node.lastEventID())
+	assert.Equal(t, "test-event-2", node.leadershipEvent(StatusLeader).ID)
+	assert.Equal(t, "test-event-2", getJSON(t, node, "/")["event_id"])
+
+	// The leader stepping down is a new event.
+	node.stepDown()
+	assert.Equal(t, "test-event-3", node.lastEventID())
+}
+
+func TestElectorNode_withEventID(t *testing.T) {
+	cases := []struct {
+		description string
+		header      string
+		expected    string
+	}{
+		{
+			description: "default header",
+			header:      "",
+			expected:    DefaultEventIDHeader,
+		},
+		{
+			description: "configured header",
+			header:      "X-Request-ID",
+			expected:    "X-Request-ID",
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:            "test-node-1",
+			EventIDHeader: c.header,
+		})
+		node.newEventID = sequentialEventIDs()
+
+		// No header is set until there is an event.
+		w := httptest.NewRecorder()
+		node.handler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		assert.NotContains(t, w.Header(), c.expected, c.description)
+
+		node.setLeader("test-node-1")
+		for _, path := range []string{"/", "/config", "/not-found"} {
+			w := httptest.NewRecorder()
+			node.handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+			assert.Equal(t, "test-event-1", w.Header().Get(c.expected), c.description, path)
+		}
+	}
+}
+
+func TestSubscriptionPublisher_eventID(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		headers <- req.Header
+	}))
+	defer server.Close()
+
+	node := NewElectorNode(&ElectorConfig{ID: "test-node-1", Logger: &testLogger{}})
+	node.newEventID = sequentialEventIDs()
+	node.setLeader("test-node-1")
+	_, err := node.subscriptions.add(server.URL)
+	assert.NoError(t, err)
+
+	assert.NoError(t, newSubscriptionPublisher(node).publish(StatusLeader))
+	header := <-headers
+	assert.Equal(t, "test-event-1", header.Get(DefaultEventIDHeader))
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+}
@@ -0,0 +1,79 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"os"
+	"os/user"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// envKubernetesServiceHost is the environment variable Kubernetes sets in
+// every container with the address of the API server.
+const envKubernetesServiceHost = "KUBERNETES_SERVICE_HOST"
+
+// externalDisabledFeatures are the Pod-coupled features which are disabled
+// when the elector runs outside of Kubernetes.
+var externalDisabledFeatures = []string{
+	"pod status labels",
+	"pod name detection",
+	"termination message",
+}
+
+// DetectExternal detects whether the elector is running outside of
+// Kubernetes, e.g. on a developer's machine against a remote cluster. This is
+// the case when neither the in-cluster environment nor the Pod name is set.
+func DetectExternal() bool {
+	return os.Getenv(envKubernetesServiceHost) == "" && os.Getenv(EnvPodName) == ""
+}
+
+// externalID gets the default ID of a node running outside of Kubernetes, as
+// "<user>@<hostname>". This keeps developers sharing a machine, or running
+// the same hostname, from colliding. If the user can not be determined, the
+// hostname is used.
+func externalID(hostname string) string {
+	u, err := user.Current()
+	if err != nil || u.Username == "" {
+		return hostname
+	}
+	return u.Username + "@" + hostname
+}
+
+// kubeConfigNamespace gets the namespace of the current context of the
+// kubeconfig file. If it does not set one, or can not be loaded, an empty
+// string is returned.
+func kubeConfigNamespace(path string) string {
+	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: path},
+		&clientcmd.ConfigOverrides{},
+	)
+	namespace, _, err := loader.Namespace()
+	if err != nil {
+		return ""
+	}
+	return namespace
+}
+
+// configureExternal disables the Pod-coupled features of a node running
+// outside of Kubernetes. They are logged once here, rather than failing each
+// time they are used.
+func (node *ElectorNode) configureExternal() {
+	node.config.TerminationMessagePath = ""
+	node.log.Infof("running outside of Kubernetes (external mode): %s are disabled", strings.Join(externalDisabledFeatures, ", "))
+}
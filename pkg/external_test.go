@@ -0,0 +1,178 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// setEnv sets or, if the value is empty, unsets an environment variable for
+// a test, returning a function which restores it.
+func setEnv(t *testing.T, key, value string) func() {
+	previous, ok := os.LookupEnv(key)
+	if value == "" {
+		assert.NoError(t, os.Unsetenv(key))
+	} else {
+		assert.NoError(t, os.Setenv(key, value))
+	}
+	return func() {
+		if ok {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestDetectExternal(t *testing.T) {
+	cases := []struct {
+		description string
+		serviceHost string
+		podName     string
+		expected    bool
+	}{
+		{
+			description: "outside of kubernetes",
+			expected:    true,
+		},
+		{
+			description: "in a cluster",
+			serviceHost: "10.0.0.1",
+			podName:     "test-pod",
+			expected:    false,
+		},
+		{
+			description: "in a cluster without the pod name",
+			serviceHost: "10.0.0.1",
+			expected:    false,
+		},
+		{
+			description: "pod name set outside of kubernetes",
+			podName:     "test-pod",
+			expected:    false,
+		},
+	}
+
+	for _, c := range cases {
+		restoreHost := setEnv(t, envKubernetesServiceHost, c.serviceHost)
+		restorePod := setEnv(t, EnvPodName, c.podName)
+		assert.Equal(t, c.expected, DetectExternal(), c.description)
+		restorePod()
+		restoreHost()
+	}
+}
+
+func TestExternalID(t *testing.T) {
+	id := externalID("test-host")
+	assert.True(t, strings.HasSuffix(id, "@test-host"), id)
+	assert.NoError(t, validateID(id))
+}
+
+func TestKubeConfigNamespace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	withNamespace := filepath.Join(dir, "with-namespace")
+	assert.NoError(t, ioutil.WriteFile(withNamespace, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://127.0.0.1:6443
+  name: test-cluster
+contexts:
+- context:
+    cluster: test-cluster
+    namespace: dev-ns
+  name: test
+current-context: test
+`), 0644))
+
+	assert.Equal(t, "dev-ns", kubeConfigNamespace(withNamespace))
+	assert.Equal(t, "", kubeConfigNamespace(filepath.Join(dir, "missing")))
+}
+
+func TestElectorNode_checkConfig_external(t *testing.T) {
+	defer setEnv(t, EnvPodName, "")()
+	defer setEnv(t, EnvNamespace, "")()
+	defer func(f string) { serviceAccountNamespaceFile = f }(serviceAccountNamespaceFile)
+	serviceAccountNamespaceFile = "/nonexistent"
+
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	kubeconfig := filepath.Join(dir, "config")
+	assert.NoError(t, ioutil.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://127.0.0.1:6443
+  name: test-cluster
+contexts:
+- context:
+    cluster: test-cluster
+    namespace: dev-ns
+  name: test
+current-context: test
+`), 0644))
+
+	logger := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		Name:                   "test-election",
+		KubeConfig:             kubeconfig,
+		TerminationMessagePath: DefaultTerminationMessagePath,
+		External:               true,
+		Logger:                 logger,
+	})
+	assert.NoError(t, node.checkConfig())
+
+	// The Pod-coupled features are disabled, with a single log line.
+	assert.Equal(t, "", node.config.PodName)
+	assert.Equal(t, "", node.config.TerminationMessagePath)
+	assert.Equal(t, 1, strings.Count(logger.String(), "external mode"))
+
+	hostname, err := os.Hostname()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(node.config.ID, "@"+hostname), node.config.ID)
+	assert.Equal(t, "dev-ns", node.config.Namespace)
+	assert.True(t, node.configInfo().External)
+}
+
+func TestElectorNode_newPublishers_external(t *testing.T) {
+	cases := []struct {
+		description string
+		external    bool
+		expected    []string
+	}{
+		{
+			description: "in a cluster",
+			external:    false,
+			expected:    []string{"pod label", "subscriptions", "watchers"},
+		},
+		{
+			description: "external",
+			external:    true,
+			expected:    []string{"subscriptions", "watchers"},
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:       "test-node-1",
+			Name:     "test-election",
+			External: c.external,
+			Logger:   &testLogger{},
+		})
+
+		var names []string
+		for _, p := range node.newPublishers(fake.NewSimpleClientset()) {
+			names = append(names, p.publisher.name())
+		}
+		assert.Equal(t, c.expected, names, c.description)
+	}
+}
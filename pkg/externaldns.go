@@ -0,0 +1,142 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// ExternalDNSHostnameAnnotationKey is the external-dns annotation which
+	// names the DNS record to publish for a Service.
+	ExternalDNSHostnameAnnotationKey = "external-dns.alpha.kubernetes.io/hostname"
+
+	// ServiceLeaderAnnotationKey is the annotation of the external-dns Service
+	// which holds the ID of the leader which maintains it.
+	ServiceLeaderAnnotationKey = "k8s-elector/leader"
+
+	// ServiceLeaderPodAnnotationKey is the annotation of the external-dns
+	// Service which holds the name of the leader's Pod.
+	ServiceLeaderPodAnnotationKey = "k8s-elector/leader-pod"
+)
+
+// servicesResource is the resource of Services, which the external-dns
+// publisher applies to with the dynamic client.
+var servicesResource = schema.GroupVersionResource{Version: "v1", Resource: "services"}
+
+// externalDNSPublisher publishes the leadership of the elector node to a
+// Service managed by external-dns, so a DNS name follows the leader.
+//
+// Only the leader writes to the Service: it applies the external-dns hostname
+// annotation, annotations naming itself, and a selector on the leader status
+// of the Pod label. Standbys never touch the Service, and a leader which
+// steps down leaves it for its successor to rewrite. The fields are written
+// with server-side apply, so the Service's other annotations and selector
+// keys are left alone. The Service is never created.
+type externalDNSPublisher struct {
+	node   *ElectorNode
+	client dynamic.Interface
+}
+
+func (p *externalDNSPublisher) name() string {
+	return "external-dns service"
+}
+
+func (p *externalDNSPublisher) publish(status string) error {
+	if status != StatusLeader {
+		return nil
+	}
+
+	// A conflict means another field manager owns some of the fields, e.g.
+	// the selector set by a manifest. The elector owns the fields it applies,
+	// so it retries, taking them over.
+	force := false
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := p.apply(force)
+		if apierrors.IsConflict(err) && !force {
+			p.node.log.Warningf("fields of service %s are managed by another manager, taking them over: %v", p.describe(), err)
+			force = true
+		}
+		return err
+	})
+}
+
+// describe describes the Service for logging.
+func (p *externalDNSPublisher) describe() string {
+	conf := p.node.config
+	return conf.Namespace + "/" + conf.ExternalDNSService
+}
+
+// apply writes the node's leadership to the Service.
+//
+// The Service is read first, so it is not created by the apply if it does
+// not exist.
+func (p *externalDNSPublisher) apply(force bool) error {
+	conf := p.node.config
+	services := p.client.Resource(servicesResource).Namespace(conf.Namespace)
+	if _, err := services.Get(conf.ExternalDNSService, metav1.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("service %s does not exist", p.describe())
+		}
+		return err
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name":        conf.ExternalDNSService,
+			"namespace":   conf.Namespace,
+			"annotations": externalDNSAnnotations(conf),
+		},
+		"spec": map[string]interface{}{
+			"selector": map[string]string{
+				PodLabelKey: StatusLeader,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = services.Patch(conf.ExternalDNSService, types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: applyFieldManager,
+		Force:        &force,
+	})
+	return err
+}
+
+// externalDNSAnnotations gets the annotations the leader applies to the
+// external-dns Service.
+func externalDNSAnnotations(conf *ElectorConfig) map[string]string {
+	annotations := map[string]string{
+		ServiceLeaderAnnotationKey:    conf.ID,
+		ServiceLeaderPodAnnotationKey: conf.PodName,
+	}
+	if conf.ExternalDNSHostname != "" {
+		annotations[ExternalDNSHostnameAnnotationKey] = conf.ExternalDNSHostname
+	}
+	return annotations
+}
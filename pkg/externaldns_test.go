@@ -0,0 +1,211 @@
+package pkg
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeService is a Service served by the fake dynamic client. The fake
+// dynamic client does not support server-side apply, so the applied
+// annotations and selector are merged into the Service here.
+type fakeService struct {
+	mu        sync.Mutex
+	service   *unstructured.Unstructured
+	conflicts int
+}
+
+// newFakeService creates a Service with the given annotations and selector,
+// as created by a manifest, and a fake dynamic client which serves it.
+func newFakeService(annotations, selector map[string]string) (*fakeService, *dynamicfake.FakeDynamicClient) {
+	svc := &unstructured.Unstructured{}
+	svc.SetAPIVersion("v1")
+	svc.SetKind("Service")
+	svc.SetNamespace("test-ns")
+	svc.SetName("test-leader")
+	svc.SetAnnotations(annotations)
+	if selector != nil {
+		_ = unstructured.SetNestedStringMap(svc.Object, selector, "spec", "selector")
+	}
+
+	s := &fakeService{service: svc}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	client.PrependReactor("get", "services", s.get)
+	client.PrependReactor("patch", "services", s.patch)
+	return s, client
+}
+
+func (s *fakeService) get(action k8stesting.Action) (bool, runtime.Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.service == nil {
+		return true, nil, apierrors.NewNotFound(servicesResource.GroupResource(), action.(k8stesting.GetAction).GetName())
+	}
+	return true, s.service.DeepCopy(), nil
+}
+
+func (s *fakeService) patch(action k8stesting.Action) (bool, runtime.Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conflicts > 0 {
+		s.conflicts--
+		return true, nil, apierrors.NewConflict(servicesResource.GroupResource(), "test-leader", assert.AnError)
+	}
+
+	applied := &unstructured.Unstructured{}
+	if err := json.Unmarshal(action.(k8stesting.PatchAction).GetPatch(), &applied.Object); err != nil {
+		return true, nil, err
+	}
+	annotations := s.service.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	for k, v := range applied.GetAnnotations() {
+		annotations[k] = v
+	}
+	s.service.SetAnnotations(annotations)
+
+	selector, _, _ := unstructured.NestedStringMap(s.service.Object, "spec", "selector")
+	if selector == nil {
+		selector = map[string]string{}
+	}
+	appliedSelector, _, _ := unstructured.NestedStringMap(applied.Object, "spec", "selector")
+	for k, v := range appliedSelector {
+		selector[k] = v
+	}
+	_ = unstructured.SetNestedStringMap(s.service.Object, selector, "spec", "selector")
+	return true, s.service.DeepCopy(), nil
+}
+
+func (s *fakeService) state() (map[string]string, map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	selector, _, _ := unstructured.NestedStringMap(s.service.Object, "spec", "selector")
+	return s.service.GetAnnotations(), selector
+}
+
+// newTestExternalDNSPublisher creates the external-dns publisher of a node
+// with the given ID, running in the given Pod.
+func newTestExternalDNSPublisher(client *dynamicfake.FakeDynamicClient, id, pod string) (*externalDNSPublisher, *testLogger) {
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:                  id,
+		Name:                "test-election",
+		Namespace:           "test-ns",
+		PodName:             pod,
+		ExternalDNSService:  "test-leader",
+		ExternalDNSHostname: "leader.myapp.example.com",
+		Logger:              log,
+	})
+	return &externalDNSPublisher{node: node, client: client}, log
+}
+
+func TestExternalDNSPublisher_takeover(t *testing.T) {
+	svc, client := newFakeService(
+		map[string]string{"team": "platform"},
+		map[string]string{"app": "myapp"},
+	)
+	first, _ := newTestExternalDNSPublisher(client, "test-node-1", "test-pod-1")
+	second, _ := newTestExternalDNSPublisher(client, "test-node-2", "test-pod-2")
+
+	assert.NoError(t, first.publish(StatusLeader))
+	annotations, selector := svc.state()
+	assert.Equal(t, map[string]string{
+		"team":                           "platform",
+		ExternalDNSHostnameAnnotationKey: "leader.myapp.example.com",
+		ServiceLeaderAnnotationKey:       "test-node-1",
+		ServiceLeaderPodAnnotationKey:    "test-pod-1",
+	}, annotations)
+	assert.Equal(t, map[string]string{"app": "myapp", PodLabelKey: StatusLeader}, selector)
+
+	// The leader steps down, leaving the Service for its successor, which
+	// rewrites the annotations.
+	assert.NoError(t, first.publish(StatusStandby))
+	annotations, _ = svc.state()
+	assert.Equal(t, "test-node-1", annotations[ServiceLeaderAnnotationKey])
+
+	assert.NoError(t, second.publish(StatusLeader))
+	annotations, selector = svc.state()
+	assert.Equal(t, map[string]string{
+		"team":                           "platform",
+		ExternalDNSHostnameAnnotationKey: "leader.myapp.example.com",
+		ServiceLeaderAnnotationKey:       "test-node-2",
+		ServiceLeaderPodAnnotationKey:    "test-pod-2",
+	}, annotations)
+	assert.Equal(t, map[string]string{"app": "myapp", PodLabelKey: StatusLeader}, selector)
+}
+
+func TestExternalDNSPublisher_standby(t *testing.T) {
+	_, client := newFakeService(nil, nil)
+	p, _ := newTestExternalDNSPublisher(client, "test-node-1", "test-pod-1")
+
+	// Standbys never touch the Service, not even to read it.
+	assert.NoError(t, p.publish(StatusStandby))
+	assert.Empty(t, client.Actions())
+}
+
+func TestExternalDNSPublisher_conflict(t *testing.T) {
+	svc, client := newFakeService(nil, nil)
+	svc.conflicts = 1
+	p, log := newTestExternalDNSPublisher(client, "test-node-1", "test-pod-1")
+
+	// The fields owned by another manager are taken over.
+	assert.NoError(t, p.publish(StatusLeader))
+	annotations, _ := svc.state()
+	assert.Equal(t, "test-node-1", annotations[ServiceLeaderAnnotationKey])
+	assert.Contains(t, log.String(), "fields of service test-ns/test-leader are managed by another manager, taking them over")
+
+	patches := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "patch" {
+			patches++
+		}
+	}
+	assert.Equal(t, 2, patches)
+}
+
+func TestExternalDNSPublisher_missing(t *testing.T) {
+	svc, client := newFakeService(nil, nil)
+	svc.service = nil
+	p, _ := newTestExternalDNSPublisher(client, "test-node-1", "test-pod-1")
+
+	// The Service is never created.
+	assert.EqualError(t, p.publish(StatusLeader), "service test-ns/test-leader does not exist")
+	for _, action := range client.Actions() {
+		assert.Equal(t, "get", action.GetVerb())
+	}
+}
+
+func TestElectorNode_newPublishers_externalDNS(t *testing.T) {
+	_, client := newFakeService(nil, nil)
+	node := NewElectorNode(&ElectorConfig{
+		ID:                 "test-node-1",
+		Name:               "test-election",
+		Namespace:          "test-ns",
+		ExternalDNSService: "test-leader",
+		DynamicClient:      client,
+		Logger:             &testLogger{},
+	})
+
+	publishers := node.newPublishers(fake.NewSimpleClientset())
+	assert.Equal(t, "external-dns service", publishers[len(publishers)-1].publisher.name())
+	assert.Contains(t, node.configInfo().Publishers, PublisherExternalDNS)
+}
+
+func TestElectorNode_checkConfig_externalDNS(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		Name:                "test-election",
+		PodName:             "test-pod",
+		ExternalDNSHostname: "leader.myapp.example.com",
+		Logger:              &testLogger{},
+	})
+	assert.EqualError(t, node.checkConfig(), "an external-dns hostname requires an external-dns service")
+}
@@ -0,0 +1,76 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// errForeignLabel is the error for a status label which the elector refuses
+// to overwrite, since it was set by something else.
+var errForeignLabel = errors.New("not set by the elector")
+
+// podLabelForeign checks whether the Pod's status label was set by something
+// other than the elector, i.e. it has a value other than the one the
+// elector's ownership annotation records. A Pod without the label has no
+// foreign label. A label without the annotation was set by an elector older
+// than the annotation if it has one of the elector's status values, so it is
+// not foreign either.
+func podLabelForeign(pod *corev1.Pod) bool {
+	value, ok := pod.Labels[PodLabelKey]
+	if !ok {
+		return false
+	}
+	owned, annotated := pod.Annotations[PodLabelOwnerAnnotationKey]
+	if !annotated {
+		return value != StatusLeader && value != StatusStandby
+	}
+	return value != owned
+}
+
+// currentPod gets the node's Pod to check its status label before it is
+// patched. The cached Pod is used if there is one. Otherwise, the Pod is only
+// read when foreign labels are respected, so the label is patched without
+// reading the Pod by default. If the Pod is not read, nil is returned.
+func (p *podLabelPublisher) currentPod() (*corev1.Pod, error) {
+	switch {
+	case p.pods != nil && p.pods.cached():
+		return p.pods.get()
+	case p.config.RespectForeignLabels:
+		return p.client.CoreV1().Pods(p.config.Namespace).Get(p.config.PodName, metav1.GetOptions{})
+	}
+	return nil, nil
+}
+
+// checkForeignLabel checks whether the Pod's status label may be overwritten
+// with the status. A label set by something else is only overwritten, with a
+// warning, if foreign labels are not respected.
+func (p *podLabelPublisher) checkForeignLabel(pod *corev1.Pod, status string) error {
+	if !podLabelForeign(pod) {
+		return nil
+	}
+	value := pod.Labels[PodLabelKey]
+	if p.config.RespectForeignLabels {
+		return fmt.Errorf("pod label %s has the value %q, which was %w; not overwriting it", PodLabelKey, value, errForeignLabel)
+	}
+	p.log.Warningf("pod label %s has the value %q, which was not set by the elector; overwriting it with %q", PodLabelKey, value, status)
+	return nil
+}
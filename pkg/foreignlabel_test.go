@@ -0,0 +1,212 @@
+package pkg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPodLabelForeign(t *testing.T) {
+	cases := []struct {
+		description string
+		labels      map[string]string
+		annotations map[string]string
+		expected    bool
+	}{
+		{
+			description: "no label",
+			labels:      map[string]string{"app": "test"},
+		},
+		{
+			description: "label set by the elector",
+			labels:      map[string]string{PodLabelKey: StatusLeader},
+			annotations: map[string]string{PodLabelOwnerAnnotationKey: StatusLeader},
+		},
+		{
+			description: "label set by something else",
+			labels:      map[string]string{PodLabelKey: "canary"},
+			expected:    true,
+		},
+		{
+			description: "label set by an elector without the annotation",
+			labels:      map[string]string{PodLabelKey: StatusStandby},
+		},
+		{
+			description: "label changed by something else",
+			labels:      map[string]string{PodLabelKey: StatusStandby},
+			annotations: map[string]string{PodLabelOwnerAnnotationKey: StatusLeader},
+			expected:    true,
+		},
+	}
+
+	for _, c := range cases {
+		pod := newTestPod("test-ns", "test-pod")
+		pod.Labels = c.labels
+		pod.Annotations = c.annotations
+		assert.Equal(t, c.expected, podLabelForeign(pod), c.description)
+	}
+}
+
+func TestPodLabelPublisher_foreignLabel(t *testing.T) {
+	cases := []struct {
+		description string
+		respect     bool
+		cached      bool
+		labels      map[string]string
+		annotations map[string]string
+		expected    string
+		err         bool
+		log         string
+	}{
+		{
+			description: "no label",
+			respect:     true,
+			expected:    StatusLeader,
+		},
+		{
+			description: "label set by the elector",
+			respect:     true,
+			labels:      map[string]string{PodLabelKey: StatusStandby},
+			annotations: map[string]string{PodLabelOwnerAnnotationKey: StatusStandby},
+			expected:    StatusLeader,
+		},
+		{
+			description: "label set by an elector without the annotation",
+			respect:     true,
+			labels:      map[string]string{PodLabelKey: StatusStandby},
+			expected:    StatusLeader,
+		},
+		{
+			description: "foreign label overwritten",
+			cached:      true,
+			labels:      map[string]string{PodLabelKey: "canary"},
+			expected:    StatusLeader,
+			log:         `pod label k8s-elector/status has the value "canary", which was not set by the elector; overwriting it with "leader"`,
+		},
+		{
+			description: "foreign label respected",
+			respect:     true,
+			labels:      map[string]string{PodLabelKey: "canary"},
+			expected:    "canary",
+			err:         true,
+		},
+	}
+
+	for _, c := range cases {
+		pod := newTestPod("test-ns", "test-pod")
+		pod.Labels = c.labels
+		pod.Annotations = c.annotations
+		client := fake.NewSimpleClientset(pod)
+		log := &testLogger{}
+		p := &podLabelPublisher{
+			config: &ElectorConfig{
+				Namespace:            "test-ns",
+				PodName:              "test-pod",
+				RespectForeignLabels: c.respect,
+			},
+			client: client,
+			log:    newLogger(&ElectorConfig{Logger: log}),
+		}
+		// Without the cache, the Pod is only read when foreign labels are
+		// respected.
+		if c.cached {
+			p.pods = newPodCache(client, "test-ns", "test-pod")
+			assert.NoError(t, p.pods.start(time.Second), c.description)
+		}
+
+		err := p.publish(StatusLeader)
+		if c.err {
+			assert.True(t, errors.Is(err, errForeignLabel), c.description)
+		} else {
+			assert.NoError(t, err, c.description)
+		}
+
+		actual, err := client.CoreV1().Pods("test-ns").Get("test-pod", metav1.GetOptions{})
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, c.expected, actual.Labels[PodLabelKey], c.description)
+		if !c.err {
+			assert.Equal(t, StatusLeader, actual.Annotations[PodLabelOwnerAnnotationKey], c.description)
+		}
+		if c.log != "" {
+			assert.Contains(t, log.String(), c.log, c.description)
+		}
+		p.pods.stop()
+	}
+}
+
+func TestPodLabelPublisher_foreignLabel_readError(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	p := &podLabelPublisher{
+		config: &ElectorConfig{
+			Namespace:            "test-ns",
+			PodName:              "test-pod",
+			RespectForeignLabels: true,
+		},
+		client: client,
+	}
+
+	// If the label cannot be checked, it is not patched.
+	err := p.publish(StatusLeader)
+	assert.EqualError(t, err, `failed to read pod to check its status label: pods "test-pod" not found`)
+	assert.Zero(t, countActions(client, "patch"))
+}
+
+func TestPodLabelPublisher_foreignLabel_notRead(t *testing.T) {
+	pod := newTestPod("test-ns", "test-pod")
+	pod.Labels = map[string]string{PodLabelKey: "canary"}
+	client := fake.NewSimpleClientset(pod)
+	p := &podLabelPublisher{
+		config: &ElectorConfig{Namespace: "test-ns", PodName: "test-pod"},
+		client: client,
+	}
+
+	// Unless foreign labels are respected, the label is patched without
+	// reading the Pod.
+	assert.NoError(t, p.publish(StatusLeader))
+	assert.Zero(t, countActions(client, "get"))
+	assert.Equal(t, 1, countActions(client, "patch"))
+}
+
+func TestElectorNode_podCache_respectForeignLabels(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:                   "test-node-1",
+		Namespace:            "test-ns",
+		PodName:              "test-pod",
+		PodCache:             true,
+		RespectForeignLabels: true,
+		Logger:               log,
+	})
+
+	pods := node.startPodCache(client)
+	defer node.stopPodCache(pods)
+	publishers := node.newPublishers(client)
+	assert.NoError(t, publishers[0].publisher.publish(StatusLeader))
+
+	// Another controller changes the label, so it is not restored.
+	pod, err := client.CoreV1().Pods("test-ns").Get("test-pod", metav1.GetOptions{})
+	assert.NoError(t, err)
+	pod.Labels[PodLabelKey] = "canary"
+	_, err = client.CoreV1().Pods("test-ns").Update(pod)
+	assert.NoError(t, err)
+
+	waitFor(t, 5*time.Second, func() bool {
+		return strings.Contains(log.String(), `pod label k8s-elector/status was changed to "canary" outside of the elector; not restoring it`)
+	})
+	client.ClearActions()
+
+	// Nor is it overwritten with the next status.
+	err = publishers[0].publisher.publish(StatusStandby)
+	assert.True(t, errors.Is(err, errForeignLabel))
+	assert.Zero(t, countActions(client, "patch"))
+	pod, err = client.CoreV1().Pods("test-ns").Get("test-pod", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "canary", pod.Labels[PodLabelKey])
+	assert.Equal(t, StatusLeader, pod.Annotations[PodLabelOwnerAnnotationKey])
+}
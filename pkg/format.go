@@ -0,0 +1,116 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// The types below are used for times and durations in the elector's JSON
+// output (its HTTP responses, subscription events, and termination message),
+// so they are formatted the same way everywhere.
+//
+// Timestamps are RFC3339 with nanoseconds, in UTC. Durations are given as two
+// fields: a number of seconds in a "*_seconds" field, for machines, and a
+// duration string (e.g. "1m30s") in a "*_human" field, for people.
+
+// Timestamp is a point in time. It is marshaled as an RFC3339 timestamp with
+// nanoseconds in UTC, or as an empty string if it is not set.
+type Timestamp time.Time
+
+// MarshalJSON implements json.Marshaler.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return t.AppendJSON(make([]byte, 0, len(time.RFC3339Nano)+2)), nil
+}
+
+// AppendJSON appends the JSON encoding of the timestamp to the buffer.
+func (t Timestamp) AppendJSON(buf []byte) []byte {
+	buf = append(buf, '"')
+	if !time.Time(t).IsZero() {
+		buf = time.Time(t).UTC().AppendFormat(buf, time.RFC3339Nano)
+	}
+	return append(buf, '"')
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*t = Timestamp{}
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return err
+	}
+	*t = Timestamp(parsed)
+	return nil
+}
+
+// String gets the timestamp as it is marshaled, without quotes.
+func (t Timestamp) String() string {
+	if time.Time(t).IsZero() {
+		return ""
+	}
+	return time.Time(t).UTC().Format(time.RFC3339Nano)
+}
+
+// Seconds is a duration which is marshaled as a number of seconds.
+type Seconds time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Seconds) MarshalJSON() ([]byte, error) {
+	return strconv.AppendFloat(nil, time.Duration(d).Seconds(), 'f', -1, 64), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Seconds) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return err
+	}
+	*d = Seconds(seconds * float64(time.Second))
+	return nil
+}
+
+// HumanDuration is a duration which is marshaled as a duration string, e.g.
+// "1m30s".
+type HumanDuration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d HumanDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *HumanDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = HumanDuration(parsed)
+	return nil
+}
@@ -0,0 +1,214 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+// assertGolden checks a JSON payload against the golden file with the given
+// name. The payload is indented, so the golden files are readable. With the
+// -update flag, the golden file is written instead.
+func assertGolden(t *testing.T, name string, payload []byte) {
+	var buf bytes.Buffer
+	assert.NoError(t, json.Indent(&buf, payload, "", "  "), name)
+	buf.WriteByte('\n')
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *updateGolden {
+		assert.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0644), name)
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	assert.NoError(t, err, name)
+	assert.Equal(t, string(expected), buf.String(), name)
+}
+
+// newGoldenNode creates a node which leads its election, with a fake clock, for
+// checking the full payloads of its output.
+func newGoldenNode(t *testing.T) (*ElectorNode, *clock.FakeClock) {
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 28, 51, 123456789, time.UTC))
+	node := NewElectorNode(&ElectorConfig{
+		ID:                        "test-node-1",
+		Name:                      "test-election",
+		Namespace:                 "test-ns",
+		LockNamespace:             "test-ns",
+		PodName:                   "test-pod",
+		NodeName:                  "test-k8s-node",
+		Address:                   "0.0.0.0:5002",
+		AuthToken:                 "secret",
+		LockType:                  resourcelock.LeasesResourceLock,
+		TTL:                       90 * time.Second,
+		SlowRenewalFraction:       0.5,
+		ClientMaxIdleConns:        100,
+		ClientMaxIdleConnsPerHost: 4,
+		PublishDebounce:           2 * time.Second,
+		PostDemotionCooldown:      1500 * time.Millisecond,
+	})
+	node.clock = clk
+	node.newEventID = func() string { return "test-event-1" }
+	node.lock = newObservedLock(&fakeLock{identity: "test-node-1"}, clk)
+	assert.NoError(t, node.lock.Create(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+	node.setLeader("test-node-1")
+	node.recordAcquisition()
+	return node, clk
+}
+
+func TestGolden_leaderInfo(t *testing.T) {
+	node, clk := newGoldenNode(t)
+
+	w := httptest.NewRecorder()
+	node.writeLeaderInfo(w, http.StatusOK, clk.Now())
+	assertGolden(t, "leader_info", w.Body.Bytes())
+}
+
+func TestGolden_config(t *testing.T) {
+	defer os.Unsetenv(EnvKubeConfigData)
+	os.Unsetenv(EnvKubeConfigData)
+	node, _ := newGoldenNode(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/config", nil)
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, req)
+	assertGolden(t, "config", w.Body.Bytes())
+}
+
+func TestGolden_timing(t *testing.T) {
+	node, _ := newGoldenNode(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/timing", nil)
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, req)
+	assertGolden(t, "timing", w.Body.Bytes())
+}
+
+func TestGolden_history(t *testing.T) {
+	node, clk := newGoldenNode(t)
+	clk.Step(90 * time.Second)
+	node.setLeader("test-node-2")
+
+	req := httptest.NewRequest(http.MethodGet, "/history", nil)
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, req)
+	assertGolden(t, "history", w.Body.Bytes())
+}
+
+func TestGolden_leadershipEvent(t *testing.T) {
+	node, _ := newGoldenNode(t)
+
+	payloads := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		data, _ := ioutil.ReadAll(req.Body)
+		payloads <- data
+	}))
+	defer server.Close()
+	_, err := node.subscriptions.add(server.URL)
+	assert.NoError(t, err)
+
+	assert.NoError(t, newSubscriptionPublisher(node).publish(StatusLeader))
+	assertGolden(t, "leadership_event", <-payloads)
+}
+
+func TestTimestamp(t *testing.T) {
+	cases := []struct {
+		description string
+		time        time.Time
+		expected    string
+	}{
+		{
+			description: "zero time",
+			time:        time.Time{},
+			expected:    `""`,
+		},
+		{
+			description: "whole seconds",
+			time:        time.Date(2019, 5, 2, 18, 28, 51, 0, time.UTC),
+			expected:    `"2019-05-02T18:28:51Z"`,
+		},
+		{
+			description: "nanoseconds",
+			time:        time.Date(2019, 5, 2, 18, 28, 51, 500000000, time.UTC),
+			expected:    `"2019-05-02T18:28:51.5Z"`,
+		},
+		{
+			description: "converted to UTC",
+			time:        time.Date(2019, 5, 2, 20, 28, 51, 0, time.FixedZone("CEST", 2*60*60)),
+			expected:    `"2019-05-02T18:28:51Z"`,
+		},
+	}
+
+	for _, c := range cases {
+		data, err := json.Marshal(Timestamp(c.time))
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, c.expected, string(data), c.description)
+
+		var ts Timestamp
+		assert.NoError(t, json.Unmarshal(data, &ts), c.description)
+		assert.True(t, time.Time(ts).Equal(c.time), c.description)
+	}
+}
+
+func TestTimestamp_UnmarshalJSON_error(t *testing.T) {
+	var ts Timestamp
+	assert.Error(t, json.Unmarshal([]byte(`"yesterday"`), &ts))
+	assert.Error(t, json.Unmarshal([]byte(`12`), &ts))
+}
+
+func TestDurations(t *testing.T) {
+	cases := []struct {
+		description string
+		duration    time.Duration
+		seconds     string
+		human       string
+	}{
+		{
+			description: "zero",
+			duration:    0,
+			seconds:     `0`,
+			human:       `"0s"`,
+		},
+		{
+			description: "fractional seconds",
+			duration:    1500 * time.Millisecond,
+			seconds:     `1.5`,
+			human:       `"1.5s"`,
+		},
+		{
+			description: "minutes",
+			duration:    90 * time.Second,
+			seconds:     `90`,
+			human:       `"1m30s"`,
+		},
+	}
+
+	for _, c := range cases {
+		data, err := json.Marshal(Seconds(c.duration))
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, c.seconds, string(data), c.description)
+
+		var seconds Seconds
+		assert.NoError(t, json.Unmarshal(data, &seconds), c.description)
+		assert.Equal(t, Seconds(c.duration), seconds, c.description)
+
+		data, err = json.Marshal(HumanDuration(c.duration))
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, c.human, string(data), c.description)
+
+		var human HumanDuration
+		assert.NoError(t, json.Unmarshal(data, &human), c.description)
+		assert.Equal(t, HumanDuration(c.duration), human, c.description)
+	}
+}
@@ -0,0 +1,270 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// HistoryAnnotationKey is the key of the election lock annotation which
+	// holds the leader transition history, as a JSON list.
+	HistoryAnnotationKey = "k8s-elector/history"
+
+	// DefaultHistoryLimit is the default maximum number of leader transitions
+	// which are kept in the history annotation.
+	DefaultHistoryLimit = 10
+
+	// maxHistoryAnnotationSize is the maximum size of the history annotation,
+	// in bytes. The lock object is renewed frequently, so the annotation is
+	// kept small regardless of the configured limit.
+	maxHistoryAnnotationSize = 4096
+)
+
+// HistoryInfo is the response for the endpoint which reports the leader
+// transitions observed by the node.
+type HistoryInfo struct {
+	Transitions []LeaderTransition `json:"transitions" description:"The most recent leader transitions observed by the node, oldest first."`
+}
+
+// LeaderTransition describes a change of leadership in the election.
+type LeaderTransition struct {
+	Time     Timestamp `json:"time"`
+	Leader   string    `json:"leader"`
+	Previous string    `json:"previous"`
+	Role     string    `json:"role,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// appendLeaderTransition appends a leader transition to the existing history
+// annotation value. Once the number of transitions exceeds the limit, or the
+// history exceeds the maximum annotation size, the oldest transitions are
+// pruned.
+//
+// If the existing value can not be parsed, it is replaced.
+func appendLeaderTransition(existing string, transition LeaderTransition, limit int) (string, error) {
+	var history []LeaderTransition
+	if existing != "" {
+		if err := json.Unmarshal([]byte(existing), &history); err != nil {
+			history = nil
+		}
+	}
+	history = append(history, transition)
+	if limit > 0 && len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+
+	for {
+		data, err := json.Marshal(history)
+		if err != nil {
+			return "", err
+		}
+		if len(data) <= maxHistoryAnnotationSize || len(history) == 1 {
+			return string(data), nil
+		}
+		history = history[1:]
+	}
+}
+
+// lockObjectMeta gets the metadata of the election lock object.
+//
+// For the multi-locks used when migrating between lock types, the leases
+// object is used.
+func lockObjectMeta(client kubernetes.Interface, lockType, namespace, name string) (*metav1.ObjectMeta, error) {
+	switch lockType {
+	case resourcelock.EndpointsResourceLock:
+		obj, err := client.CoreV1().Endpoints(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &obj.ObjectMeta, nil
+	case resourcelock.ConfigMapsResourceLock:
+		obj, err := client.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &obj.ObjectMeta, nil
+	default:
+		obj, err := client.CoordinationV1().Leases(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &obj.ObjectMeta, nil
+	}
+}
+
+// patchLockObject applies a merge patch to the election lock object.
+func patchLockObject(client kubernetes.Interface, lockType, namespace, name string, patch []byte) error {
+	var err error
+	switch lockType {
+	case resourcelock.EndpointsResourceLock:
+		_, err = client.CoreV1().Endpoints(namespace).Patch(name, types.MergePatchType, patch)
+	case resourcelock.ConfigMapsResourceLock:
+		_, err = client.CoreV1().ConfigMaps(namespace).Patch(name, types.MergePatchType, patch)
+	default:
+		_, err = client.CoordinationV1().Leases(namespace).Patch(name, types.MergePatchType, patch)
+	}
+	return err
+}
+
+// appendLockHistory appends a leader transition to the history annotation of
+// the election lock object.
+//
+// The annotation is patched with the resource version it was read at, so a
+// concurrent update of the lock object (e.g. a renewal) is not overwritten. On
+// a conflict, the update is retried.
+func appendLockHistory(client kubernetes.Interface, lockType, namespace, name string, transition LeaderTransition, limit int) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		meta, err := lockObjectMeta(client, lockType, namespace, name)
+		if err != nil {
+			return err
+		}
+
+		history, err := appendLeaderTransition(meta.Annotations[HistoryAnnotationKey], transition, limit)
+		if err != nil {
+			return err
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"resourceVersion": meta.ResourceVersion,
+				"annotations": map[string]string{
+					HistoryAnnotationKey: history,
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		return patchLockObject(client, lockType, namespace, name, patch)
+	})
+}
+
+// recordHistory records the transition of leadership to this node on the
+// election lock object.
+//
+// Recording the history never affects the election, so failures are only
+// logged.
+func (node *ElectorNode) recordHistory(client kubernetes.Interface, previous *resourcelock.LeaderElectionRecord) {
+	transition := LeaderTransition{
+		Time:   Timestamp(node.clock.Now()),
+		Leader: node.config.ID,
+		Role:   node.config.role(),
+	}
+	if previous != nil {
+		transition.Previous = previous.HolderIdentity
+	}
+
+	err := appendLockHistory(
+		client,
+		node.config.LockType,
+		node.config.LockNamespace,
+		node.config.Name,
+		transition,
+		node.config.HistoryLimit,
+	)
+	if err != nil {
+		node.log.Errorf("failed to record leader history: %v", err)
+	}
+}
+
+// observeTransition records the change of leadership to the identity in the
+// node's in-memory history of leader transitions. Only the most recent
+// transitions, up to the history limit, are kept.
+//
+// The caller must hold the node's lock.
+func (node *ElectorNode) observeTransition(identity string) {
+	previous := node.currentLeader
+	if previous == "" && node.previousLeader != identity {
+		previous = node.previousLeader
+	}
+	node.appendTransition(LeaderTransition{
+		Time:     Timestamp(node.clock.Now()),
+		Leader:   identity,
+		Previous: previous,
+		Role:     node.config.role(),
+	})
+	node.transitionCount++
+}
+
+// transitionRing is a fixed-size ring of leader transitions. Once it is full,
+// each transition added overwrites the oldest one, so its memory does not
+// grow with the node's uptime.
+type transitionRing struct {
+	buf  []LeaderTransition
+	next int
+	full bool
+}
+
+// add adds a transition to the ring, which holds up to size transitions. The
+// ring is allocated when the first transition is added.
+func (r *transitionRing) add(transition LeaderTransition, size int) {
+	if r.buf == nil {
+		r.buf = make([]LeaderTransition, size)
+	}
+	r.buf[r.next] = transition
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list gets a copy of the transitions in the ring, oldest first.
+func (r *transitionRing) list() []LeaderTransition {
+	if !r.full {
+		transitions := make([]LeaderTransition, r.next)
+		copy(transitions, r.buf[:r.next])
+		return transitions
+	}
+	transitions := make([]LeaderTransition, 0, len(r.buf))
+	transitions = append(transitions, r.buf[r.next:]...)
+	return append(transitions, r.buf[:r.next]...)
+}
+
+// appendTransition appends a leader transition to the node's in-memory
+// history. Once the history limit is reached, the oldest transition is
+// overwritten.
+//
+// The caller must hold the node's lock.
+func (node *ElectorNode) appendTransition(transition LeaderTransition) {
+	limit := node.config.HistoryLimit
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	node.transitions.add(transition, limit)
+}
+
+// history gets a copy of the leader transitions observed by the node.
+func (node *ElectorNode) history() []LeaderTransition {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.transitions.list()
+}
+
+// httpHistory is the handler for the endpoint which reports the leader
+// transitions observed by the node.
+func (node *ElectorNode) httpHistory(res http.ResponseWriter, req *http.Request) {
+	node.writeJSON(res, http.StatusOK, HistoryInfo{
+		Transitions: node.history(),
+	})
+}
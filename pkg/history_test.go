@@ -0,0 +1,231 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestAppendLeaderTransition(t *testing.T) {
+	cases := []struct {
+		description string
+		existing    string
+		limit       int
+		expected    []string
+	}{
+		{
+			description: "no existing history",
+			existing:    "",
+			limit:       3,
+			expected:    []string{"node-new"},
+		},
+		{
+			description: "existing history under the limit",
+			existing:    `[{"leader":"node-1"}]`,
+			limit:       3,
+			expected:    []string{"node-1", "node-new"},
+		},
+		{
+			description: "existing history at the limit",
+			existing:    `[{"leader":"node-1"},{"leader":"node-2"},{"leader":"node-3"}]`,
+			limit:       3,
+			expected:    []string{"node-2", "node-3", "node-new"},
+		},
+		{
+			description: "invalid existing history",
+			existing:    `not-json`,
+			limit:       3,
+			expected:    []string{"node-new"},
+		},
+	}
+
+	for _, c := range cases {
+		value, err := appendLeaderTransition(c.existing, LeaderTransition{Leader: "node-new"}, c.limit)
+		assert.NoError(t, err, c.description)
+
+		var history []LeaderTransition
+		assert.NoError(t, json.Unmarshal([]byte(value), &history), c.description)
+		var leaders []string
+		for _, transition := range history {
+			leaders = append(leaders, transition.Leader)
+		}
+		assert.Equal(t, c.expected, leaders, c.description)
+	}
+}
+
+func TestAppendLeaderTransition_maxSize(t *testing.T) {
+	var value string
+	leader := strings.Repeat("x", 500)
+	for i := 0; i < 20; i++ {
+		var err error
+		value, err = appendLeaderTransition(value, LeaderTransition{Leader: fmt.Sprintf("%s-%d", leader, i)}, 100)
+		assert.NoError(t, err)
+		assert.True(t, len(value) <= maxHistoryAnnotationSize, "history exceeds the maximum size")
+	}
+
+	// The newest transition is kept.
+	var history []LeaderTransition
+	assert.NoError(t, json.Unmarshal([]byte(value), &history))
+	assert.Equal(t, leader+"-19", history[len(history)-1].Leader)
+}
+
+func TestAppendLockHistory(t *testing.T) {
+	meta := metav1.ObjectMeta{
+		Name:      "test-election",
+		Namespace: "test-ns",
+	}
+	cases := []struct {
+		description string
+		lockType    string
+		object      runtime.Object
+	}{
+		{
+			description: "leases lock",
+			lockType:    resourcelock.LeasesResourceLock,
+			object:      &coordinationv1.Lease{ObjectMeta: meta},
+		},
+		{
+			description: "endpoints lock",
+			lockType:    resourcelock.EndpointsResourceLock,
+			object:      &corev1.Endpoints{ObjectMeta: meta},
+		},
+		{
+			description: "configmaps lock",
+			lockType:    resourcelock.ConfigMapsResourceLock,
+			object:      &corev1.ConfigMap{ObjectMeta: meta},
+		},
+	}
+
+	for _, c := range cases {
+		client := fake.NewSimpleClientset(c.object)
+		for _, leader := range []string{"node-1", "node-2"} {
+			err := appendLockHistory(client, c.lockType, "test-ns", "test-election", LeaderTransition{Leader: leader}, 10)
+			assert.NoError(t, err, c.description)
+		}
+
+		lockMeta, err := lockObjectMeta(client, c.lockType, "test-ns", "test-election")
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, `[{"time":"","leader":"node-1","previous":""},{"time":"","leader":"node-2","previous":""}]`,
+			lockMeta.Annotations[HistoryAnnotationKey], c.description)
+	}
+}
+
+func TestAppendLockHistory_noLock(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	err := appendLockHistory(client, resourcelock.LeasesResourceLock, "test-ns", "test-election", LeaderTransition{Leader: "node-1"}, 10)
+	assert.Error(t, err)
+}
+
+func TestElectorNode_recordHistory(t *testing.T) {
+	client := fake.NewSimpleClientset(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-election",
+			Namespace: "lock-ns",
+		},
+	})
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		LockType:      resourcelock.LeasesResourceLock,
+		LockNamespace: "lock-ns",
+		HistoryLimit:  DefaultHistoryLimit,
+		Logger:        &testLogger{},
+	})
+	node.clock = clock.NewFakeClock(time.Date(2019, 5, 2, 18, 28, 51, 0, time.UTC))
+
+	node.recordHistory(client, &resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2"})
+
+	lease, err := client.CoordinationV1().Leases("lock-ns").Get("test-election", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"time":"2019-05-02T18:28:51Z","leader":"test-node-1","previous":"test-node-2","role":"test-election"}]`,
+		lease.Annotations[HistoryAnnotationKey])
+}
+
+func TestElectorNode_recordHistory_error(t *testing.T) {
+	buf := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		LockType:      resourcelock.LeasesResourceLock,
+		LockNamespace: "lock-ns",
+		Logger:        buf,
+	})
+
+	node.recordHistory(fake.NewSimpleClientset(), nil)
+	assert.Contains(t, buf.String(), "failed to record leader history")
+}
+
+func TestElectorNode_observeTransition(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:           "test-node-1",
+		Name:         "test-election",
+		HistoryLimit: 2,
+	})
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 28, 51, 0, time.UTC))
+	node.clock = clk
+
+	node.setLeader("test-node-1")
+	node.setLeader("test-node-1")
+	node.setLeader("test-node-2")
+	node.setLeader("")
+	node.setLeader("test-node-3")
+
+	// Only the most recent transitions, up to the limit, are kept. A node
+	// which is not observed as the leader in between is still the previous
+	// leader.
+	assert.Equal(t, []LeaderTransition{
+		{Time: Timestamp(clk.Now()), Leader: "test-node-2", Previous: "test-node-1", Role: "test-election"},
+		{Time: Timestamp(clk.Now()), Leader: "test-node-3", Previous: "test-node-2", Role: "test-election"},
+	}, node.history())
+}
+
+func TestElectorNode_httpHistory(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:   "test-node-1",
+		Name: "test-election",
+	})
+
+	data := getJSON(t, node, "/history")
+	assert.Equal(t, []interface{}{}, data["transitions"])
+
+	node.setLeader("test-node-1")
+	data = getJSON(t, node, "/history")
+	if transitions, ok := data["transitions"].([]interface{}); assert.True(t, ok) && assert.Len(t, transitions, 1) {
+		transition := transitions[0].(map[string]interface{})
+		assert.Equal(t, "test-node-1", transition["leader"])
+		assert.Equal(t, "", transition["previous"])
+	}
+}
+
+func TestTransitionRing(t *testing.T) {
+	var ring transitionRing
+	assert.Equal(t, []LeaderTransition{}, ring.list())
+
+	ring.add(LeaderTransition{Leader: "test-node-1"}, 3)
+	ring.add(LeaderTransition{Leader: "test-node-2"}, 3)
+	assert.Equal(t, []LeaderTransition{{Leader: "test-node-1"}, {Leader: "test-node-2"}}, ring.list())
+
+	// However many transitions are added, the ring holds the most recent
+	// ones in the memory allocated for the first.
+	for i := 3; i <= 1000; i++ {
+		ring.add(LeaderTransition{Leader: fmt.Sprintf("test-node-%d", i)}, 3)
+	}
+	assert.Len(t, ring.buf, 3)
+	assert.Equal(t, 3, cap(ring.buf))
+	assert.Equal(t, []LeaderTransition{
+		{Leader: "test-node-998"},
+		{Leader: "test-node-999"},
+		{Leader: "test-node-1000"},
+	}, ring.list())
+}
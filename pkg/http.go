@@ -0,0 +1,767 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LeaderInfo is the response for the leader info endpoint.
+type LeaderInfo struct {
+	Node           string                 `json:"node" description:"The ID of the node being queried for leadership status."`
+	Role           string                 `json:"role" description:"The role name of the node being queried. This is cosmetic, and is the election name unless set."`
+	NodeName       string                 `json:"node_name" description:"The name of the Kubernetes node which the node being queried runs on. Empty if it is not known."`
+	Leader         string                 `json:"leader" description:"The ID of the node which is currently the leader."`
+	LeaderMeta     *LeaderMeta            `json:"leader_meta,omitempty" description:"The metadata of the leader, parsed from its ID if it is a structured ID (<hostname>/<pod IP>/<node name>). Omitted otherwise."`
+	PreviousLeader string                 `json:"previous_leader" description:"The ID of the node which was the leader before the current leader. Empty until leadership has changed hands."`
+	Leaderless     bool                   `json:"leaderless" description:"Whether the election lock was observed unheld beyond its lease duration, with no node having acquired it since. The leader is empty while the election is leaderless."`
+	Stale          bool                   `json:"stale" description:"Whether the node has not read the election lock within the stale threshold, e.g. because the API server can not be reached, so the leader may be out of date. Once the stale expiry has passed too, the leader is empty."`
+	EventID        string                 `json:"event_id" description:"The ID of the node's last leadership event, as delivered to subscriptions and watchers. Empty until the node has observed a leader."`
+	IsLeader       bool                   `json:"is_leader" description:"Whether the node being queried is the leader node."`
+	HasLed         bool                   `json:"has_led" description:"Whether the node being queried has held leadership at any time since its process started."`
+	Acquisitions   int                    `json:"acquisitions" description:"The number of times the node being queried has acquired leadership since its process started."`
+	ElectionEpoch  int                    `json:"election_epoch" description:"The epoch of the election loop of the node being queried: the number of its current run of the election, starting at 1 and incremented each time the election is re-run. An epoch which keeps increasing indicates an unstable election."`
+	LeaseMismatch  *LeaseDurationMismatch `json:"lease_duration_mismatch,omitempty" description:"The lease duration of the node being queried and the lease duration recorded by the leader, if they differ."`
+	Renewals       int64                  `json:"renewals" description:"The number of times the node being queried has successfully written its leadership to the election lock since its process started, including acquisitions. A count which stops increasing while the node is the leader indicates stalled renewals."`
+	Timestamp      Timestamp              `json:"timestamp" description:"The timestamp for when the response was returned."`
+}
+
+// MessageResponse is the response for endpoints which only report a message.
+type MessageResponse struct {
+	Message string `json:"message" description:"A message describing the result of the request."`
+}
+
+// route defines an endpoint served by the elector node's HTTP server.
+//
+// The route table is the source of truth for both the HTTP server and the
+// generated OpenAPI document, so each route describes its response type.
+type route struct {
+	Path     string
+	Method   string
+	Summary  string
+	Response interface{}
+	Handler  http.HandlerFunc
+
+	// ContentType is the content type of the response. If not set, the
+	// response is JSON.
+	ContentType string
+
+	// Auth is whether the route requires the auth token. The handler is
+	// wrapped with requireAuth.
+	Auth bool
+
+	// Chaos is whether the route is only served while the chaos hooks are
+	// enabled. The handler is wrapped with requireChaos.
+	Chaos bool
+
+	// Errors are the error responses of the handler, by status code. The
+	// error responses of the wrapping handlers are given by Auth and Chaos,
+	// and every route rejects requests with another method.
+	Errors map[int]routeError
+}
+
+// routeError describes an error response of a route.
+type routeError struct {
+	Description string
+
+	// Response is the type of the response, which has the content type of
+	// the route. If not set, the response is a JSON MessageResponse.
+	Response interface{}
+}
+
+// routes gets the HTTP routes served by the elector node.
+func (node *ElectorNode) routes() []route {
+	return []route{
+		{
+			Path:     "/",
+			Method:   http.MethodGet,
+			Summary:  "Get the leadership status of the node.",
+			Response: LeaderInfo{},
+			Handler:  node.httpLeaderInfo,
+		},
+		{
+			Path:     "/leader/status",
+			Method:   http.MethodGet,
+			Summary:  "Get the leadership status of the node as the response status code, for load balancer health checks. Returns 200 if the node is the leader and 503 otherwise, unless inverted.",
+			Response: LeaderInfo{},
+			Handler:  node.httpLeaderStatus,
+			Errors: map[int]routeError{
+				http.StatusServiceUnavailable: {Description: "The node is not the leader, or is the leader if inverted.", Response: LeaderInfo{}},
+			},
+		},
+		{
+			Path:        "/leader/id",
+			Method:      http.MethodGet,
+			Summary:     "Get the ID of the current leader as plain text, followed by a newline. Returns 503 with an empty line if no leader is known.",
+			Response:    "",
+			Handler:     node.httpLeaderID,
+			ContentType: "text/plain",
+			Errors: map[int]routeError{
+				http.StatusServiceUnavailable: {Description: "No leader is known.", Response: ""},
+			},
+		},
+		{
+			Path:     "/config",
+			Method:   http.MethodGet,
+			Summary:  "Get the effective configuration of the node, including the election timings.",
+			Response: ConfigInfo{},
+			Handler:  node.httpConfig,
+		},
+		{
+			Path:     "/backend",
+			Method:   http.MethodGet,
+			Summary:  "Get the lock type and object of the election lock, and whether the API resources backing it are available.",
+			Response: BackendInfo{},
+			Handler:  node.httpBackend,
+		},
+		{
+			Path:     "/lease",
+			Method:   http.MethodGet,
+			Summary:  "Get the election lock record last read by the election. With the 'nocache' query parameter set to true, the lock is read from the API server instead, giving up after 5 seconds.",
+			Response: LeaseInfo{},
+			Handler:  node.httpLease,
+			Errors: map[int]routeError{
+				http.StatusBadRequest:         {Description: "The 'nocache' query parameter is not a boolean."},
+				http.StatusServiceUnavailable: {Description: "The election has not started, no lock record is known yet, or the lock could not be read."},
+			},
+		},
+		{
+			Path:     "/permissions",
+			Method:   http.MethodGet,
+			Summary:  "Get whether the node's service account has each of the permissions the node needs, as reviewed by the API server. The results are cached for a minute.",
+			Response: PermissionsInfo{},
+			Handler:  node.httpPermissions,
+		},
+		{
+			Path:     "/timing",
+			Method:   http.MethodGet,
+			Summary:  "Get the timings of the election, including the renewal deadlines of the leader.",
+			Response: TimingDetails{},
+			Handler:  node.httpTiming,
+		},
+		{
+			Path:     "/version",
+			Method:   http.MethodGet,
+			Summary:  "Get the build info of the application running the elector.",
+			Response: BuildInfo{},
+			Handler:  node.httpVersion,
+		},
+		{
+			Path:     "/healthz",
+			Method:   http.MethodGet,
+			Summary:  "Get the health of the node, including whether it has detected a split brain. Returns 503 if the election failed to start.",
+			Response: HealthInfo{},
+			Handler:  node.httpHealth,
+			Errors: map[int]routeError{
+				http.StatusServiceUnavailable: {Description: "The election failed to start.", Response: HealthInfo{}},
+			},
+		},
+		{
+			Path:     "/history",
+			Method:   http.MethodGet,
+			Summary:  "Get the most recent leader transitions observed by the node.",
+			Response: HistoryInfo{},
+			Handler:  node.httpHistory,
+		},
+		{
+			Path:        "/watch",
+			Method:      http.MethodGet,
+			Summary:     "Stream the leadership status of the node as server-sent events. The current status is sent when the stream opens, followed by an event for each leadership transition.",
+			Response:    LeadershipEvent{},
+			Handler:     node.httpWatch,
+			ContentType: "text/event-stream",
+			Errors: map[int]routeError{
+				http.StatusServiceUnavailable: {Description: "The maximum number of streams are open."},
+			},
+		},
+		{
+			Path:     "/subscribe",
+			Method:   http.MethodGet,
+			Summary:  "List the subscriptions to leadership transitions. Requires authentication.",
+			Response: SubscriptionList{},
+			Handler:  node.httpListSubscriptions,
+			Auth:     true,
+		},
+		{
+			Path:     "/subscribe",
+			Method:   http.MethodPost,
+			Summary:  "Subscribe a callback URL to leadership transitions, which are POSTed to it as they happen. Requires authentication.",
+			Response: Subscription{},
+			Handler:  node.httpSubscribe,
+			Auth:     true,
+			Errors: map[int]routeError{
+				http.StatusBadRequest: {Description: "The request body or callback URL is invalid."},
+				http.StatusConflict:   {Description: "Subscriptions are disabled, or the maximum number of subscriptions exist."},
+			},
+		},
+		{
+			Path:     "/subscribe",
+			Method:   http.MethodDelete,
+			Summary:  "Delete the subscription with the ID given by the 'id' query parameter. Requires authentication.",
+			Response: MessageResponse{},
+			Handler:  node.httpUnsubscribe,
+			Auth:     true,
+			Errors: map[int]routeError{
+				http.StatusNotFound: {Description: "There is no subscription with the ID."},
+			},
+		},
+		{
+			Path:        "/metrics",
+			Method:      http.MethodGet,
+			Summary:     "Get the metrics of the node in the Prometheus exposition format. Returns 404 if the metrics are registered with a Registerer the elector can not gather from.",
+			Response:    "",
+			Handler:     node.httpMetrics,
+			ContentType: "text/plain",
+			Errors: map[int]routeError{
+				http.StatusNotFound: {Description: "The metrics are registered with a Registerer the elector can not gather from."},
+			},
+		},
+		{
+			Path:     "/canary",
+			Method:   http.MethodGet,
+			Summary:  "Get the leadership status of the node in the canary election.",
+			Response: LeaderInfo{},
+			Handler:  node.httpCanaryInfo,
+			Errors: map[int]routeError{
+				http.StatusNotFound: {Description: "No canary election is configured."},
+			},
+		},
+		{
+			Path:     "/shutdown",
+			Method:   http.MethodPost,
+			Summary:  "Gracefully shut down the elector, releasing the lease if held. Requires authentication.",
+			Response: MessageResponse{},
+			Handler:  node.httpShutdown,
+			Auth:     true,
+		},
+		{
+			Path:     "/prestop",
+			Method:   http.MethodPost,
+			Summary:  "Withdraw the node from the election, for a Pod's pre-stop hook. Responds once the node has stepped down and released the lease, if held. The node keeps running as a standby until it is shut down. Requires authentication.",
+			Response: PreStopResponse{},
+			Handler:  node.httpPreStop,
+			Auth:     true,
+		},
+		{
+			Path:     "/pause",
+			Method:   http.MethodPost,
+			Summary:  "Pause the node's participation in the election, releasing the lease if held. The node keeps serving HTTP until it is resumed. Requires authentication.",
+			Response: MessageResponse{},
+			Handler:  node.httpPause,
+			Auth:     true,
+			Errors: map[int]routeError{
+				http.StatusConflict: {Description: "The node runs as a single node, so there is no election to pause."},
+			},
+		},
+		{
+			Path:     "/resume",
+			Method:   http.MethodPost,
+			Summary:  "Resume the node's participation in the election after it was paused. Requires authentication.",
+			Response: MessageResponse{},
+			Handler:  node.httpResume,
+			Auth:     true,
+		},
+		{
+			Path:     "/chaos/fail-renewals",
+			Method:   http.MethodPost,
+			Summary:  "Inject failures into the next renewals of the node's lease. The number of failures is given by the 'count' query parameter. Returns 404 unless the chaos hooks are enabled. Requires authentication.",
+			Response: MessageResponse{},
+			Handler:  node.httpChaosFailRenewals,
+			Auth:     true,
+			Chaos:    true,
+			Errors: map[int]routeError{
+				http.StatusBadRequest: {Description: "The 'count' query parameter is not a positive integer."},
+			},
+		},
+		{
+			Path:     "/chaos/fail-patches",
+			Method:   http.MethodPost,
+			Summary:  "Inject failures into the patches of the node's Pod label for the duration given by the 'duration' query parameter, e.g. 30s. Returns 404 unless the chaos hooks are enabled. Requires authentication.",
+			Response: MessageResponse{},
+			Handler:  node.httpChaosFailPatches,
+			Auth:     true,
+			Chaos:    true,
+			Errors: map[int]routeError{
+				http.StatusBadRequest: {Description: "The 'duration' query parameter is not a positive duration."},
+			},
+		},
+		{
+			Path:     "/openapi.json",
+			Method:   http.MethodGet,
+			Summary:  "Get the OpenAPI document describing the elector HTTP API.",
+			Response: map[string]interface{}{},
+			Handler:  node.httpOpenAPI,
+		},
+	}
+}
+
+// mux builds the HTTP request multiplexer for the elector node's routes.
+func (node *ElectorNode) mux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// A path may be served with different handlers for different methods, so
+	// the handlers are grouped by path before they are registered.
+	var paths []string
+	handlers := map[string]map[string]http.HandlerFunc{}
+	for _, r := range node.routes() {
+		if _, ok := handlers[r.Path]; !ok {
+			paths = append(paths, r.Path)
+			handlers[r.Path] = map[string]http.HandlerFunc{}
+		}
+		handler := r.Handler
+		if r.Auth {
+			handler = node.requireAuth(handler)
+		}
+		if r.Chaos {
+			handler = node.requireChaos(handler)
+		}
+		handlers[r.Path][r.Method] = handler
+	}
+	for _, path := range paths {
+		mux.HandleFunc(path, node.allowMethods(handlers[path]))
+	}
+	return mux
+}
+
+// handler builds the HTTP handler for the elector node's HTTP server. This is the
+// node's request multiplexer, instrumented with the HTTP server metrics, wrapped
+// with any configured middleware.
+func (node *ElectorNode) handler() http.Handler {
+	var handler http.Handler = node.withEventID(node.instrumentHTTP(node.mux()))
+	if node.config.AccessLog {
+		handler = node.accessLog(handler)
+	}
+	return handler
+}
+
+// responseWriter wraps an http.ResponseWriter to capture the status code
+// of the response.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader captures the status code and writes it to the response.
+func (w *responseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes data to the response. If no status code was written, the
+// status is implicitly 200.
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush flushes the response, if the wrapped writer supports it. This keeps
+// streaming responses working through the middleware.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// accessLogEntry is an entry in the HTTP access log.
+type accessLogEntry struct {
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	RemoteAddr string  `json:"remote_addr"`
+	Status     int     `json:"status"`
+	Latency    float64 `json:"latency_seconds"`
+}
+
+// accessLog wraps a handler to log each request it serves as a JSON access
+// log entry.
+func (node *ElectorNode) accessLog(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		w := &responseWriter{ResponseWriter: res}
+		handler.ServeHTTP(w, req)
+
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		entry, err := json.Marshal(accessLogEntry{
+			Method:     req.Method,
+			Path:       req.URL.Path,
+			RemoteAddr: req.RemoteAddr,
+			Status:     w.status,
+			Latency:    time.Since(start).Seconds(),
+		})
+		if err != nil {
+			node.log.Errorf("failed to build http access log entry: %v", err)
+			return
+		}
+		node.log.Infof("http access: %s", entry)
+	})
+}
+
+// allowMethods wraps the handlers for a path so that each only handles requests
+// with its HTTP method. Requests with any other method are rejected.
+func (node *ElectorNode) allowMethods(handlers map[string]http.HandlerFunc) http.HandlerFunc {
+	methods := make([]string, 0, len(handlers))
+	for method := range handlers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	allow := strings.Join(methods, ", ")
+
+	return func(res http.ResponseWriter, req *http.Request) {
+		handler, ok := handlers[req.Method]
+		if !ok {
+			res.Header().Set("Allow", allow)
+			node.writeJSON(res, http.StatusMethodNotAllowed, MessageResponse{
+				Message: "method not allowed",
+			})
+			return
+		}
+		handler(res, req)
+	}
+}
+
+// requireAuth wraps a handler so that it requires the request to provide the
+// configured auth token as a bearer token.
+//
+// If no auth token is configured, all requests to the handler are rejected.
+func (node *ElectorNode) requireAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if node.config.AuthToken == "" {
+			node.writeJSON(res, http.StatusForbidden, MessageResponse{
+				Message: "endpoint disabled: no auth token configured",
+			})
+			return
+		}
+
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(node.config.AuthToken)) != 1 {
+			node.writeJSON(res, http.StatusUnauthorized, MessageResponse{
+				Message: "unauthorized",
+			})
+			return
+		}
+		handler(res, req)
+	}
+}
+
+// httpShutdownTimeout is the time the HTTP server is given to finish serving
+// in-flight requests once its context is cancelled.
+const httpShutdownTimeout = 5 * time.Second
+
+// serveHTTP starts the HTTP server which exposes the leader information.
+//
+// If the elector is not configured with an address (via the -http flag), the
+// HTTP server will not be started.
+func (node *ElectorNode) serveHTTP() {
+	if node.config.Address == "" {
+		node.log.Info("http server will not be started: no address given")
+		return
+	}
+
+	node.log.Infof("starting HTTP server on %v", node.config.Address)
+	ln, err := net.Listen("tcp", node.config.Address)
+	if err != nil {
+		// The node is not usable without its HTTP server, so shut it down.
+		node.log.Errorf("failed to start the HTTP server: %v", err)
+		node.setStopReason("failed to start the HTTP server: %v", err)
+		node.shutdown()
+		return
+	}
+	node.serveHTTPOn(ln)
+}
+
+// serveHTTPOn serves the node's HTTP endpoints on the listener until the HTTP
+// context is cancelled, at which point the server is shut down gracefully.
+//
+// The HTTP server runs with its own context rather than the node's context, so
+// its lifecycle is independent of the election's: cancelling the election
+// leaves the endpoints up while the lock is released, and shutting down the
+// HTTP server does not affect the election.
+func (node *ElectorNode) serveHTTPOn(ln net.Listener) {
+	server := &http.Server{Handler: node.handler()}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-node.httpCtx.Done():
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			node.log.Errorf("failed to shut down the HTTP server: %v", err)
+		}
+	}()
+
+	node.setHTTPAddr(ln.Addr().String())
+	defer node.setHTTPAddr("")
+	err := server.Serve(ln)
+	if err != nil && err != http.ErrServerClosed {
+		// The node is not usable without its HTTP server, so shut it down.
+		node.log.Errorf("HTTP server failed: %v", err)
+		node.setStopReason("HTTP server failed: %v", err)
+		node.shutdown()
+		return
+	}
+	node.log.Info("HTTP server stopped")
+}
+
+// setHTTPAddr records the address the node's HTTP server is bound to, or an
+// empty address once it stops serving.
+func (node *ElectorNode) setHTTPAddr(addr string) {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.httpAddr = addr
+}
+
+// HTTPAddr gets the address the node's HTTP server is bound to. This is the
+// actual address, so the port is known when the configured Address uses port
+// 0. It is empty while the HTTP server is not serving.
+func (node *ElectorNode) HTTPAddr() string {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.httpAddr
+}
+
+// isServingHTTP checks whether the node's HTTP server is serving.
+func (node *ElectorNode) isServingHTTP() bool {
+	return node.HTTPAddr() != ""
+}
+
+// jsonContentType is the Content-Type header value for JSON responses.
+var jsonContentType = []string{"application/json"}
+
+// leaderInfoBuffers pools the buffers used to write leader info responses.
+var leaderInfoBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 256)
+		return &buf
+	},
+}
+
+// leaderInfoBody is a LeaderInfo whose JSON encoding leaves out the timestamp:
+// its timestamp field shadows the one of the LeaderInfo, and is omitted since
+// it is not set.
+type leaderInfoBody struct {
+	LeaderInfo
+	Timestamp *Timestamp `json:"timestamp,omitempty"`
+}
+
+// cachedLeaderInfo gets the cached JSON encoding of the node's LeaderInfo,
+// without the timestamp, which changes for each response. The cached payload
+// is invalidated when the leader changes or the node acquires leadership, and
+// rebuilt on the next request. It is also rebuilt once the node renews the
+// election lock, or its view of the leader becomes stale, or expires.
+func (node *ElectorNode) cachedLeaderInfo() ([]byte, error) {
+	threshold, expiry := node.staleThresholds()
+	renewals := node.renewCount()
+	now := node.clock.Now()
+
+	node.mu.RLock()
+	payload := node.leaderPayload
+	valid := node.leaderPayloadRenewals == renewals &&
+		node.leaderPayloadStaleness == node.leaderStalenessLocked(now, threshold, expiry)
+	node.mu.RUnlock()
+	if payload != nil && valid {
+		return payload, nil
+	}
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	stale := node.leaderStalenessLocked(now, threshold, expiry)
+	if node.leaderPayload == nil || node.leaderPayloadRenewals != renewals || node.leaderPayloadStaleness != stale {
+		leader := node.currentLeader
+		if stale == leaderExpired {
+			leader = ""
+		}
+		data, err := json.Marshal(leaderInfoBody{LeaderInfo: LeaderInfo{
+			Node:           node.config.ID,
+			Role:           node.config.role(),
+			NodeName:       node.config.NodeName,
+			Leader:         leader,
+			LeaderMeta:     parseLeaderMeta(leader),
+			PreviousLeader: node.previousLeader,
+			Leaderless:     !node.leaderlessSince.IsZero(),
+			Stale:          stale != leaderFresh,
+			EventID:        node.eventID,
+			IsLeader:       node.config.ID == node.currentLeader,
+			HasLed:         node.acquisitions > 0,
+			Acquisitions:   node.acquisitions,
+			ElectionEpoch:  node.restarts + 1,
+			LeaseMismatch:  node.leaseMismatch,
+			Renewals:       renewals,
+		}})
+		if err != nil {
+			return nil, err
+		}
+		node.leaderPayload = data
+		node.leaderPayloadRenewals = renewals
+		node.leaderPayloadStaleness = stale
+	}
+	return node.leaderPayload, nil
+}
+
+// writeLeaderInfo writes the node's LeaderInfo to the response as JSON with the
+// specified status code and the given time as the timestamp. The response is
+// the cached payload, with the timestamp written after its other fields, so
+// writing it does not allocate. If the payload can not be built, the
+// LeaderInfo is marshaled instead.
+func (node *ElectorNode) writeLeaderInfo(res http.ResponseWriter, status int, now time.Time) {
+	body, err := node.cachedLeaderInfo()
+	if err != nil {
+		node.log.Errorf("failed to build cached leader info: %v", err)
+		info := node.leaderInfo()
+		info.Timestamp = Timestamp(now)
+		node.writeJSON(res, status, info)
+		return
+	}
+
+	// The cached payload is a JSON object, so the timestamp is written
+	// before its closing brace.
+	bufp := leaderInfoBuffers.Get().(*[]byte)
+	buf := append((*bufp)[:0], body[:len(body)-1]...)
+	buf = append(buf, `,"timestamp":`...)
+	buf = Timestamp(now).AppendJSON(buf)
+	buf = append(buf, '}')
+
+	res.Header()["Content-Type"] = jsonContentType
+	res.WriteHeader(status)
+	if _, err := res.Write(buf); err != nil {
+		node.log.Errorf("failed to write http response: %v", err)
+	}
+
+	*bufp = buf
+	leaderInfoBuffers.Put(bufp)
+}
+
+// writeJSON writes the given value to the response as JSON with the
+// specified status code.
+func (node *ElectorNode) writeJSON(res http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		res.WriteHeader(http.StatusInternalServerError)
+		if _, e := res.Write([]byte(err.Error())); e != nil {
+			node.log.Errorf("failed writing http error response (%v): %v", err, e)
+		}
+		return
+	}
+
+	res.Header()["Content-Type"] = jsonContentType
+	res.WriteHeader(status)
+	_, err = res.Write(data)
+	if err != nil {
+		node.log.Errorf("failed to write http response: %v", err)
+	}
+}
+
+// httpLeaderInfo is the handler for the endpoint which provides leader info.
+//
+// This endpoint is typically polled by probes and dashboards, so it serves
+// the cached leader info payload rather than marshaling a response for each
+// request. Requests are not logged here; enable the access log to log them.
+func (node *ElectorNode) httpLeaderInfo(res http.ResponseWriter, req *http.Request) {
+	node.writeLeaderInfo(res, http.StatusOK, node.clock.Now())
+}
+
+// httpLeaderStatus is the handler for the endpoint which reports the leadership
+// status of the node via the response status code.
+//
+// By default, the leader responds with 200 OK and all other nodes respond with
+// 503 Service Unavailable, so a load balancer health check only routes traffic
+// to the leader. If configured, the status codes are inverted.
+func (node *ElectorNode) httpLeaderStatus(res http.ResponseWriter, req *http.Request) {
+	status := http.StatusServiceUnavailable
+	if node.IsLeader() != node.config.InvertLeaderStatus {
+		status = http.StatusOK
+	}
+	node.writeLeaderInfo(res, status, node.clock.Now())
+}
+
+// httpLeaderID is the handler for the endpoint which provides the identity of
+// the current leader as plain text, for use in shell scripts.
+func (node *ElectorNode) httpLeaderID(res http.ResponseWriter, req *http.Request) {
+	leader := node.leader()
+	status := http.StatusOK
+	if leader == "" {
+		status = http.StatusServiceUnavailable
+	}
+
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	res.WriteHeader(status)
+	if _, err := io.WriteString(res, leader+"\n"); err != nil {
+		node.log.Errorf("failed to write http response: %v", err)
+	}
+}
+
+// httpConfig is the handler for the endpoint which provides the effective
+// configuration of the node.
+func (node *ElectorNode) httpConfig(res http.ResponseWriter, req *http.Request) {
+	node.writeJSON(res, http.StatusOK, node.configInfo())
+}
+
+// httpTiming is the handler for the endpoint which provides the timings of
+// the election.
+func (node *ElectorNode) httpTiming(res http.ResponseWriter, req *http.Request) {
+	node.writeJSON(res, http.StatusOK, node.timingDetails())
+}
+
+// httpHealth is the handler for the endpoint which provides the node's health.
+func (node *ElectorNode) httpHealth(res http.ResponseWriter, req *http.Request) {
+	health := node.healthInfo()
+	status := http.StatusOK
+	if health.StartupError != "" {
+		status = http.StatusServiceUnavailable
+	}
+	node.writeJSON(res, status, health)
+}
+
+// httpOpenAPI is the handler for the endpoint which provides the OpenAPI
+// document for the elector HTTP API.
+func (node *ElectorNode) httpOpenAPI(res http.ResponseWriter, req *http.Request) {
+	node.writeJSON(res, http.StatusOK, openAPIDocument(node.routes()))
+}
+
+// httpShutdown is the handler for the endpoint which shuts down the elector.
+//
+// This takes the same shutdown path as receiving a SIGTERM, so the lease is
+// released (if held) and the node terminates gracefully. The node is shut down
+// directly rather than through the signal channel, so the request is recorded
+// as the reason for the stop, and is not lost if another signal is pending. If
+// the node is already stopping, the reason it is stopping for is kept.
+func (node *ElectorNode) httpShutdown(res http.ResponseWriter, req *http.Request) {
+	node.log.Infof("received shutdown request from %s", req.RemoteAddr)
+	node.setStopReason("shutdown requested over HTTP by %s", req.RemoteAddr)
+	node.terminate()
+
+	node.writeJSON(res, http.StatusOK, MessageResponse{
+		Message: "shutting down",
+	})
+}
@@ -0,0 +1,645 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestElectorNode_serveHTTP_noAddress(t *testing.T) {
+	buf := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		Address: "",
+		Logger:  buf,
+	})
+
+	node.serveHTTP()
+	assert.False(t, node.isServingHTTP())
+	assert.Contains(t, buf.String(), "no address given")
+}
+
+// startTestHTTPServer serves the node's HTTP endpoints on a local port, and
+// gets the base URL and a channel which is closed once the server stops.
+func startTestHTTPServer(t *testing.T, node *ElectorNode) (string, <-chan struct{}) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		node.serveHTTPOn(ln)
+	}()
+	return "http://" + ln.Addr().String(), stopped
+}
+
+func TestElectorNode_serveHTTPOn_electionCancelled(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{ID: "test-node-1", Logger: &testLogger{}})
+	url, stopped := startTestHTTPServer(t, node)
+	defer func() {
+		node.httpCancel()
+		<-stopped
+	}()
+
+	// Cancelling the election does not stop the HTTP server.
+	node.cancel()
+	res, err := http.Get(url + "/")
+	if assert.NoError(t, err) {
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	}
+}
+
+func TestElectorNode_serveHTTPOn_httpCancelled(t *testing.T) {
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{ID: "test-node-1", Logger: log})
+	url, stopped := startTestHTTPServer(t, node)
+
+	res, err := http.Get(url + "/healthz")
+	if assert.NoError(t, err) {
+		var health HealthInfo
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&health))
+		res.Body.Close()
+		assert.True(t, health.Serving)
+	}
+	assert.True(t, node.isServingHTTP())
+	assert.Equal(t, strings.TrimPrefix(url, "http://"), node.HTTPAddr())
+
+	// Stopping the HTTP server does not cancel the election.
+	node.httpCancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		assert.Fail(t, "timed out waiting for the HTTP server to stop")
+	}
+	assert.NoError(t, node.ctx.Err())
+	assert.Contains(t, log.String(), "HTTP server stopped")
+	assert.False(t, node.isServingHTTP())
+	assert.Empty(t, node.HTTPAddr())
+
+	_, err = http.Get(url + "/")
+	assert.Error(t, err)
+}
+
+func TestElectorNode_httpHandler_noLeader(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+
+	req := httptest.NewRequest("GET", "localhost:3333/", nil)
+	w := httptest.NewRecorder()
+
+	node.httpLeaderInfo(w, req)
+
+	resp := w.Result()
+
+	data := map[string]interface{}{}
+	d := json.NewDecoder(resp.Body)
+	err := d.Decode(&data)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	assert.NotNil(t, data["timestamp"])
+	assert.Equal(t, "test-node-1", data["node"])
+	assert.Equal(t, "", data["leader"])
+	assert.Equal(t, false, data["is_leader"])
+}
+
+func TestElectorNode_httpHandler_otherNodeIsLeader(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+	node.currentLeader = "test-node-2"
+
+	req := httptest.NewRequest("GET", "localhost:3333/", nil)
+	w := httptest.NewRecorder()
+
+	node.httpLeaderInfo(w, req)
+
+	resp := w.Result()
+
+	data := map[string]interface{}{}
+	d := json.NewDecoder(resp.Body)
+	err := d.Decode(&data)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	assert.NotNil(t, data["timestamp"])
+	assert.Equal(t, "test-node-1", data["node"])
+	assert.Equal(t, "test-node-2", data["leader"])
+	assert.Equal(t, false, data["is_leader"])
+}
+
+func TestElectorNode_httpHandler_isLeader(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+	node.currentLeader = "test-node-1"
+
+	req := httptest.NewRequest("GET", "localhost:3333/", nil)
+	w := httptest.NewRecorder()
+
+	node.httpLeaderInfo(w, req)
+
+	resp := w.Result()
+
+	data := map[string]interface{}{}
+	d := json.NewDecoder(resp.Body)
+	err := d.Decode(&data)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	assert.NotNil(t, data["timestamp"])
+	assert.Equal(t, "test-node-1", data["node"])
+	assert.Equal(t, "test-node-1", data["leader"])
+	assert.Equal(t, true, data["is_leader"])
+}
+
+func TestElectorNode_httpShutdown(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		AuthToken: "secret",
+	})
+
+	req := httptest.NewRequest("POST", "/shutdown", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+
+	node.mux().ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Error(t, node.ctx.Err())
+	assert.Equal(t, "shutdown requested over HTTP by 192.0.2.1:1234", node.stopReasonFor(nil))
+}
+
+func TestElectorNode_httpShutdown_stopping(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		AuthToken: "secret",
+		Logger:    &testLogger{},
+	})
+	node.setStopReason("received termination signal %v", "terminated")
+
+	// A node which is already stopping keeps the reason it is stopping for.
+	w := postAuthorized(node, "/shutdown")
+	assert.Equal(t, 200, w.Code)
+	assert.Error(t, node.ctx.Err())
+	assert.Equal(t, "received termination signal terminated", node.stopReasonFor(nil))
+}
+
+func TestElectorNode_httpShutdown_rejected(t *testing.T) {
+	cases := []struct {
+		description string
+		token       string
+		method      string
+		auth        string
+		expected    int
+	}{
+		{
+			description: "no auth token configured",
+			token:       "",
+			method:      "POST",
+			auth:        "Bearer secret",
+			expected:    403,
+		},
+		{
+			description: "no auth token provided",
+			token:       "secret",
+			method:      "POST",
+			auth:        "",
+			expected:    401,
+		},
+		{
+			description: "wrong auth token provided",
+			token:       "secret",
+			method:      "POST",
+			auth:        "Bearer other",
+			expected:    401,
+		},
+		{
+			description: "wrong method",
+			token:       "secret",
+			method:      "GET",
+			auth:        "Bearer secret",
+			expected:    405,
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			AuthToken: c.token,
+		})
+
+		req := httptest.NewRequest(c.method, "/shutdown", nil)
+		if c.auth != "" {
+			req.Header.Set("Authorization", c.auth)
+		}
+		w := httptest.NewRecorder()
+
+		node.mux().ServeHTTP(w, req)
+
+		assert.Equal(t, c.expected, w.Code, c.description)
+		assert.NoError(t, node.ctx.Err(), c.description)
+	}
+}
+
+func TestElectorNode_accessLog(t *testing.T) {
+	cases := []struct {
+		description string
+		method      string
+		path        string
+		status      int
+	}{
+		{
+			description: "successful request",
+			method:      "GET",
+			path:        "/",
+			status:      200,
+		},
+		{
+			description: "method not allowed",
+			method:      "POST",
+			path:        "/openapi.json",
+			status:      405,
+		},
+		{
+			description: "unauthorized request",
+			method:      "POST",
+			path:        "/shutdown",
+			status:      403,
+		},
+	}
+
+	for _, c := range cases {
+		buf := &testLogger{}
+		node := NewElectorNode(&ElectorConfig{
+			AccessLog: true,
+			Logger:    buf,
+		})
+
+		req := httptest.NewRequest(c.method, c.path, nil)
+		w := httptest.NewRecorder()
+		node.handler().ServeHTTP(w, req)
+
+		assert.Equal(t, c.status, w.Code, c.description)
+
+		i := strings.Index(buf.String(), "http access: ")
+		if !assert.True(t, i >= 0, c.description) {
+			continue
+		}
+		line := strings.SplitN(buf.String()[i+len("http access: "):], "\n", 2)[0]
+
+		entry := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry), c.description)
+		assert.Equal(t, c.method, entry["method"], c.description)
+		assert.Equal(t, c.path, entry["path"], c.description)
+		assert.Equal(t, float64(c.status), entry["status"], c.description)
+		assert.NotEmpty(t, entry["remote_addr"], c.description)
+		assert.Contains(t, entry, "latency_seconds", c.description)
+	}
+}
+
+func TestElectorNode_accessLog_disabled(t *testing.T) {
+	buf := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		Logger: buf,
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	node.handler().ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.NotContains(t, buf.String(), "http access")
+}
+
+func TestElectorNode_httpConfig_timings(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:        "test-node-1",
+		Name:      "test-election",
+		AuthToken: "secret",
+		TTL:       12 * time.Second,
+	})
+
+	data := getJSON(t, node, "/config")
+	assert.Equal(t, "test-node-1", data["id"])
+	assert.Equal(t, "test-election", data["election"])
+	assert.NotContains(t, data, "auth_token")
+	assert.Equal(t, map[string]interface{}{
+		"lease_duration_seconds": 12.0,
+		"lease_duration_human":   "12s",
+		"renew_deadline_seconds": 4.0,
+		"renew_deadline_human":   "4s",
+		"retry_period_seconds":   2.0,
+		"retry_period_human":     "2s",
+	}, data["timings"])
+
+	// Once the election adopts a lease duration, the timings are derived from it.
+	node.leaseDuration = 30 * time.Second
+
+	data = getJSON(t, node, "/config")
+	assert.Equal(t, 12.0, data["ttl_seconds"])
+	assert.Equal(t, "12s", data["ttl_human"])
+	assert.Equal(t, map[string]interface{}{
+		"lease_duration_seconds": 30.0,
+		"lease_duration_human":   "30s",
+		"renew_deadline_seconds": 10.0,
+		"renew_deadline_human":   "10s",
+		"retry_period_seconds":   5.0,
+		"retry_period_human":     "5s",
+	}, data["timings"])
+}
+
+func TestElectorNode_httpLeaderStatus(t *testing.T) {
+	cases := []struct {
+		description string
+		leader      string
+		invert      bool
+		expected    int
+	}{
+		{
+			description: "no leader",
+			leader:      "",
+			expected:    503,
+		},
+		{
+			description: "other node is leader",
+			leader:      "test-node-2",
+			expected:    503,
+		},
+		{
+			description: "node is leader",
+			leader:      "test-node-1",
+			expected:    200,
+		},
+		{
+			description: "other node is leader, inverted",
+			leader:      "test-node-2",
+			invert:      true,
+			expected:    200,
+		},
+		{
+			description: "node is leader, inverted",
+			leader:      "test-node-1",
+			invert:      true,
+			expected:    503,
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:                 "test-node-1",
+			InvertLeaderStatus: c.invert,
+		})
+		node.currentLeader = c.leader
+
+		req := httptest.NewRequest("GET", "/leader/status", nil)
+		w := httptest.NewRecorder()
+		node.mux().ServeHTTP(w, req)
+
+		resp := w.Result()
+		assert.Equal(t, c.expected, resp.StatusCode, c.description)
+
+		data := map[string]interface{}{}
+		err := json.NewDecoder(resp.Body).Decode(&data)
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, c.leader, data["leader"], c.description)
+		assert.Equal(t, c.leader == "test-node-1", data["is_leader"], c.description)
+	}
+}
+
+func TestElectorNode_httpTiming(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	node := NewElectorNode(&ElectorConfig{
+		ID:  "test-node-1",
+		TTL: 6 * time.Second,
+	})
+	node.clock = clk
+	node.lock = newObservedLock(&fakeLock{identity: "test-node-1"}, clk)
+
+	// Before the node leads, there are no renewal timings.
+	data := getJSON(t, node, "/timing")
+	assert.Equal(t, 6.0, data["lease_duration_seconds"])
+	assert.Equal(t, "6s", data["lease_duration_human"])
+	assert.Equal(t, 2.0, data["renew_deadline_seconds"])
+	assert.Equal(t, "2s", data["renew_deadline_human"])
+	assert.Equal(t, 1.0, data["retry_period_seconds"])
+	assert.Equal(t, "1s", data["retry_period_human"])
+	assert.Equal(t, 1.2, data["jitter_factor"])
+	assert.Equal(t, 1.0, data["rejoin_delay_seconds"])
+	assert.Equal(t, "1s", data["rejoin_delay_human"])
+	assert.Equal(t, "", data["last_renew"])
+	assert.Equal(t, "", data["next_renew"])
+	assert.Equal(t, "", data["renew_deadline_at"])
+
+	// Once leading, the deadlines are computed from the last renewal.
+	err := node.lock.Create(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+	assert.NoError(t, err)
+	node.setLeader("test-node-1")
+	clk.Step(500 * time.Millisecond)
+
+	data = getJSON(t, node, "/timing")
+	assert.Equal(t, "2019-05-02T18:00:00Z", data["last_renew"])
+	assert.Equal(t, "2019-05-02T18:00:01Z", data["next_renew"])
+	assert.Equal(t, "2019-05-02T18:00:02Z", data["renew_deadline_at"])
+
+	// A renewal moves the deadlines.
+	err = node.lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+	assert.NoError(t, err)
+
+	data = getJSON(t, node, "/timing")
+	assert.Equal(t, "2019-05-02T18:00:00.5Z", data["last_renew"])
+	assert.Equal(t, "2019-05-02T18:00:01.5Z", data["next_renew"])
+	assert.Equal(t, "2019-05-02T18:00:02.5Z", data["renew_deadline_at"])
+
+	// When another node leads, there are no renewal timings.
+	node.setLeader("test-node-2")
+	data = getJSON(t, node, "/timing")
+	assert.Equal(t, "", data["last_renew"])
+}
+
+func TestElectorNode_httpLeaderID(t *testing.T) {
+	cases := []struct {
+		description string
+		leader      string
+		status      int
+		body        string
+	}{
+		{
+			description: "no leader",
+			leader:      "",
+			status:      http.StatusServiceUnavailable,
+			body:        "\n",
+		},
+		{
+			description: "node is the leader",
+			leader:      "test-node-1",
+			status:      http.StatusOK,
+			body:        "test-node-1\n",
+		},
+		{
+			description: "another node is the leader",
+			leader:      "test-node-2",
+			status:      http.StatusOK,
+			body:        "test-node-2\n",
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID: "test-node-1",
+		})
+		node.setLeader(c.leader)
+
+		req := httptest.NewRequest("GET", "/leader/id", nil)
+		w := httptest.NewRecorder()
+		node.mux().ServeHTTP(w, req)
+
+		assert.Equal(t, c.status, w.Code, c.description)
+		assert.Equal(t, c.body, w.Body.String(), c.description)
+		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), c.description)
+	}
+}
+
+func TestElectorNode_writeLeaderInfo(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+	now := time.Date(2019, 5, 2, 18, 28, 51, 0, time.UTC)
+
+	// The cached payload matches the marshaled LeaderInfo, and is updated
+	// immediately when the leader changes or leadership is acquired.
+	acquisitions := 0
+	for _, c := range []struct {
+		leader   string
+		previous string
+	}{
+		{leader: "", previous: ""},
+		{leader: "test-node-2", previous: ""},
+		{leader: "test-node-1", previous: "test-node-2"},
+		{leader: "test-node-3", previous: "test-node-1"},
+		{leader: "test-node-3", previous: "test-node-1"},
+		{leader: "test-node-1", previous: "test-node-3"},
+	} {
+		node.setLeader(c.leader)
+		if c.leader == "test-node-1" {
+			node.recordAcquisition()
+			acquisitions++
+		}
+
+		w := httptest.NewRecorder()
+		node.writeLeaderInfo(w, 200, now)
+
+		expected, err := json.Marshal(LeaderInfo{
+			Node:           "test-node-1",
+			Leader:         c.leader,
+			PreviousLeader: c.previous,
+			EventID:        node.lastEventID(),
+			IsLeader:       c.leader == "test-node-1",
+			HasLed:         acquisitions > 0,
+			Acquisitions:   acquisitions,
+			ElectionEpoch:  1,
+			Timestamp:      Timestamp(now),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, string(expected), w.Body.String(), c.leader)
+		assert.Equal(t, "application/json", w.Result().Header.Get("Content-Type"), c.leader)
+	}
+}
+
+func TestElectorNode_writeLeaderInfo_renewals(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 28, 51, 0, time.UTC))
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+	node.lock = newObservedLock(&fakeLock{identity: "test-node-1"}, clk)
+	node.setLeader("test-node-1")
+
+	// The cached payload is rebuilt once the node renews the lock, so each
+	// response has the current renewals.
+	for i := 1; i <= 3; i++ {
+		err := node.lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+		assert.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		node.writeLeaderInfo(w, 200, clk.Now())
+
+		var info LeaderInfo
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+		assert.Equal(t, int64(i), info.Renewals)
+		assert.Equal(t, "2019-05-02T18:28:51Z", info.Timestamp.String())
+	}
+}
+
+func TestLeaderInfoBody(t *testing.T) {
+	info := LeaderInfo{
+		Node:      "test-node-1",
+		Leader:    "test-node-2",
+		Renewals:  3,
+		Timestamp: Timestamp(time.Date(2019, 5, 2, 18, 28, 51, 0, time.UTC)),
+	}
+	body, err := json.Marshal(leaderInfoBody{LeaderInfo: info})
+	assert.NoError(t, err)
+	full, err := json.Marshal(info)
+	assert.NoError(t, err)
+
+	// The body has every field of the leader info but the timestamp.
+	var bodyFields, fullFields map[string]interface{}
+	assert.NoError(t, json.Unmarshal(body, &bodyFields))
+	assert.NoError(t, json.Unmarshal(full, &fullFields))
+	assert.Equal(t, "2019-05-02T18:28:51Z", fullFields["timestamp"])
+	delete(fullFields, "timestamp")
+	assert.Equal(t, fullFields, bodyFields)
+}
+
+// discardResponseWriter is an http.ResponseWriter which discards the response,
+// so benchmarks only measure the handler.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+func BenchmarkElectorNode_httpLeaderInfo(b *testing.B) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+	node.setLeader("test-node-2")
+	req := httptest.NewRequest("GET", "/", nil)
+	w := &discardResponseWriter{header: http.Header{}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		node.httpLeaderInfo(w, req)
+	}
+}
+
+// BenchmarkElectorNode_httpLeaderInfo_marshal benchmarks marshaling the
+// leader info for each request, which the cached payload replaces.
+func BenchmarkElectorNode_httpLeaderInfo_marshal(b *testing.B) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+	node.setLeader("test-node-2")
+	w := &discardResponseWriter{header: http.Header{}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		node.writeJSON(w, http.StatusOK, node.leaderInfo())
+	}
+}
@@ -0,0 +1,117 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// httpHandlerNotFound is the handler label of the requests which did not
+// match any route.
+const httpHandlerNotFound = "not_found"
+
+// httpServerMetrics are the metrics of the elector node's HTTP server.
+type httpServerMetrics struct {
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+}
+
+// newHTTPServerMetrics creates the metrics of the elector node's HTTP server.
+// They have the same constant labels as the node's other metrics.
+func newHTTPServerMetrics(config *ElectorConfig) *httpServerMetrics {
+	labels := prometheus.Labels{"election": config.Name}
+	if config.MetricsIdentityLabel {
+		labels["identity"] = config.ID
+	}
+
+	return &httpServerMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   config.MetricsNamespace,
+			Subsystem:   metricsSubsystem,
+			Name:        "http_request_duration_seconds",
+			Help:        "The duration of the HTTP requests served by the elector, by handler, status code, and method.",
+			Buckets:     prometheus.DefBuckets,
+			ConstLabels: labels,
+		}, []string{"handler", "code", "method"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   config.MetricsNamespace,
+			Subsystem:   metricsSubsystem,
+			Name:        "http_requests_in_flight",
+			Help:        "The number of HTTP requests being served by the elector.",
+			ConstLabels: labels,
+		}),
+	}
+}
+
+// register registers the HTTP server metrics.
+func (m *httpServerMetrics) register(registerer prometheus.Registerer) error {
+	if err := registerer.Register(m.duration); err != nil {
+		return err
+	}
+	return registerer.Register(m.inFlight)
+}
+
+// instrumentHTTP wraps the node's request multiplexer to time each request it
+// serves.
+//
+// Requests are labelled with the pattern of the route which served them, as
+// registered from the route table, rather than their path, so the number of
+// series is bounded by the number of routes. Requests for unknown paths are
+// labelled with the route which served them (e.g. the catch-all "/" route),
+// or "not_found" if there is none. If the node's metrics are not registered,
+// the multiplexer is not wrapped.
+func (node *ElectorNode) instrumentHTTP(mux *http.ServeMux) http.Handler {
+	metrics := node.serverMetrics
+	if metrics == nil {
+		return mux
+	}
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		handler := httpHandlerNotFound
+		if _, pattern := mux.Handler(req); pattern != "" {
+			handler = pattern
+		}
+
+		metrics.inFlight.Inc()
+		defer metrics.inFlight.Dec()
+
+		start := time.Now()
+		w := &responseWriter{ResponseWriter: res}
+		mux.ServeHTTP(w, req)
+
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		metrics.duration.WithLabelValues(handler, strconv.Itoa(w.status), httpMethodLabel(req.Method)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// httpMethodLabel gets the method label of a request. Methods other than the
+// standard HTTP methods are labelled "other", so arbitrary methods sent by
+// clients do not create new series.
+func httpMethodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,91 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElectorNode_instrumentHTTP(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	node := newTestMetricsNode(&ElectorConfig{Registerer: registry})
+	assert.NoError(t, node.registerMetrics())
+
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodGet, path: "/"},
+		{method: http.MethodGet, path: "/config"},
+		{method: http.MethodGet, path: "/config"},
+		{method: http.MethodPost, path: "/config"},
+		{method: "BREW", path: "/healthz"},
+		{method: http.MethodGet, path: "/metrics"},
+		{method: http.MethodGet, path: "/no/such/path"},
+	}
+	handler := node.handler()
+	for _, r := range requests {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(r.method, r.path, nil))
+	}
+
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+
+	counts := map[[3]string]uint64{}
+	var inFlight *float64
+	for _, family := range families {
+		switch family.GetName() {
+		case "elector_http_request_duration_seconds":
+			for _, m := range family.GetMetric() {
+				labels := map[string]string{}
+				for _, l := range m.GetLabel() {
+					labels[l.GetName()] = l.GetValue()
+				}
+				assert.Equal(t, "test-election", labels["election"])
+				counts[[3]string{labels["handler"], labels["code"], labels["method"]}] = m.GetHistogram().GetSampleCount()
+			}
+		case "elector_http_requests_in_flight":
+			v := family.GetMetric()[0].GetGauge().GetValue()
+			inFlight = &v
+		}
+	}
+
+	// Requests are labelled with the route which served them, so unknown
+	// paths do not create new series.
+	assert.Equal(t, map[[3]string]uint64{
+		{"/", "200", "GET"}:          2,
+		{"/config", "200", "GET"}:    2,
+		{"/config", "405", "POST"}:   1,
+		{"/healthz", "405", "other"}: 1,
+		{"/metrics", "200", "GET"}:   1,
+	}, counts)
+	if assert.NotNil(t, inFlight) {
+		assert.Equal(t, float64(0), *inFlight)
+	}
+}
+
+func TestElectorNode_instrumentHTTP_notRegistered(t *testing.T) {
+	node := newTestMetricsNode(&ElectorConfig{})
+	mux := node.mux()
+	assert.Equal(t, mux, node.instrumentHTTP(mux))
+}
+
+func TestHTTPMethodLabel(t *testing.T) {
+	cases := []struct {
+		method   string
+		expected string
+	}{
+		{method: http.MethodGet, expected: "GET"},
+		{method: http.MethodPost, expected: "POST"},
+		{method: http.MethodDelete, expected: "DELETE"},
+		{method: "BREW", expected: "other"},
+		{method: "", expected: "other"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, httpMethodLabel(c.method), c.method)
+	}
+}
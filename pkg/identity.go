@@ -0,0 +1,87 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// MaxIDLength is the maximum length of an elector node ID. The ID is recorded
+// as the holder of the election lock, and reported in payloads and logs, so
+// it is bounded to the length of a Kubernetes object name.
+const MaxIDLength = 253
+
+// idLabelHashLength is the number of hex characters of the hash of an ID
+// which are added to its label value when the ID is not a valid label value.
+const idLabelHashLength = 10
+
+// validateID checks that the ID of an elector node is usable as the holder
+// identity of the election lock and in the node's payloads and logs.
+func validateID(id string) error {
+	if len(id) > MaxIDLength {
+		return fmt.Errorf("invalid ID %q: must be no more than %d characters", id, MaxIDLength)
+	}
+	for _, r := range id {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("invalid ID %q: must not contain control characters", id)
+		}
+	}
+	return nil
+}
+
+// idLabelValue derives a form of the ID which is a valid Kubernetes label
+// value. IDs which are already valid label values are used as they are.
+//
+// Otherwise, characters which are not allowed in a label value are replaced,
+// the value is truncated to fit the 63 character limit, and a hash of the full
+// ID is appended. The hash keeps IDs which share a prefix, or differ only in
+// replaced characters, from deriving the same value, and the derivation is
+// deterministic, so every node derives the same value for an ID.
+func idLabelValue(id string) string {
+	if len(validation.IsValidLabelValue(id)) == 0 {
+		return id
+	}
+
+	sanitized := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.') {
+			return r
+		}
+		return '-'
+	}, id)
+
+	sum := sha256.Sum256([]byte(id))
+	hash := hex.EncodeToString(sum[:])[:idLabelHashLength]
+
+	// The value must start and end with an alphanumeric character. The hash
+	// always ends it, so only the start and the end of the truncated prefix
+	// need trimming.
+	prefix := sanitized
+	if max := validation.LabelValueMaxLength - idLabelHashLength - 1; len(prefix) > max {
+		prefix = prefix[:max]
+	}
+	prefix = strings.Trim(prefix, "-_.")
+	if prefix == "" {
+		return hash
+	}
+	return prefix + "-" + hash
+}
@@ -0,0 +1,144 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestValidateID(t *testing.T) {
+	cases := []struct {
+		description string
+		id          string
+		valid       bool
+	}{
+		{
+			description: "hostname",
+			id:          "k8s-elector-74c54b485f-564ht",
+			valid:       true,
+		},
+		{
+			description: "colons and slashes",
+			id:          "node:1/a",
+			valid:       true,
+		},
+		{
+			description: "max length",
+			id:          strings.Repeat("a", MaxIDLength),
+			valid:       true,
+		},
+		{
+			description: "too long",
+			id:          strings.Repeat("a", MaxIDLength+1),
+			valid:       false,
+		},
+		{
+			description: "newline",
+			id:          "test-node\n1",
+			valid:       false,
+		},
+		{
+			description: "tab",
+			id:          "test-node\t1",
+			valid:       false,
+		},
+		{
+			description: "null",
+			id:          "test-node\x001",
+			valid:       false,
+		},
+	}
+
+	for _, c := range cases {
+		err := validateID(c.id)
+		if c.valid {
+			assert.NoError(t, err, c.description)
+		} else {
+			assert.Error(t, err, c.description)
+		}
+	}
+}
+
+func TestElectorNode_checkConfig_invalidID(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:        "test-node\n1",
+		Name:      "test-election",
+		Namespace: "test-ns",
+		Logger:    &testLogger{},
+	})
+	err := node.checkConfig()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "control characters")
+}
+
+func TestIDLabelValue(t *testing.T) {
+	uuids := "3f8a2c1e-8b4d-4f6a-9c2e-1d7b5a3e9f01.6c2e8a4f-1b3d-4e5f-8a9c-2d4e6f8a0b1c"
+
+	cases := []struct {
+		description string
+		id          string
+		expected    string
+	}{
+		{
+			description: "valid label value",
+			id:          "test-node-1",
+			expected:    "test-node-1",
+		},
+		{
+			description: "invalid characters",
+			id:          "node:1",
+			expected:    "node-1-" + testIDHash("node:1"),
+		},
+		{
+			description: "too long",
+			id:          uuids,
+			expected:    uuids[:52] + "-" + testIDHash(uuids),
+		},
+		{
+			description: "trailing separator after truncation",
+			id:          strings.Repeat("a", 51) + "." + strings.Repeat("b", 20),
+			expected:    strings.Repeat("a", 51) + "-" + testIDHash(strings.Repeat("a", 51)+"."+strings.Repeat("b", 20)),
+		},
+		{
+			description: "no valid characters",
+			id:          "::",
+			expected:    testIDHash("::"),
+		},
+	}
+
+	for _, c := range cases {
+		value := idLabelValue(c.id)
+		assert.Equal(t, c.expected, value, c.description)
+		assert.Empty(t, validation.IsValidLabelValue(value), c.description)
+
+		// The derivation is deterministic.
+		assert.Equal(t, value, idLabelValue(c.id), c.description)
+	}
+}
+
+func TestIDLabelValue_distinct(t *testing.T) {
+	// IDs which only differ after the truncation point, or in replaced
+	// characters, derive distinct values.
+	prefix := strings.Repeat("a", 70)
+	assert.NotEqual(t, idLabelValue(prefix+"-1"), idLabelValue(prefix+"-2"))
+	assert.NotEqual(t, idLabelValue("node:1"), idLabelValue("node/1"))
+}
+
+func TestElectorNode_configInfo_idLabelValue(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "node:1",
+	})
+	info := node.configInfo()
+	assert.Equal(t, "node:1", info.ID)
+	assert.Equal(t, idLabelValue("node:1"), info.IDLabelValue)
+}
+
+// testIDHash gets the hash of an ID which is appended to its label value.
+func testIDHash(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])[:idLabelHashLength]
+}
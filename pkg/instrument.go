@@ -0,0 +1,119 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// DefaultSlowRenewalFraction is the default fraction of the renew deadline
+// which a renewal may take before it is considered slow.
+const DefaultSlowRenewalFraction = 0.5
+
+// Operations on the election lock, as reported to lock observers.
+const (
+	lockOpGet    = "get"
+	lockOpCreate = "create"
+	lockOpUpdate = "update"
+)
+
+// lockObserver observes an operation made through an instrumentedLock: the
+// operation, the record written (empty for gets), how long the operation took,
+// and its error.
+type lockObserver func(op string, ler resourcelock.LeaderElectionRecord, took time.Duration, err error)
+
+// instrumentedLock wraps a resourcelock.Interface to time the operations made
+// through it, and reports them to its observers.
+type instrumentedLock struct {
+	resourcelock.Interface
+
+	clock     clock.Clock
+	observers []lockObserver
+}
+
+// newInstrumentedLock wraps the lock to report its operations to the given
+// observers, timed with the clock.
+func newInstrumentedLock(lock resourcelock.Interface, clk clock.Clock, observers ...lockObserver) *instrumentedLock {
+	return &instrumentedLock{
+		Interface: lock,
+		clock:     clk,
+		observers: observers,
+	}
+}
+
+// observe reports an operation which started at the given time.
+func (l *instrumentedLock) observe(op string, ler resourcelock.LeaderElectionRecord, start time.Time, err error) {
+	took := l.clock.Since(start)
+	for _, observer := range l.observers {
+		observer(op, ler, took, err)
+	}
+}
+
+// Get gets the lock record.
+func (l *instrumentedLock) Get() (*resourcelock.LeaderElectionRecord, []byte, error) {
+	start := l.clock.Now()
+	record, raw, err := l.Interface.Get()
+	l.observe(lockOpGet, resourcelock.LeaderElectionRecord{}, start, err)
+	return record, raw, err
+}
+
+// Create creates the lock record.
+func (l *instrumentedLock) Create(ler resourcelock.LeaderElectionRecord) error {
+	start := l.clock.Now()
+	err := l.Interface.Create(ler)
+	l.observe(lockOpCreate, ler, start, err)
+	return err
+}
+
+// Update updates the lock record.
+func (l *instrumentedLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	start := l.clock.Now()
+	err := l.Interface.Update(ler)
+	l.observe(lockOpUpdate, ler, start, err)
+	return err
+}
+
+// observeSlowRenewals is a lockObserver which warns when a renewal of the
+// node's lease takes longer than the configured fraction of the renew deadline,
+// as an early warning before the node fails to renew in time and loses its
+// leadership. Slow renewals are counted.
+func (node *ElectorNode) observeSlowRenewals(op string, ler resourcelock.LeaderElectionRecord, took time.Duration, err error) {
+	if op != lockOpUpdate || err != nil || ler.HolderIdentity != node.config.ID {
+		return
+	}
+
+	deadline := node.timings().RenewDeadline
+	threshold := time.Duration(float64(deadline) * node.config.SlowRenewalFraction)
+	if took <= threshold {
+		return
+	}
+
+	node.mu.Lock()
+	node.slowRenewals++
+	node.mu.Unlock()
+	node.log.Warningf("slow lease renewal: took %v, %.0f%% of the %v renew deadline", took, 100*float64(took)/float64(deadline), deadline)
+}
+
+// slowRenewalCount gets the number of slow renewals the node has made.
+func (node *ElectorNode) slowRenewalCount() int {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.slowRenewals
+}
@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// slowLock is a fakeLock whose writes take the given latency on a fake clock.
+type slowLock struct {
+	*fakeLock
+	clock   *clock.FakeClock
+	latency time.Duration
+}
+
+func (l *slowLock) Create(ler resourcelock.LeaderElectionRecord) error {
+	l.clock.Step(l.latency)
+	return l.fakeLock.Create(ler)
+}
+
+func (l *slowLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	l.clock.Step(l.latency)
+	return l.fakeLock.Update(ler)
+}
+
+// observedOp is an operation reported to a lockObserver.
+type observedOp struct {
+	op     string
+	holder string
+	took   time.Duration
+	err    error
+}
+
+func TestInstrumentedLock(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	fake := &fakeLock{identity: "test-node-1"}
+	var ops []observedOp
+	lock := newInstrumentedLock(&slowLock{fakeLock: fake, clock: clk, latency: 2 * time.Second}, clk,
+		func(op string, ler resourcelock.LeaderElectionRecord, took time.Duration, err error) {
+			ops = append(ops, observedOp{op, ler.HolderIdentity, took, err})
+		},
+	)
+
+	_, _, err := lock.Get()
+	assert.Error(t, err)
+	assert.NoError(t, lock.Create(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+	assert.NoError(t, lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+	fake.err = errors.New("unavailable")
+	assert.Error(t, lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+
+	assert.Equal(t, []observedOp{
+		{op: lockOpGet, took: 0, err: errors.New("not found")},
+		{op: lockOpCreate, holder: "test-node-1", took: 2 * time.Second},
+		{op: lockOpUpdate, holder: "test-node-1", took: 2 * time.Second},
+		{op: lockOpUpdate, holder: "test-node-1", took: 2 * time.Second, err: errors.New("unavailable")},
+	}, ops)
+	assert.Equal(t, "test-node-1", lock.Identity())
+}
+
+func TestElectorNode_observeSlowRenewals(t *testing.T) {
+	cases := []struct {
+		description string
+		latency     time.Duration
+		fraction    float64
+		slow        bool
+	}{
+		{
+			description: "fast renewal",
+			latency:     500 * time.Millisecond,
+			fraction:    0.5,
+			slow:        false,
+		},
+		{
+			description: "renewal at the threshold",
+			latency:     2 * time.Second,
+			fraction:    0.5,
+			slow:        false,
+		},
+		{
+			description: "slow renewal",
+			latency:     3 * time.Second,
+			fraction:    0.5,
+			slow:        true,
+		},
+		{
+			description: "renewal under a higher fraction",
+			latency:     3 * time.Second,
+			fraction:    0.9,
+			slow:        false,
+		},
+	}
+
+	for _, c := range cases {
+		log := &testLogger{}
+		clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+		node := NewElectorNode(&ElectorConfig{
+			ID:                  "test-node-1",
+			TTL:                 12 * time.Second,
+			SlowRenewalFraction: c.fraction,
+			Logger:              log,
+		})
+		fake := &fakeLock{identity: "test-node-1"}
+		lock := newInstrumentedLock(&slowLock{fakeLock: fake, clock: clk, latency: c.latency}, clk, node.observeSlowRenewals)
+
+		// The renew deadline is a third of the TTL, so 4s.
+		err := lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+		assert.NoError(t, err, c.description)
+
+		if c.slow {
+			assert.Equal(t, 1, node.slowRenewalCount(), c.description)
+			assert.Contains(t, log.String(), "slow lease renewal: took 3s, 75% of the 4s renew deadline", c.description)
+		} else {
+			assert.Equal(t, 0, node.slowRenewalCount(), c.description)
+			assert.NotContains(t, log.String(), "slow lease renewal", c.description)
+		}
+	}
+}
+
+func TestElectorNode_observeSlowRenewals_notRenewal(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	node := NewElectorNode(&ElectorConfig{
+		ID:                  "test-node-1",
+		TTL:                 12 * time.Second,
+		SlowRenewalFraction: 0.5,
+		Logger:              &testLogger{},
+	})
+	fake := &fakeLock{identity: "test-node-1"}
+	lock := newInstrumentedLock(&slowLock{fakeLock: fake, clock: clk, latency: 3 * time.Second}, clk, node.observeSlowRenewals)
+
+	// Acquiring the lock, releasing it, and failed renewals are not counted.
+	assert.NoError(t, lock.Create(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+	assert.NoError(t, lock.Update(resourcelock.LeaderElectionRecord{}))
+	fake.err = errors.New("unavailable")
+	assert.Error(t, lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+	assert.Equal(t, 0, node.slowRenewalCount())
+}
@@ -0,0 +1,73 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"net"
+	"strings"
+)
+
+// EnvPodIP is the environment variable which is checked for the IP of the
+// elector node's Pod, for its structured ID. It is typically set from the
+// Pod's status.podIP with the downward API.
+const EnvPodIP = "ELECTOR_POD_IP"
+
+// structuredIDSeparator separates the fields of a structured ID. It can not
+// appear in a hostname, a Pod IP, or a node name.
+const structuredIDSeparator = "/"
+
+// LeaderMeta is the metadata of an election participant, parsed from its
+// structured ID.
+type LeaderMeta struct {
+	Hostname string `json:"hostname" description:"The hostname of the participant."`
+	PodIP    string `json:"pod_ip" description:"The IP of the participant's Pod. Empty if it was not known when the participant started."`
+	NodeName string `json:"node_name" description:"The name of the Kubernetes node the participant's Pod runs on. Empty if it was not known when the participant started."`
+}
+
+// structuredID builds the structured ID of an elector node, as
+// "<hostname>/<pod IP>/<node name>". Fields which are not known are left
+// empty, so the ID can still be parsed.
+func structuredID(hostname, podIP, nodeName string) string {
+	return strings.Join([]string{hostname, podIP, nodeName}, structuredIDSeparator)
+}
+
+// parseLeaderMeta parses the metadata of an election participant from its ID.
+// If the ID is not a structured ID, nil is returned, since IDs are otherwise
+// opaque.
+func parseLeaderMeta(id string) *LeaderMeta {
+	fields := strings.Split(id, structuredIDSeparator)
+	if len(fields) != 3 || fields[0] == "" {
+		return nil
+	}
+	if fields[1] != "" && net.ParseIP(fields[1]) == nil {
+		return nil
+	}
+	return &LeaderMeta{
+		Hostname: fields[0],
+		PodIP:    fields[1],
+		NodeName: fields[2],
+	}
+}
+
+// LeaderMeta gets the metadata of the current leader, parsed from its ID. If
+// there is no leader, or its ID is not a structured ID, nil is returned.
+//
+// This lets consumers find the leader's Pod IP and node without looking up
+// its Pod, when the participants use structured IDs.
+func (node *ElectorNode) LeaderMeta() *LeaderMeta {
+	return parseLeaderMeta(node.leader())
+}
@@ -0,0 +1,188 @@
+package pkg
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestParseLeaderMeta(t *testing.T) {
+	cases := []struct {
+		description string
+		id          string
+		expected    *LeaderMeta
+	}{
+		{
+			description: "structured ID",
+			id:          "elector-0/10.0.0.12/worker-2",
+			expected:    &LeaderMeta{Hostname: "elector-0", PodIP: "10.0.0.12", NodeName: "worker-2"},
+		},
+		{
+			description: "structured ID with an IPv6 pod IP",
+			id:          "elector-0/fd00::12/worker-2",
+			expected:    &LeaderMeta{Hostname: "elector-0", PodIP: "fd00::12", NodeName: "worker-2"},
+		},
+		{
+			description: "structured ID with unknown fields",
+			id:          "elector-0//",
+			expected:    &LeaderMeta{Hostname: "elector-0"},
+		},
+		{
+			description: "hostname",
+			id:          "elector-0",
+		},
+		{
+			description: "composite ID",
+			id:          "elector-0-k8s-elector-74c54b485f-hgf9z",
+		},
+		{
+			description: "too many fields",
+			id:          "elector-0/10.0.0.12/worker-2/extra",
+		},
+		{
+			description: "no hostname",
+			id:          "/10.0.0.12/worker-2",
+		},
+		{
+			description: "invalid pod IP",
+			id:          "team/elector-0/worker-2",
+		},
+		{
+			description: "no leader",
+			id:          "",
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, parseLeaderMeta(c.id), c.description)
+	}
+}
+
+func TestElectorNode_checkConfig_structuredID(t *testing.T) {
+	hostname, err := os.Hostname()
+	assert.NoError(t, err)
+
+	cases := []struct {
+		description string
+		config      *ElectorConfig
+		env         string
+		expected    string
+	}{
+		{
+			description: "structured ID",
+			config:      &ElectorConfig{StructuredID: true, NodeName: "worker-2"},
+			env:         "10.0.0.12",
+			expected:    hostname + "/10.0.0.12/worker-2",
+		},
+		{
+			description: "structured ID with the pod IP set in config",
+			config:      &ElectorConfig{StructuredID: true, PodIP: "10.0.0.13"},
+			env:         "10.0.0.12",
+			expected:    hostname + "/10.0.0.13/",
+		},
+		{
+			description: "structured ID takes precedence over resolving collisions",
+			config:      &ElectorConfig{StructuredID: true, ResolveIDCollisions: true},
+			expected:    hostname + "//",
+		},
+		{
+			description: "ID set",
+			config:      &ElectorConfig{StructuredID: true, ID: "test-node-1"},
+			env:         "10.0.0.12",
+			expected:    "test-node-1",
+		},
+		{
+			description: "structured ID not enabled",
+			config:      &ElectorConfig{NodeName: "worker-2"},
+			env:         "10.0.0.12",
+			expected:    hostname,
+		},
+	}
+
+	defer os.Unsetenv(EnvPodIP)
+	for _, c := range cases {
+		assert.NoError(t, os.Setenv(EnvPodIP, c.env), c.description)
+		c.config.Name = "test-election"
+		c.config.PodName = "test-pod"
+		c.config.Logger = &testLogger{}
+		node := NewElectorNode(c.config)
+
+		assert.NoError(t, node.checkConfig(), c.description)
+		assert.Equal(t, c.expected, node.config.ID, c.description)
+	}
+}
+
+func TestElectorNode_LeaderMeta(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "elector-1/10.0.0.11/worker-1",
+		Logger: &testLogger{},
+	})
+	assert.Nil(t, node.LeaderMeta())
+	assert.NotContains(t, getJSON(t, node, "/"), "leader_meta")
+
+	node.setLeader("elector-0/10.0.0.12/worker-2")
+	assert.Equal(t, &LeaderMeta{Hostname: "elector-0", PodIP: "10.0.0.12", NodeName: "worker-2"}, node.LeaderMeta())
+	assert.Equal(t, map[string]interface{}{
+		"hostname":  "elector-0",
+		"pod_ip":    "10.0.0.12",
+		"node_name": "worker-2",
+	}, getJSON(t, node, "/")["leader_meta"])
+	assert.Equal(t, "elector-0", node.leaderInfo().LeaderMeta.Hostname)
+
+	// A leader without a structured ID has no metadata.
+	node.setLeader("test-node-2")
+	assert.Nil(t, node.LeaderMeta())
+	assert.NotContains(t, getJSON(t, node, "/"), "leader_meta")
+}
+
+func TestElectorNode_run_onNewLeader(t *testing.T) {
+	var mu sync.Mutex
+	var leaders []string
+	var metas []*LeaderMeta
+
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "elector-0/10.0.0.12/worker-2",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		PodName:       "test-pod",
+		LockType:      resourcelock.LeasesResourceLock,
+		TTL:           1 * time.Second,
+		Client:        client,
+		Logger:        &testLogger{},
+		OnNewLeader: func(leader string, meta *LeaderMeta) {
+			mu.Lock()
+			defer mu.Unlock()
+			leaders = append(leaders, leader)
+			metas = append(metas, meta)
+		},
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.runUntilError()
+	}()
+	waitFor(t, 5*time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(leaders) > 0
+	})
+
+	mu.Lock()
+	assert.Equal(t, "elector-0/10.0.0.12/worker-2", leaders[0])
+	assert.Equal(t, &LeaderMeta{Hostname: "elector-0", PodIP: "10.0.0.12", NodeName: "worker-2"}, metas[0])
+	mu.Unlock()
+
+	node.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node did not stop")
+	}
+}
@@ -0,0 +1,151 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// leaseReadTimeout bounds how long a forced read of the election lock may
+// take before the /lease endpoint gives up on it, so a slow API server does
+// not hang the request.
+const leaseReadTimeout = 5 * time.Second
+
+// LeaseInfo is the election lock record, as reported by the /lease endpoint.
+type LeaseInfo struct {
+	Holder               string        `json:"holder" description:"The ID of the holder of the lock. Empty if the lock is not held."`
+	AcquireTime          Timestamp     `json:"acquire_time" description:"When the holder acquired the lock."`
+	RenewTime            Timestamp     `json:"renew_time" description:"When the holder last renewed the lock."`
+	LeaseDurationSeconds Seconds       `json:"lease_duration_seconds" description:"The lease duration of the holder, in seconds."`
+	LeaseDurationHuman   HumanDuration `json:"lease_duration_human" description:"The lease duration of the holder, as a duration string."`
+	LeaderTransitions    int           `json:"leader_transitions" description:"The number of times leadership of the lock has changed hands."`
+	ReadAt               Timestamp     `json:"read_at" description:"When the record was read from the API server."`
+	Fresh                bool          `json:"fresh" description:"Whether the record was read for this request, rather than being the last record read by the election."`
+}
+
+// newLeaseInfo creates the LeaseInfo for a lock record read at the given time.
+func newLeaseInfo(record *resourcelock.LeaderElectionRecord, readAt time.Time, fresh bool) LeaseInfo {
+	duration := time.Duration(record.LeaseDurationSeconds) * time.Second
+	return LeaseInfo{
+		Holder:               record.HolderIdentity,
+		AcquireTime:          Timestamp(record.AcquireTime.Time),
+		RenewTime:            Timestamp(record.RenewTime.Time),
+		LeaseDurationSeconds: Seconds(duration),
+		LeaseDurationHuman:   HumanDuration(duration),
+		LeaderTransitions:    record.LeaderTransitions,
+		ReadAt:               Timestamp(readAt),
+		Fresh:                fresh,
+	}
+}
+
+// leaseRead is a forced read of the election lock. Concurrent forced reads
+// share the read which is in flight, so they make a single request.
+type leaseRead struct {
+	done   chan struct{}
+	record *resourcelock.LeaderElectionRecord
+	readAt time.Time
+	err    error
+}
+
+// readLease reads the election lock from the API server, bypassing the last
+// record read by the election. The read is made through the lock beneath the
+// observed lock, so it does not affect the election's own view of the lock.
+//
+// The read gives up after leaseReadTimeout. The request itself is left to
+// finish in the background, where later forced reads can still join it.
+func (node *ElectorNode) readLease(lock *observedLock) (*resourcelock.LeaderElectionRecord, time.Time, error) {
+	node.mu.Lock()
+	read := node.leaseRead
+	if read == nil {
+		read = &leaseRead{done: make(chan struct{})}
+		node.leaseRead = read
+		go func() {
+			read.record, _, read.err = lock.Interface.Get()
+			if read.err == nil && read.record == nil {
+				read.err = errors.New("no lock record")
+			}
+			read.readAt = node.clock.Now()
+
+			node.mu.Lock()
+			node.leaseRead = nil
+			node.mu.Unlock()
+			close(read.done)
+		}()
+	}
+	node.mu.Unlock()
+
+	select {
+	case <-read.done:
+		return read.record, read.readAt, read.err
+	case <-node.clock.After(leaseReadTimeout):
+		return nil, time.Time{}, fmt.Errorf("timed out after %v reading the election lock", leaseReadTimeout)
+	}
+}
+
+// httpLease is the handler for the endpoint which provides the election lock
+// record. By default, the last record read by the election is served, without
+// a request to the API server. With the 'nocache' query parameter set to
+// true, the lock is read from the API server instead.
+func (node *ElectorNode) httpLease(res http.ResponseWriter, req *http.Request) {
+	nocache := false
+	if value := req.URL.Query().Get("nocache"); value != "" {
+		var err error
+		if nocache, err = strconv.ParseBool(value); err != nil {
+			node.writeJSON(res, http.StatusBadRequest, MessageResponse{
+				Message: "the 'nocache' query parameter must be a boolean",
+			})
+			return
+		}
+	}
+
+	node.mu.RLock()
+	lock := node.lock
+	node.mu.RUnlock()
+	if lock == nil {
+		node.writeJSON(res, http.StatusServiceUnavailable, MessageResponse{
+			Message: "the election has not started",
+		})
+		return
+	}
+
+	if !nocache {
+		record, readAt := lock.lastRecord()
+		if record == nil {
+			node.writeJSON(res, http.StatusServiceUnavailable, MessageResponse{
+				Message: "no election lock record is known yet",
+			})
+			return
+		}
+		node.writeJSON(res, http.StatusOK, newLeaseInfo(record, readAt, false))
+		return
+	}
+
+	record, readAt, err := node.readLease(lock)
+	if err != nil {
+		node.writeJSON(res, http.StatusServiceUnavailable, MessageResponse{
+			Message: fmt.Sprintf("failed to read the election lock: %v", err),
+		})
+		return
+	}
+	node.writeJSON(res, http.StatusOK, newLeaseInfo(record, readAt, true))
+}
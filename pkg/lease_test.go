@@ -0,0 +1,177 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// blockingLock is a fakeLock whose reads block until they are released, as
+// when the API server is slow to respond.
+type blockingLock struct {
+	fakeLock
+
+	release chan struct{}
+	getsMu  sync.Mutex
+	gets    int
+}
+
+func (l *blockingLock) Get() (*resourcelock.LeaderElectionRecord, []byte, error) {
+	l.getsMu.Lock()
+	l.gets++
+	l.getsMu.Unlock()
+	<-l.release
+	return l.fakeLock.Get()
+}
+
+func (l *blockingLock) getCount() int {
+	l.getsMu.Lock()
+	defer l.getsMu.Unlock()
+	return l.gets
+}
+
+// newTestLeaseNode creates a node whose election lock is the given lock, as
+// if the election had started.
+func newTestLeaseNode(lock resourcelock.Interface) (*ElectorNode, *clock.FakeClock) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Logger: &testLogger{},
+	})
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 28, 51, 0, time.UTC))
+	node.clock = clk
+	node.lock = newObservedLock(lock, clk)
+	return node, clk
+}
+
+func TestElectorNode_httpLease(t *testing.T) {
+	renewed := time.Date(2019, 5, 2, 18, 28, 50, 0, time.UTC)
+	lock := &fakeLock{record: &resourcelock.LeaderElectionRecord{
+		HolderIdentity:       "test-node-2",
+		LeaseDurationSeconds: 15,
+		AcquireTime:          metav1.NewTime(renewed.Add(-time.Minute)),
+		RenewTime:            metav1.NewTime(renewed),
+		LeaderTransitions:    3,
+	}}
+	node, clk := newTestLeaseNode(lock)
+
+	// Until the election reads the lock, no record is known.
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, httptest.NewRequest("GET", "/lease", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"message":"no election lock record is known yet"}`, w.Body.String())
+
+	_, _, err := node.lock.Get()
+	assert.NoError(t, err)
+	clk.Step(time.Second)
+
+	// The lock changes hands, which the record last read by the election does
+	// not show until the lock is read from the API server.
+	assert.NoError(t, lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-3", LeaseDurationSeconds: 15, LeaderTransitions: 4}))
+	assert.Equal(t, map[string]interface{}{
+		"holder":                 "test-node-2",
+		"acquire_time":           "2019-05-02T18:27:50Z",
+		"renew_time":             "2019-05-02T18:28:50Z",
+		"lease_duration_seconds": float64(15),
+		"lease_duration_human":   "15s",
+		"leader_transitions":     float64(3),
+		"read_at":                "2019-05-02T18:28:51Z",
+		"fresh":                  false,
+	}, getJSON(t, node, "/lease"))
+	assert.Equal(t, getJSON(t, node, "/lease"), getJSON(t, node, "/lease?nocache=false"))
+
+	fresh := getJSON(t, node, "/lease?nocache=true")
+	assert.Equal(t, "test-node-3", fresh["holder"])
+	assert.Equal(t, float64(4), fresh["leader_transitions"])
+	assert.Equal(t, "2019-05-02T18:28:52Z", fresh["read_at"])
+	assert.Equal(t, true, fresh["fresh"])
+
+	// The forced read does not change the election's view of the lock.
+	record, _ := node.lock.lastRecord()
+	assert.Equal(t, "test-node-2", record.HolderIdentity)
+}
+
+func TestElectorNode_httpLease_errors(t *testing.T) {
+	cases := []struct {
+		description string
+		path        string
+		lock        resourcelock.Interface
+		status      int
+		message     string
+	}{
+		{
+			description: "election not started",
+			path:        "/lease",
+			status:      http.StatusServiceUnavailable,
+			message:     "the election has not started",
+		},
+		{
+			description: "invalid nocache",
+			path:        "/lease?nocache=yes",
+			lock:        &fakeLock{},
+			status:      http.StatusBadRequest,
+			message:     "the 'nocache' query parameter must be a boolean",
+		},
+		{
+			description: "forced read failed",
+			path:        "/lease?nocache=true",
+			lock:        &fakeLock{},
+			status:      http.StatusServiceUnavailable,
+			message:     "failed to read the election lock: not found",
+		},
+	}
+
+	for _, c := range cases {
+		node, _ := newTestLeaseNode(c.lock)
+		if c.lock == nil {
+			node.lock = nil
+		}
+
+		w := httptest.NewRecorder()
+		node.mux().ServeHTTP(w, httptest.NewRequest("GET", c.path, nil))
+		assert.Equal(t, c.status, w.Code, c.description)
+		assert.JSONEq(t, `{"message":"`+c.message+`"}`, w.Body.String(), c.description)
+	}
+}
+
+func TestElectorNode_httpLease_timeout(t *testing.T) {
+	lock := &blockingLock{
+		fakeLock: fakeLock{record: &resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2"}},
+		release:  make(chan struct{}),
+	}
+	node, clk := newTestLeaseNode(lock)
+
+	get := func() <-chan *httptest.ResponseRecorder {
+		done := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			w := httptest.NewRecorder()
+			node.mux().ServeHTTP(w, httptest.NewRequest("GET", "/lease?nocache=true", nil))
+			done <- w
+		}()
+		return done
+	}
+
+	// A slow read gives up after the timeout, rather than hanging.
+	first := get()
+	waitFor(t, 5*time.Second, clk.HasWaiters)
+	clk.Step(leaseReadTimeout)
+	w := <-first
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"message":"failed to read the election lock: timed out after 5s reading the election lock"}`, w.Body.String())
+
+	// A forced read made while the slow read is in flight joins it, so the
+	// lock is only read once.
+	second := get()
+	waitFor(t, 5*time.Second, clk.HasWaiters)
+	close(lock.release)
+	w = <-second
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"holder":"test-node-2"`)
+	assert.Equal(t, 1, lock.getCount())
+}
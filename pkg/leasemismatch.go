@@ -0,0 +1,135 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"time"
+
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// leaseDurationMismatchTolerance is the difference between the node's lease
+// duration and the one recorded by the leader which is tolerated. The record
+// only holds whole seconds, so smaller differences are expected.
+const leaseDurationMismatchTolerance = time.Second
+
+// LeaseDurationMismatch describes a difference between the lease duration of
+// the elector node and the lease duration recorded by the leader.
+type LeaseDurationMismatch struct {
+	Leader            string        `json:"leader" description:"The ID of the leader which recorded the lease duration."`
+	ConfiguredSeconds Seconds       `json:"configured_seconds" description:"The lease duration of the node, in seconds."`
+	ConfiguredHuman   HumanDuration `json:"configured_human" description:"The lease duration of the node, as a duration string."`
+	ObservedSeconds   Seconds       `json:"observed_seconds" description:"The lease duration recorded by the leader, in seconds."`
+	ObservedHuman     HumanDuration `json:"observed_human" description:"The lease duration recorded by the leader, as a duration string."`
+}
+
+// checkLeaseDuration compares the node's lease duration with the lease
+// duration recorded by the leader in an observed lock record.
+//
+// Participants with different lease durations disagree on when the leader's
+// lease expires, which makes failover unpredictable. This is typically caused
+// by a partial rollout of a new TTL, so it is reported rather than corrected.
+// A warning is logged when the mismatch is first seen (or its values change),
+// and the mismatch is cleared once the durations match again.
+func (node *ElectorNode) checkLeaseDuration(record resourcelock.LeaderElectionRecord) {
+	// A released record has no holder, so it has no lease duration to compare.
+	if record.HolderIdentity == "" || record.LeaseDurationSeconds <= 0 {
+		return
+	}
+
+	configured := node.timings().LeaseDuration
+	observed := time.Duration(record.LeaseDurationSeconds) * time.Second
+	diff := configured - observed
+	if diff < 0 {
+		diff = -diff
+	}
+
+	var mismatch *LeaseDurationMismatch
+	if diff > leaseDurationMismatchTolerance {
+		mismatch = &LeaseDurationMismatch{
+			Leader:            record.HolderIdentity,
+			ConfiguredSeconds: Seconds(configured),
+			ConfiguredHuman:   HumanDuration(configured),
+			ObservedSeconds:   Seconds(observed),
+			ObservedHuman:     HumanDuration(observed),
+		}
+	}
+
+	node.mu.Lock()
+	previous := node.leaseMismatch
+	changed := (previous == nil) != (mismatch == nil) || (mismatch != nil && *previous != *mismatch)
+	if changed {
+		node.leaseMismatch = mismatch
+		node.leaderPayload = nil
+	}
+	node.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if mismatch != nil {
+		node.log.Warningf(
+			"lease duration of the leader %s (%v) differs from this node's lease duration (%v): failover may be unpredictable until the participants agree",
+			record.HolderIdentity, observed, configured,
+		)
+	} else {
+		node.log.Infof("lease duration of the leader %s matches this node's lease duration (%v)", record.HolderIdentity, configured)
+	}
+}
+
+// leaseDurationMismatch gets the mismatch between the node's lease duration
+// and the lease duration recorded by the leader. If they match, nil is
+// returned.
+func (node *ElectorNode) leaseDurationMismatch() *LeaseDurationMismatch {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.leaseMismatch
+}
+
+// recordLeaseDuration gets the lease duration to compute the expiry of the
+// lease in a lock record with.
+//
+// The leader election client records the lease duration of the node which
+// wrote the record, so after a partial rollout of a new TTL it changes as
+// leadership moves. The expiry of a lease is computed with the duration
+// recorded by its holder, since that is what the other participants use.
+// The node's own lease duration is only used if the record has none.
+func (node *ElectorNode) recordLeaseDuration(record resourcelock.LeaderElectionRecord) time.Duration {
+	if record.LeaseDurationSeconds > 0 {
+		return time.Duration(record.LeaseDurationSeconds) * time.Second
+	}
+	return node.timings().LeaseDuration
+}
+
+// leaseExpiry gets the lease duration of the last lock record observed, and
+// when the lease of its holder expires. The lease expires a lease duration
+// after the record was last seen to change, as measured with the node's
+// clock. If no held record has been observed, the node's own lease duration
+// and the zero time are returned.
+func (node *ElectorNode) leaseExpiry() (time.Duration, time.Time) {
+	node.mu.RLock()
+	duration, holder, at := node.observedDuration, node.observedHolder, node.observedAt
+	node.mu.RUnlock()
+
+	if duration <= 0 {
+		duration = node.timings().LeaseDuration
+	}
+	if holder == "" || at.IsZero() {
+		return duration, time.Time{}
+	}
+	return duration, at.Add(duration)
+}
@@ -0,0 +1,168 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestElectorNode_checkLeaseDuration(t *testing.T) {
+	cases := []struct {
+		description string
+		record      resourcelock.LeaderElectionRecord
+		mismatch    *LeaseDurationMismatch
+	}{
+		{
+			description: "matching duration",
+			record:      resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2", LeaseDurationSeconds: 30},
+			mismatch:    nil,
+		},
+		{
+			description: "within tolerance",
+			record:      resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2", LeaseDurationSeconds: 31},
+			mismatch:    nil,
+		},
+		{
+			description: "shorter duration",
+			record:      resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2", LeaseDurationSeconds: 10},
+			mismatch: &LeaseDurationMismatch{
+				Leader:            "test-node-2",
+				ConfiguredSeconds: Seconds(30 * time.Second),
+				ConfiguredHuman:   HumanDuration(30 * time.Second),
+				ObservedSeconds:   Seconds(10 * time.Second),
+				ObservedHuman:     HumanDuration(10 * time.Second),
+			},
+		},
+		{
+			description: "longer duration",
+			record:      resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2", LeaseDurationSeconds: 60},
+			mismatch: &LeaseDurationMismatch{
+				Leader:            "test-node-2",
+				ConfiguredSeconds: Seconds(30 * time.Second),
+				ConfiguredHuman:   HumanDuration(30 * time.Second),
+				ObservedSeconds:   Seconds(60 * time.Second),
+				ObservedHuman:     HumanDuration(60 * time.Second),
+			},
+		},
+		{
+			description: "released record",
+			record:      resourcelock.LeaderElectionRecord{LeaseDurationSeconds: 1},
+			mismatch:    nil,
+		},
+	}
+
+	for _, c := range cases {
+		log := &testLogger{}
+		node := NewElectorNode(&ElectorConfig{
+			ID:     "test-node-1",
+			TTL:    30 * time.Second,
+			Logger: log,
+		})
+
+		node.checkLeaseDuration(c.record)
+		assert.Equal(t, c.mismatch, node.leaseDurationMismatch(), c.description)
+		assert.Equal(t, c.mismatch, node.leaderInfo().LeaseMismatch, c.description)
+		if c.mismatch != nil {
+			assert.Contains(t, log.String(), "WARNING [id=test-node-1] lease duration of the leader test-node-2", c.description)
+		} else {
+			assert.NotContains(t, log.String(), "WARNING", c.description)
+		}
+	}
+}
+
+func TestElectorNode_checkLeaseDuration_cleared(t *testing.T) {
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		TTL:    30 * time.Second,
+		Logger: log,
+	})
+	mismatched := resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2", LeaseDurationSeconds: 10}
+
+	// The warning is only logged when the mismatch is first seen.
+	node.checkLeaseDuration(mismatched)
+	node.checkLeaseDuration(mismatched)
+	assert.NotNil(t, node.leaseDurationMismatch())
+	assert.Equal(t, 1, strings.Count(log.String(), "WARNING"))
+
+	// The mismatch is cleared once the leader's record matches.
+	node.checkLeaseDuration(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2", LeaseDurationSeconds: 30})
+	assert.Nil(t, node.leaseDurationMismatch())
+	assert.Contains(t, log.String(), "INFO [id=test-node-1] lease duration of the leader test-node-2 matches")
+
+	// Leading with the node's own record clears it as well.
+	node.checkLeaseDuration(mismatched)
+	assert.NotNil(t, node.leaseDurationMismatch())
+	node.checkLeaseDuration(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1", LeaseDurationSeconds: 30})
+	assert.Nil(t, node.leaseDurationMismatch())
+}
+
+func TestElectorNode_checkLeaseDuration_leaderInfoPayload(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		TTL:    30 * time.Second,
+		Logger: &testLogger{},
+	})
+	node.setLeader("test-node-2")
+
+	// The payload is only extended with the mismatch while there is one.
+	payload, err := node.cachedLeaderInfo()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(payload), "lease_duration_mismatch")
+
+	node.checkLeaseDuration(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2", LeaseDurationSeconds: 10})
+	data := getJSON(t, node, "/")
+	mismatch, ok := data["lease_duration_mismatch"].(map[string]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, "test-node-2", mismatch["leader"])
+	assert.Equal(t, float64(30), mismatch["configured_seconds"])
+	assert.Equal(t, float64(10), mismatch["observed_seconds"])
+
+	node.checkLeaseDuration(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2", LeaseDurationSeconds: 30})
+	data = getJSON(t, node, "/")
+	assert.NotContains(t, data, "lease_duration_mismatch")
+}
+
+func TestElectorNode_recordLeaseDuration(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{ID: "test-node-1", TTL: 10 * time.Second})
+
+	assert.Equal(t, 30*time.Second, node.recordLeaseDuration(resourcelock.LeaderElectionRecord{LeaseDurationSeconds: 30}))
+	assert.Equal(t, 10*time.Second, node.recordLeaseDuration(resourcelock.LeaderElectionRecord{}))
+}
+
+func TestElectorNode_leaseExpiry(t *testing.T) {
+	node, clk, _ := newLeaderlessTestNode()
+
+	// Until a held record is observed, the node's own lease duration is used
+	// and the expiry is unknown.
+	duration, expiresAt := node.leaseExpiry()
+	assert.Equal(t, 10*time.Second, duration)
+	assert.True(t, expiresAt.IsZero())
+
+	// The holder's lease expires by the duration it recorded, even though it
+	// differs from the node's own.
+	record := heldRecord("test-node-2", clk.Now())
+	record.LeaseDurationSeconds = 30
+	node.observeRecord(record)
+	duration, expiresAt = node.leaseExpiry()
+	assert.Equal(t, 30*time.Second, duration)
+	assert.Equal(t, clk.Now().Add(30*time.Second), expiresAt)
+
+	timing := getJSON(t, node, "/timing")
+	assert.Equal(t, float64(30), timing["observed_lease_duration_seconds"])
+	assert.Equal(t, Timestamp(expiresAt).String(), timing["lease_expires_at"])
+	assert.Contains(t, node.log.out.(*testLogger).String(), "differs from this node's lease duration")
+
+	// Once the lease moves to a holder with a shorter duration, expiry is
+	// computed with that duration instead.
+	clk.Step(5 * time.Second)
+	record = heldRecord("test-node-3", clk.Now())
+	record.LeaseDurationSeconds = 10
+	node.observeRecord(record)
+	duration, expiresAt = node.leaseExpiry()
+	assert.Equal(t, 10*time.Second, duration)
+	assert.Equal(t, clk.Now().Add(10*time.Second), expiresAt)
+}
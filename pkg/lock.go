@@ -0,0 +1,222 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// errLockFenced is returned when writing to a lock which has been fenced.
+var errLockFenced = errors.New("the election lock is fenced: leadership was lost to another holder")
+
+// observedLock wraps the resourcelock.Interface used for the election to keep
+// track of the lock records it reads and writes.
+type observedLock struct {
+	resourcelock.Interface
+
+	clock clock.Clock
+
+	// observe, if set, is called with each lock record read through the lock.
+	observe func(resourcelock.LeaderElectionRecord)
+
+	// deleted, if set, is called with the last lock record read through the
+	// lock when the lock object is found to have been deleted.
+	deleted func(last resourcelock.LeaderElectionRecord)
+
+	// recreated, if set, is called once a deleted lock object is re-created
+	// through the lock. It is called with the record which was deleted, and
+	// whether the lock was already held through the lock when it was deleted.
+	recreated func(deleted resourcelock.LeaderElectionRecord, held bool)
+
+	// calls serializes the calls into the wrapped lock. Lock implementations
+	// such as the LeaseLock keep the object they last read for their next
+	// update, so they are not safe for concurrent use, but the election's
+	// renew loop may still be running a call when the lock is handed off.
+	calls sync.Mutex
+
+	mu        sync.Mutex
+	lastGet   *resourcelock.LeaderElectionRecord
+	lastGetAt time.Time
+	removed   *resourcelock.LeaderElectionRecord
+	acquired  bool
+	previous  *resourcelock.LeaderElectionRecord
+	lastRenew time.Time
+	renewals  int64
+	fenced    bool
+}
+
+// newObservedLock wraps the given lock to observe its records. The clock is
+// used to timestamp the renewals of the lock.
+func newObservedLock(lock resourcelock.Interface, clk clock.Clock) *observedLock {
+	return &observedLock{Interface: lock, clock: clk}
+}
+
+// Get gets the lock record, keeping track of it as the last observed record.
+//
+// If the lock object is not found after a record was read through the lock,
+// it was deleted externally. The election re-creates a missing lock object
+// on its next attempt, so the deletion is only reported.
+func (l *observedLock) Get() (*resourcelock.LeaderElectionRecord, []byte, error) {
+	l.calls.Lock()
+	record, raw, err := l.Interface.Get()
+	l.calls.Unlock()
+	if err == nil && record != nil {
+		r := *record
+		l.mu.Lock()
+		l.lastGet = &r
+		l.lastGetAt = l.clock.Now()
+		l.mu.Unlock()
+		if l.observe != nil {
+			l.observe(r)
+		}
+	}
+	if apierrors.IsNotFound(err) {
+		l.mu.Lock()
+		last := l.lastGet
+		if last != nil {
+			l.lastGet = nil
+			l.removed = last
+		}
+		l.mu.Unlock()
+		if last != nil && l.deleted != nil {
+			l.deleted(*last)
+		}
+	}
+	return record, raw, err
+}
+
+// Create creates the lock record. Creating the record acquires the lock. If
+// the lock object was deleted externally, the record which was deleted is
+// kept as the record which was in place before the lock was acquired;
+// otherwise, there is no previous record.
+func (l *observedLock) Create(ler resourcelock.LeaderElectionRecord) error {
+	if l.isFenced() {
+		return errLockFenced
+	}
+	l.calls.Lock()
+	err := l.Interface.Create(ler)
+	l.calls.Unlock()
+	if err == nil {
+		l.mu.Lock()
+		held := l.acquired
+		removed := l.removed
+		l.removed = nil
+		if !l.acquired {
+			l.acquired = true
+			l.previous = removed
+		}
+		l.renewed(ler)
+		l.mu.Unlock()
+		if removed != nil && l.recreated != nil {
+			l.recreated(*removed, held)
+		}
+	}
+	return err
+}
+
+// Update updates the lock record. The first successful update made through
+// the lock acquires it, so the last observed record is kept as the record
+// which was in place before the lock was acquired.
+func (l *observedLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	if l.isFenced() {
+		return errLockFenced
+	}
+	l.calls.Lock()
+	err := l.Interface.Update(ler)
+	l.calls.Unlock()
+	if err == nil {
+		l.mu.Lock()
+		if !l.acquired {
+			l.acquired = true
+			l.previous = l.lastGet
+		}
+		l.renewed(ler)
+		l.mu.Unlock()
+	}
+	return err
+}
+
+// renewed tracks the time and count of renewals after the lock record was
+// written. A record held by this lock's identity is a renewal; any other
+// record (e.g. when the lock is released) clears the last renewal time.
+//
+// The caller must hold the lock's mutex.
+func (l *observedLock) renewed(ler resourcelock.LeaderElectionRecord) {
+	if ler.HolderIdentity == l.Identity() {
+		l.lastRenew = l.clock.Now()
+		l.renewals++
+	} else {
+		l.lastRenew = time.Time{}
+	}
+}
+
+// lastRenewTime gets the time the lock was last successfully renewed by
+// this lock's identity. If it does not hold the lock, the zero time is
+// returned.
+func (l *observedLock) lastRenewTime() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastRenew
+}
+
+// renewCount gets the number of times the lock was successfully written with
+// this lock's identity as the holder, including when it was acquired.
+func (l *observedLock) renewCount() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.renewals
+}
+
+// lastRecord gets the last lock record read through the lock, and when it was
+// read. If no record has been read, or the lock object was deleted since, nil
+// is returned.
+func (l *observedLock) lastRecord() (*resourcelock.LeaderElectionRecord, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastGet, l.lastGetAt
+}
+
+// previousRecord gets the lock record which was in place before the lock was
+// acquired through this lock. If the lock has not been acquired, or there was
+// no previous record, nil is returned.
+func (l *observedLock) previousRecord() *resourcelock.LeaderElectionRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.previous
+}
+
+// fence prevents any further writes through the lock. This is used once the
+// lock is found to be held by another identity, so that stepping down does not
+// release (and so clear) the other holder's record.
+func (l *observedLock) fence() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fenced = true
+}
+
+// isFenced checks whether writes through the lock are prevented.
+func (l *observedLock) isFenced() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fenced
+}
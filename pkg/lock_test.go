@@ -0,0 +1,307 @@
+package pkg
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// fakeLock is an in-memory resourcelock.Interface for tests.
+type fakeLock struct {
+	mu       sync.Mutex
+	identity string
+	record   *resourcelock.LeaderElectionRecord
+	err      error
+}
+
+func (l *fakeLock) Get() (*resourcelock.LeaderElectionRecord, []byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return nil, nil, l.err
+	}
+	if l.record == nil {
+		return nil, nil, errors.New("not found")
+	}
+	r := *l.record
+	return &r, nil, nil
+}
+
+func (l *fakeLock) Create(ler resourcelock.LeaderElectionRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	l.record = &ler
+	return nil
+}
+
+func (l *fakeLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	l.record = &ler
+	return nil
+}
+
+func (l *fakeLock) RecordEvent(string) {}
+
+func (l *fakeLock) Identity() string {
+	return l.identity
+}
+
+func (l *fakeLock) Describe() string {
+	return "fake/" + l.identity
+}
+
+func TestObservedLock_previousRecord_update(t *testing.T) {
+	lock := newObservedLock(&fakeLock{
+		identity: "test-node-1",
+		record:   &resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2"},
+	}, clock.RealClock{})
+	assert.Nil(t, lock.previousRecord())
+
+	_, _, err := lock.Get()
+	assert.NoError(t, err)
+	assert.Nil(t, lock.previousRecord())
+
+	// The first update acquires the lock.
+	err = lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "test-node-2", lock.previousRecord().HolderIdentity)
+
+	// Subsequent reads and updates are renewals, which do not change the
+	// previous record.
+	_, _, err = lock.Get()
+	assert.NoError(t, err)
+	err = lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "test-node-2", lock.previousRecord().HolderIdentity)
+}
+
+func TestObservedLock_previousRecord_create(t *testing.T) {
+	lock := newObservedLock(&fakeLock{identity: "test-node-1"}, clock.RealClock{})
+
+	_, _, err := lock.Get()
+	assert.Error(t, err)
+
+	err = lock.Create(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+	assert.NoError(t, err)
+	assert.Nil(t, lock.previousRecord())
+}
+
+func TestObservedLock_previousRecord_failedUpdate(t *testing.T) {
+	fake := &fakeLock{
+		identity: "test-node-1",
+		record:   &resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2"},
+	}
+	lock := newObservedLock(fake, clock.RealClock{})
+
+	_, _, err := lock.Get()
+	assert.NoError(t, err)
+
+	fake.err = errors.New("conflict")
+	err = lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+	assert.Error(t, err)
+	assert.Nil(t, lock.previousRecord())
+}
+
+func TestObservedLock_lastRenewTime(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	lock := newObservedLock(&fakeLock{identity: "test-node-1"}, clk)
+	assert.True(t, lock.lastRenewTime().IsZero())
+
+	// Acquiring the lock is a renewal.
+	err := lock.Create(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, clk.Now(), lock.lastRenewTime())
+
+	clk.Step(2 * time.Second)
+	err = lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, clk.Now(), lock.lastRenewTime())
+
+	// Releasing the lock clears the renewal time.
+	err = lock.Update(resourcelock.LeaderElectionRecord{})
+	assert.NoError(t, err)
+	assert.True(t, lock.lastRenewTime().IsZero())
+}
+
+func TestObservedLock_renewCount(t *testing.T) {
+	lock := newObservedLock(&fakeLock{identity: "test-node-1"}, clock.RealClock{})
+	assert.Equal(t, int64(0), lock.renewCount())
+
+	// Acquiring and renewing the lock are counted.
+	assert.NoError(t, lock.Create(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+	assert.NoError(t, lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+	assert.Equal(t, int64(2), lock.renewCount())
+
+	// Releasing the lock is not.
+	assert.NoError(t, lock.Update(resourcelock.LeaderElectionRecord{}))
+	assert.Equal(t, int64(2), lock.renewCount())
+
+	// Nor are failed writes.
+	lock.Interface.(*fakeLock).err = errors.New("unavailable")
+	assert.Error(t, lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+	assert.Equal(t, int64(2), lock.renewCount())
+}
+
+func TestObservedLock_observe(t *testing.T) {
+	var observed []string
+	lock := newObservedLock(&fakeLock{
+		identity: "test-node-1",
+		record:   &resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2", LeaseDurationSeconds: 10},
+	}, clock.RealClock{})
+	lock.observe = func(ler resourcelock.LeaderElectionRecord) {
+		observed = append(observed, ler.HolderIdentity)
+	}
+
+	_, _, err := lock.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test-node-2"}, observed)
+}
+
+func TestObservedLock_deleted(t *testing.T) {
+	cases := []struct {
+		description string
+		held        bool
+	}{
+		{
+			description: "deleted while held by another node",
+			held:        false,
+		},
+		{
+			description: "deleted while held through the lock",
+			held:        true,
+		},
+	}
+
+	for _, c := range cases {
+		holder := "test-node-2"
+		if c.held {
+			holder = "test-node-1"
+		}
+		inner := &fakeLock{identity: "test-node-1"}
+		lock := newObservedLock(inner, clock.RealClock{})
+		if c.held {
+			assert.NoError(t, lock.Create(resourcelock.LeaderElectionRecord{HolderIdentity: holder}), c.description)
+		} else {
+			inner.record = &resourcelock.LeaderElectionRecord{HolderIdentity: holder}
+		}
+
+		var deleted, recreated []string
+		var recreatedHeld bool
+		lock.deleted = func(last resourcelock.LeaderElectionRecord) {
+			deleted = append(deleted, last.HolderIdentity)
+		}
+		lock.recreated = func(last resourcelock.LeaderElectionRecord, held bool) {
+			recreated = append(recreated, last.HolderIdentity)
+			recreatedHeld = held
+		}
+
+		_, _, err := lock.Get()
+		assert.NoError(t, err, c.description)
+		assert.Empty(t, deleted, c.description)
+
+		// The deletion is only reported once, however many times the missing
+		// lock object is read.
+		inner.err = apierrors.NewNotFound(schema.GroupResource{Resource: "leases"}, "test-election")
+		for i := 0; i < 2; i++ {
+			_, _, err = lock.Get()
+			assert.True(t, apierrors.IsNotFound(err), c.description)
+		}
+		assert.Equal(t, []string{holder}, deleted, c.description)
+		assert.Empty(t, recreated, c.description)
+
+		inner.err = nil
+		assert.NoError(t, lock.Create(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}), c.description)
+		assert.Equal(t, []string{holder}, recreated, c.description)
+		assert.Equal(t, c.held, recreatedHeld, c.description)
+
+		// A node which acquires the lock by re-creating it keeps the deleted
+		// record as the previous record.
+		previous := lock.previousRecord()
+		if c.held {
+			assert.Nil(t, previous, c.description)
+		} else if assert.NotNil(t, previous, c.description) {
+			assert.Equal(t, holder, previous.HolderIdentity, c.description)
+		}
+	}
+}
+
+func TestObservedLock_deleted_notRead(t *testing.T) {
+	lock := newObservedLock(&fakeLock{
+		identity: "test-node-1",
+		err:      apierrors.NewNotFound(schema.GroupResource{Resource: "leases"}, "test-election"),
+	}, clock.RealClock{})
+	lock.deleted = func(resourcelock.LeaderElectionRecord) {
+		assert.Fail(t, "a lock object which was never read can not be deleted")
+	}
+
+	_, _, err := lock.Get()
+	assert.Error(t, err)
+}
+
+// unsafeLock is a fakeLock which records whether its calls overlap, as the
+// calls into a lock which is not safe for concurrent use must not.
+type unsafeLock struct {
+	*fakeLock
+	active     int32
+	overlapped int32
+}
+
+func (l *unsafeLock) enter() func() {
+	if atomic.AddInt32(&l.active, 1) > 1 {
+		atomic.StoreInt32(&l.overlapped, 1)
+	}
+	time.Sleep(time.Millisecond)
+	return func() { atomic.AddInt32(&l.active, -1) }
+}
+
+func (l *unsafeLock) Get() (*resourcelock.LeaderElectionRecord, []byte, error) {
+	defer l.enter()()
+	return l.fakeLock.Get()
+}
+
+func (l *unsafeLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	defer l.enter()()
+	return l.fakeLock.Update(ler)
+}
+
+func TestObservedLock_serialized(t *testing.T) {
+	inner := &unsafeLock{fakeLock: &fakeLock{
+		identity: "test-node-1",
+		record:   &resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"},
+	}}
+	lock := newObservedLock(inner, clock.RealClock{})
+
+	// The election's renew loop and the hand-off of the lock both read and
+	// update the lock.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, _, err := lock.Get()
+				assert.NoError(t, err)
+				assert.NoError(t, lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&inner.overlapped))
+	assert.Equal(t, int64(40), lock.renewCount())
+}
@@ -0,0 +1,107 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// TransitionReasonLockDeleted is the reason recorded for the leader
+// transitions and outages caused by the election lock object being deleted
+// externally.
+const TransitionReasonLockDeleted = "lock_deleted"
+
+// lockDeleted reports that the election lock object was deleted externally.
+// The last record read before it was deleted is given.
+//
+// The election re-creates the lock object on its next attempt, so the node
+// only warns, counts the deletion, and records it in its in-memory history
+// of leader transitions.
+func (node *ElectorNode) lockDeleted(last resourcelock.LeaderElectionRecord) {
+	node.log.Warningf("lock object deleted externally: %s/%s was deleted while held by %q, it will be re-created by the election",
+		node.config.LockNamespace, node.config.Name, last.HolderIdentity)
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.lockDeletions++
+	node.appendTransition(LeaderTransition{
+		Time:     Timestamp(node.clock.Now()),
+		Previous: last.HolderIdentity,
+		Role:     node.config.role(),
+		Reason:   TransitionReasonLockDeleted,
+	})
+}
+
+// lockRecreated returns the hook which runs once the node re-creates the
+// deleted election lock object.
+//
+// If the node acquired the lock by re-creating it, the deletion is recorded
+// along with the acquisition, as the deleted record is the previous record
+// of the lock. If the node was already the leader, there is no acquisition,
+// so the deletion is recorded to the lock history and the outages ConfigMap
+// here, if enabled. Recording never affects the election, so failures are
+// only logged.
+func (node *ElectorNode) lockRecreated(client kubernetes.Interface) func(resourcelock.LeaderElectionRecord, bool) {
+	return func(deleted resourcelock.LeaderElectionRecord, held bool) {
+		node.log.Infof("re-created the deleted election lock %s/%s", node.config.LockNamespace, node.config.Name)
+		if !held || node.passive {
+			return
+		}
+
+		if node.config.RecordHistory {
+			err := appendLockHistory(
+				client,
+				node.config.LockType,
+				node.config.LockNamespace,
+				node.config.Name,
+				LeaderTransition{
+					Time:     Timestamp(node.clock.Now()),
+					Leader:   node.config.ID,
+					Previous: deleted.HolderIdentity,
+					Role:     node.config.role(),
+					Reason:   TransitionReasonLockDeleted,
+				},
+				node.config.HistoryLimit,
+			)
+			if err != nil {
+				node.log.Errorf("failed to record leader history: %v", err)
+			}
+		}
+
+		// The deletion is recorded regardless of the outage threshold, since
+		// the leader re-creates the lock as soon as it notices it is gone. As
+		// with other outages, it is measured from the wall clock renew time of
+		// the deleted record.
+		if node.config.RecordOutages {
+			if record, ok := newOutageRecord(&deleted, node.config.ID, node.clock.Now(), 0); ok {
+				record.Reason = TransitionReasonLockDeleted
+				if err := appendOutageRecord(client, node.config.LockNamespace, node.config.Name, record, node.config.OutageRecordLimit); err != nil {
+					node.log.Errorf("failed to record election outage: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// lockDeletionCount gets the number of times the node found the election
+// lock object deleted externally.
+func (node *ElectorNode) lockDeletionCount() int {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.lockDeletions
+}
@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestElectorNode_run_lockDeleted(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		PodName:       "test-pod",
+		LockType:      resourcelock.LeasesResourceLock,
+		TTL:           1 * time.Second,
+		Client:        client,
+		Logger:        &testLogger{},
+		RecordHistory: true,
+		RecordOutages: true,
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.run()
+	}()
+	defer func() {
+		node.Stop()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "election did not stop")
+		}
+	}()
+
+	lease := func() map[string]string {
+		obj, err := client.CoordinationV1().Leases("test-ns").Get("test-election", metav1.GetOptions{})
+		if err != nil || obj.Spec.HolderIdentity == nil || *obj.Spec.HolderIdentity != "test-node-1" {
+			return nil
+		}
+		return obj.Annotations
+	}
+
+	// Wait for the acquisition to be recorded, so it is not recorded to the
+	// re-created lease.
+	waitFor(t, 5*time.Second, func() bool {
+		return node.IsLeader() && lease()[HistoryAnnotationKey] != ""
+	})
+
+	// Delete the lease out from under the leader. It is re-created by the
+	// next renewal, well within the lease duration, and the node keeps
+	// leading through it.
+	assert.NoError(t, client.CoordinationV1().Leases("test-ns").Delete("test-election", &metav1.DeleteOptions{}))
+	waitFor(t, 1*time.Second, func() bool {
+		return lease() != nil
+	})
+	assert.True(t, node.IsLeader())
+	assert.Equal(t, 1, node.lockDeletionCount())
+	_, acquisitions := node.leadership()
+	assert.Equal(t, 1, acquisitions)
+
+	transitions := node.history()
+	if assert.NotEmpty(t, transitions) {
+		last := transitions[len(transitions)-1]
+		assert.Equal(t, "", last.Leader)
+		assert.Equal(t, "test-node-1", last.Previous)
+		assert.Equal(t, TransitionReasonLockDeleted, last.Reason)
+	}
+
+	// The deletion is recorded on the re-created lease and to the outages.
+	waitFor(t, 5*time.Second, func() bool {
+		return lease()[HistoryAnnotationKey] != ""
+	})
+	var history []LeaderTransition
+	assert.NoError(t, json.Unmarshal([]byte(lease()[HistoryAnnotationKey]), &history))
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, "test-node-1", history[0].Leader)
+		assert.Equal(t, "test-node-1", history[0].Previous)
+		assert.Equal(t, TransitionReasonLockDeleted, history[0].Reason)
+	}
+
+	var records []OutageRecord
+	waitFor(t, 5*time.Second, func() bool {
+		cm, err := client.CoreV1().ConfigMaps("test-ns").Get("test-election"+OutagesConfigMapSuffix, metav1.GetOptions{})
+		if err != nil {
+			return false
+		}
+		return json.Unmarshal([]byte(cm.Data[OutagesConfigMapKey]), &records) == nil && len(records) > 0
+	})
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "test-node-1", records[0].PreviousHolder)
+		assert.Equal(t, "test-node-1", records[0].NewHolder)
+		assert.Equal(t, TransitionReasonLockDeleted, records[0].Reason)
+	}
+}
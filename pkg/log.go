@@ -0,0 +1,325 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// Logger is the interface for the logger used by an elector node. This allows
+// applications using the elector as a library to route its logs into their
+// own logging setup.
+type Logger interface {
+	Infof(format string, args ...interface{})
+	Warningf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// KlogLogger is a Logger which writes to klog. It is used if an elector node
+// is not configured with a Logger.
+//
+// KlogLogger only writes to klog; it never changes the global klog
+// configuration. The reported call site of a log line is adjusted to be the
+// caller of the elector's internal logger.
+type KlogLogger struct{}
+
+// klogDepth holds the klog functions which KlogLogger writes with, so the call
+// site it reports can be checked without capturing the global klog output.
+var klogDepth = struct {
+	info, warning, error func(depth int, args ...interface{})
+}{
+	info:    klog.InfoDepth,
+	warning: klog.WarningDepth,
+	error:   klog.ErrorDepth,
+}
+
+// defaultLogger is the Logger which is written to if an elector node is not
+// configured with one.
+var defaultLogger Logger = KlogLogger{}
+
+// Infof logs a formatted message at INFO level.
+func (KlogLogger) Infof(format string, args ...interface{}) {
+	klogDepth.info(2, fmt.Sprintf(format, args...))
+}
+
+// Warningf logs a formatted message at WARNING level.
+func (KlogLogger) Warningf(format string, args ...interface{}) {
+	klogDepth.warning(2, fmt.Sprintf(format, args...))
+}
+
+// Errorf logs a formatted message at ERROR level.
+func (KlogLogger) Errorf(format string, args ...interface{}) {
+	klogDepth.error(2, fmt.Sprintf(format, args...))
+}
+
+// LabelledLogger is a Logger which records the election name and node ID of
+// its log messages as structured labels. When an elector node is configured
+// with a LabelledLogger, its messages are not prefixed with those fields.
+type LabelledLogger interface {
+	Logger
+
+	// WithLabels gets a Logger which adds the given labels to its messages.
+	WithLabels(labels map[string]string) Logger
+}
+
+// Log severities, as recognized by managed cloud logging.
+const (
+	severityInfo    = "INFO"
+	severityWarning = "WARNING"
+	severityError   = "ERROR"
+)
+
+// cloudLogEntry is a log entry written by a CloudLogger. Google Cloud Logging
+// parses the labels under the "logging.googleapis.com/labels" key as the
+// entry's labels.
+type cloudLogEntry struct {
+	Severity string            `json:"severity"`
+	Message  string            `json:"message"`
+	Time     Timestamp         `json:"time"`
+	Labels   map[string]string `json:"logging.googleapis.com/labels,omitempty"`
+}
+
+// CloudLogger is a LabelledLogger which writes log messages as JSON, one per
+// line, with the keys that managed cloud logging (Google Cloud Logging, AWS
+// CloudWatch) parses automatically: the "severity", the "message", the "time",
+// and the labels under "logging.googleapis.com/labels".
+type CloudLogger struct {
+	out    *cloudLogOutput
+	labels map[string]string
+}
+
+// cloudLogOutput is the output shared by a CloudLogger and the loggers
+// derived from it with WithLabels.
+type cloudLogOutput struct {
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+}
+
+// NewCloudLogger creates a CloudLogger which writes to w.
+func NewCloudLogger(w io.Writer) *CloudLogger {
+	return &CloudLogger{out: &cloudLogOutput{w: w, now: time.Now}}
+}
+
+// WithLabels gets a CloudLogger which adds the given labels to its messages,
+// on top of the labels of this logger.
+func (l *CloudLogger) WithLabels(labels map[string]string) Logger {
+	merged := make(map[string]string, len(l.labels)+len(labels))
+	for k, v := range l.labels {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return &CloudLogger{out: l.out, labels: merged}
+}
+
+// Infof logs a formatted message at INFO severity.
+func (l *CloudLogger) Infof(format string, args ...interface{}) {
+	l.write(severityInfo, fmt.Sprintf(format, args...))
+}
+
+// Warningf logs a formatted message at WARNING severity.
+func (l *CloudLogger) Warningf(format string, args ...interface{}) {
+	l.write(severityWarning, fmt.Sprintf(format, args...))
+}
+
+// Errorf logs a formatted message at ERROR severity.
+func (l *CloudLogger) Errorf(format string, args ...interface{}) {
+	l.write(severityError, fmt.Sprintf(format, args...))
+}
+
+// write writes a log entry with the given severity and message.
+func (l *CloudLogger) write(severity, message string) {
+	entry := cloudLogEntry{
+		Severity: severity,
+		Message:  message,
+		Labels:   l.labels,
+	}
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	entry.Time = Timestamp(l.out.now())
+	data, err := json.Marshal(entry)
+	if err != nil {
+		// The entry only holds strings, so marshaling it can not fail.
+		return
+	}
+	_, _ = l.out.w.Write(append(data, '\n'))
+}
+
+// Leadership roles of an elector node, as shown in the role prefix of its log
+// messages.
+const (
+	logRoleLeader   = "leader"
+	logRoleStandby  = "standby"
+	logRoleLameDuck = "lame-duck"
+)
+
+// logRole holds the current leadership role of an elector node for the role
+// prefix of its log messages. It is shared by all copies of the node's logger
+// and is updated atomically on leadership transitions, so every message is
+// prefixed with the role the node had when the message was written.
+type logRole struct {
+	v atomic.Value
+}
+
+// newLogRole creates a logRole for a node which starts as a standby.
+func newLogRole() *logRole {
+	r := &logRole{}
+	r.v.Store(logRoleStandby)
+	return r
+}
+
+// set sets the current role. Setting the role of a nil logRole is a no-op.
+func (r *logRole) set(role string) {
+	if r == nil {
+		return
+	}
+	r.v.Store(role)
+}
+
+// prefix gets the log prefix for the current role. A nil logRole has no
+// prefix.
+func (r *logRole) prefix() string {
+	if r == nil {
+		return ""
+	}
+	return fmt.Sprintf("[%s] ", r.v.Load())
+}
+
+// logger wraps the configured Logger to prepend a prefix to all log messages.
+// The prefix includes the configured log prefix as well as the election name,
+// role name (if it differs from the election name) and node ID, making it
+// possible to attribute log lines when the logs of multiple electors end up in
+// the same stream.
+//
+// If configured to, the prefix starts with the node's current leadership role,
+// which the node updates as it gains and loses leadership.
+//
+// If configured to, repeats of the messages logged on its error paths are
+// suppressed.
+type logger struct {
+	out     Logger
+	prefix  string
+	role    *logRole
+	repeats *logRepeats
+}
+
+// newLogger creates a new logger for the given elector configuration.
+func newLogger(config *ElectorConfig) logger {
+	if config == nil {
+		return logger{out: defaultLogger}
+	}
+
+	out := config.Logger
+	if out == nil {
+		out = defaultLogger
+	}
+
+	var role *logRole
+	if config.LogRolePrefix {
+		role = newLogRole()
+	}
+
+	// A labelled logger records the prefix and fields as labels instead.
+	if labelled, ok := out.(LabelledLogger); ok {
+		labels := map[string]string{}
+		if config.LogPrefix != "" {
+			labels["prefix"] = config.LogPrefix
+		}
+		if config.Name != "" {
+			labels["election"] = config.Name
+		}
+		if config.role() != config.Name {
+			labels["role"] = config.role()
+		}
+		if config.ID != "" {
+			labels["id"] = config.ID
+		}
+		return logger{out: labelled.WithLabels(labels), role: role, repeats: newLogRepeats(config)}
+	}
+
+	var prefix string
+	if config.LogPrefix != "" {
+		prefix = fmt.Sprintf("[%s] ", config.LogPrefix)
+	}
+
+	var fields []string
+	if config.Name != "" {
+		fields = append(fields, "election="+config.Name)
+	}
+	if config.role() != config.Name {
+		fields = append(fields, "role="+config.role())
+	}
+	if config.ID != "" {
+		fields = append(fields, "id="+config.ID)
+	}
+	if len(fields) > 0 {
+		prefix += fmt.Sprintf("[%s] ", strings.Join(fields, " "))
+	}
+
+	return logger{out: out, prefix: prefix, role: role, repeats: newLogRepeats(config)}
+}
+
+// setRole sets the leadership role shown in the prefix of the logger's
+// messages. It has no effect if the logger does not show the role.
+func (l logger) setRole(role string) {
+	l.role.set(role)
+}
+
+// prefixed prepends the logger's prefix to a message.
+func (l logger) prefixed(message string) string {
+	return l.role.prefix() + l.prefix + message
+}
+
+// Info logs a message at INFO level.
+func (l logger) Info(args ...interface{}) {
+	l.output().Infof("%s", l.prefixed(fmt.Sprint(args...)))
+}
+
+// Infof logs a formatted message at INFO level.
+func (l logger) Infof(format string, args ...interface{}) {
+	l.output().Infof("%s", l.prefixed(fmt.Sprintf(format, args...)))
+}
+
+// Warningf logs a formatted message at WARNING level.
+func (l logger) Warningf(format string, args ...interface{}) {
+	l.output().Warningf("%s", l.prefixed(fmt.Sprintf(format, args...)))
+}
+
+// Errorf logs a formatted message at ERROR level.
+func (l logger) Errorf(format string, args ...interface{}) {
+	l.output().Errorf("%s", l.prefixed(fmt.Sprintf(format, args...)))
+}
+
+// output gets the Logger to write to. The zero-value logger writes to the
+// default logger.
+func (l logger) output() Logger {
+	if l.out == nil {
+		return defaultLogger
+	}
+	return l.out
+}
@@ -0,0 +1,294 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testLogger is a Logger which captures log messages so tests can make
+// assertions against a node's logs without using the global klog output.
+type testLogger struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *testLogger) write(level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf.WriteString(level + " " + fmt.Sprintf(format, args...) + "\n")
+}
+
+func (l *testLogger) Infof(format string, args ...interface{}) {
+	l.write("INFO", format, args...)
+}
+
+func (l *testLogger) Warningf(format string, args ...interface{}) {
+	l.write("WARNING", format, args...)
+}
+
+func (l *testLogger) Errorf(format string, args ...interface{}) {
+	l.write("ERROR", format, args...)
+}
+
+func (l *testLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
+}
+
+// setDefaultLogger sets the Logger which is written to when an elector node is
+// not configured with one. The returned function restores the previous one.
+func setDefaultLogger(out Logger) (restore func()) {
+	previous := defaultLogger
+	defaultLogger = out
+	return func() {
+		defaultLogger = previous
+	}
+}
+
+func TestKlogLogger_callSite(t *testing.T) {
+	var file, message string
+	previous := klogDepth.info
+	klogDepth.info = func(depth int, args ...interface{}) {
+		// The call site is reported at the depth relative to the caller of
+		// the klog function, which this replaces.
+		_, file, _, _ = runtime.Caller(depth + 1)
+		message = fmt.Sprint(args...)
+	}
+	defer func() {
+		klogDepth.info = previous
+	}()
+
+	log := logger{out: KlogLogger{}}
+	log.Infof("test %s", "message")
+
+	assert.Equal(t, "test message", message)
+	assert.Equal(t, "log_test.go", filepath.Base(file))
+}
+
+func TestLogger_noPrefix(t *testing.T) {
+	out := &testLogger{}
+
+	log := newLogger(&ElectorConfig{Logger: out})
+	log.Infof("test %s", "message")
+
+	assert.Equal(t, "INFO test message\n", out.String())
+}
+
+func TestLogger_prefix(t *testing.T) {
+	out := &testLogger{}
+
+	log := newLogger(&ElectorConfig{
+		Logger:    out,
+		LogPrefix: "test-prefix",
+		Name:      "test-election",
+		ID:        "test-node-1",
+	})
+	log.Info("info message")
+	log.Infof("infof %s", "message")
+	log.Warningf("warning %s", "message")
+	log.Errorf("error %s", "message")
+
+	assert.Contains(t, out.String(), "INFO [test-prefix] [election=test-election id=test-node-1] info message")
+	assert.Contains(t, out.String(), "INFO [test-prefix] [election=test-election id=test-node-1] infof message")
+	assert.Contains(t, out.String(), "WARNING [test-prefix] [election=test-election id=test-node-1] warning message")
+	assert.Contains(t, out.String(), "ERROR [test-prefix] [election=test-election id=test-node-1] error message")
+}
+
+func TestLogger_role(t *testing.T) {
+	cases := []struct {
+		description string
+		role        string
+		expected    string
+	}{
+		{
+			description: "no role name",
+			role:        "",
+			expected:    "INFO [election=test-election id=test-node-1] message\n",
+		},
+		{
+			description: "role name is the election name",
+			role:        "test-election",
+			expected:    "INFO [election=test-election id=test-node-1] message\n",
+		},
+		{
+			description: "role name",
+			role:        "test-role",
+			expected:    "INFO [election=test-election role=test-role id=test-node-1] message\n",
+		},
+	}
+
+	for _, c := range cases {
+		out := &testLogger{}
+		log := newLogger(&ElectorConfig{
+			Logger:   out,
+			Name:     "test-election",
+			RoleName: c.role,
+			ID:       "test-node-1",
+		})
+		log.Info("message")
+		assert.Equal(t, c.expected, out.String(), c.description)
+	}
+}
+
+func TestLogger_rolePrefix(t *testing.T) {
+	out := &testLogger{}
+	log := newLogger(&ElectorConfig{
+		Logger:        out,
+		Name:          "test-election",
+		ID:            "test-node-1",
+		LogRolePrefix: true,
+	})
+
+	log.Info("first")
+	log.setRole(logRoleLeader)
+	log.Warningf("%s", "second")
+	log.setRole(logRoleLameDuck)
+	log.Errorf("%s", "third")
+
+	assert.Equal(t,
+		"INFO [standby] [election=test-election id=test-node-1] first\n"+
+			"WARNING [leader] [election=test-election id=test-node-1] second\n"+
+			"ERROR [lame-duck] [election=test-election id=test-node-1] third\n",
+		out.String(),
+	)
+}
+
+func TestLogger_rolePrefix_off(t *testing.T) {
+	out := &testLogger{}
+	log := newLogger(&ElectorConfig{Logger: out})
+
+	log.setRole(logRoleLeader)
+	log.Info("message")
+
+	assert.Equal(t, "INFO message\n", out.String())
+}
+
+func TestLogger_rolePrefix_labelled(t *testing.T) {
+	var buf bytes.Buffer
+	log := newLogger(&ElectorConfig{
+		Logger:        NewCloudLogger(&buf),
+		Name:          "test-election",
+		LogRolePrefix: true,
+	})
+
+	log.setRole(logRoleLeader)
+	log.Info("message")
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "[leader] message", entry["message"])
+}
+
+func TestElectorNode_logRolePrefix(t *testing.T) {
+	out := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		Logger:        out,
+		ID:            "test-node-1",
+		LogRolePrefix: true,
+	})
+
+	node.setLeader("test-node-2")
+	node.log.Info("other node leads")
+	node.setLeader("test-node-1")
+	node.log.Info("elected")
+	node.stepDown()
+	node.log.Info("stepping down")
+	node.setLeader("")
+	node.log.Info("stepped down")
+
+	assert.Equal(t,
+		"INFO [standby] [id=test-node-1] other node leads\n"+
+			"INFO [leader] [id=test-node-1] elected\n"+
+			"INFO [lame-duck] [id=test-node-1] stepping down\n"+
+			"INFO [standby] [id=test-node-1] stepped down\n",
+		out.String(),
+	)
+}
+
+func TestLogger_zeroValue(t *testing.T) {
+	out := &testLogger{}
+	defer setDefaultLogger(out)()
+
+	var log logger
+	log.Infof("test %s", "message")
+
+	assert.Equal(t, "INFO test message\n", out.String())
+}
+
+func TestElectorConfig_Log_prefix(t *testing.T) {
+	out := &testLogger{}
+	conf := ElectorConfig{
+		Logger:    out,
+		LogPrefix: "test-prefix",
+	}
+
+	conf.Log()
+
+	assert.Contains(t, out.String(), "[test-prefix] elector config")
+}
+
+func TestCloudLogger(t *testing.T) {
+	var buf bytes.Buffer
+	out := NewCloudLogger(&buf)
+	out.out.now = func() time.Time {
+		return time.Date(2019, 5, 2, 18, 28, 51, 123456789, time.UTC)
+	}
+
+	log := newLogger(&ElectorConfig{
+		Logger:    out,
+		LogPrefix: "test-prefix",
+		Name:      "test-election",
+		ID:        "test-node-1",
+	})
+	log.Info("info message")
+	log.Warningf("warning %s", "message")
+	log.Errorf("error %s", "message")
+
+	labels := `"logging.googleapis.com/labels":{"election":"test-election","id":"test-node-1","prefix":"test-prefix"}`
+	assert.Equal(t,
+		`{"severity":"INFO","message":"info message","time":"2019-05-02T18:28:51.123456789Z",`+labels+"}\n"+
+			`{"severity":"WARNING","message":"warning message","time":"2019-05-02T18:28:51.123456789Z",`+labels+"}\n"+
+			`{"severity":"ERROR","message":"error message","time":"2019-05-02T18:28:51.123456789Z",`+labels+"}\n",
+		buf.String(),
+	)
+}
+
+func TestCloudLogger_noLabels(t *testing.T) {
+	var buf bytes.Buffer
+	out := NewCloudLogger(&buf)
+
+	log := newLogger(&ElectorConfig{Logger: out})
+	log.Infof("test %s", "message")
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "INFO", entry["severity"])
+	assert.Equal(t, "test message", entry["message"])
+	assert.NotEmpty(t, entry["time"])
+	assert.NotContains(t, entry, "logging.googleapis.com/labels")
+}
+
+func TestCloudLogger_WithLabels(t *testing.T) {
+	var buf bytes.Buffer
+	out := NewCloudLogger(&buf)
+
+	// Labels are merged, without changing the parent logger's labels.
+	parent := out.WithLabels(map[string]string{"election": "test-election", "id": "test-node-1"}).(*CloudLogger)
+	child := parent.WithLabels(map[string]string{"id": "test-node-2"}).(*CloudLogger)
+	assert.Equal(t, map[string]string{"election": "test-election", "id": "test-node-1"}, parent.labels)
+	assert.Equal(t, map[string]string{"election": "test-election", "id": "test-node-2"}, child.labels)
+
+	// The loggers write to the same output.
+	parent.Infof("parent")
+	child.Infof("child")
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("\n")))
+}
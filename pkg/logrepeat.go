@@ -0,0 +1,134 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Defaults for suppressing repeated error messages, as used by the elector
+// command.
+const (
+	DefaultLogRepeatEvery    = 100
+	DefaultLogRepeatInterval = 1 * time.Minute
+)
+
+// maxRepeatedMessages bounds the number of distinct messages a logRepeats
+// tracks. Once it is reached, the tracked messages are forgotten, so each is
+// logged again the next time it occurs.
+const maxRepeatedMessages = 100
+
+// logRepeats suppresses repeated identical log messages, e.g. the same error
+// from every retry of a publish which keeps failing. A message is logged the
+// first time it occurs; while it keeps repeating, it is only logged again
+// every N occurrences or once the interval has passed since it was last
+// logged, whichever comes first, with the number of occurrences suppressed in
+// between.
+//
+// It is shared by all copies of the node's logger.
+type logRepeats struct {
+	every    int
+	interval time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	messages map[string]*repeatedMessage
+}
+
+// repeatedMessage tracks the occurrences of a message since it was last
+// logged.
+type repeatedMessage struct {
+	logged     time.Time
+	suppressed int
+}
+
+// newLogRepeats creates a logRepeats for the given elector configuration. It
+// is nil, suppressing nothing, if the configuration does not enable it.
+func newLogRepeats(config *ElectorConfig) *logRepeats {
+	if config.LogRepeatEvery <= 0 && config.LogRepeatInterval <= 0 {
+		return nil
+	}
+	return &logRepeats{
+		every:    config.LogRepeatEvery,
+		interval: config.LogRepeatInterval,
+		now:      time.Now,
+		messages: map[string]*repeatedMessage{},
+	}
+}
+
+// allow checks whether a message should be logged, and gets the number of
+// times it was suppressed since it was last logged. A nil logRepeats allows
+// every message.
+func (r *logRepeats) allow(message string) (bool, int) {
+	if r == nil {
+		return true, 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+
+	m, ok := r.messages[message]
+	if !ok {
+		if len(r.messages) >= maxRepeatedMessages {
+			r.messages = map[string]*repeatedMessage{}
+		}
+		r.messages[message] = &repeatedMessage{logged: now}
+		return true, 0
+	}
+
+	if (r.every > 0 && m.suppressed+1 >= r.every) || (r.interval > 0 && now.Sub(m.logged) >= r.interval) {
+		suppressed := m.suppressed
+		m.suppressed = 0
+		m.logged = now
+		return true, suppressed
+	}
+	m.suppressed++
+	return false, 0
+}
+
+// repeated formats a message, and checks whether it should be logged. A
+// message logged after some of its repeats were suppressed says so.
+func (l logger) repeated(format string, args ...interface{}) (string, bool) {
+	message := fmt.Sprintf(format, args...)
+	ok, suppressed := l.repeats.allow(message)
+	if !ok {
+		return "", false
+	}
+	if suppressed > 0 {
+		message = fmt.Sprintf("%s (repeated %d times since last logged)", message, suppressed)
+	}
+	return l.prefixed(message), true
+}
+
+// repeatedWarningf logs a formatted message at WARNING level, suppressing it
+// if it is a repeat of a message logged recently.
+func (l logger) repeatedWarningf(format string, args ...interface{}) {
+	if message, ok := l.repeated(format, args...); ok {
+		l.output().Warningf("%s", message)
+	}
+}
+
+// repeatedErrorf logs a formatted message at ERROR level, suppressing it if
+// it is a repeat of a message logged recently.
+func (l logger) repeatedErrorf(format string, args ...interface{}) {
+	if message, ok := l.repeated(format, args...); ok {
+		l.output().Errorf("%s", message)
+	}
+}
@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogRepeats_allow(t *testing.T) {
+	cases := []struct {
+		description string
+		every       int
+		interval    time.Duration
+		step        time.Duration
+		logged      []bool
+	}{
+		{
+			description: "every 3 occurrences",
+			every:       3,
+			logged:      []bool{true, false, false, true, false, false, true},
+		},
+		{
+			description: "every interval",
+			interval:    time.Minute,
+			step:        25 * time.Second,
+			logged:      []bool{true, false, false, true, false, false, true},
+		},
+		{
+			description: "occurrences before interval",
+			every:       2,
+			interval:    time.Minute,
+			step:        time.Second,
+			logged:      []bool{true, false, true, false, true},
+		},
+	}
+
+	for _, c := range cases {
+		now := time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC)
+		r := newLogRepeats(&ElectorConfig{LogRepeatEvery: c.every, LogRepeatInterval: c.interval})
+		r.now = func() time.Time { return now }
+
+		for i, expected := range c.logged {
+			logged, _ := r.allow("test message")
+			assert.Equal(t, expected, logged, "%s: occurrence %d", c.description, i+1)
+			now = now.Add(c.step)
+		}
+	}
+}
+
+func TestLogRepeats_disabled(t *testing.T) {
+	r := newLogRepeats(&ElectorConfig{})
+	assert.Nil(t, r)
+
+	for i := 0; i < 3; i++ {
+		logged, suppressed := r.allow("test message")
+		assert.True(t, logged)
+		assert.Zero(t, suppressed)
+	}
+}
+
+func TestLogger_repeatedErrorf(t *testing.T) {
+	out := &testLogger{}
+	log := newLogger(&ElectorConfig{
+		Logger:         out,
+		ID:             "test-node-1",
+		LogRepeatEvery: 3,
+	})
+
+	for i := 0; i < 4; i++ {
+		log.repeatedErrorf("failed to update pod label: %s", "forbidden")
+	}
+	log.repeatedErrorf("failed to update pod label: %s", "timeout")
+
+	assert.Equal(t,
+		"ERROR [id=test-node-1] failed to update pod label: forbidden\n"+
+			"ERROR [id=test-node-1] failed to update pod label: forbidden (repeated 2 times since last logged)\n"+
+			"ERROR [id=test-node-1] failed to update pod label: timeout\n",
+		out.String(),
+	)
+}
+
+func TestLogger_repeatedWarningf_sharedByCopies(t *testing.T) {
+	out := &testLogger{}
+	log := newLogger(&ElectorConfig{Logger: out, LogRepeatInterval: time.Hour})
+
+	// Copies of a logger suppress the same repeats.
+	copied := log
+	log.repeatedWarningf("failed to deliver")
+	copied.repeatedWarningf("failed to deliver")
+	assert.Equal(t, 1, strings.Count(out.String(), "WARNING failed to deliver"))
+}
@@ -0,0 +1,243 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsSubsystem is the subsystem of the elector's metric names. Metric
+// names are prefixed with the configured MetricsNamespace, if any.
+const metricsSubsystem = "elector"
+
+// metricsCollector collects the metrics of an elector node.
+//
+// The metrics are read from the node's state as they are collected, so they
+// always agree with what the node reports over HTTP. Every metric is labelled
+// with the election name. The node identity is only added as a label if
+// configured, since it multiplies the number of series across nodes; it is
+// always available on the info metric.
+type metricsCollector struct {
+	node *ElectorNode
+
+	info          *prometheus.Desc
+	buildInfo     *prometheus.Desc
+	isLeader      *prometheus.Desc
+	hasLed        *prometheus.Desc
+	acquisitions  *prometheus.Desc
+	renewals      *prometheus.Desc
+	slowRenewals  *prometheus.Desc
+	lockAuth      *prometheus.Desc
+	lockDeletions *prometheus.Desc
+	firstLeader   *prometheus.Desc
+	leaseMismatch *prometheus.Desc
+	reruns        *prometheus.Desc
+	runErrors     *prometheus.Desc
+
+	// leaderChanges is only labelled with the leaders that leadership moved
+	// between (from and to) if the identity label is configured, as those
+	// labels multiply the number of series in the same way.
+	leaderChanges      *prometheus.Desc
+	leaderChangeLabels bool
+}
+
+// newMetricsCollector creates the metrics collector for the elector node.
+func newMetricsCollector(node *ElectorNode) *metricsCollector {
+	conf := node.config
+	labels := prometheus.Labels{"election": conf.Name}
+	if conf.MetricsIdentityLabel {
+		labels["identity"] = conf.ID
+	}
+
+	desc := func(name, help string, labels prometheus.Labels) *prometheus.Desc {
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(conf.MetricsNamespace, metricsSubsystem, name),
+			help,
+			nil,
+			labels,
+		)
+	}
+
+	// The node name is only known if it was configured, e.g. through the
+	// downward API.
+	infoLabels := prometheus.Labels{
+		"election":  conf.Name,
+		"identity":  conf.ID,
+		"namespace": conf.LockNamespace,
+		"lock_type": conf.LockType,
+		"role":      conf.role(),
+	}
+	if conf.NodeName != "" {
+		infoLabels["node_name"] = conf.NodeName
+	}
+
+	buildLabels := prometheus.Labels{
+		"election": conf.Name,
+		"version":  conf.Build.Version,
+		"commit":   conf.Build.Commit,
+		"tag":      conf.Build.Tag,
+		"go":       conf.Build.GoVersion,
+		"os":       conf.Build.OS,
+		"arch":     conf.Build.Arch,
+		"date":     conf.Build.BuildDate,
+	}
+
+	var leaderChangeLabels []string
+	if conf.MetricsIdentityLabel {
+		leaderChangeLabels = []string{"from", "to"}
+	}
+
+	return &metricsCollector{
+		node:          node,
+		info:          desc("info", "Information about the elector node. The value is always 1.", infoLabels),
+		buildInfo:     desc("build_info", "Information about the build of the application running the elector node. The value is always 1.", buildLabels),
+		isLeader:      desc("is_leader", "Whether the node is the leader of the election (1) or not (0).", labels),
+		hasLed:        desc("has_led", "Whether the node has been the leader of the election at any point (1) or not (0).", labels),
+		acquisitions:  desc("acquisitions_total", "The number of times the node has acquired leadership.", labels),
+		renewals:      desc("renew_total", "The number of successful renewals of the leader's lease by the node.", labels),
+		slowRenewals:  desc("slow_renewals_total", "The number of lease renewals which took longer than the slow renewal fraction of the renew deadline.", labels),
+		lockDeletions: desc("lock_deletions_total", "The number of times the election lock object was found deleted externally.", labels),
+		firstLeader:   desc("time_to_first_leader_seconds", "The time from the start of the node until it first observed a leader. Not reported until a leader is observed.", labels),
+		reruns:        desc("rerun_total", "The number of times the election loop has been re-run.", labels),
+		runErrors: prometheus.NewDesc(
+			prometheus.BuildFQName(conf.MetricsNamespace, metricsSubsystem, "rerun_errors_total"),
+			"The number of errors which stopped a run of the election loop, by category (client, lock, config, other).",
+			[]string{"category"},
+			labels,
+		),
+		lockAuth: prometheus.NewDesc(
+			prometheus.BuildFQName(conf.MetricsNamespace, metricsSubsystem, "lock_auth_errors_total"),
+			"The number of operations on the election lock which the API server rejected as unauthorized or forbidden, by reason (unauthorized, forbidden).",
+			[]string{"reason"},
+			labels,
+		),
+		leaseMismatch: desc("lease_duration_mismatch", "Whether the lease duration recorded by the leader differs from the node's lease duration (1) or not (0).", labels),
+		leaderChanges: prometheus.NewDesc(
+			prometheus.BuildFQName(conf.MetricsNamespace, metricsSubsystem, "leader_changes_total"),
+			"The number of times the node observed the leader change, including the first leader observed.",
+			leaderChangeLabels,
+			labels,
+		),
+		leaderChangeLabels: conf.MetricsIdentityLabel,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.info
+	ch <- c.buildInfo
+	ch <- c.isLeader
+	ch <- c.hasLed
+	ch <- c.acquisitions
+	ch <- c.renewals
+	ch <- c.slowRenewals
+	ch <- c.lockAuth
+	ch <- c.lockDeletions
+	ch <- c.firstLeader
+	ch <- c.leaseMismatch
+	ch <- c.reruns
+	ch <- c.runErrors
+	ch <- c.leaderChanges
+}
+
+// Collect implements prometheus.Collector.
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	hasLed, acquisitions := c.node.leadership()
+
+	ch <- prometheus.MustNewConstMetric(c.info, prometheus.GaugeValue, 1)
+	ch <- prometheus.MustNewConstMetric(c.buildInfo, prometheus.GaugeValue, 1)
+	ch <- prometheus.MustNewConstMetric(c.isLeader, prometheus.GaugeValue, boolValue(c.node.IsLeader()))
+	ch <- prometheus.MustNewConstMetric(c.hasLed, prometheus.GaugeValue, boolValue(hasLed))
+	ch <- prometheus.MustNewConstMetric(c.acquisitions, prometheus.CounterValue, float64(acquisitions))
+	ch <- prometheus.MustNewConstMetric(c.renewals, prometheus.CounterValue, float64(c.node.renewCount()))
+	ch <- prometheus.MustNewConstMetric(c.slowRenewals, prometheus.CounterValue, float64(c.node.slowRenewalCount()))
+	lockAuth := c.node.lockAuthErrorCounts()
+	for _, reason := range lockAuthReasons {
+		ch <- prometheus.MustNewConstMetric(c.lockAuth, prometheus.CounterValue, float64(lockAuth[reason]), reason)
+	}
+	ch <- prometheus.MustNewConstMetric(c.lockDeletions, prometheus.CounterValue, float64(c.node.lockDeletionCount()))
+	reruns, runErrors := c.node.rerunCounts()
+	ch <- prometheus.MustNewConstMetric(c.reruns, prometheus.CounterValue, float64(reruns))
+	for _, category := range runErrorCategories {
+		ch <- prometheus.MustNewConstMetric(c.runErrors, prometheus.CounterValue, float64(runErrors[category]), category)
+	}
+	ch <- prometheus.MustNewConstMetric(c.leaseMismatch, prometheus.GaugeValue, boolValue(c.node.leaseDurationMismatch() != nil))
+	if d, ok := c.node.firstLeaderTime(); ok {
+		ch <- prometheus.MustNewConstMetric(c.firstLeader, prometheus.GaugeValue, d.Seconds())
+	}
+
+	changes := c.node.leaderChangeCounts()
+	if c.leaderChangeLabels {
+		for change, count := range changes {
+			ch <- prometheus.MustNewConstMetric(c.leaderChanges, prometheus.CounterValue, float64(count), change.From, change.To)
+		}
+		return
+	}
+	total := 0
+	for _, count := range changes {
+		total += count
+	}
+	ch <- prometheus.MustNewConstMetric(c.leaderChanges, prometheus.CounterValue, float64(total))
+}
+
+// boolValue converts a bool to a metric value.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// registerMetrics registers the elector node's metrics.
+//
+// If a Registerer is configured, the metrics are registered with it. Otherwise,
+// they are registered with a registry of the node's own. The metrics are served
+// at /metrics if the registry they were registered with can be gathered from.
+func (node *ElectorNode) registerMetrics() error {
+	registerer := node.config.Registerer
+	if registerer == nil {
+		registerer = prometheus.NewRegistry()
+	}
+	if gatherer, ok := registerer.(prometheus.Gatherer); ok {
+		node.gatherer = gatherer
+	}
+	if err := registerer.Register(newMetricsCollector(node)); err != nil {
+		return err
+	}
+
+	serverMetrics := newHTTPServerMetrics(node.config)
+	if err := serverMetrics.register(registerer); err != nil {
+		return err
+	}
+	node.serverMetrics = serverMetrics
+	return nil
+}
+
+// httpMetrics serves the elector node's metrics in the Prometheus exposition
+// format.
+func (node *ElectorNode) httpMetrics(res http.ResponseWriter, req *http.Request) {
+	if node.gatherer == nil {
+		node.writeJSON(res, http.StatusNotFound, MessageResponse{
+			Message: "metrics are not served by the elector",
+		})
+		return
+	}
+	promhttp.HandlerFor(node.gatherer, promhttp.HandlerOpts{}).ServeHTTP(res, req)
+}
@@ -0,0 +1,333 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// newTestMetricsNode creates a node which has acquired leadership twice and is
+// the current leader, for checking its metrics.
+func newTestMetricsNode(config *ElectorConfig) *ElectorNode {
+	config.ID = "test-node-1"
+	config.Name = "test-election"
+	config.LockNamespace = "test-ns"
+	config.LockType = "leases"
+	config.Logger = &testLogger{}
+
+	node := NewElectorNode(config)
+	node.currentLeader = "test-node-1"
+	node.acquisitions = 2
+	node.renewals = 5
+	node.slowRenewals = 1
+	return node
+}
+
+func TestElectorNode_registerMetrics(t *testing.T) {
+	cases := []struct {
+		description   string
+		namespace     string
+		nodeName      string
+		identityLabel bool
+		expected      string
+	}{
+		{
+			description:   "without identity label",
+			identityLabel: false,
+			expected: `
+# HELP elector_is_leader Whether the node is the leader of the election (1) or not (0).
+# TYPE elector_is_leader gauge
+elector_is_leader{election="test-election"} 1
+# HELP elector_acquisitions_total The number of times the node has acquired leadership.
+# TYPE elector_acquisitions_total counter
+elector_acquisitions_total{election="test-election"} 2
+# HELP elector_info Information about the elector node. The value is always 1.
+# TYPE elector_info gauge
+elector_info{election="test-election",identity="test-node-1",lock_type="leases",namespace="test-ns",role="test-election"} 1
+`,
+		},
+		{
+			description:   "with identity label",
+			identityLabel: true,
+			expected: `
+# HELP elector_is_leader Whether the node is the leader of the election (1) or not (0).
+# TYPE elector_is_leader gauge
+elector_is_leader{election="test-election",identity="test-node-1"} 1
+# HELP elector_acquisitions_total The number of times the node has acquired leadership.
+# TYPE elector_acquisitions_total counter
+elector_acquisitions_total{election="test-election",identity="test-node-1"} 2
+# HELP elector_info Information about the elector node. The value is always 1.
+# TYPE elector_info gauge
+elector_info{election="test-election",identity="test-node-1",lock_type="leases",namespace="test-ns",role="test-election"} 1
+`,
+		},
+		{
+			description:   "with namespace",
+			namespace:     "myapp",
+			identityLabel: false,
+			expected: `
+# HELP myapp_elector_is_leader Whether the node is the leader of the election (1) or not (0).
+# TYPE myapp_elector_is_leader gauge
+myapp_elector_is_leader{election="test-election"} 1
+# HELP myapp_elector_acquisitions_total The number of times the node has acquired leadership.
+# TYPE myapp_elector_acquisitions_total counter
+myapp_elector_acquisitions_total{election="test-election"} 2
+# HELP myapp_elector_info Information about the elector node. The value is always 1.
+# TYPE myapp_elector_info gauge
+myapp_elector_info{election="test-election",identity="test-node-1",lock_type="leases",namespace="test-ns",role="test-election"} 1
+`,
+		},
+		{
+			description:   "with node name",
+			nodeName:      "test-k8s-node",
+			identityLabel: false,
+			expected: `
+# HELP elector_is_leader Whether the node is the leader of the election (1) or not (0).
+# TYPE elector_is_leader gauge
+elector_is_leader{election="test-election"} 1
+# HELP elector_acquisitions_total The number of times the node has acquired leadership.
+# TYPE elector_acquisitions_total counter
+elector_acquisitions_total{election="test-election"} 2
+# HELP elector_info Information about the elector node. The value is always 1.
+# TYPE elector_info gauge
+elector_info{election="test-election",identity="test-node-1",lock_type="leases",namespace="test-ns",node_name="test-k8s-node",role="test-election"} 1
+`,
+		},
+	}
+
+	for _, c := range cases {
+		registry := prometheus.NewRegistry()
+		node := newTestMetricsNode(&ElectorConfig{
+			MetricsNamespace:     c.namespace,
+			NodeName:             c.nodeName,
+			MetricsIdentityLabel: c.identityLabel,
+			Registerer:           registry,
+		})
+		assert.NoError(t, node.registerMetrics(), c.description)
+
+		prefix := ""
+		if c.namespace != "" {
+			prefix = c.namespace + "_"
+		}
+		err := testutil.GatherAndCompare(
+			registry,
+			strings.NewReader(c.expected),
+			prefix+"elector_is_leader",
+			prefix+"elector_acquisitions_total",
+			prefix+"elector_info",
+		)
+		assert.NoError(t, err, c.description)
+	}
+}
+
+func TestElectorNode_registerMetrics_counters(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	node := newTestMetricsNode(&ElectorConfig{
+		Registerer: registry,
+	})
+	assert.NoError(t, node.registerMetrics())
+
+	err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP elector_has_led Whether the node has been the leader of the election at any point (1) or not (0).
+# TYPE elector_has_led gauge
+elector_has_led{election="test-election"} 1
+# HELP elector_renew_total The number of successful renewals of the leader's lease by the node.
+# TYPE elector_renew_total counter
+elector_renew_total{election="test-election"} 5
+# HELP elector_slow_renewals_total The number of lease renewals which took longer than the slow renewal fraction of the renew deadline.
+# TYPE elector_slow_renewals_total counter
+elector_slow_renewals_total{election="test-election"} 1
+`), "elector_has_led", "elector_renew_total", "elector_slow_renewals_total")
+	assert.NoError(t, err)
+}
+
+func TestElectorNode_registerMetrics_externalRegisterer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	node := newTestMetricsNode(&ElectorConfig{
+		Registerer: prometheus.WrapRegistererWithPrefix("app_", registry),
+	})
+	assert.NoError(t, node.registerMetrics())
+
+	// The metrics are registered with the application's registry.
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	var names []string
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	assert.Contains(t, names, "app_elector_is_leader")
+
+	// The wrapping registerer can not be gathered from, so the metrics are not
+	// served by the elector.
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Registering the same metrics again fails.
+	assert.Error(t, node.registerMetrics())
+}
+
+func TestElectorNode_httpMetrics(t *testing.T) {
+	node := newTestMetricsNode(&ElectorConfig{})
+	assert.NoError(t, node.registerMetrics())
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `elector_is_leader{election="test-election"} 1`)
+}
+
+func TestElectorNode_registerMetrics_timeToFirstLeader(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	node := newTestMetricsNode(&ElectorConfig{
+		Registerer: registry,
+	})
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	node.clock = clk
+	node.started = clk.Now()
+	assert.NoError(t, node.registerMetrics())
+
+	// The metric is not reported until a leader is observed.
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(""), "elector_time_to_first_leader_seconds"))
+
+	clk.Step(2500 * time.Millisecond)
+	node.recordFirstLeader("test-node-2")
+	err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP elector_time_to_first_leader_seconds The time from the start of the node until it first observed a leader. Not reported until a leader is observed.
+# TYPE elector_time_to_first_leader_seconds gauge
+elector_time_to_first_leader_seconds{election="test-election"} 2.5
+`), "elector_time_to_first_leader_seconds")
+	assert.NoError(t, err)
+}
+
+func TestElectorNode_registerMetrics_leaseDurationMismatch(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	node := newTestMetricsNode(&ElectorConfig{
+		TTL:        30 * time.Second,
+		Registerer: registry,
+	})
+	assert.NoError(t, node.registerMetrics())
+
+	expected := func(value int) string {
+		return fmt.Sprintf(`
+# HELP elector_lease_duration_mismatch Whether the lease duration recorded by the leader differs from the node's lease duration (1) or not (0).
+# TYPE elector_lease_duration_mismatch gauge
+elector_lease_duration_mismatch{election="test-election"} %d
+`, value)
+	}
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected(0)), "elector_lease_duration_mismatch"))
+
+	node.checkLeaseDuration(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2", LeaseDurationSeconds: 10})
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected(1)), "elector_lease_duration_mismatch"))
+
+	node.checkLeaseDuration(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2", LeaseDurationSeconds: 30})
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected(0)), "elector_lease_duration_mismatch"))
+}
+
+func TestElectorNode_registerMetrics_reruns(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	node := newTestMetricsNode(&ElectorConfig{
+		Registerer: registry,
+	})
+	assert.NoError(t, node.registerMetrics())
+
+	node.recordRerun("the election stopped")
+	node.recordRerun("the election stopped")
+	node.recordRunError(&runError{category: runErrorLock, err: errors.New("test error")})
+
+	// Every category is reported, so rates can be taken before an error of
+	// the category has occurred.
+	err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP elector_rerun_total The number of times the election loop has been re-run.
+# TYPE elector_rerun_total counter
+elector_rerun_total{election="test-election"} 2
+# HELP elector_rerun_errors_total The number of errors which stopped a run of the election loop, by category (client, lock, config, other).
+# TYPE elector_rerun_errors_total counter
+elector_rerun_errors_total{category="client",election="test-election"} 0
+elector_rerun_errors_total{category="config",election="test-election"} 0
+elector_rerun_errors_total{category="lock",election="test-election"} 1
+elector_rerun_errors_total{category="other",election="test-election"} 0
+`), "elector_rerun_total", "elector_rerun_errors_total")
+	assert.NoError(t, err)
+}
+
+func TestElectorNode_registerMetrics_buildInfo(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	node := newTestMetricsNode(&ElectorConfig{
+		Registerer: registry,
+		Build: BuildInfo{
+			Version:   "1.2.3",
+			Commit:    "abc123",
+			Tag:       "v1.2.3",
+			GoVersion: "go1.13",
+			OS:        "linux",
+			Arch:      "amd64",
+			BuildDate: "2019-05-02T18:28:51Z",
+		},
+	})
+	assert.NoError(t, node.registerMetrics())
+
+	err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP elector_build_info Information about the build of the application running the elector node. The value is always 1.
+# TYPE elector_build_info gauge
+elector_build_info{arch="amd64",commit="abc123",date="2019-05-02T18:28:51Z",election="test-election",go="go1.13",os="linux",tag="v1.2.3",version="1.2.3"} 1
+`), "elector_build_info")
+	assert.NoError(t, err)
+}
+
+func TestElectorNode_registerMetrics_leaderChanges(t *testing.T) {
+	cases := []struct {
+		description   string
+		identityLabel bool
+		expected      string
+	}{
+		{
+			description:   "without identity label",
+			identityLabel: false,
+			expected: `
+# HELP elector_leader_changes_total The number of times the node observed the leader change, including the first leader observed.
+# TYPE elector_leader_changes_total counter
+elector_leader_changes_total{election="test-election"} 3
+`,
+		},
+		{
+			description:   "with identity label",
+			identityLabel: true,
+			expected: `
+# HELP elector_leader_changes_total The number of times the node observed the leader change, including the first leader observed.
+# TYPE elector_leader_changes_total counter
+elector_leader_changes_total{election="test-election",from="",identity="test-node-1",to="test-node-2"} 1
+elector_leader_changes_total{election="test-election",from="test-node-1",identity="test-node-1",to="test-node-2"} 1
+elector_leader_changes_total{election="test-election",from="test-node-2",identity="test-node-1",to="test-node-1"} 1
+`,
+		},
+	}
+
+	for _, c := range cases {
+		registry := prometheus.NewRegistry()
+		node := newTestMetricsNode(&ElectorConfig{
+			MetricsIdentityLabel: c.identityLabel,
+			Registerer:           registry,
+		})
+		node.currentLeader = ""
+		for _, leader := range []string{"test-node-2", "test-node-1", "test-node-2"} {
+			node.setLeader(leader)
+		}
+		assert.NoError(t, node.registerMetrics(), c.description)
+
+		err := testutil.GatherAndCompare(registry, strings.NewReader(c.expected), "elector_leader_changes_total")
+		assert.NoError(t, err, c.description)
+	}
+}
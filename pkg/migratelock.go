@@ -0,0 +1,89 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// migrationLockType gets the multi-lock type which migrates the election lock
+// from one lock type to another.
+//
+// A multi-lock reads the lock record from the object of the old lock type and
+// writes it to the objects of both lock types. Nodes which have not been
+// migrated yet keep using the object of the old lock type, so they agree with
+// the migrated nodes on the leader, and the election is not left without a
+// leader while the migration rolls out. Only migrations to leases are
+// supported.
+func migrationLockType(from, to string) (string, error) {
+	if to != resourcelock.LeasesResourceLock {
+		return "", fmt.Errorf("migrating the lock type to %s is not supported: only migrating to %s is supported", to, resourcelock.LeasesResourceLock)
+	}
+	switch from {
+	case resourcelock.ConfigMapsResourceLock:
+		return resourcelock.ConfigMapsLeasesResourceLock, nil
+	case resourcelock.EndpointsResourceLock:
+		return resourcelock.EndpointsLeasesResourceLock, nil
+	default:
+		return "", fmt.Errorf("migrating the lock type from %s to %s is not supported", from, to)
+	}
+}
+
+// electionLockType gets the lock type the election runs with. While the lock
+// type is being migrated, this is the multi-lock type for the migration;
+// otherwise, it is the configured lock type.
+func (node *ElectorNode) electionLockType() string {
+	if node.config.LockTypeMigrateFrom == "" {
+		return node.config.LockType
+	}
+	// The migration is checked with the rest of the configuration.
+	lockType, err := migrationLockType(node.config.LockTypeMigrateFrom, node.config.LockType)
+	if err != nil {
+		return node.config.LockType
+	}
+	return lockType
+}
+
+// checkMigration logs the progress of a lock type migration when a run of the
+// election starts.
+//
+// The object of the new lock type is created by the first leader to renew
+// the lock with the multi-lock. Once it exists, and every node runs with the
+// new lock type, the migration can be finished by no longer migrating from
+// the old lock type.
+func (node *ElectorNode) checkMigration(client kubernetes.Interface) {
+	from, to := node.config.LockTypeMigrateFrom, node.config.LockType
+	if from == "" {
+		return
+	}
+
+	_, err := client.CoordinationV1().Leases(node.config.LockNamespace).Get(node.config.Name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		node.log.Infof("migrating the election lock from %s to %s: the %s object exists and is kept in sync with the %s object; once every node uses the %s lock type, stop migrating from %s",
+			from, to, to, from, to, from)
+	case apierrors.IsNotFound(err):
+		node.log.Infof("migrating the election lock from %s to %s: the %s object does not exist yet, it will be created by the leader", from, to, to)
+	default:
+		node.log.Warningf("migrating the election lock from %s to %s: failed to check the %s object: %v", from, to, to, err)
+	}
+}
@@ -0,0 +1,207 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestMigrationLockType(t *testing.T) {
+	cases := []struct {
+		description string
+		from        string
+		to          string
+		expected    string
+	}{
+		{
+			description: "configmaps to leases",
+			from:        resourcelock.ConfigMapsResourceLock,
+			to:          resourcelock.LeasesResourceLock,
+			expected:    resourcelock.ConfigMapsLeasesResourceLock,
+		},
+		{
+			description: "endpoints to leases",
+			from:        resourcelock.EndpointsResourceLock,
+			to:          resourcelock.LeasesResourceLock,
+			expected:    resourcelock.EndpointsLeasesResourceLock,
+		},
+	}
+
+	for _, c := range cases {
+		lockType, err := migrationLockType(c.from, c.to)
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, c.expected, lockType, c.description)
+	}
+}
+
+func TestMigrationLockType_error(t *testing.T) {
+	cases := []struct {
+		description string
+		from        string
+		to          string
+	}{
+		{
+			description: "leases to configmaps",
+			from:        resourcelock.LeasesResourceLock,
+			to:          resourcelock.ConfigMapsResourceLock,
+		},
+		{
+			description: "leases to leases",
+			from:        resourcelock.LeasesResourceLock,
+			to:          resourcelock.LeasesResourceLock,
+		},
+		{
+			description: "dynamic to leases",
+			from:        DynamicResourceLock,
+			to:          resourcelock.LeasesResourceLock,
+		},
+		{
+			description: "configmaps to dynamic",
+			from:        resourcelock.ConfigMapsResourceLock,
+			to:          DynamicResourceLock,
+		},
+	}
+
+	for _, c := range cases {
+		_, err := migrationLockType(c.from, c.to)
+		assert.Error(t, err, c.description)
+	}
+}
+
+func TestElectorNode_checkConfig_lockTypeMigrateFrom(t *testing.T) {
+	cases := []struct {
+		description string
+		from        string
+		ok          bool
+	}{
+		{
+			description: "not migrating",
+			from:        "",
+			ok:          true,
+		},
+		{
+			description: "migrating from configmaps",
+			from:        resourcelock.ConfigMapsResourceLock,
+			ok:          true,
+		},
+		{
+			description: "migrating from an unsupported lock type",
+			from:        DynamicResourceLock,
+			ok:          false,
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			Name:                "test-election",
+			Namespace:           "test-ns",
+			LockType:            resourcelock.LeasesResourceLock,
+			LockTypeMigrateFrom: c.from,
+		})
+
+		err := node.checkConfig()
+		if c.ok {
+			assert.NoError(t, err, c.description)
+		} else {
+			assert.Error(t, err, c.description)
+		}
+	}
+}
+
+func TestLockResources_migration(t *testing.T) {
+	resources := lockResources(&ElectorConfig{
+		LockType:            resourcelock.LeasesResourceLock,
+		LockTypeMigrateFrom: resourcelock.ConfigMapsResourceLock,
+	})
+	assert.Equal(t, []schema.GroupVersionResource{
+		{Version: "v1", Resource: "configmaps"},
+		{Group: "coordination.k8s.io", Version: "v1", Resource: "leases"},
+	}, resources)
+}
+
+func TestElectorNode_checkMigration(t *testing.T) {
+	cases := []struct {
+		description string
+		objects     []*coordinationv1.Lease
+		expected    string
+	}{
+		{
+			description: "lease not created yet",
+			expected:    "the leases object does not exist yet",
+		},
+		{
+			description: "lease created",
+			objects: []*coordinationv1.Lease{
+				{ObjectMeta: metav1.ObjectMeta{Name: "test-election", Namespace: "test-ns"}},
+			},
+			expected: "the leases object exists",
+		},
+	}
+
+	for _, c := range cases {
+		client := fake.NewSimpleClientset()
+		for _, lease := range c.objects {
+			_, err := client.CoordinationV1().Leases("test-ns").Create(lease)
+			assert.NoError(t, err, c.description)
+		}
+		logger := &testLogger{}
+		node := NewElectorNode(&ElectorConfig{
+			Name:                "test-election",
+			LockNamespace:       "test-ns",
+			LockType:            resourcelock.LeasesResourceLock,
+			LockTypeMigrateFrom: resourcelock.ConfigMapsResourceLock,
+			Logger:              logger,
+		})
+
+		node.checkMigration(client)
+		assert.Contains(t, logger.String(), c.expected, c.description)
+	}
+}
+
+func TestElectorNode_run_lockTypeMigrateFrom(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:                  "test-node-1",
+		Name:                "test-election",
+		Namespace:           "test-ns",
+		LockNamespace:       "test-ns",
+		PodName:             "test-pod",
+		LockType:            resourcelock.LeasesResourceLock,
+		LockTypeMigrateFrom: resourcelock.ConfigMapsResourceLock,
+		TTL:                 1 * time.Second,
+		Client:              client,
+		Logger:              &testLogger{},
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.run()
+	}()
+	defer func() {
+		node.Stop()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "election did not stop")
+		}
+	}()
+
+	// The lock record is written to both the configmap and the lease.
+	waitFor(t, 5*time.Second, func() bool {
+		cm, err := client.CoreV1().ConfigMaps("test-ns").Get("test-election", metav1.GetOptions{})
+		if err != nil || !strings.Contains(cm.Annotations[resourcelock.LeaderElectionRecordAnnotationKey], "test-node-1") {
+			return false
+		}
+		lease, err := client.CoordinationV1().Leases("test-ns").Get("test-election", metav1.GetOptions{})
+		return err == nil && lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity == "test-node-1"
+	})
+	assert.True(t, node.IsLeader())
+}
@@ -0,0 +1,50 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// verifyNamespaces checks that the namespaces the node uses (the namespace of
+// its Pod and the namespace of the election lock) exist and can be read.
+//
+// A namespace which does not exist (e.g. because of a typo) otherwise only
+// surfaces as a confusing failure to create the election lock, so this lets
+// the node fail fast with a clear error instead.
+func (node *ElectorNode) verifyNamespaces(client kubernetes.Interface) error {
+	namespaces := []string{node.config.Namespace}
+	if node.config.LockNamespace != node.config.Namespace {
+		namespaces = append(namespaces, node.config.LockNamespace)
+	}
+
+	for _, ns := range namespaces {
+		_, err := client.CoreV1().Namespaces().Get(ns, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("namespace %q does not exist", ns)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to verify namespace %q: %v", ns, err)
+		}
+		node.log.Infof("verified namespace %s exists", ns)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package pkg
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestNamespace(name string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+func TestElectorNode_verifyNamespaces(t *testing.T) {
+	cases := []struct {
+		description   string
+		lockNamespace string
+		objects       []runtime.Object
+		reactionErr   error
+		expected      string
+	}{
+		{
+			description:   "namespace exists",
+			lockNamespace: "test-ns",
+			objects:       []runtime.Object{newTestNamespace("test-ns")},
+		},
+		{
+			description:   "both namespaces exist",
+			lockNamespace: "test-lock-ns",
+			objects:       []runtime.Object{newTestNamespace("test-ns"), newTestNamespace("test-lock-ns")},
+		},
+		{
+			description:   "namespace does not exist",
+			lockNamespace: "test-ns",
+			expected:      `namespace "test-ns" does not exist`,
+		},
+		{
+			description:   "lock namespace does not exist",
+			lockNamespace: "test-lock-ns",
+			objects:       []runtime.Object{newTestNamespace("test-ns")},
+			expected:      `namespace "test-lock-ns" does not exist`,
+		},
+		{
+			description:   "namespace not accessible",
+			lockNamespace: "test-ns",
+			objects:       []runtime.Object{newTestNamespace("test-ns")},
+			reactionErr:   apierrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "test-ns", errors.New("test error")),
+			expected:      `unable to verify namespace "test-ns": `,
+		},
+	}
+
+	for _, c := range cases {
+		client := fake.NewSimpleClientset(c.objects...)
+		if c.reactionErr != nil {
+			reactionErr := c.reactionErr
+			client.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, reactionErr
+			})
+		}
+		node := NewElectorNode(&ElectorConfig{
+			ID:            "test-node-1",
+			Namespace:     "test-ns",
+			LockNamespace: c.lockNamespace,
+			Logger:        &testLogger{},
+		})
+
+		err := node.verifyNamespaces(client)
+		if c.expected == "" {
+			assert.NoError(t, err, c.description)
+		} else {
+			assert.Error(t, err, c.description)
+			assert.Contains(t, err.Error(), c.expected, c.description)
+		}
+	}
+}
+
+func TestElectorNode_run_verifyNamespace(t *testing.T) {
+	defer os.Unsetenv(EnvKubeConfigData)
+	os.Unsetenv(EnvKubeConfigData)
+	node := NewElectorNode(&ElectorConfig{
+		ID:              "test-node-1",
+		Name:            "test-election",
+		Namespace:       "test-ns",
+		LockNamespace:   "test-ns",
+		PodName:         "test-pod",
+		TTL:             1 * time.Second,
+		Client:          fake.NewSimpleClientset(newTestPod("test-ns", "test-pod")),
+		Logger:          &testLogger{},
+		VerifyNamespace: true,
+	})
+	node.setInitializing(true)
+
+	err := node.run()
+	assert.EqualError(t, err, `namespace "test-ns" does not exist`)
+	var runErr *runError
+	assert.True(t, errors.As(err, &runErr))
+	assert.Equal(t, runErrorConfig, runErr.category)
+}
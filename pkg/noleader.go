@@ -0,0 +1,96 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"time"
+
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// observeRecord checks each lock record observed during the election.
+func (node *ElectorNode) observeRecord(record resourcelock.LeaderElectionRecord) {
+	node.checkLeaseDuration(record)
+	node.checkLeaderless(record)
+}
+
+// checkLeaderless checks whether the election has gone without a leader.
+//
+// The leader election client only reports a new leader once another node
+// acquires the lock, so a leader which stops renewing its lease would be
+// reported until then. Once the lock is observed unheld, either because its
+// record was released or because it has not been renewed for its lease
+// duration, the node no longer reports the stale leader. As with the leader
+// election client, how long the record has gone unchanged is measured with
+// the node's clock, so clock skew with the holder does not matter.
+//
+// The leader which acquires the lock next is reported as soon as its record
+// is observed, even if it is the same leader as before.
+func (node *ElectorNode) checkLeaderless(record resourcelock.LeaderElectionRecord) {
+	now := node.clock.Now()
+	leaseDuration := node.recordLeaseDuration(record)
+
+	node.mu.Lock()
+	node.lastObserved = now
+	if record.HolderIdentity != node.observedHolder || !record.RenewTime.Time.Equal(node.observedRenew) {
+		node.observedHolder = record.HolderIdentity
+		node.observedRenew = record.RenewTime.Time
+		node.observedAt = now
+	}
+	node.observedDuration = leaseDuration
+	unheld := record.HolderIdentity == "" || now.Sub(node.observedAt) > leaseDuration
+	current := node.currentLeader
+	leaderless := !node.leaderlessSince.IsZero()
+	since := node.leaderlessSince
+	node.mu.Unlock()
+
+	switch {
+	case unheld && current != "" && current != node.config.ID:
+		node.noLeader(current, now)
+	case !unheld && leaderless && current == "":
+		node.log.Infof("%s holds the election lock after %v without a leader", record.HolderIdentity, now.Sub(since))
+		node.setLeader(record.HolderIdentity)
+	}
+}
+
+// noLeader reports that the election has no leader: the stale leader is
+// cleared, the leaderless state is recorded, and watchers and the OnNoLeader
+// callback are notified.
+func (node *ElectorNode) noLeader(lastLeader string, now time.Time) {
+	node.log.Warningf("the election has no leader: the lease of %s has expired and no node has acquired it", lastLeader)
+	node.setLeader("")
+
+	node.mu.Lock()
+	node.leaderlessSince = now
+	node.leaderPayload = nil
+	node.mu.Unlock()
+
+	if !node.passive {
+		node.broadcastWatchers(node.leadershipEvent(node.status()))
+	}
+	if node.config.OnNoLeader != nil {
+		node.config.OnNoLeader(lastLeader)
+	}
+}
+
+// isLeaderless checks whether the election lock was observed unheld beyond
+// its lease duration, with no node having acquired it since.
+func (node *ElectorNode) isLeaderless() bool {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return !node.leaderlessSince.IsZero()
+}
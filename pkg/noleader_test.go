@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// newLeaderlessTestNode creates a node which has observed test-node-2 as the
+// leader, recording the leaders reported to its OnNoLeader callback.
+func newLeaderlessTestNode() (*ElectorNode, *clock.FakeClock, *[]string) {
+	var lastLeaders []string
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 28, 51, 0, time.UTC))
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Name:   "test-election",
+		TTL:    10 * time.Second,
+		Logger: &testLogger{},
+		OnNoLeader: func(lastLeader string) {
+			lastLeaders = append(lastLeaders, lastLeader)
+		},
+	})
+	node.clock = clk
+	node.setLeader("test-node-2")
+	return node, clk, &lastLeaders
+}
+
+// heldRecord creates a lock record held by the identity, renewed at the time.
+func heldRecord(identity string, renewed time.Time) resourcelock.LeaderElectionRecord {
+	return resourcelock.LeaderElectionRecord{
+		HolderIdentity:       identity,
+		LeaseDurationSeconds: 10,
+		RenewTime:            metav1.NewTime(renewed),
+	}
+}
+
+func TestElectorNode_checkLeaderless_expired(t *testing.T) {
+	node, clk, lastLeaders := newLeaderlessTestNode()
+	watcher, _ := node.watchers.add(DefaultMaxWatchers)
+	record := heldRecord("test-node-2", clk.Now())
+
+	// The lease has not yet expired.
+	node.observeRecord(record)
+	clk.Step(10 * time.Second)
+	node.observeRecord(record)
+	assert.Equal(t, "test-node-2", node.leader())
+	assert.False(t, node.isLeaderless())
+
+	// The record has gone unchanged for longer than its lease duration.
+	clk.Step(time.Second)
+	node.observeRecord(record)
+	assert.Equal(t, "", node.leader())
+	assert.True(t, node.isLeaderless())
+	assert.Equal(t, []string{"test-node-2"}, *lastLeaders)
+	assert.Equal(t, true, getJSON(t, node, "/")["leaderless"])
+	assert.Equal(t, "", getJSON(t, node, "/")["leader"])
+
+	event := <-watcher.events
+	assert.Equal(t, StatusStandby, event.Status)
+	assert.Equal(t, "", event.Leader)
+	assert.Equal(t, "test-node-2", event.PreviousLeader)
+
+	// Observing the expired record again does not report it again.
+	node.observeRecord(record)
+	assert.Len(t, *lastLeaders, 1)
+
+	// The same leader renewing its lease is reported as the leader again.
+	clk.Step(time.Second)
+	node.observeRecord(heldRecord("test-node-2", clk.Now()))
+	assert.Equal(t, "test-node-2", node.leader())
+	assert.False(t, node.isLeaderless())
+	assert.Equal(t, false, getJSON(t, node, "/")["leaderless"])
+}
+
+func TestElectorNode_checkLeaderless_released(t *testing.T) {
+	node, _, lastLeaders := newLeaderlessTestNode()
+
+	node.observeRecord(resourcelock.LeaderElectionRecord{LeaseDurationSeconds: 1})
+	assert.Equal(t, "", node.leader())
+	assert.True(t, node.isLeaderless())
+	assert.Equal(t, []string{"test-node-2"}, *lastLeaders)
+
+	// Another node acquiring the lock ends the leaderless window.
+	node.setLeader("test-node-3")
+	assert.False(t, node.isLeaderless())
+}
+
+func TestElectorNode_checkLeaderless_notReported(t *testing.T) {
+	cases := []struct {
+		description string
+		leader      string
+	}{
+		{
+			description: "no leader known",
+			leader:      "",
+		},
+		{
+			description: "the node is the leader",
+			leader:      "test-node-1",
+		},
+	}
+
+	for _, c := range cases {
+		node, clk, lastLeaders := newLeaderlessTestNode()
+		node.setLeader(c.leader)
+		record := heldRecord("test-node-2", clk.Now())
+
+		node.observeRecord(record)
+		clk.Step(time.Minute)
+		node.observeRecord(record)
+		assert.Equal(t, c.leader, node.leader(), c.description)
+		assert.False(t, node.isLeaderless(), c.description)
+		assert.Empty(t, *lastLeaders, c.description)
+	}
+}
+
+func TestElectorNode_checkLeaderless_recordedDuration(t *testing.T) {
+	node, clk, _ := newLeaderlessTestNode()
+	record := heldRecord("test-node-2", clk.Now())
+	record.LeaseDurationSeconds = 30
+
+	// The holder's lease is not considered expired once the node's own lease
+	// duration has passed, only once the duration it recorded has.
+	node.observeRecord(record)
+	clk.Step(11 * time.Second)
+	node.observeRecord(record)
+	assert.Equal(t, "test-node-2", node.leader())
+	assert.False(t, node.isLeaderless())
+
+	clk.Step(20 * time.Second)
+	node.observeRecord(record)
+	assert.Equal(t, "", node.leader())
+	assert.True(t, node.isLeaderless())
+}
@@ -0,0 +1,201 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openAPIVersion is the version of the OpenAPI specification which the
+// generated document conforms to.
+const openAPIVersion = "3.0.0"
+
+// openAPIDocument builds an OpenAPI document describing the given routes.
+//
+// The response schemas are generated from the route response types, so
+// the document stays in sync with what the handlers actually return.
+func openAPIDocument(routes []route) map[string]interface{} {
+	paths := map[string]interface{}{}
+	for _, r := range routes {
+		operations, ok := paths[r.Path].(map[string]interface{})
+		if !ok {
+			operations = map[string]interface{}{}
+			paths[r.Path] = operations
+		}
+		contentType := r.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+
+		responses := map[string]interface{}{
+			"200": openAPIResponse("OK", contentType, r.Response),
+		}
+		for status, e := range routeErrors(r) {
+			if e.Response == nil {
+				responses[strconv.Itoa(status)] = openAPIResponse(e.Description, "application/json", MessageResponse{})
+			} else {
+				responses[strconv.Itoa(status)] = openAPIResponse(e.Description, contentType, e.Response)
+			}
+		}
+		operations[strings.ToLower(r.Method)] = map[string]interface{}{
+			"summary":   r.Summary,
+			"responses": responses,
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   "k8s-elector",
+			"version": "v1",
+		},
+		"paths": paths,
+	}
+}
+
+// openAPIResponse builds the OpenAPI description of a response with the
+// given content type, whose schema is generated from the response type.
+func openAPIResponse(description, contentType string, response interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			contentType: map[string]interface{}{
+				"schema": schemaFor(reflect.TypeOf(response)),
+			},
+		},
+	}
+}
+
+// routeErrors gets all of the error responses of a route: those of its
+// handler, and those of the handlers it is wrapped with when it is served.
+func routeErrors(r route) map[int]routeError {
+	errors := map[int]routeError{
+		http.StatusMethodNotAllowed: {Description: "The path is not served for the request method."},
+	}
+	if r.Auth {
+		errors[http.StatusUnauthorized] = routeError{Description: "The request does not provide the auth token as a bearer token."}
+		errors[http.StatusForbidden] = routeError{Description: "No auth token is configured, so the endpoint is disabled."}
+	}
+	if r.Chaos {
+		errors[http.StatusNotFound] = routeError{Description: "The chaos hooks are disabled."}
+	}
+	for status, e := range r.Errors {
+		errors[status] = e
+	}
+	return errors
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	timestampType     = reflect.TypeOf(Timestamp{})
+	secondsType       = reflect.TypeOf(Seconds(0))
+	humanDurationType = reflect.TypeOf(HumanDuration(0))
+)
+
+// schemaFor generates the JSON schema for the given type.
+//
+// Struct fields are described using their json tag for the property name
+// and their description tag for the property description. Fields which are
+// not omitted when empty are listed as required.
+func schemaFor(t reflect.Type) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	switch t {
+	case timeType, timestampType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case secondsType:
+		return map[string]interface{}{"type": "number"}
+	case humanDurationType:
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, ok := jsonFieldName(field)
+			if !ok {
+				continue
+			}
+			schema := schemaFor(field.Type)
+			if desc := field.Tag.Get("description"); desc != "" {
+				schema["description"] = desc
+			}
+			properties[name] = schema
+			if !jsonOmitEmpty(field) {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// jsonFieldName gets the name a struct field is marshaled to JSON with. If
+// the field is not marshaled, false is returned.
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+// jsonOmitEmpty checks whether a struct field is omitted from its JSON
+// encoding when it is empty.
+func jsonOmitEmpty(field reflect.StructField) bool {
+	for _, option := range strings.Split(field.Tag.Get("json"), ",")[1:] {
+		if option == "omitempty" {
+			return true
+		}
+	}
+	return false
+}
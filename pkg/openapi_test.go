@@ -0,0 +1,261 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getJSON issues a GET request against the node's HTTP mux and decodes the
+// JSON response body.
+func getJSON(t *testing.T, node *ElectorNode, path string) map[string]interface{} {
+	req := httptest.NewRequest("GET", path, nil)
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, req)
+
+	data := map[string]interface{}{}
+	err := json.NewDecoder(w.Result().Body).Decode(&data)
+	assert.NoError(t, err)
+	return data
+}
+
+// assertMatchesSchema checks that a decoded JSON value matches the given
+// JSON schema.
+func assertMatchesSchema(t *testing.T, schema map[string]interface{}, value interface{}, path string) {
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !assert.True(t, ok, "%s: expected object, got %T", path, value) {
+			return
+		}
+		properties, ok := schema["properties"].(map[string]interface{})
+		if !ok {
+			return
+		}
+		for name := range obj {
+			assert.Contains(t, properties, name, "%s: undocumented property %s", path, name)
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			assert.Contains(t, obj, name, "%s: missing required property %s", path, name)
+		}
+		for name, propSchema := range properties {
+			if v, exists := obj[name]; exists {
+				assertMatchesSchema(t, propSchema.(map[string]interface{}), v, path+"."+name)
+			}
+		}
+	case "array":
+		_, ok := value.([]interface{})
+		assert.True(t, ok, "%s: expected array, got %T", path, value)
+	case "string":
+		_, ok := value.(string)
+		assert.True(t, ok, "%s: expected string, got %T", path, value)
+	case "boolean":
+		_, ok := value.(bool)
+		assert.True(t, ok, "%s: expected boolean, got %T", path, value)
+	case "integer", "number":
+		_, ok := value.(float64)
+		assert.True(t, ok, "%s: expected number, got %T", path, value)
+	}
+}
+
+// responseSchema gets the JSON response schema for a GET on the path from
+// a decoded OpenAPI document.
+func responseSchema(t *testing.T, doc map[string]interface{}, path string) map[string]interface{} {
+	paths := doc["paths"].(map[string]interface{})
+	op, ok := paths[path].(map[string]interface{})
+	if !assert.True(t, ok, "path %s not documented", path) {
+		return nil
+	}
+	get := op["get"].(map[string]interface{})
+	responses := get["responses"].(map[string]interface{})
+	content := responses["200"].(map[string]interface{})["content"].(map[string]interface{})
+	return content["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+}
+
+func TestElectorNode_httpOpenAPI(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+
+	doc := getJSON(t, node, "/openapi.json")
+
+	assert.Equal(t, openAPIVersion, doc["openapi"])
+	paths := doc["paths"].(map[string]interface{})
+	for _, r := range node.routes() {
+		assert.Contains(t, paths, r.Path)
+	}
+}
+
+func TestElectorNode_httpOpenAPI_matchesResponses(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+	node.currentLeader = "test-node-2"
+
+	doc := getJSON(t, node, "/openapi.json")
+
+	for _, path := range []string{"/", "/config", "/backend", "/permissions", "/timing", "/healthz", "/version"} {
+		schema := responseSchema(t, doc, path)
+		assertMatchesSchema(t, schema, getJSON(t, node, path), path)
+	}
+}
+
+func TestElectorNode_httpOpenAPI_contentType(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+
+	doc := getJSON(t, node, "/openapi.json")
+	paths := doc["paths"].(map[string]interface{})
+	get := paths["/leader/id"].(map[string]interface{})["get"].(map[string]interface{})
+	content := get["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"text/plain": map[string]interface{}{
+			"schema": map[string]interface{}{"type": "string"},
+		},
+	}, content)
+}
+
+// responseStatuses gets the status codes of the documented responses for a
+// method on the path from a decoded OpenAPI document.
+func responseStatuses(doc map[string]interface{}, path, method string) []string {
+	op := doc["paths"].(map[string]interface{})[path].(map[string]interface{})
+	responses := op[strings.ToLower(method)].(map[string]interface{})["responses"].(map[string]interface{})
+
+	statuses := make([]string, 0, len(responses))
+	for status := range responses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	return statuses
+}
+
+func TestElectorNode_httpOpenAPI_errors(t *testing.T) {
+	cases := []struct {
+		description string
+		path        string
+		method      string
+		expected    []string
+	}{
+		{
+			description: "no handler errors",
+			path:        "/config",
+			method:      http.MethodGet,
+			expected:    []string{"200", "405"},
+		},
+		{
+			description: "handler errors",
+			path:        "/lease",
+			method:      http.MethodGet,
+			expected:    []string{"200", "400", "405", "503"},
+		},
+		{
+			description: "authenticated",
+			path:        "/subscribe",
+			method:      http.MethodPost,
+			expected:    []string{"200", "400", "401", "403", "405", "409"},
+		},
+		{
+			description: "chaos",
+			path:        "/chaos/fail-renewals",
+			method:      http.MethodPost,
+			expected:    []string{"200", "400", "401", "403", "404", "405"},
+		},
+	}
+
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+	doc := getJSON(t, node, "/openapi.json")
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, responseStatuses(doc, c.path, c.method), c.description)
+	}
+}
+
+func TestElectorNode_httpOpenAPI_errorContent(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+	doc := getJSON(t, node, "/openapi.json")
+	paths := doc["paths"].(map[string]interface{})
+
+	// Errors are described by a message, unless the route gives its own
+	// response for them.
+	lease := paths["/lease"].(map[string]interface{})["get"].(map[string]interface{})["responses"].(map[string]interface{})
+	content := lease["503"].(map[string]interface{})["content"].(map[string]interface{})
+	schema := content["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+	assert.Contains(t, schema["properties"], "message")
+
+	leaderID := paths["/leader/id"].(map[string]interface{})["get"].(map[string]interface{})["responses"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"text/plain": map[string]interface{}{
+			"schema": map[string]interface{}{"type": "string"},
+		},
+	}, leaderID["503"].(map[string]interface{})["content"])
+}
+
+func TestElectorNode_httpOpenAPI_errorsServed(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:        "test-node-1",
+		AuthToken: "test-token",
+	})
+	doc := getJSON(t, node, "/openapi.json")
+
+	// The error responses of the wrapping handlers are documented for the
+	// routes which are served with them.
+	for _, r := range node.routes() {
+		statuses := responseStatuses(doc, r.Path, r.Method)
+
+		w := httptest.NewRecorder()
+		node.mux().ServeHTTP(w, httptest.NewRequest(http.MethodPut, r.Path, nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code, r.Path)
+		assert.Contains(t, statuses, strconv.Itoa(w.Code), r.Path)
+
+		if r.Auth || r.Chaos {
+			w := httptest.NewRecorder()
+			node.mux().ServeHTTP(w, httptest.NewRequest(r.Method, r.Path, nil))
+			assert.Contains(t, statuses, strconv.Itoa(w.Code), "%s %s", r.Method, r.Path)
+		}
+	}
+}
+
+func TestSchemaFor(t *testing.T) {
+	type nested struct {
+		Value int `json:"value"`
+	}
+	type example struct {
+		Name     string            `json:"name" description:"the name"`
+		Enabled  bool              `json:"enabled,omitempty"`
+		Ratio    float64           `json:"ratio"`
+		Items    []string          `json:"items"`
+		Labels   map[string]string `json:"labels"`
+		When     time.Time         `json:"when"`
+		Nested   *nested           `json:"nested"`
+		Ignored  string            `json:"-"`
+		internal string
+	}
+
+	schema := schemaFor(reflect.TypeOf(example{}))
+	assert.Equal(t, "object", schema["type"])
+
+	props := schema["properties"].(map[string]interface{})
+	assert.Len(t, props, 7)
+	assert.Equal(t, []string{"name", "ratio", "items", "labels", "when", "nested"}, schema["required"])
+	assert.Equal(t, map[string]interface{}{"type": "string", "description": "the name"}, props["name"])
+	assert.Equal(t, map[string]interface{}{"type": "boolean"}, props["enabled"])
+	assert.Equal(t, map[string]interface{}{"type": "number"}, props["ratio"])
+	assert.Equal(t, map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}, props["items"])
+	assert.Equal(t, map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}}, props["labels"])
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "date-time"}, props["when"])
+	assert.Equal(t, "object", props["nested"].(map[string]interface{})["type"])
+}
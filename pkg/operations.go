@@ -0,0 +1,115 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// drainTimeout bounds how long the node waits for its in-flight operations to
+// finish when it stops.
+const drainTimeout = 10 * time.Second
+
+// operations tracks the in-flight side-effect operations of a node, such as
+// publishing its status or running its election commands, which run in their
+// own goroutines. The node waits for them to finish when it stops, so they are
+// not cut off mid-flight when the process exits.
+//
+// A nil *operations tracks nothing.
+type operations struct {
+	wg sync.WaitGroup
+
+	mu    sync.Mutex
+	count int
+}
+
+// start registers an operation as in-flight. The returned function marks it
+// as finished, and may be called more than once.
+func (o *operations) start() (done func()) {
+	if o == nil {
+		return func() {}
+	}
+
+	o.mu.Lock()
+	o.count++
+	o.wg.Add(1)
+	o.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			o.mu.Lock()
+			o.count--
+			o.mu.Unlock()
+			o.wg.Done()
+		})
+	}
+}
+
+// inFlight gets the number of in-flight operations.
+func (o *operations) inFlight() int {
+	if o == nil {
+		return 0
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.count
+}
+
+// wait waits for the in-flight operations to finish, up to the timeout. It
+// returns whether they finished.
+func (o *operations) wait(clk clock.Clock, timeout time.Duration) bool {
+	if o == nil {
+		return true
+	}
+
+	done := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-clk.After(timeout):
+		return false
+	}
+}
+
+// track runs the function as an in-flight operation of the node.
+func (node *ElectorNode) track(fn func()) {
+	defer node.operations.start()()
+	fn()
+}
+
+// drainOperations waits for the node's in-flight operations to finish, up to
+// the drain timeout, so they are not cut off when the process exits.
+func (node *ElectorNode) drainOperations() {
+	count := node.operations.inFlight()
+	if count == 0 {
+		return
+	}
+
+	node.log.Infof("waiting for %d in-flight operations to finish", count)
+	if !node.operations.wait(node.clock, drainTimeout) {
+		node.log.Warningf("timed out after %v waiting for %d in-flight operations to finish", drainTimeout, node.operations.inFlight())
+	}
+}
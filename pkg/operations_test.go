@@ -0,0 +1,108 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// slowPublisher is a publisher which takes a while to publish, until it is
+// released.
+type slowPublisher struct {
+	testPublisher
+	release chan struct{}
+}
+
+func (p *slowPublisher) publish(status string) error {
+	<-p.release
+	return p.testPublisher.publish(status)
+}
+
+func TestOperations(t *testing.T) {
+	ops := &operations{}
+	assert.Equal(t, 0, ops.inFlight())
+
+	done1 := ops.start()
+	done2 := ops.start()
+	assert.Equal(t, 2, ops.inFlight())
+
+	// Marking an operation as finished more than once has no effect.
+	done1()
+	done1()
+	assert.Equal(t, 1, ops.inFlight())
+
+	done2()
+	assert.Equal(t, 0, ops.inFlight())
+	assert.True(t, ops.wait(clock.RealClock{}, time.Second))
+}
+
+func TestOperations_nil(t *testing.T) {
+	var ops *operations
+	ops.start()()
+	assert.Equal(t, 0, ops.inFlight())
+	assert.True(t, ops.wait(clock.RealClock{}, time.Second))
+}
+
+func TestElectorNode_Stop_drainsOperations(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Logger: &testLogger{},
+	})
+	pub := &slowPublisher{release: make(chan struct{})}
+	p := newDebouncedPublisher(pub, clock.RealClock{}, 10*time.Millisecond, node.log, node.operations)
+
+	p.update(StatusLeader)
+	waitFor(t, 5*time.Second, func() bool {
+		return node.operations.inFlight() == 1
+	})
+	assert.Equal(t, 1, node.healthInfo().InFlightOperations)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(pub.release)
+	}()
+
+	// Stopping the node waits for the publish to finish.
+	node.Stop()
+	assert.Equal(t, []string{StatusLeader}, pub.statuses())
+	assert.Equal(t, 0, node.operations.inFlight())
+}
+
+func TestElectorNode_drainOperations_timeout(t *testing.T) {
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Logger: log,
+	})
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	node.clock = clk
+
+	release := make(chan struct{})
+	defer close(release)
+	go node.track(func() {
+		<-release
+	})
+	waitFor(t, 5*time.Second, func() bool {
+		return node.operations.inFlight() == 1
+	})
+
+	drained := make(chan struct{})
+	go func() {
+		node.drainOperations()
+		close(drained)
+	}()
+
+	// The node stops waiting once the drain timeout has passed, leaving the
+	// operation in flight.
+	waitFor(t, 5*time.Second, clk.HasWaiters)
+	clk.Step(drainTimeout)
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "timed out waiting for the drain to time out")
+	}
+	assert.Equal(t, 1, node.operations.inFlight())
+	assert.Contains(t, log.String(), "timed out after 10s waiting for 1 in-flight operations to finish")
+}
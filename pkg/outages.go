@@ -0,0 +1,161 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"encoding/json"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// OutagesConfigMapSuffix is appended to the election name to get the name
+	// of the ConfigMap which outages are recorded to.
+	OutagesConfigMapSuffix = "-outages"
+
+	// OutagesConfigMapKey is the ConfigMap data key which holds the recorded
+	// outages, as a JSON list.
+	OutagesConfigMapKey = "outages"
+
+	// DefaultOutageRecordLimit is the default maximum number of outages which
+	// are kept in the outages ConfigMap.
+	DefaultOutageRecordLimit = 20
+)
+
+// OutageRecord describes a window of time in which the election had no leader.
+type OutageRecord struct {
+	Start           Timestamp `json:"start"`
+	End             Timestamp `json:"end"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	PreviousHolder  string    `json:"previous_holder"`
+	NewHolder       string    `json:"new_holder"`
+	Reason          string    `json:"reason,omitempty"`
+}
+
+// newOutageRecord creates a record of the outage which ended when the new
+// holder acquired the lock at the given time. The outage starts at the last
+// time the previous holder renewed the lock.
+//
+// If the previous lock record does not describe a holder with a known renew
+// time, or if the outage was shorter than the threshold, false is returned.
+func newOutageRecord(previous *resourcelock.LeaderElectionRecord, newHolder string, acquired time.Time, threshold time.Duration) (OutageRecord, bool) {
+	if previous == nil || previous.HolderIdentity == "" || previous.RenewTime.IsZero() {
+		return OutageRecord{}, false
+	}
+
+	start := previous.RenewTime.Time
+	duration := acquired.Sub(start)
+	if duration <= threshold {
+		return OutageRecord{}, false
+	}
+
+	return OutageRecord{
+		Start:           Timestamp(start),
+		End:             Timestamp(acquired),
+		DurationSeconds: duration.Seconds(),
+		PreviousHolder:  previous.HolderIdentity,
+		NewHolder:       newHolder,
+	}, true
+}
+
+// appendOutageRecord appends an outage record to the outages ConfigMap for the
+// election, creating the ConfigMap if it does not exist. Once the number of
+// records exceeds the limit, the oldest records are pruned.
+//
+// The ConfigMap is updated with the resource version it was read at, so a
+// record appended concurrently (e.g. by a node which led after this one) is
+// not overwritten. On a conflict, the update is retried.
+func appendOutageRecord(client kubernetes.Interface, namespace, election string, record OutageRecord, limit int) error {
+	name := election + OutagesConfigMapSuffix
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			data, err := marshalOutageRecords([]OutageRecord{record}, limit)
+			if err != nil {
+				return err
+			}
+			_, err = configMaps.Create(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Data: map[string]string{
+					OutagesConfigMapKey: data,
+				},
+			})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		var records []OutageRecord
+		if existing := cm.Data[OutagesConfigMapKey]; existing != "" {
+			if err := json.Unmarshal([]byte(existing), &records); err != nil {
+				return err
+			}
+		}
+
+		data, err := marshalOutageRecords(append(records, record), limit)
+		if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[OutagesConfigMapKey] = data
+		_, err = configMaps.Update(cm)
+		return err
+	})
+}
+
+// marshalOutageRecords marshals the most recent outage records, up to the limit.
+func marshalOutageRecords(records []OutageRecord, limit int) (string, error) {
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// recordOutage records the outage which ended when this node acquired the
+// lock, if it exceeded the outage threshold.
+//
+// Recording outages never affects the election, so failures are only logged.
+func (node *ElectorNode) recordOutage(client kubernetes.Interface, previous *resourcelock.LeaderElectionRecord) {
+	record, ok := newOutageRecord(previous, node.config.ID, node.clock.Now(), node.config.OutageThreshold)
+	if !ok {
+		return
+	}
+
+	node.log.Infof("recording election outage: %s without a leader (previous holder: %s)",
+		time.Duration(record.DurationSeconds*float64(time.Second)), record.PreviousHolder)
+	if err := appendOutageRecord(client, node.config.LockNamespace, node.config.Name, record, node.config.OutageRecordLimit); err != nil {
+		node.log.Errorf("failed to record election outage: %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// getOutageRecords gets the outage records stored in the outages ConfigMap.
+func getOutageRecords(t *testing.T, client *fake.Clientset) []OutageRecord {
+	cm, err := client.CoreV1().ConfigMaps("test-ns").Get("test-election-outages", metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	var records []OutageRecord
+	assert.NoError(t, json.Unmarshal([]byte(cm.Data[OutagesConfigMapKey]), &records))
+	return records
+}
+
+func TestNewOutageRecord(t *testing.T) {
+	now := time.Now()
+	previous := &resourcelock.LeaderElectionRecord{
+		HolderIdentity: "test-node-2",
+		RenewTime:      metav1.NewTime(now.Add(-30 * time.Second)),
+	}
+
+	record, ok := newOutageRecord(previous, "test-node-1", now, 10*time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, "test-node-2", record.PreviousHolder)
+	assert.Equal(t, "test-node-1", record.NewHolder)
+	assert.Equal(t, Timestamp(now), record.End)
+	assert.InDelta(t, 30, record.DurationSeconds, 0.001)
+}
+
+func TestNewOutageRecord_notRecorded(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		description string
+		previous    *resourcelock.LeaderElectionRecord
+	}{
+		{
+			description: "no previous record",
+			previous:    nil,
+		},
+		{
+			description: "previous record has no holder",
+			previous: &resourcelock.LeaderElectionRecord{
+				RenewTime: metav1.NewTime(now.Add(-30 * time.Second)),
+			},
+		},
+		{
+			description: "previous record has no renew time",
+			previous: &resourcelock.LeaderElectionRecord{
+				HolderIdentity: "test-node-2",
+			},
+		},
+		{
+			description: "outage is within the threshold",
+			previous: &resourcelock.LeaderElectionRecord{
+				HolderIdentity: "test-node-2",
+				RenewTime:      metav1.NewTime(now.Add(-5 * time.Second)),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		_, ok := newOutageRecord(c.previous, "test-node-1", now, 10*time.Second)
+		assert.False(t, ok, c.description)
+	}
+}
+
+func TestAppendOutageRecord_create(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	err := appendOutageRecord(client, "test-ns", "test-election", OutageRecord{PreviousHolder: "a", NewHolder: "b"}, 5)
+	assert.NoError(t, err)
+
+	records := getOutageRecords(t, client)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "a", records[0].PreviousHolder)
+	assert.Equal(t, "b", records[0].NewHolder)
+}
+
+func TestAppendOutageRecord_append(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-election-outages",
+			Namespace: "test-ns",
+		},
+		Data: map[string]string{
+			OutagesConfigMapKey: `[{"previous_holder":"a","new_holder":"b"}]`,
+		},
+	})
+
+	err := appendOutageRecord(client, "test-ns", "test-election", OutageRecord{PreviousHolder: "b", NewHolder: "c"}, 5)
+	assert.NoError(t, err)
+
+	records := getOutageRecords(t, client)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "a", records[0].PreviousHolder)
+	assert.Equal(t, "b", records[1].PreviousHolder)
+}
+
+func TestAppendOutageRecord_conflict(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-election-outages",
+			Namespace: "test-ns",
+		},
+		Data: map[string]string{
+			OutagesConfigMapKey: `[{"previous_holder":"a","new_holder":"b"}]`,
+		},
+	}
+	client := fake.NewSimpleClientset(cm)
+
+	// Another node appends its record between the read and the update, so
+	// the first update conflicts.
+	conflicted := false
+	client.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicted {
+			return false, nil, nil
+		}
+		conflicted = true
+		concurrent := cm.DeepCopy()
+		concurrent.Data[OutagesConfigMapKey] = `[{"previous_holder":"a","new_holder":"b"},{"previous_holder":"b","new_holder":"c"}]`
+		assert.NoError(t, client.Tracker().Update(corev1.SchemeGroupVersion.WithResource("configmaps"), concurrent, "test-ns"))
+		return true, nil, apierrors.NewConflict(corev1.Resource("configmaps"), "test-election-outages", assert.AnError)
+	})
+
+	err := appendOutageRecord(client, "test-ns", "test-election", OutageRecord{PreviousHolder: "c", NewHolder: "d"}, 5)
+	assert.NoError(t, err)
+	assert.True(t, conflicted)
+
+	// The update is retried, so neither record is lost.
+	records := getOutageRecords(t, client)
+	assert.Len(t, records, 3)
+	assert.Equal(t, "b", records[1].PreviousHolder)
+	assert.Equal(t, "c", records[2].PreviousHolder)
+}
+
+func TestAppendOutageRecord_prune(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	for i := 0; i < 5; i++ {
+		err := appendOutageRecord(client, "test-ns", "test-election", OutageRecord{NewHolder: fmt.Sprint(i)}, 3)
+		assert.NoError(t, err)
+	}
+
+	// Only the most recent records are kept.
+	records := getOutageRecords(t, client)
+	assert.Len(t, records, 3)
+	assert.Equal(t, "2", records[0].NewHolder)
+	assert.Equal(t, "3", records[1].NewHolder)
+	assert.Equal(t, "4", records[2].NewHolder)
+}
+
+func TestElectorNode_recordOutage_failure(t *testing.T) {
+	buf := &testLogger{}
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-election-outages",
+			Namespace: "test-ns",
+		},
+		Data: map[string]string{
+			OutagesConfigMapKey: "not json",
+		},
+	})
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		Logger:        buf,
+	})
+
+	node.recordOutage(client, &resourcelock.LeaderElectionRecord{
+		HolderIdentity: "test-node-2",
+		RenewTime:      metav1.NewTime(time.Now().Add(-time.Minute)),
+	})
+
+	assert.Contains(t, buf.String(), "failed to record election outage")
+}
@@ -0,0 +1,133 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"context"
+	"net/http"
+)
+
+// pause pauses the node's participation in the election, e.g. to park it for
+// maintenance without stopping it. If the node is the leader, it publishes
+// that it is stepping down, and the current run of the election is withdrawn,
+// which releases the election lock. The node keeps serving HTTP, and does not
+// re-join the election until it is resumed.
+//
+// If the node is already paused, false is returned.
+func (node *ElectorNode) pause() bool {
+	node.mu.Lock()
+	if node.paused {
+		node.mu.Unlock()
+		return false
+	}
+	node.paused = true
+	node.resumed = make(chan struct{})
+	withdraw := node.withdraw
+	node.mu.Unlock()
+
+	node.log.Info("pausing election participation")
+	node.stepDown()
+	if withdraw != nil {
+		withdraw()
+	}
+	return true
+}
+
+// resume resumes the node's participation in the election after it was
+// paused. If the node is not paused, false is returned.
+func (node *ElectorNode) resume() bool {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	if !node.paused {
+		return false
+	}
+	node.log.Info("resuming election participation")
+	node.paused = false
+	close(node.resumed)
+	return true
+}
+
+// isPaused checks whether the node's participation in the election is paused.
+func (node *ElectorNode) isPaused() bool {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.paused
+}
+
+// standFor sets the function which withdraws the node from the current run of
+// the election, so the run can be withdrawn when the node is paused. If the
+// node is already paused, false is returned and the node should not stand.
+func (node *ElectorNode) standFor(withdraw context.CancelFunc) bool {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.withdraw = withdraw
+	return !node.paused
+}
+
+// waitWhilePaused blocks while the node is paused. It returns once the node is
+// resumed, or it is shut down or withdrawn from the election.
+func (node *ElectorNode) waitWhilePaused() {
+	node.mu.RLock()
+	paused, resumed := node.paused, node.resumed
+	node.mu.RUnlock()
+	if !paused {
+		return
+	}
+
+	node.log.Info("election participation paused, waiting to be resumed")
+	select {
+	case <-resumed:
+	case <-node.electionCtx.Done():
+	}
+}
+
+// httpPause is the handler for the endpoint which pauses the node's
+// participation in the election.
+func (node *ElectorNode) httpPause(res http.ResponseWriter, req *http.Request) {
+	node.log.Infof("received pause request from %s", req.RemoteAddr)
+
+	// A single node does not take part in an election, so there is nothing
+	// to pause.
+	if node.config.SingleNode {
+		node.writeJSON(res, http.StatusConflict, MessageResponse{
+			Message: "pausing is not supported for a single node",
+		})
+		return
+	}
+
+	message := "paused election participation"
+	if !node.pause() {
+		message = "election participation is already paused"
+	}
+	node.writeJSON(res, http.StatusOK, MessageResponse{
+		Message: message,
+	})
+}
+
+// httpResume is the handler for the endpoint which resumes the node's
+// participation in the election after it was paused.
+func (node *ElectorNode) httpResume(res http.ResponseWriter, req *http.Request) {
+	node.log.Infof("received resume request from %s", req.RemoteAddr)
+
+	message := "resumed election participation"
+	if !node.resume() {
+		message = "election participation is not paused"
+	}
+	node.writeJSON(res, http.StatusOK, MessageResponse{
+		Message: message,
+	})
+}
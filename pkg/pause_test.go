@@ -0,0 +1,228 @@
+package pkg
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// postAuthorized POSTs to the given path of the node with its auth token.
+func postAuthorized(node *ElectorNode, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, req)
+	return w
+}
+
+func TestElectorNode_httpPause(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		PodName:       "test-pod",
+		LockType:      resourcelock.LeasesResourceLock,
+		TTL:           1 * time.Second,
+		Client:        client,
+		Logger:        &testLogger{},
+		AuthToken:     "secret",
+		// The lock is released when pausing even if it is kept on shutdown.
+		KeepLeaseOnShutdown: true,
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.runUntilError()
+	}()
+	waitFor(t, 5*time.Second, func() bool {
+		return node.IsLeader()
+	})
+
+	w := postAuthorized(node, "/pause")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message":"paused election participation"}`, w.Body.String())
+
+	// The node releases the lock and stays out of the election.
+	holder := func() string {
+		holder, _ := testLockHolder(t, client, resourcelock.LeasesResourceLock, "test-ns", "test-election")
+		return holder
+	}
+	waitFor(t, 5*time.Second, func() bool {
+		return holder() == ""
+	})
+	assert.False(t, node.IsLeader())
+	assert.Equal(t, true, getJSON(t, node, "/healthz")["paused"])
+	time.Sleep(2 * time.Second)
+	assert.Equal(t, "", holder())
+
+	w = postAuthorized(node, "/pause")
+	assert.JSONEq(t, `{"message":"election participation is already paused"}`, w.Body.String())
+
+	// Once resumed, the node re-joins the election.
+	w = postAuthorized(node, "/resume")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"message":"resumed election participation"}`, w.Body.String())
+	assert.Equal(t, false, getJSON(t, node, "/healthz")["paused"])
+	waitFor(t, 5*time.Second, func() bool {
+		return node.IsLeader()
+	})
+
+	w = postAuthorized(node, "/resume")
+	assert.JSONEq(t, `{"message":"election participation is not paused"}`, w.Body.String())
+
+	node.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node did not stop")
+	}
+}
+
+func TestElectorNode_pause_standby(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pause")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	marker := filepath.Join(dir, "demoted")
+
+	client := fake.NewSimpleClientset(
+		newTestPod("test-ns", "test-pod"),
+		newTestLease("test-node-2", time.Now(), 60),
+	)
+	node := NewElectorNode(&ElectorConfig{
+		ID:                   "test-node-1",
+		Name:                 "test-election",
+		Namespace:            "test-ns",
+		LockNamespace:        "test-ns",
+		PodName:              "test-pod",
+		LockType:             resourcelock.LeasesResourceLock,
+		TTL:                  1 * time.Second,
+		Client:               client,
+		Logger:               &testLogger{},
+		OnDemoted:            "touch " + marker,
+		PostDemotionCooldown: time.Minute,
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.runUntilError()
+	}()
+	waitFor(t, 5*time.Second, func() bool {
+		return node.leader() == "test-node-2"
+	})
+
+	// A standby which is withdrawn from the election was never the leader,
+	// so it is not demoted: the on-demoted command is not run and there is
+	// no post-demotion cooldown.
+	assert.True(t, node.pause())
+	time.Sleep(1 * time.Second)
+	_, err = os.Stat(marker)
+	assert.True(t, os.IsNotExist(err))
+	node.mu.RLock()
+	assert.False(t, node.demoted)
+	node.mu.RUnlock()
+	assert.Equal(t, rejoinDelay, node.rerunDelay())
+
+	node.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node did not stop")
+	}
+}
+
+func TestElectorNode_pause_stop(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Logger: &testLogger{},
+	})
+	assert.True(t, node.pause())
+
+	done := make(chan struct{})
+	go func() {
+		node.waitWhilePaused()
+		close(done)
+	}()
+
+	// A paused node stops waiting when it is shut down.
+	node.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node did not stop waiting")
+	}
+}
+
+func TestElectorNode_standFor(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Logger: &testLogger{},
+	})
+
+	withdrawn := false
+	assert.True(t, node.standFor(func() { withdrawn = true }))
+
+	// Pausing withdraws the node from the current run of the election, and
+	// it does not stand again until resumed.
+	assert.True(t, node.pause())
+	assert.True(t, withdrawn)
+	assert.False(t, node.standFor(func() {}))
+
+	assert.True(t, node.resume())
+	assert.True(t, node.standFor(func() {}))
+}
+
+func TestElectorNode_httpPause_errors(t *testing.T) {
+	cases := []struct {
+		description string
+		path        string
+		token       string
+		singleNode  bool
+		status      int
+	}{
+		{
+			description: "pause, unauthorized",
+			path:        "/pause",
+			status:      http.StatusUnauthorized,
+		},
+		{
+			description: "resume, unauthorized",
+			path:        "/resume",
+			status:      http.StatusUnauthorized,
+		},
+		{
+			description: "pause, single node",
+			path:        "/pause",
+			token:       "secret",
+			singleNode:  true,
+			status:      http.StatusConflict,
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:         "test-node-1",
+			Logger:     &testLogger{},
+			AuthToken:  "secret",
+			SingleNode: c.singleNode,
+		})
+
+		req := httptest.NewRequest(http.MethodPost, c.path, nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		w := httptest.NewRecorder()
+		node.mux().ServeHTTP(w, req)
+		assert.Equal(t, c.status, w.Code, c.description)
+		assert.False(t, node.isPaused(), c.description)
+	}
+}
@@ -0,0 +1,245 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"net/http"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// permissionsCacheTTL is how long the results of the permission checks are
+// reported at /permissions before they are checked again.
+const permissionsCacheTTL = 1 * time.Minute
+
+// PermissionsInfo describes whether the elector node's service account has the
+// permissions the node needs, as reviewed by the API server.
+type PermissionsInfo struct {
+	Allowed     bool         `json:"allowed" description:"Whether all of the required permissions are allowed. False until they have been checked."`
+	Permissions []Permission `json:"permissions" description:"The permissions the node needs for its configuration, and whether each is allowed."`
+	CheckedAt   Timestamp    `json:"checked_at" description:"The timestamp for when the permissions were last checked. Empty until they have been checked, when a run of the election starts."`
+}
+
+// Permission describes a permission the elector node needs: a verb on a
+// resource, in a namespace unless the resource is cluster-scoped.
+type Permission struct {
+	Verb        string `json:"verb" description:"The verb of the permission, e.g. get."`
+	Group       string `json:"group" description:"The API group of the resource. Empty for the core group."`
+	Resource    string `json:"resource" description:"The name of the resource."`
+	Subresource string `json:"subresource,omitempty" description:"The subresource, if any, e.g. status."`
+	Namespace   string `json:"namespace,omitempty" description:"The namespace the permission is needed in. Empty for cluster-scoped resources."`
+	Purpose     string `json:"purpose" description:"What the node needs the permission for, e.g. election lock."`
+	Allowed     bool   `json:"allowed" description:"Whether the permission is allowed. False until it has been checked."`
+	Unknown     bool   `json:"unknown,omitempty" description:"Whether the permission could not be checked, e.g. because the API server did not answer the review."`
+	Reason      string `json:"reason,omitempty" description:"The reason the permission is allowed or denied, as given by the API server's authorizer, if any."`
+	Error       string `json:"error,omitempty" description:"The error from checking the permission, if any."`
+}
+
+// requiredPermissions gets the permissions the elector node needs for its
+// configuration. Each permission is only listed once, for the first purpose
+// which needs it.
+func requiredPermissions(conf *ElectorConfig) []Permission {
+	var permissions []Permission
+	seen := map[Permission]bool{}
+	add := func(purpose, group, resource, subresource, namespace string, verbs ...string) {
+		for _, verb := range verbs {
+			p := Permission{
+				Verb:        verb,
+				Group:       group,
+				Resource:    resource,
+				Subresource: subresource,
+				Namespace:   namespace,
+			}
+			if seen[p] {
+				continue
+			}
+			seen[p] = true
+			p.Purpose = purpose
+			permissions = append(permissions, p)
+		}
+	}
+
+	for _, gvr := range lockResources(conf) {
+		add("election lock", gvr.Group, gvr.Resource, "", conf.LockNamespace, "get", "create", "update")
+		if conf.RecordHistory {
+			add("leader history", gvr.Group, gvr.Resource, "", conf.LockNamespace, "patch")
+		}
+	}
+	if conf.publisherEnabled(PublisherPodLabel) {
+		add("pod label", corev1.GroupName, "pods", "", conf.Namespace, "patch")
+		if conf.PodCache {
+			add("pod cache", corev1.GroupName, "pods", "", conf.Namespace, "list", "watch")
+		} else if conf.RespectForeignLabels {
+			add("foreign label check", corev1.GroupName, "pods", "", conf.Namespace, "get")
+		}
+	}
+	if conf.publisherEnabled(PublisherStatusObject) {
+		gvr := conf.StatusObjectResource
+		add("status object", gvr.Group, gvr.Resource, "", conf.StatusObjectNamespace, "get")
+		add("status object", gvr.Group, gvr.Resource, "status", conf.StatusObjectNamespace, "patch")
+	}
+	if conf.publisherEnabled(PublisherExternalDNS) {
+		add("external-dns service", corev1.GroupName, "services", "", conf.Namespace, "get", "patch")
+	}
+	if conf.RecordOutages {
+		add("outage records", corev1.GroupName, "configmaps", "", conf.LockNamespace, "get", "create", "update")
+	}
+	if conf.VerifyNamespace {
+		add("namespace verification", corev1.GroupName, "namespaces", "", "", "get")
+	}
+	return permissions
+}
+
+// reviewPermission creates a SelfSubjectAccessReview for the permission.
+//
+// A client without an authorizer, such as a fake clientset, may not answer the
+// review at all; the fake clientset panics on it. The review is then nil, so
+// the check can never fail or stop the node.
+func reviewPermission(client kubernetes.Interface, p Permission) (review *authorizationv1.SelfSubjectAccessReview, err error) {
+	defer func() {
+		if recover() != nil {
+			review, err = nil, nil
+		}
+	}()
+	return client.AuthorizationV1().SelfSubjectAccessReviews().Create(&authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   p.Namespace,
+				Verb:        p.Verb,
+				Group:       p.Group,
+				Resource:    p.Resource,
+				Subresource: p.Subresource,
+			},
+		},
+	})
+}
+
+// reviewPermissions checks whether the node's service account has each of the
+// permissions it needs, with a SelfSubjectAccessReview per permission.
+//
+// A permission whose review fails or is not answered is unknown. The API
+// server echoes the reviewed attributes back, so an empty review is taken as
+// unanswered too.
+func (node *ElectorNode) reviewPermissions(client kubernetes.Interface) []Permission {
+	permissions := requiredPermissions(node.config)
+	for i, p := range permissions {
+		review, err := reviewPermission(client, p)
+		switch {
+		case err != nil:
+			permissions[i].Unknown = true
+			permissions[i].Error = err.Error()
+		case review == nil || review.Spec.ResourceAttributes == nil:
+			permissions[i].Unknown = true
+		default:
+			permissions[i].Allowed = review.Status.Allowed
+			permissions[i].Reason = review.Status.Reason
+		}
+	}
+	return permissions
+}
+
+// checkPermissions checks whether the node's service account has the
+// permissions it needs.
+//
+// This is done in the background when a run of the election starts, and the
+// client is kept so /permissions can check again once the results are stale.
+// A permission which is not allowed is only logged, since the step which needs
+// it reports its failure.
+func (node *ElectorNode) checkPermissions(client kubernetes.Interface) {
+	permissions := node.reviewPermissions(client)
+	for _, p := range permissions {
+		switch {
+		case p.Error != "":
+			node.log.Warningf("unable to check permission to %s %s (%s): %s", p.Verb, describePermission(p), p.Purpose, p.Error)
+		case p.Unknown:
+			node.log.Infof("unable to check permission to %s %s (%s): the review was not answered", p.Verb, describePermission(p), p.Purpose)
+		case !p.Allowed:
+			node.log.Warningf("not permitted to %s %s (%s)", p.Verb, describePermission(p), p.Purpose)
+		}
+	}
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.permissionsClient = client
+	node.permissions = permissions
+	node.permissionsChecked = node.clock.Now()
+}
+
+// describePermission describes the resource of a permission for logging.
+func describePermission(p Permission) string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	if p.Subresource != "" {
+		resource += "/" + p.Subresource
+	}
+	if p.Namespace != "" {
+		resource += " in namespace " + p.Namespace
+	}
+	return resource
+}
+
+// permissionsInfo gets whether the node has the permissions it needs. Once
+// the results of the last check are older than the cache TTL, the permissions
+// are checked again.
+func (node *ElectorNode) permissionsInfo() PermissionsInfo {
+	node.mu.RLock()
+	client := node.permissionsClient
+	permissions := node.permissions
+	checked := node.permissionsChecked
+	node.mu.RUnlock()
+
+	if client != nil && node.clock.Since(checked) >= permissionsCacheTTL {
+		permissions = node.reviewPermissions(client)
+		checked = node.clock.Now()
+
+		node.mu.Lock()
+		node.permissions = permissions
+		node.permissionsChecked = checked
+		node.mu.Unlock()
+	}
+
+	// Until the permissions are checked, they are reported as they are
+	// required by the configuration.
+	if checked.IsZero() {
+		permissions = requiredPermissions(node.config)
+	}
+	if permissions == nil {
+		permissions = []Permission{}
+	}
+
+	allowed := !checked.IsZero()
+	for _, p := range permissions {
+		allowed = allowed && p.Allowed
+	}
+
+	return PermissionsInfo{
+		Allowed:     allowed,
+		Permissions: permissions,
+		CheckedAt:   Timestamp(checked),
+	}
+}
+
+// httpPermissions is the handler for the endpoint which provides whether the
+// node has the permissions it needs.
+func (node *ElectorNode) httpPermissions(res http.ResponseWriter, req *http.Request) {
+	node.writeJSON(res, http.StatusOK, node.permissionsInfo())
+}
@@ -0,0 +1,255 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// accessReviewer answers SelfSubjectAccessReviews, allowing every verb on
+// every resource except for the denied ones, and counting the reviews.
+type accessReviewer struct {
+	denied  map[string]bool
+	reviews int
+}
+
+func (r *accessReviewer) react(action k8stesting.Action) (bool, runtime.Object, error) {
+	review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+	attrs := review.Spec.ResourceAttributes
+	r.reviews++
+	review.Status.Allowed = !r.denied[attrs.Verb+" "+attrs.Resource]
+	if !review.Status.Allowed {
+		review.Status.Reason = "denied by test"
+	}
+	return true, review, nil
+}
+
+func TestRequiredPermissions(t *testing.T) {
+	cases := []struct {
+		description string
+		config      *ElectorConfig
+		expected    []string
+	}{
+		{
+			description: "leases lock",
+			config: &ElectorConfig{
+				LockType:      resourcelock.LeasesResourceLock,
+				LockNamespace: "test-ns",
+				Namespace:     "test-ns",
+			},
+			expected: []string{
+				"get leases.coordination.k8s.io in namespace test-ns (election lock)",
+				"create leases.coordination.k8s.io in namespace test-ns (election lock)",
+				"update leases.coordination.k8s.io in namespace test-ns (election lock)",
+				"patch pods in namespace test-ns (pod label)",
+			},
+		},
+		{
+			description: "leases lock, respecting foreign labels",
+			config: &ElectorConfig{
+				LockType:             resourcelock.LeasesResourceLock,
+				LockNamespace:        "test-ns",
+				Namespace:            "test-ns",
+				RespectForeignLabels: true,
+			},
+			expected: []string{
+				"get leases.coordination.k8s.io in namespace test-ns (election lock)",
+				"create leases.coordination.k8s.io in namespace test-ns (election lock)",
+				"update leases.coordination.k8s.io in namespace test-ns (election lock)",
+				"patch pods in namespace test-ns (pod label)",
+				"get pods in namespace test-ns (foreign label check)",
+			},
+		},
+		{
+			description: "external, with outages and namespace verification",
+			config: &ElectorConfig{
+				LockType:        resourcelock.ConfigMapsResourceLock,
+				LockNamespace:   "test-ns",
+				External:        true,
+				RecordOutages:   true,
+				VerifyNamespace: true,
+			},
+			expected: []string{
+				"get configmaps in namespace test-ns (election lock)",
+				"create configmaps in namespace test-ns (election lock)",
+				"update configmaps in namespace test-ns (election lock)",
+				"get namespaces (namespace verification)",
+			},
+		},
+		{
+			description: "status object and history",
+			config: &ElectorConfig{
+				LockType:              resourcelock.LeasesResourceLock,
+				LockNamespace:         "test-ns",
+				External:              true,
+				RecordHistory:         true,
+				StatusObjectResource:  testStatusResource,
+				StatusObjectName:      "my-election",
+				StatusObjectNamespace: "other-ns",
+			},
+			expected: []string{
+				"get leases.coordination.k8s.io in namespace test-ns (election lock)",
+				"create leases.coordination.k8s.io in namespace test-ns (election lock)",
+				"update leases.coordination.k8s.io in namespace test-ns (election lock)",
+				"patch leases.coordination.k8s.io in namespace test-ns (leader history)",
+				"get elections.apps.example.com in namespace other-ns (status object)",
+				"patch elections.apps.example.com/status in namespace other-ns (status object)",
+			},
+		},
+		{
+			description: "external-dns service",
+			config: &ElectorConfig{
+				LockType:           resourcelock.LeasesResourceLock,
+				LockNamespace:      "test-ns",
+				Namespace:          "test-ns",
+				DisabledPublishers: []string{PublisherPodLabel},
+				ExternalDNSService: "test-leader",
+			},
+			expected: []string{
+				"get leases.coordination.k8s.io in namespace test-ns (election lock)",
+				"create leases.coordination.k8s.io in namespace test-ns (election lock)",
+				"update leases.coordination.k8s.io in namespace test-ns (election lock)",
+				"get services in namespace test-ns (external-dns service)",
+				"patch services in namespace test-ns (external-dns service)",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		var actual []string
+		for _, p := range requiredPermissions(c.config) {
+			actual = append(actual, p.Verb+" "+describePermission(p)+" ("+p.Purpose+")")
+		}
+		assert.Equal(t, c.expected, actual, c.description)
+	}
+}
+
+func TestElectorNode_checkPermissions(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	reviewer := &accessReviewer{denied: map[string]bool{"patch pods": true}}
+	client.PrependReactor("create", "selfsubjectaccessreviews", reviewer.react)
+
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		LockType:      resourcelock.LeasesResourceLock,
+		Logger:        log,
+	})
+	now := time.Date(2019, 5, 2, 18, 28, 51, 0, time.UTC)
+	clk := clock.NewFakeClock(now)
+	node.clock = clk
+
+	// Until they are checked, the permissions are reported as required.
+	info := node.permissionsInfo()
+	assert.False(t, info.Allowed)
+	assert.Len(t, info.Permissions, 4)
+	assert.Equal(t, Timestamp{}, info.CheckedAt)
+
+	node.checkPermissions(client)
+	assert.Equal(t, 4, reviewer.reviews)
+	assert.Contains(t, log.String(), "WARNING [election=test-election id=test-node-1] not permitted to patch pods in namespace test-ns (pod label)")
+
+	info = node.permissionsInfo()
+	assert.False(t, info.Allowed)
+	assert.Equal(t, Timestamp(now), info.CheckedAt)
+	for _, p := range info.Permissions {
+		assert.Equal(t, p.Resource != "pods", p.Allowed, p.Verb+" "+p.Resource)
+	}
+	assert.Equal(t, "denied by test", info.Permissions[3].Reason)
+
+	// The results are cached until they are stale.
+	assert.Equal(t, 4, reviewer.reviews)
+	delete(reviewer.denied, "patch pods")
+	clk.Step(permissionsCacheTTL)
+	info = node.permissionsInfo()
+	assert.Equal(t, 8, reviewer.reviews)
+	assert.True(t, info.Allowed)
+	assert.Equal(t, Timestamp(now.Add(permissionsCacheTTL)), info.CheckedAt)
+}
+
+func TestElectorNode_checkPermissions_error(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &authorizationv1.SelfSubjectAccessReview{}, assert.AnError
+	})
+
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		LockNamespace: "test-ns",
+		LockType:      resourcelock.LeasesResourceLock,
+		External:      true,
+		Logger:        log,
+	})
+
+	node.checkPermissions(client)
+	info := node.permissionsInfo()
+	assert.False(t, info.Allowed)
+	for _, p := range info.Permissions {
+		assert.False(t, p.Allowed)
+		assert.True(t, p.Unknown)
+		assert.Equal(t, assert.AnError.Error(), p.Error)
+	}
+	assert.Contains(t, log.String(), "WARNING [id=test-node-1] unable to check permission to get leases.coordination.k8s.io in namespace test-ns (election lock)")
+}
+
+func TestElectorNode_checkPermissions_unanswered(t *testing.T) {
+	cases := []struct {
+		description string
+		react       k8stesting.ReactionFunc
+		unknown     int
+	}{
+		{
+			// The fake clientset stores the first review like any other
+			// object, echoing it back, and panics on the rest.
+			description: "no authorizer",
+			unknown:     2,
+		},
+		{
+			description: "empty review",
+			react: func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, &authorizationv1.SelfSubjectAccessReview{}, nil
+			},
+			unknown: 3,
+		},
+	}
+
+	for _, c := range cases {
+		client := fake.NewSimpleClientset()
+		if c.react != nil {
+			client.PrependReactor("create", "selfsubjectaccessreviews", c.react)
+		}
+		log := &testLogger{}
+		node := NewElectorNode(&ElectorConfig{
+			ID:            "test-node-1",
+			LockNamespace: "test-ns",
+			LockType:      resourcelock.LeasesResourceLock,
+			External:      true,
+			Logger:        log,
+		})
+
+		assert.NotPanics(t, func() { node.checkPermissions(client) }, c.description)
+		info := node.permissionsInfo()
+		assert.False(t, info.Allowed, c.description)
+		unknown := 0
+		for _, p := range info.Permissions {
+			assert.False(t, p.Allowed, c.description)
+			assert.Empty(t, p.Error, c.description)
+			if p.Unknown {
+				unknown++
+			}
+		}
+		assert.Equal(t, c.unknown, unknown, c.description)
+		assert.Contains(t, log.String(), "INFO [id=test-node-1] unable to check permission to update leases.coordination.k8s.io in namespace test-ns (election lock): the review was not answered", c.description)
+	}
+}
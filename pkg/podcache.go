@@ -0,0 +1,191 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// podCacheSyncTimeout is how long the pod cache waits for its informer to list
+// the node's Pod before falling back to reading it directly.
+const podCacheSyncTimeout = 10 * time.Second
+
+// podCache serves reads of the elector node's Pod for the Pod-coupled
+// features, from a single-object informer which watches the Pod by name.
+//
+// If the informer can not be started, e.g. because the node's service account
+// is not permitted to watch Pods, the Pod is read with a direct GET instead,
+// and no update notifications are made.
+type podCache struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+
+	informer cache.SharedIndexInformer
+	stopCh   chan struct{}
+}
+
+// newPodCache creates a cache of the named Pod. The cache reads the Pod
+// directly until it is started.
+func newPodCache(client kubernetes.Interface, namespace, name string) *podCache {
+	return &podCache{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+// start starts the informer which watches the Pod, and waits for it to list
+// the Pod. If the Pod can not be listed and watched, an error is returned and
+// the cache keeps reading the Pod directly.
+//
+// The Pod is listed and watched once before the informer is started, since
+// the informer itself retries a denied list or watch indefinitely rather
+// than failing.
+func (c *podCache) start(timeout time.Duration) error {
+	selector := fields.OneTermEqualSelector("metadata.name", c.name).String()
+	pods := c.client.CoreV1().Pods(c.namespace)
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return pods.List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return pods.Watch(options)
+		},
+	}
+
+	if _, err := lw.List(metav1.ListOptions{}); err != nil {
+		return err
+	}
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	w.Stop()
+
+	informer := cache.NewSharedIndexInformer(lw, &corev1.Pod{}, 0, cache.Indexers{})
+	stopCh := make(chan struct{})
+	go informer.Run(stopCh)
+
+	err = wait.PollImmediate(10*time.Millisecond, timeout, func() (bool, error) {
+		return informer.HasSynced(), nil
+	})
+	if err != nil {
+		close(stopCh)
+		return err
+	}
+	c.informer = informer
+	c.stopCh = stopCh
+	return nil
+}
+
+// stop stops the informer, if it was started. It is safe to call on a nil
+// cache.
+func (c *podCache) stop() {
+	if c == nil || c.stopCh == nil {
+		return
+	}
+	close(c.stopCh)
+	c.stopCh = nil
+}
+
+// cached checks whether the Pod is served from the informer, rather than read
+// directly.
+func (c *podCache) cached() bool {
+	return c.informer != nil
+}
+
+// get gets the Pod, from the informer if it was started, and with a direct
+// GET otherwise.
+func (c *podCache) get() (*corev1.Pod, error) {
+	if !c.cached() {
+		return c.client.CoreV1().Pods(c.namespace).Get(c.name, metav1.GetOptions{})
+	}
+
+	obj, exists, err := c.informer.GetStore().GetByKey(c.namespace + "/" + c.name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, apierrors.NewNotFound(corev1.Resource("pods"), c.name)
+	}
+	return obj.(*corev1.Pod), nil
+}
+
+// onUpdate registers a handler for updates to the Pod. Updates are only
+// notified while the Pod is served from the informer.
+func (c *podCache) onUpdate(handler func(pod *corev1.Pod)) {
+	if !c.cached() {
+		return
+	}
+	c.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, obj interface{}) {
+			if pod, ok := obj.(*corev1.Pod); ok {
+				handler(pod)
+			}
+		},
+	})
+}
+
+// startPodCache starts the cache of the node's Pod for a run of the election,
+// if it is enabled and a Pod-coupled feature needs the Pod. Otherwise, nil is
+// returned.
+func (node *ElectorNode) startPodCache(client kubernetes.Interface) *podCache {
+	if node.passive || !node.config.PodCache || !node.config.publisherEnabled(PublisherPodLabel) {
+		return nil
+	}
+
+	pods := newPodCache(client, node.config.Namespace, node.config.PodName)
+	if err := pods.start(podCacheSyncTimeout); err != nil {
+		node.log.Warningf("unable to watch pod %s, reading it directly instead: %v", node.config.PodName, err)
+	}
+
+	node.mu.Lock()
+	node.pods = pods
+	node.mu.Unlock()
+	return pods
+}
+
+// stopPodCache stops the cache of the node's Pod from a run of the election
+// which has stopped.
+func (node *ElectorNode) stopPodCache(pods *podCache) {
+	pods.stop()
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.pods = nil
+}
+
+// podCache gets the cache of the node's Pod for the current run of the
+// election, if any.
+func (node *ElectorNode) podCache() *podCache {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.pods
+}
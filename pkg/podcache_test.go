@@ -0,0 +1,164 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// countActions counts the actions of the fake client with the given verb on
+// Pods.
+func countActions(client *fake.Clientset, verb string) int {
+	count := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == verb && action.GetResource().Resource == "pods" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestPodCache_cached(t *testing.T) {
+	pod := newTestPod("test-ns", "test-pod")
+	pod.Labels = map[string]string{PodLabelKey: StatusLeader}
+	client := fake.NewSimpleClientset(pod, newTestPod("test-ns", "other-pod"))
+
+	pods := newPodCache(client, "test-ns", "test-pod")
+	assert.NoError(t, pods.start(time.Second))
+	defer pods.stop()
+	assert.True(t, pods.cached())
+
+	// Reads are served from the informer, without a request for each.
+	client.ClearActions()
+	for i := 0; i < 3; i++ {
+		actual, err := pods.get()
+		assert.NoError(t, err)
+		assert.Equal(t, "test-pod", actual.Name)
+		assert.Equal(t, StatusLeader, actual.Labels[PodLabelKey])
+	}
+	assert.Zero(t, countActions(client, "get"))
+
+	// A label which is already current is not patched.
+	p := &podLabelPublisher{
+		config: &ElectorConfig{Namespace: "test-ns", PodName: "test-pod"},
+		client: client,
+		pods:   pods,
+	}
+	assert.NoError(t, p.publish(StatusLeader))
+	assert.Zero(t, countActions(client, "patch"))
+	assert.NoError(t, p.publish(StatusStandby))
+	assert.Equal(t, 1, countActions(client, "patch"))
+}
+
+func TestPodCache_fallback(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	client.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		return true, nil, apierrors.NewForbidden(corev1.Resource("pods"), "", assert.AnError)
+	})
+
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:        "test-node-1",
+		Namespace: "test-ns",
+		PodName:   "test-pod",
+		PodCache:  true,
+		Logger:    log,
+	})
+
+	pods := node.startPodCache(client)
+	defer node.stopPodCache(pods)
+	assert.False(t, pods.cached())
+	assert.Contains(t, log.String(), "WARNING [id=test-node-1] unable to watch pod test-pod, reading it directly instead")
+
+	// The Pod is read directly instead.
+	client.ClearActions()
+	actual, err := pods.get()
+	assert.NoError(t, err)
+	assert.Equal(t, "test-pod", actual.Name)
+	assert.Equal(t, 1, countActions(client, "get"))
+
+	// Without the informer, the label is patched without reading the Pod.
+	client.ClearActions()
+	p := node.newPublishers(client)[0]
+	p.publishNow(StatusLeader)
+	assert.Zero(t, countActions(client, "get"))
+	assert.Equal(t, 1, countActions(client, "patch"))
+}
+
+func TestElectorNode_startPodCache_disabled(t *testing.T) {
+	cases := []struct {
+		description string
+		config      *ElectorConfig
+	}{
+		{
+			description: "not enabled",
+			config:      &ElectorConfig{Namespace: "test-ns", PodName: "test-pod"},
+		},
+		{
+			description: "pod label not published",
+			config: &ElectorConfig{
+				Namespace:          "test-ns",
+				PodName:            "test-pod",
+				PodCache:           true,
+				DisabledPublishers: []string{PublisherPodLabel},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c.config.Logger = &testLogger{}
+		node := NewElectorNode(c.config)
+		client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+
+		pods := node.startPodCache(client)
+		assert.Nil(t, pods, c.description)
+		assert.Empty(t, client.Actions(), c.description)
+		node.stopPodCache(pods)
+	}
+}
+
+func TestElectorNode_podCache_heal(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:        "test-node-1",
+		Namespace: "test-ns",
+		PodName:   "test-pod",
+		PodCache:  true,
+		Logger:    log,
+	})
+
+	pods := node.startPodCache(client)
+	defer node.stopPodCache(pods)
+	assert.True(t, pods.cached())
+
+	publishers := node.newPublishers(client)
+	assert.Equal(t, "pod label", publishers[0].publisher.name())
+	publishers[0].publishNow(StatusLeader)
+
+	podLabel := func() string {
+		pod, err := client.CoreV1().Pods("test-ns").Get("test-pod", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return pod.Labels[PodLabelKey]
+	}
+	assert.Equal(t, StatusLeader, podLabel())
+
+	// The label is edited outside of the elector, so it is restored.
+	pod, err := client.CoreV1().Pods("test-ns").Get("test-pod", metav1.GetOptions{})
+	assert.NoError(t, err)
+	pod.Labels[PodLabelKey] = StatusStandby
+	_, err = client.CoreV1().Pods("test-ns").Update(pod)
+	assert.NoError(t, err)
+
+	waitFor(t, 5*time.Second, func() bool {
+		return podLabel() == StatusLeader
+	})
+	assert.Contains(t, log.String(), `pod label k8s-elector/status was changed to "standby" outside of the elector; restoring it to "leader"`)
+}
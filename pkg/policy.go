@@ -0,0 +1,163 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Candidate describes an elector node which is about to contend for leadership.
+type Candidate struct {
+	// ID is the ID of the elector node.
+	ID string
+
+	// Election is the name of the election.
+	Election string
+
+	// Namespace is the namespace of the elector node's Pod.
+	Namespace string
+
+	// PodName is the name of the elector node's Pod.
+	PodName string
+
+	// Holder is the identity recorded on the election lock when the node
+	// contends for it, e.g. the leader whose lease expired. It is empty if
+	// the lock was released or does not exist yet.
+	Holder string
+}
+
+// SelectionPolicy influences which candidate wins the election.
+//
+// By default, the election is first-come-first-served: whichever node first
+// sees that the lock is free takes it. A SelectionPolicy is consulted every time
+// the node tries to acquire the lock, and may delay the attempt. Candidates which
+// wait less are more likely to win, so e.g. delaying based on a score of each
+// node gives priority-based leadership.
+type SelectionPolicy interface {
+	// AcquireDelay gets how long the candidate waits before trying to acquire
+	// the election lock.
+	AcquireDelay(candidate Candidate) time.Duration
+}
+
+// SelectionPolicyFunc is an adapter to allow the use of an ordinary function
+// as a SelectionPolicy.
+type SelectionPolicyFunc func(candidate Candidate) time.Duration
+
+// AcquireDelay calls f(candidate).
+func (f SelectionPolicyFunc) AcquireDelay(candidate Candidate) time.Duration {
+	return f(candidate)
+}
+
+// NoopSelectionPolicy is the default SelectionPolicy. It never delays trying
+// to acquire the lock, so the election remains first-come-first-served.
+type NoopSelectionPolicy struct{}
+
+// AcquireDelay always returns zero.
+func (NoopSelectionPolicy) AcquireDelay(Candidate) time.Duration {
+	return 0
+}
+
+// selectionLock wraps the resourcelock.Interface used for the election to
+// consult the selection policy before each attempt to acquire the lock.
+type selectionLock struct {
+	resourcelock.Interface
+
+	ctx       context.Context
+	log       logger
+	policy    SelectionPolicy
+	candidate Candidate
+
+	mu     sync.Mutex
+	holder string
+}
+
+// newSelectionLock wraps the given lock to apply the selection policy.
+func newSelectionLock(ctx context.Context, lock resourcelock.Interface, policy SelectionPolicy, candidate Candidate, log logger) *selectionLock {
+	return &selectionLock{
+		Interface: lock,
+		ctx:       ctx,
+		log:       log,
+		policy:    policy,
+		candidate: candidate,
+	}
+}
+
+// Get gets the lock record, keeping track of its holder.
+func (l *selectionLock) Get() (*resourcelock.LeaderElectionRecord, []byte, error) {
+	record, raw, err := l.Interface.Get()
+	if err == nil && record != nil {
+		l.mu.Lock()
+		l.holder = record.HolderIdentity
+		l.mu.Unlock()
+	}
+	return record, raw, err
+}
+
+// Create creates the lock record. This acquires the lock, so it is delayed
+// per the selection policy.
+func (l *selectionLock) Create(ler resourcelock.LeaderElectionRecord) error {
+	l.mu.Lock()
+	l.holder = ""
+	l.mu.Unlock()
+
+	if err := l.wait(); err != nil {
+		return err
+	}
+	return l.Interface.Create(ler)
+}
+
+// Update updates the lock record. If the update acquires the lock from another
+// holder (rather than renewing or releasing it), it is delayed per the selection
+// policy.
+func (l *selectionLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	l.mu.Lock()
+	acquiring := ler.HolderIdentity == l.Identity() && l.holder != l.Identity()
+	l.mu.Unlock()
+
+	if acquiring {
+		if err := l.wait(); err != nil {
+			return err
+		}
+	}
+	return l.Interface.Update(ler)
+}
+
+// wait waits for the delay given by the selection policy. If the context is
+// cancelled while waiting, its error is returned.
+func (l *selectionLock) wait() error {
+	l.mu.Lock()
+	candidate := l.candidate
+	candidate.Holder = l.holder
+	l.mu.Unlock()
+
+	delay := l.policy.AcquireDelay(candidate)
+	if delay <= 0 {
+		return nil
+	}
+
+	l.log.Infof("selection policy: waiting %v before trying to acquire the lock", delay)
+	select {
+	case <-l.ctx.Done():
+		return l.ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
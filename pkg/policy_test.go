@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// testPolicy is a SelectionPolicy which records the candidates it is
+// consulted for.
+type testPolicy struct {
+	mu         sync.Mutex
+	delay      time.Duration
+	candidates []Candidate
+}
+
+func (p *testPolicy) AcquireDelay(candidate Candidate) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.candidates = append(p.candidates, candidate)
+	return p.delay
+}
+
+func TestNoopSelectionPolicy(t *testing.T) {
+	assert.Equal(t, time.Duration(0), NoopSelectionPolicy{}.AcquireDelay(Candidate{ID: "test-node-1"}))
+}
+
+func TestSelectionPolicyFunc(t *testing.T) {
+	policy := SelectionPolicyFunc(func(candidate Candidate) time.Duration {
+		if candidate.ID == "test-node-1" {
+			return time.Second
+		}
+		return 0
+	})
+	assert.Equal(t, time.Second, policy.AcquireDelay(Candidate{ID: "test-node-1"}))
+	assert.Equal(t, time.Duration(0), policy.AcquireDelay(Candidate{ID: "test-node-2"}))
+}
+
+func TestSelectionLock(t *testing.T) {
+	fakeLock := &fakeLock{
+		identity: "test-node-1",
+		record:   &resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2"},
+	}
+	policy := &testPolicy{delay: 10 * time.Millisecond}
+	lock := newSelectionLock(context.Background(), fakeLock, policy, Candidate{
+		ID:       "test-node-1",
+		Election: "test-election",
+	}, logger{out: &testLogger{}})
+
+	// Acquiring the lock from another holder consults the policy.
+	_, _, err := lock.Get()
+	assert.NoError(t, err)
+	err = lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Candidate{{ID: "test-node-1", Election: "test-election", Holder: "test-node-2"}}, policy.candidates)
+
+	// Renewing the lock does not.
+	_, _, err = lock.Get()
+	assert.NoError(t, err)
+	err = lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+	assert.NoError(t, err)
+	assert.Len(t, policy.candidates, 1)
+
+	// Releasing the lock does not.
+	err = lock.Update(resourcelock.LeaderElectionRecord{})
+	assert.NoError(t, err)
+	assert.Len(t, policy.candidates, 1)
+
+	// Creating the lock acquires it, so consults the policy.
+	err = lock.Create(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+	assert.NoError(t, err)
+	assert.Len(t, policy.candidates, 2)
+	assert.Equal(t, "", policy.candidates[1].Holder)
+}
+
+func TestSelectionLock_cancelled(t *testing.T) {
+	fakeLock := &fakeLock{
+		identity: "test-node-1",
+		record:   &resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2"},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lock := newSelectionLock(ctx, fakeLock, &testPolicy{delay: time.Hour}, Candidate{ID: "test-node-1"}, logger{out: &testLogger{}})
+
+	_, _, err := lock.Get()
+	assert.NoError(t, err)
+	err = lock.Update(resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"})
+	assert.Error(t, err)
+	assert.Equal(t, "test-node-2", fakeLock.record.HolderIdentity)
+}
+
+func TestElectorNode_run_selectionPolicy(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		newTestPod("test-ns", "test-pod-1"),
+		newTestPod("test-ns", "test-pod-2"),
+	)
+
+	// The policy delays the first node, so the second node wins.
+	policy := SelectionPolicyFunc(func(candidate Candidate) time.Duration {
+		if candidate.ID == "test-node-1" {
+			return 2 * time.Second
+		}
+		return 0
+	})
+
+	var nodes []*ElectorNode
+	for _, id := range []string{"1", "2"} {
+		node := NewElectorNode(&ElectorConfig{
+			ID:              "test-node-" + id,
+			Name:            "test-election",
+			Namespace:       "test-ns",
+			LockNamespace:   "test-ns",
+			PodName:         "test-pod-" + id,
+			LockType:        resourcelock.LeasesResourceLock,
+			TTL:             1 * time.Second,
+			Client:          client,
+			SelectionPolicy: policy,
+			Logger:          &testLogger{},
+		})
+		nodes = append(nodes, node)
+		go node.run()
+	}
+	defer func() {
+		for _, node := range nodes {
+			node.cancel()
+		}
+	}()
+
+	waitFor(t, 5*time.Second, func() bool {
+		lease, err := client.CoordinationV1().Leases("test-ns").Get("test-election", metav1.GetOptions{})
+		return err == nil && lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != ""
+	})
+	lease, err := client.CoordinationV1().Leases("test-ns").Get("test-election", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "test-node-2", *lease.Spec.HolderIdentity)
+}
@@ -0,0 +1,85 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"net/http"
+
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// PreStopResponse is the response for the pre-stop endpoint.
+type PreStopResponse struct {
+	Message string           `json:"message" description:"A message describing the result of the request."`
+	Summary *ShutdownSummary `json:"summary,omitempty" description:"The summary of the node's life, as of its withdrawal from the election. Not set if the node was already shutting down."`
+}
+
+// drain withdraws the node from the election, e.g. for a Pod's pre-stop hook.
+//
+// If the node is the leader, it publishes that it is stepping down and then
+// releases the election lock, so another node can take over before the Pod is
+// terminated. The node does not re-join the election, but keeps running as a
+// standby until it is shut down. The returned channel is closed once the node
+// has withdrawn.
+func (node *ElectorNode) drain() <-chan struct{} {
+	node.stepDown()
+	node.drainElection()
+	return node.drained
+}
+
+// releaseLock releases the election lock if it is held by the node.
+func (node *ElectorNode) releaseLock(lock resourcelock.Interface) {
+	record, _, err := lock.Get()
+	if err != nil {
+		node.log.Errorf("failed to release the election lock: %v", err)
+		return
+	}
+	if record.HolderIdentity != node.config.ID {
+		return
+	}
+
+	err = lock.Update(resourcelock.LeaderElectionRecord{
+		LeaderTransitions: record.LeaderTransitions,
+	})
+	if err != nil {
+		node.log.Errorf("failed to release the election lock: %v", err)
+		return
+	}
+	node.log.Info("released the election lock")
+}
+
+// httpPreStop withdraws the node from the election for a pre-stop hook. It
+// responds once the node has stepped down and released the election lock, so
+// the hook blocks the Pod's termination until leadership is handed off. The
+// response includes the summary of the node's life as of its withdrawal.
+func (node *ElectorNode) httpPreStop(res http.ResponseWriter, req *http.Request) {
+	node.log.Infof("received pre-stop request from %s", req.RemoteAddr)
+
+	select {
+	case <-node.drain():
+		summary := node.shutdownSummary("withdrawn from the election for a pre-stop hook")
+		node.writeJSON(res, http.StatusOK, PreStopResponse{
+			Message: "withdrawn from the election",
+			Summary: &summary,
+		})
+	case <-node.ctx.Done():
+		node.writeJSON(res, http.StatusOK, PreStopResponse{
+			Message: "shutting down",
+		})
+	case <-req.Context().Done():
+	}
+}
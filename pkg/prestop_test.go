@@ -0,0 +1,152 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestElectorNode_httpPreStop(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		PodName:       "test-pod",
+		LockType:      resourcelock.LeasesResourceLock,
+		TTL:           1 * time.Second,
+		Client:        client,
+		Logger:        &testLogger{},
+		AuthToken:     "secret",
+		// The lock is released for the pre-stop hook even if it is kept on
+		// shutdown.
+		KeepLeaseOnShutdown: true,
+	})
+
+	holder := func() string {
+		lease, err := client.CoordinationV1().Leases("test-ns").Get("test-election", metav1.GetOptions{})
+		if err != nil || lease.Spec.HolderIdentity == nil {
+			return ""
+		}
+		return *lease.Spec.HolderIdentity
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.runUntilError()
+	}()
+	waitFor(t, 5*time.Second, func() bool {
+		return node.IsLeader()
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/prestop", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, req)
+
+	// The response is only sent once the lock is released.
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp PreStopResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "withdrawn from the election", resp.Message)
+	if assert.NotNil(t, resp.Summary) {
+		assert.Equal(t, "test-node-1", resp.Summary.Node)
+		assert.Equal(t, 1, resp.Summary.Acquisitions)
+		assert.Equal(t, "withdrawn from the election for a pre-stop hook", resp.Summary.Reason)
+	}
+	assert.Equal(t, "", holder())
+	assert.False(t, node.IsLeader())
+
+	// The node keeps running as a standby until it is shut down.
+	select {
+	case err := <-done:
+		assert.Fail(t, "node stopped after withdrawing", "%v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	node.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node did not stop")
+	}
+}
+
+func TestElectorNode_httpPreStop_unauthorized(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:        "test-node-1",
+		Logger:    &testLogger{},
+		AuthToken: "secret",
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/prestop", nil)
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// The node did not withdraw from the election.
+	assert.NoError(t, node.electionCtx.Err())
+}
+
+func TestElectorNode_httpPreStop_get(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:        "test-node-1",
+		Logger:    &testLogger{},
+		AuthToken: "secret",
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/prestop", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "POST", w.Header().Get("Allow"))
+
+	// The node did not withdraw from the election.
+	assert.NoError(t, node.electionCtx.Err())
+}
+
+func TestElectorNode_releaseLock(t *testing.T) {
+	cases := []struct {
+		description string
+		holder      string
+		expected    string
+	}{
+		{
+			description: "held by the node",
+			holder:      "test-node-1",
+			expected:    "",
+		},
+		{
+			description: "held by another node",
+			holder:      "test-node-2",
+			expected:    "test-node-2",
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:     "test-node-1",
+			Logger: &testLogger{},
+		})
+		lock := &fakeLock{
+			identity: "test-node-1",
+			record: &resourcelock.LeaderElectionRecord{
+				HolderIdentity:    c.holder,
+				LeaderTransitions: 3,
+			},
+		}
+
+		node.releaseLock(lock)
+		assert.Equal(t, c.expected, lock.record.HolderIdentity, c.description)
+		assert.Equal(t, 3, lock.record.LeaderTransitions, c.description)
+	}
+}
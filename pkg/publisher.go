@@ -0,0 +1,568 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultPublishDebounce is the default debounce window for publishing the
+// elector node's status, as used by the elector command.
+const DefaultPublishDebounce = 2 * time.Second
+
+// stepDownTimeout bounds how long a voluntary shutdown waits for the node's
+// stepping down status to be published before the election lock is released.
+const stepDownTimeout = 5 * time.Second
+
+// The names of the built-in publishers, which can be selected with the
+// Publishers and DisabledPublishers of the ElectorConfig.
+const (
+	// PublisherPodLabel publishes the status to the label of the elector's Pod.
+	PublisherPodLabel = "pod-label"
+
+	// PublisherSubscriptions delivers leadership events to the callback URLs
+	// subscribed through the HTTP API.
+	PublisherSubscriptions = "subscriptions"
+
+	// PublisherWatch delivers leadership events to the /watch streams.
+	PublisherWatch = "watch"
+
+	// PublisherStatusObject publishes the leadership to the status of the
+	// configured status object. It only runs if a status object is set.
+	PublisherStatusObject = "status-object"
+
+	// PublisherExternalDNS publishes the leadership to the annotations and
+	// selector of the Service managed by external-dns. It only runs if an
+	// external-dns Service is set.
+	PublisherExternalDNS = "external-dns"
+)
+
+// PublisherNames are the names of the built-in publishers.
+var PublisherNames = []string{
+	PublisherPodLabel,
+	PublisherSubscriptions,
+	PublisherWatch,
+	PublisherStatusObject,
+	PublisherExternalDNS,
+}
+
+// ParsePublishers parses a comma-separated list of publisher names, e.g.
+// "pod-label,watch". Each name must be one of the PublisherNames.
+func ParsePublishers(value string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if err := validatePublishers(names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// validatePublishers checks that each of the names is the name of a built-in
+// publisher.
+func validatePublishers(names []string) error {
+	for _, name := range names {
+		if !containsPublisher(PublisherNames, name) {
+			return fmt.Errorf("unknown publisher %q: must be one of %s", name, strings.Join(PublisherNames, ", "))
+		}
+	}
+	return nil
+}
+
+// containsPublisher checks whether the publisher name is in the list.
+func containsPublisher(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// publisherEnabled checks whether the built-in publisher with the given name
+// runs. A publisher runs if it is selected, or no publishers are selected,
+// and it is not disabled. A node running outside of Kubernetes has no Pod to
+// label, the status object publisher needs a status object, and the
+// external-dns publisher needs a Service.
+func (conf *ElectorConfig) publisherEnabled(name string) bool {
+	if name == PublisherPodLabel && conf.External {
+		return false
+	}
+	if name == PublisherStatusObject && conf.StatusObjectName == "" {
+		return false
+	}
+	if name == PublisherExternalDNS && conf.ExternalDNSService == "" {
+		return false
+	}
+	if len(conf.Publishers) > 0 && !containsPublisher(conf.Publishers, name) {
+		return false
+	}
+	return !containsPublisher(conf.DisabledPublishers, name)
+}
+
+// activePublishers gets the names of the built-in publishers which run.
+func (conf *ElectorConfig) activePublishers() []string {
+	active := []string{}
+	for _, name := range PublisherNames {
+		if conf.publisherEnabled(name) {
+			active = append(active, name)
+		}
+	}
+	return active
+}
+
+// publisher publishes the leadership status of the elector node outside of
+// the elector, e.g. to the label of its Pod.
+type publisher interface {
+	// name gets the name of the publisher, for logging.
+	name() string
+
+	// publish publishes the status (StatusLeader or StatusStandby).
+	publish(status string) error
+}
+
+// podLabelPublisher publishes the status of the elector node to the label of
+// its Pod.
+//
+// If the Pod is cached, the label is not patched when the cached Pod already
+// has it, and a label changed outside of the elector is restored. A label set
+// by something else is overwritten with a warning, unless foreign labels are
+// respected.
+type podLabelPublisher struct {
+	config *ElectorConfig
+	client kubernetes.Interface
+	pods   *podCache
+	log    logger
+	fault  func() error
+
+	mu     sync.Mutex
+	status string
+}
+
+func (p *podLabelPublisher) name() string {
+	return "pod label"
+}
+
+func (p *podLabelPublisher) publish(status string) error {
+	p.mu.Lock()
+	p.status = status
+	p.mu.Unlock()
+
+	pod, err := p.currentPod()
+	if err != nil {
+		return fmt.Errorf("failed to read pod to check its status label: %w", err)
+	}
+	if pod != nil {
+		if podLabelCurrent(p.config, pod, status) {
+			return nil
+		}
+		if err := p.checkForeignLabel(pod, status); err != nil {
+			return err
+		}
+	}
+	return p.patch(status)
+}
+
+// heal restores the label of the node's Pod when the Pod is updated with a
+// label other than the status last published, e.g. when the label was edited
+// by hand. If foreign labels are respected, a label set by something else is
+// left alone instead.
+func (p *podLabelPublisher) heal(pod *corev1.Pod) {
+	p.mu.Lock()
+	status := p.status
+	p.mu.Unlock()
+
+	if status == "" || podLabelCurrent(p.config, pod, status) {
+		return
+	}
+	if p.config.RespectForeignLabels && podLabelForeign(pod) {
+		p.log.repeatedWarningf("pod label %s was changed to %q outside of the elector; not restoring it, since foreign labels are respected", PodLabelKey, pod.Labels[PodLabelKey])
+		return
+	}
+	p.log.Warningf("pod label %s was changed to %q outside of the elector; restoring it to %q", PodLabelKey, pod.Labels[PodLabelKey], status)
+	if err := p.patch(status); err != nil {
+		p.log.repeatedErrorf("failed to restore pod label: %v", err)
+	}
+}
+
+// patch patches the label of the node's Pod, unless a failure is injected
+// into it by the chaos hooks.
+func (p *podLabelPublisher) patch(status string) error {
+	if p.fault != nil {
+		if err := p.fault(); err != nil {
+			return err
+		}
+	}
+	return updatePodLabel(p.config, p.client, status)
+}
+
+// podLabelCurrent checks whether the Pod already has the label for the status,
+// along with the role annotation, if any.
+func podLabelCurrent(cfg *ElectorConfig, pod *corev1.Pod, status string) bool {
+	if pod.Labels[PodLabelKey] != status {
+		return false
+	}
+	if role := cfg.role(); role != "" && pod.Annotations[PodRoleAnnotationKey] != role {
+		return false
+	}
+	return true
+}
+
+// debouncedPublisher wraps a publisher to collapse bursts of status changes.
+//
+// A status change is published once the debounce window has passed since the
+// first change in a burst, and only the final status of the burst is published.
+// This prevents redundant API requests when leadership observations catch up in
+// bursts, e.g. when the API server recovers from an outage. A status which was
+// already published is not published again.
+//
+// Statuses are published in the order they were scheduled: a status which was
+// scheduled before another status was published is dropped, so a debounced
+// status cannot land after a status published immediately.
+type debouncedPublisher struct {
+	publisher publisher
+	clock     clock.Clock
+	window    time.Duration
+	log       logger
+	ops       *operations
+
+	// publishing serializes the calls to the wrapped publisher.
+	publishing sync.Mutex
+
+	mu        sync.Mutex
+	pending   string
+	timer     clock.Timer
+	published string
+	collapsed int
+	failed    int
+	lastErr   error
+
+	// scheduled is the sequence number of the last status scheduled to be
+	// published, and attempted that of the last status publishing was
+	// attempted for.
+	scheduled uint64
+	attempted uint64
+}
+
+// newDebouncedPublisher wraps the publisher to debounce it with the given
+// window. If the window is not positive, status changes are published
+// immediately. Debounced publishes are tracked as in-flight operations.
+func newDebouncedPublisher(p publisher, clk clock.Clock, window time.Duration, log logger, ops *operations) *debouncedPublisher {
+	return &debouncedPublisher{
+		publisher: p,
+		clock:     clk,
+		window:    window,
+		log:       log,
+		ops:       ops,
+	}
+}
+
+// update schedules the status to be published. If a status is already
+// pending, it is replaced and counted as collapsed.
+func (p *debouncedPublisher) update(status string) {
+	p.mu.Lock()
+	if p.window <= 0 {
+		seq := p.schedule()
+		p.mu.Unlock()
+		p.publish(status, seq)
+		return
+	}
+
+	if p.timer != nil {
+		p.collapsed++
+		p.pending = status
+		p.mu.Unlock()
+		return
+	}
+	p.pending = status
+	p.timer = p.clock.NewTimer(p.window)
+	timer := p.timer
+	p.mu.Unlock()
+
+	go func() {
+		<-timer.C()
+		defer p.ops.start()()
+		p.flushTimer(timer)
+	}()
+}
+
+// flush publishes the pending status, if any, without waiting for the debounce
+// window to pass. This is used when the election stops, so the final status
+// is not lost.
+func (p *debouncedPublisher) flush() {
+	p.mu.Lock()
+	timer := p.timer
+	p.mu.Unlock()
+
+	// The timer is left to fire, at which point there is nothing left to
+	// publish for it.
+	if timer != nil {
+		p.flushTimer(timer)
+	}
+}
+
+// publishNow publishes the status immediately, without waiting for the
+// debounce window to pass. Any pending status is dropped in favour of it.
+func (p *debouncedPublisher) publishNow(status string) {
+	p.mu.Lock()
+	if p.timer != nil {
+		// The timer is left to fire, at which point there is nothing left to
+		// publish for it.
+		p.timer = nil
+		p.collapsed++
+	}
+	seq := p.schedule()
+	p.mu.Unlock()
+
+	p.publish(status, seq)
+}
+
+// flushTimer publishes the status pending for the given timer. If the status
+// has already been published by another flush, nothing is done.
+func (p *debouncedPublisher) flushTimer(timer clock.Timer) {
+	p.mu.Lock()
+	if p.timer != timer {
+		p.mu.Unlock()
+		return
+	}
+	p.timer = nil
+	status := p.pending
+	seq := p.schedule()
+	p.mu.Unlock()
+
+	p.publish(status, seq)
+}
+
+// schedule assigns the next sequence number to a status to be published.
+//
+// The caller must hold the publisher's mutex.
+func (p *debouncedPublisher) schedule() uint64 {
+	p.scheduled++
+	return p.scheduled
+}
+
+// publish publishes the status with the given sequence number, unless it was
+// the last status published, or a status scheduled after it was already
+// published.
+func (p *debouncedPublisher) publish(status string, seq uint64) {
+	p.publishing.Lock()
+	defer p.publishing.Unlock()
+
+	p.mu.Lock()
+	if seq < p.attempted || status == p.published {
+		p.collapsed++
+		p.mu.Unlock()
+		return
+	}
+	p.attempted = seq
+	p.mu.Unlock()
+
+	if err := p.publisher.publish(status); err != nil {
+		p.log.repeatedErrorf("failed to publish %s status (%s): %v", status, p.publisher.name(), err)
+		p.mu.Lock()
+		p.failed++
+		p.lastErr = err
+		p.mu.Unlock()
+		return
+	}
+
+	p.mu.Lock()
+	p.published = status
+	p.lastErr = nil
+	p.mu.Unlock()
+}
+
+// collapsedCount gets the number of status changes which were collapsed
+// rather than published.
+func (p *debouncedPublisher) collapsedCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.collapsed
+}
+
+// failedCount gets the number of status changes which failed to publish.
+func (p *debouncedPublisher) failedCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.failed
+}
+
+// health gets the health of the publisher. It is healthy unless its last
+// attempt to publish a status failed.
+func (p *debouncedPublisher) health() PublisherHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	health := PublisherHealth{
+		Name:      p.publisher.name(),
+		Healthy:   p.lastErr == nil,
+		Published: p.published,
+		Pending:   p.pending,
+		Failures:  p.failed,
+		Collapsed: p.collapsed,
+	}
+	if p.lastErr != nil {
+		health.LastError = p.lastErr.Error()
+	}
+	return health
+}
+
+// patchErrorCount gets the number of status changes the publisher failed to
+// patch onto the node's Pod label. It is zero for the other publishers.
+func (p *debouncedPublisher) patchErrorCount() int {
+	if _, ok := p.publisher.(*podLabelPublisher); !ok {
+		return 0
+	}
+	return p.failedCount()
+}
+
+// setPublishers sets the publishers for the current run of the election.
+func (node *ElectorNode) setPublishers(publishers []*debouncedPublisher) {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.publishers = publishers
+}
+
+// stopPublishers publishes any pending status of the publishers from a run of
+// the election which has stopped, and keeps count of their collapsed changes
+// and Pod label patch errors.
+func (node *ElectorNode) stopPublishers(publishers []*debouncedPublisher) {
+	collapsed, patchErrors := 0, 0
+	for _, p := range publishers {
+		p.flush()
+		collapsed += p.collapsedCount()
+		patchErrors += p.patchErrorCount()
+	}
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.collapsedPublishes += collapsed
+	node.patchErrors += patchErrors
+	node.publishers = nil
+}
+
+// publishStatus publishes the node's status with each of its publishers.
+//
+// Publishing is debounced, so the status may be published after a delay. The
+// node's internal state, and so its HTTP endpoints, are not affected by this.
+func (node *ElectorNode) publishStatus(status string) {
+	node.mu.RLock()
+	publishers := node.publishers
+	node.mu.RUnlock()
+
+	for _, p := range publishers {
+		p.update(status)
+	}
+}
+
+// stepDown publishes that the leader is stepping down ahead of a voluntary
+// shutdown.
+//
+// When the node shuts down, the election lock is released once the election
+// is cancelled. Publishing the standby status first means the node's Pod label
+// and subscribers no longer name it as the leader by the time another node can
+// take the lock. Publishing is bounded by stepDownTimeout, after which the
+// shutdown continues regardless. Until it stops leading, the node is a lame
+// duck.
+func (node *ElectorNode) stepDown() {
+	if node.passive || !node.IsLeader() {
+		return
+	}
+	node.log.setRole(logRoleLameDuck)
+	node.mu.Lock()
+	node.newEvent()
+	node.mu.Unlock()
+
+	node.mu.RLock()
+	publishers := node.publishers
+	node.mu.RUnlock()
+	if len(publishers) == 0 {
+		return
+	}
+
+	node.log.Info("publishing standby status before releasing leadership")
+	done := make(chan struct{})
+	finished := node.operations.start()
+	go func() {
+		defer finished()
+		var wg sync.WaitGroup
+		for _, p := range publishers {
+			wg.Add(1)
+			go func(p *debouncedPublisher) {
+				defer wg.Done()
+				p.publishNow(StatusStandby)
+			}(p)
+		}
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-node.clock.After(stepDownTimeout):
+		node.log.Warningf("timed out after %v publishing standby status, releasing leadership anyway", stepDownTimeout)
+	}
+}
+
+// publisherHealth gets the health of the publishers of the current run of the
+// election.
+func (node *ElectorNode) publisherHealth() []PublisherHealth {
+	node.mu.RLock()
+	publishers := node.publishers
+	node.mu.RUnlock()
+
+	health := make([]PublisherHealth, 0, len(publishers))
+	for _, p := range publishers {
+		health = append(health, p.health())
+	}
+	return health
+}
+
+// collapsedPublishCount gets the number of status changes which were
+// collapsed rather than published.
+func (node *ElectorNode) collapsedPublishCount() int {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+
+	count := node.collapsedPublishes
+	for _, p := range node.publishers {
+		count += p.collapsedCount()
+	}
+	return count
+}
+
+// patchErrorCount gets the number of status changes which failed to be
+// patched onto the node's Pod label.
+func (node *ElectorNode) patchErrorCount() int {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+
+	count := node.patchErrors
+	for _, p := range node.publishers {
+		count += p.patchErrorCount()
+	}
+	return count
+}
@@ -0,0 +1,486 @@
+package pkg
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// testPublisher is a publisher which records the statuses it publishes.
+type testPublisher struct {
+	mu        sync.Mutex
+	err       error
+	published []string
+}
+
+func (p *testPublisher) name() string {
+	return "test"
+}
+
+func (p *testPublisher) publish(status string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, status)
+	return nil
+}
+
+func (p *testPublisher) statuses() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.published...)
+}
+
+// newTestDebouncedPublisher creates a debounced test publisher with a fake clock.
+func newTestDebouncedPublisher(window time.Duration) (*debouncedPublisher, *testPublisher, *clock.FakeClock) {
+	pub := &testPublisher{}
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	return newDebouncedPublisher(pub, clk, window, logger{out: &testLogger{}}, &operations{}), pub, clk
+}
+
+func TestDebouncedPublisher_burst(t *testing.T) {
+	p, pub, clk := newTestDebouncedPublisher(2 * time.Second)
+
+	// A burst of status changes within the window.
+	for _, status := range []string{StatusLeader, StatusStandby, StatusLeader, StatusStandby} {
+		p.update(status)
+		clk.Step(100 * time.Millisecond)
+	}
+	assert.Empty(t, pub.statuses())
+
+	// Once the window passes, only the final status is published.
+	clk.Step(2 * time.Second)
+	waitFor(t, 3*time.Second, func() bool {
+		return len(pub.statuses()) > 0
+	})
+	assert.Equal(t, []string{StatusStandby}, pub.statuses())
+	assert.Equal(t, 3, p.collapsedCount())
+
+	// Publishing the same status again is collapsed.
+	p.update(StatusStandby)
+	clk.Step(2 * time.Second)
+	waitFor(t, 3*time.Second, func() bool {
+		return p.collapsedCount() == 4
+	})
+	assert.Equal(t, []string{StatusStandby}, pub.statuses())
+
+	// A new burst publishes its final status.
+	p.update(StatusLeader)
+	clk.Step(2 * time.Second)
+	waitFor(t, 3*time.Second, func() bool {
+		return len(pub.statuses()) == 2
+	})
+	assert.Equal(t, []string{StatusStandby, StatusLeader}, pub.statuses())
+}
+
+func TestDebouncedPublisher_noWindow(t *testing.T) {
+	p, pub, _ := newTestDebouncedPublisher(0)
+
+	p.update(StatusLeader)
+	p.update(StatusStandby)
+	assert.Equal(t, []string{StatusLeader, StatusStandby}, pub.statuses())
+	assert.Equal(t, 0, p.collapsedCount())
+}
+
+func TestDebouncedPublisher_flush(t *testing.T) {
+	p, pub, clk := newTestDebouncedPublisher(2 * time.Second)
+
+	p.update(StatusLeader)
+	p.update(StatusStandby)
+	p.flush()
+	assert.Equal(t, []string{StatusStandby}, pub.statuses())
+
+	// Once the window passes, nothing more is published.
+	clk.Step(2 * time.Second)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{StatusStandby}, pub.statuses())
+
+	// Flushing without a pending status does nothing.
+	p.flush()
+	assert.Equal(t, []string{StatusStandby}, pub.statuses())
+}
+
+func TestDebouncedPublisher_publishNow(t *testing.T) {
+	p, pub, clk := newTestDebouncedPublisher(2 * time.Second)
+
+	// The pending status is dropped in favour of the one published now.
+	p.update(StatusLeader)
+	p.publishNow(StatusStandby)
+	assert.Equal(t, []string{StatusStandby}, pub.statuses())
+	assert.Equal(t, 1, p.collapsedCount())
+
+	// Once the window passes, nothing more is published.
+	clk.Step(2 * time.Second)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{StatusStandby}, pub.statuses())
+}
+
+// blockingPublisher is a testPublisher which blocks publishing the leader
+// status until it is released.
+type blockingPublisher struct {
+	testPublisher
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingPublisher) publish(status string) error {
+	if status == StatusLeader {
+		close(p.entered)
+		<-p.release
+	}
+	return p.testPublisher.publish(status)
+}
+
+func TestDebouncedPublisher_publishNow_racesFlush(t *testing.T) {
+	pub := &blockingPublisher{entered: make(chan struct{}), release: make(chan struct{})}
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	p := newDebouncedPublisher(pub, clk, 2*time.Second, logger{out: &testLogger{}}, &operations{})
+
+	// The debounced leader status is being published when the node steps
+	// down and publishes its standby status immediately.
+	p.update(StatusLeader)
+	clk.Step(2 * time.Second)
+	select {
+	case <-pub.entered:
+	case <-time.After(3 * time.Second):
+		assert.FailNow(t, "debounced status was not published")
+	}
+	done := make(chan struct{})
+	go func() {
+		p.publishNow(StatusStandby)
+		close(done)
+	}()
+
+	// The standby status is published after the leader status lands, not
+	// before it.
+	time.Sleep(50 * time.Millisecond)
+	close(pub.release)
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		assert.FailNow(t, "standby status was not published")
+	}
+	assert.Equal(t, []string{StatusLeader, StatusStandby}, pub.statuses())
+	assert.Equal(t, StatusStandby, p.health().Published)
+}
+
+func TestDebouncedPublisher_superseded(t *testing.T) {
+	p, pub, _ := newTestDebouncedPublisher(2 * time.Second)
+
+	// A status scheduled before another was published is dropped, e.g. a
+	// debounced status whose timer fired before the node stepped down.
+	p.update(StatusLeader)
+	p.mu.Lock()
+	p.timer = nil
+	leader := p.schedule()
+	p.mu.Unlock()
+	p.publishNow(StatusStandby)
+	p.publish(StatusLeader, leader)
+
+	assert.Equal(t, []string{StatusStandby}, pub.statuses())
+	assert.Equal(t, 1, p.collapsedCount())
+}
+
+func TestDebouncedPublisher_error(t *testing.T) {
+	p, pub, _ := newTestDebouncedPublisher(0)
+	buf := &testLogger{}
+	p.log = logger{out: buf}
+
+	pub.err = errors.New("test error")
+	p.update(StatusLeader)
+	assert.Contains(t, buf.String(), "failed to publish leader status (test): test error")
+
+	// A failed status is not considered published, so it is retried.
+	pub.err = nil
+	p.update(StatusLeader)
+	assert.Equal(t, []string{StatusLeader}, pub.statuses())
+}
+
+func TestElectorNode_publishStatus(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		Logger: &testLogger{},
+	})
+	p, pub, _ := newTestDebouncedPublisher(2 * time.Second)
+	publishers := []*debouncedPublisher{p}
+
+	// Without publishers, nothing is published.
+	node.publishStatus(StatusLeader)
+
+	node.setPublishers(publishers)
+	node.publishStatus(StatusLeader)
+	node.publishStatus(StatusStandby)
+	assert.Equal(t, 1, node.collapsedPublishCount())
+
+	// Stopping the publishers publishes the pending status and keeps the
+	// count of collapsed changes.
+	node.stopPublishers(publishers)
+	assert.Equal(t, []string{StatusStandby}, pub.statuses())
+	assert.Equal(t, 1, node.collapsedPublishCount())
+	assert.Equal(t, 1, node.statusSnapshot().CollapsedPublishes)
+}
+
+func TestElectorNode_stepDown(t *testing.T) {
+	cases := []struct {
+		description string
+		leader      string
+		passive     bool
+		expected    []string
+	}{
+		{
+			description: "leader publishes standby",
+			leader:      "test-node-1",
+			expected:    []string{StatusStandby},
+		},
+		{
+			description: "standby does not publish",
+			leader:      "test-node-2",
+			expected:    nil,
+		},
+		{
+			description: "passive leader does not publish",
+			leader:      "test-node-1",
+			passive:     true,
+			expected:    nil,
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:     "test-node-1",
+			Logger: &testLogger{},
+		})
+		node.passive = c.passive
+		node.setLeader(c.leader)
+
+		// Publishing is not debounced while stepping down.
+		p, pub, _ := newTestDebouncedPublisher(time.Hour)
+		node.setPublishers([]*debouncedPublisher{p})
+
+		node.stepDown()
+		assert.Equal(t, c.expected, pub.statuses(), c.description)
+	}
+}
+
+func TestElectorNode_Stop_publishBeforeRelease(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:              "test-node-1",
+		Name:            "test-election",
+		Namespace:       "test-ns",
+		LockNamespace:   "test-ns",
+		PodName:         "test-pod",
+		LockType:        resourcelock.LeasesResourceLock,
+		TTL:             1 * time.Second,
+		Client:          client,
+		Logger:          &testLogger{},
+		PublishDebounce: time.Hour,
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.run()
+	}()
+	waitFor(t, 5*time.Second, func() bool {
+		return node.IsLeader()
+	})
+
+	node.Stop()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "election did not stop")
+	}
+
+	// Find when the standby label was published and when the lease was released.
+	published, released := -1, -1
+	for i, action := range client.Actions() {
+		switch {
+		case action.Matches("patch", "pods"):
+			patch := string(action.(k8stesting.PatchAction).GetPatch())
+			if published == -1 && strings.Contains(patch, StatusStandby) {
+				published = i
+			}
+		case action.Matches("update", "leases"):
+			lease := action.(k8stesting.UpdateAction).GetObject().(*coordinationv1.Lease)
+			if released == -1 && lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity == "" {
+				released = i
+			}
+		}
+	}
+	assert.NotEqual(t, -1, published, "standby status was not published")
+	assert.NotEqual(t, -1, released, "lease was not released")
+	assert.True(t, published < released, "standby status published after the lease was released")
+}
+
+func TestPodLabelPublisher(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	p := &podLabelPublisher{
+		config: &ElectorConfig{
+			Name:      "test-election",
+			RoleName:  "test-role",
+			Namespace: "test-ns",
+			PodName:   "test-pod",
+		},
+		client: client,
+	}
+
+	assert.NoError(t, p.publish(StatusLeader))
+	pod, err := client.CoreV1().Pods("test-ns").Get("test-pod", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusLeader, pod.Labels[PodLabelKey])
+	assert.Equal(t, "test-role", pod.Annotations[PodRoleAnnotationKey])
+}
+
+func TestParsePublishers(t *testing.T) {
+	cases := []struct {
+		description string
+		value       string
+		expected    []string
+	}{
+		{
+			description: "empty",
+			value:       "",
+			expected:    nil,
+		},
+		{
+			description: "single publisher",
+			value:       "pod-label",
+			expected:    []string{PublisherPodLabel},
+		},
+		{
+			description: "multiple publishers with spaces",
+			value:       "subscriptions, watch,",
+			expected:    []string{PublisherSubscriptions, PublisherWatch},
+		},
+	}
+
+	for _, c := range cases {
+		names, err := ParsePublishers(c.value)
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, c.expected, names, c.description)
+	}
+}
+
+func TestParsePublishers_unknown(t *testing.T) {
+	names, err := ParsePublishers("pod-label,leader-file")
+	assert.Nil(t, names)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `"leader-file"`)
+		assert.Contains(t, err.Error(), "pod-label, subscriptions, watch, status-object, external-dns")
+	}
+}
+
+func TestElectorNode_checkConfig_publishers(t *testing.T) {
+	cases := []struct {
+		description string
+		publishers  []string
+		disabled    []string
+	}{
+		{
+			description: "unknown publisher",
+			publishers:  []string{"leader-file"},
+		},
+		{
+			description: "unknown disabled publisher",
+			disabled:    []string{"leader-file"},
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			Name:               "test-election",
+			Publishers:         c.publishers,
+			DisabledPublishers: c.disabled,
+			Logger:             &testLogger{},
+		})
+		err := node.checkConfig()
+		if assert.Error(t, err, c.description) {
+			assert.Contains(t, err.Error(), "leader-file", c.description)
+		}
+	}
+}
+
+func TestElectorNode_newPublishers_selected(t *testing.T) {
+	cases := []struct {
+		description string
+		publishers  []string
+		disabled    []string
+		expected    []string
+		active      []string
+	}{
+		{
+			description: "all publishers by default",
+			expected:    []string{"pod label", "subscriptions", "watchers"},
+			active:      []string{PublisherPodLabel, PublisherSubscriptions, PublisherWatch},
+		},
+		{
+			description: "selected publishers",
+			publishers:  []string{PublisherWatch, PublisherPodLabel},
+			expected:    []string{"pod label", "watchers"},
+			active:      []string{PublisherPodLabel, PublisherWatch},
+		},
+		{
+			description: "disabled publishers",
+			disabled:    []string{PublisherPodLabel},
+			expected:    []string{"subscriptions", "watchers"},
+			active:      []string{PublisherSubscriptions, PublisherWatch},
+		},
+		{
+			description: "selected and disabled",
+			publishers:  []string{PublisherPodLabel, PublisherWatch},
+			disabled:    []string{PublisherWatch},
+			expected:    []string{"pod label"},
+			active:      []string{PublisherPodLabel},
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:                 "test-node-1",
+			Name:               "test-election",
+			Publishers:         c.publishers,
+			DisabledPublishers: c.disabled,
+			Logger:             &testLogger{},
+		})
+
+		var names []string
+		for _, p := range node.newPublishers(fake.NewSimpleClientset()) {
+			names = append(names, p.publisher.name())
+		}
+		assert.Equal(t, c.expected, names, c.description)
+		assert.Equal(t, c.active, node.configInfo().Publishers, c.description)
+	}
+}
+
+func TestElectorNode_publishStatus_disabledPublisher(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:                 "test-node-1",
+		Name:               "test-election",
+		Namespace:          "test-ns",
+		PodName:            "test-pod",
+		DisabledPublishers: []string{PublisherPodLabel},
+		Logger:             &testLogger{},
+	})
+
+	publishers := node.newPublishers(client)
+	node.setPublishers(publishers)
+	node.publishStatus(StatusLeader)
+	node.stopPublishers(publishers)
+
+	// The Pod label publisher was never registered, so the Pod is untouched.
+	assert.Empty(t, client.Actions())
+}
@@ -0,0 +1,62 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"strings"
+)
+
+// pureElectionDisabledFeatures are the side effects which are disabled when
+// the elector runs a pure election.
+var pureElectionDisabledFeatures = []string{
+	"pod status labels",
+	"subscriptions",
+	"the status object",
+	"the external-dns service",
+	"on-elected and on-demoted commands",
+	"outage and history records",
+	"termination message",
+}
+
+// pureElectionDisabledPublishers are the publishers which are disabled when
+// the elector runs a pure election. Only the /watch streams, which are part
+// of the HTTP reporting, are kept.
+var pureElectionDisabledPublishers = []string{
+	PublisherPodLabel,
+	PublisherSubscriptions,
+	PublisherStatusObject,
+	PublisherExternalDNS,
+}
+
+// configurePureElection disables the side effects of a node running a pure
+// election, leaving only the election itself and its HTTP and metrics
+// reporting. They are logged once here, rather than skipped silently each
+// time they would be used.
+func (node *ElectorNode) configurePureElection() {
+	conf := node.config
+	for _, name := range pureElectionDisabledPublishers {
+		if !containsPublisher(conf.DisabledPublishers, name) {
+			conf.DisabledPublishers = append(conf.DisabledPublishers, name)
+		}
+	}
+	conf.OnElected = ""
+	conf.OnDemoted = ""
+	conf.RecordOutages = false
+	conf.RecordHistory = false
+	conf.TerminationMessagePath = ""
+	node.log.Infof("running a pure election: %s are disabled", strings.Join(pureElectionDisabledFeatures, ", "))
+}
@@ -0,0 +1,53 @@
+package pkg
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestElectorNode_checkConfig_pureElection(t *testing.T) {
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		Name:                   "test-election",
+		PodName:                "test-pod",
+		AuthToken:              "secret",
+		PureElection:           true,
+		OnElected:              "echo elected",
+		OnDemoted:              "echo demoted",
+		RecordOutages:          true,
+		RecordHistory:          true,
+		TerminationMessagePath: "/dev/termination-log",
+		DisabledPublishers:     []string{PublisherPodLabel},
+		Logger:                 log,
+	})
+
+	assert.NoError(t, node.checkConfig())
+	assert.Equal(t, []string{PublisherPodLabel, PublisherSubscriptions, PublisherStatusObject, PublisherExternalDNS}, node.config.DisabledPublishers)
+	assert.Equal(t, []string{PublisherWatch}, node.config.activePublishers())
+	assert.Empty(t, node.config.OnElected)
+	assert.Empty(t, node.config.OnDemoted)
+	assert.False(t, node.config.RecordOutages)
+	assert.False(t, node.config.RecordHistory)
+	assert.Empty(t, node.config.TerminationMessagePath)
+	assert.True(t, node.configInfo().PureElection)
+	assert.Contains(t, log.String(), "running a pure election: pod status labels, subscriptions")
+
+	// Only the watchers are published to, so the Pod is never patched.
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	var names []string
+	for _, p := range node.newPublishers(client) {
+		names = append(names, p.publisher.name())
+	}
+	assert.Equal(t, []string{"watchers"}, names)
+	for _, p := range requiredPermissions(node.config) {
+		assert.NotEqual(t, "pods", p.Resource)
+	}
+
+	// Subscriptions would never be delivered to, so they are rejected.
+	w := doSubscribeRequest(node, http.MethodPost, "/subscribe", `{"url": "http://127.0.0.1:8080/callback"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Empty(t, node.subscriptions.list())
+}
@@ -0,0 +1,89 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// verifyLeadership re-reads the election lock and checks whether it is held by
+// the node. If the lock can not be read, the claim can not be disproven, so the
+// node is assumed to still hold it.
+func (node *ElectorNode) verifyLeadership(lock resourcelock.Interface) (holder string, ok bool) {
+	record, _, err := lock.Get()
+	if err != nil {
+		node.log.Warningf("failed to read election lock to verify leadership: %v", err)
+		return "", true
+	}
+	if record == nil {
+		return "", false
+	}
+	return record.HolderIdentity, record.HolderIdentity == node.config.ID
+}
+
+// reconcileLeadership periodically verifies the node's claim to leadership
+// while it leads, as a safety net beyond the checks made by the leader
+// election itself.
+//
+// If the node believes it leads but the lock is held by another identity (a
+// split brain, or an external takeover of the lock), the split brain is
+// recorded and the lock is fenced so the node can no longer write to it. The
+// node then withdraws from the election, which triggers the usual demotion
+// side effects without releasing the lock from under its actual holder.
+func (node *ElectorNode) reconcileLeadership(ctx context.Context, lock resourcelock.Interface, fenced *observedLock, interval time.Duration, withdraw func()) {
+	for {
+		timer := node.clock.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		if !node.IsLeader() {
+			continue
+		}
+		holder, ok := node.verifyLeadership(lock)
+		if ok || !node.IsLeader() {
+			continue
+		}
+
+		node.log.Errorf(
+			"SPLIT BRAIN DETECTED: node believes it is the leader, but the election lock is held by %q; stepping down",
+			holder,
+		)
+		node.mu.Lock()
+		node.splitBrainDetected = true
+		node.mu.Unlock()
+
+		fenced.fence()
+		withdraw()
+		return
+	}
+}
+
+// splitBrain checks whether the node has detected a split brain, i.e. that it
+// believed it was the leader while the election lock was held by another
+// identity.
+func (node *ElectorNode) splitBrain() bool {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.splitBrainDetected
+}
@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestElectorNode_verifyLeadership(t *testing.T) {
+	cases := []struct {
+		description string
+		lock        *fakeLock
+		holder      string
+		ok          bool
+	}{
+		{
+			description: "held by the node",
+			lock:        &fakeLock{record: &resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}},
+			holder:      "test-node-1",
+			ok:          true,
+		},
+		{
+			description: "held by another node",
+			lock:        &fakeLock{record: &resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2"}},
+			holder:      "test-node-2",
+			ok:          false,
+		},
+		{
+			description: "released",
+			lock:        &fakeLock{record: &resourcelock.LeaderElectionRecord{}},
+			holder:      "",
+			ok:          false,
+		},
+		{
+			description: "lock read error",
+			lock:        &fakeLock{err: errors.New("unavailable")},
+			holder:      "",
+			ok:          true,
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{ID: "test-node-1", Logger: &testLogger{}})
+		holder, ok := node.verifyLeadership(c.lock)
+		assert.Equal(t, c.holder, holder, c.description)
+		assert.Equal(t, c.ok, ok, c.description)
+	}
+}
+
+// runTestReconcile runs the leadership reconcile for the node against the lock
+// for the given number of intervals, and gets whether the node withdrew.
+func runTestReconcile(t *testing.T, node *ElectorNode, lock *fakeLock, intervals int) (*observedLock, bool) {
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	node.clock = clk
+	observed := newObservedLock(lock, clk)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var withdrawn int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		node.reconcileLeadership(ctx, lock, observed, time.Second, func() {
+			atomic.StoreInt32(&withdrawn, 1)
+		})
+	}()
+
+	// Each interval is handled once the node waits for the next one, or has
+	// withdrawn.
+	handled := func() bool {
+		return clk.HasWaiters() || atomic.LoadInt32(&withdrawn) == 1
+	}
+	for i := 0; i < intervals; i++ {
+		waitFor(t, time.Second, handled)
+		if atomic.LoadInt32(&withdrawn) == 1 {
+			break
+		}
+		clk.Step(time.Second)
+	}
+	// Wait for the last interval to be handled before stopping.
+	waitFor(t, time.Second, handled)
+	cancel()
+	<-done
+	return observed, atomic.LoadInt32(&withdrawn) == 1
+}
+
+func TestElectorNode_reconcileLeadership_splitBrain(t *testing.T) {
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{ID: "test-node-1", Logger: log})
+	node.setLeader("test-node-1")
+	lock := &fakeLock{
+		identity: "test-node-1",
+		record:   &resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-2"},
+	}
+
+	observed, withdrawn := runTestReconcile(t, node, lock, 3)
+	assert.True(t, withdrawn)
+	assert.True(t, node.splitBrain())
+	assert.Contains(t, log.String(), "SPLIT BRAIN DETECTED")
+
+	// Stepping down must not release the other holder's record.
+	err := observed.Update(resourcelock.LeaderElectionRecord{})
+	assert.Equal(t, errLockFenced, err)
+	assert.Equal(t, "test-node-2", lock.record.HolderIdentity)
+}
+
+func TestElectorNode_reconcileLeadership_ok(t *testing.T) {
+	cases := []struct {
+		description string
+		leader      string
+		lock        *fakeLock
+	}{
+		{
+			description: "leader holds the lock",
+			leader:      "test-node-1",
+			lock:        &fakeLock{record: &resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-1"}},
+		},
+		{
+			description: "standby node",
+			leader:      "test-node-2",
+			lock:        &fakeLock{record: &resourcelock.LeaderElectionRecord{HolderIdentity: "test-node-3"}},
+		},
+		{
+			description: "lock read error",
+			leader:      "test-node-1",
+			lock:        &fakeLock{err: errors.New("unavailable")},
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{ID: "test-node-1", Logger: &testLogger{}})
+		node.setLeader(c.leader)
+
+		_, withdrawn := runTestReconcile(t, node, c.lock, 3)
+		assert.False(t, withdrawn, c.description)
+		assert.False(t, node.splitBrain(), c.description)
+	}
+}
+
+func TestElectorNode_httpHealth(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{ID: "test-node-1"})
+
+	data := getJSON(t, node, "/healthz")
+	assert.Equal(t, "ok", data["status"])
+	assert.Equal(t, false, data["split_brain_detected"])
+
+	node.splitBrainDetected = true
+	data = getJSON(t, node, "/healthz")
+	assert.Equal(t, true, data["split_brain_detected"])
+}
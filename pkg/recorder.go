@@ -2,13 +2,14 @@ package pkg
 
 import (
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/klog"
 )
 
 // lockRecorder implements the EventRecorder which is used to log events
 // on the Kubernetes object being used as the election lock.
-type lockRecorder struct{}
+type lockRecorder struct {
+	log logger
+}
 
 func (recorder *lockRecorder) Eventf(obj runtime.Object, eventType, reason, message string, args ...interface{}) {
-	klog.Infof("lock event %s (%s): %s", reason, eventType, message)
+	recorder.log.Infof("lock event %s (%s): %s", reason, eventType, message)
 }
@@ -1,18 +1,14 @@
 package pkg
 
 import (
-	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	"k8s.io/klog"
 )
 
 func TestLockRecorder_Eventf(t *testing.T) {
-	rec := lockRecorder{}
-
-	var buf bytes.Buffer
-	klog.SetOutput(&buf)
+	buf := &testLogger{}
+	rec := lockRecorder{log: newLogger(&ElectorConfig{Logger: buf})}
 
 	rec.Eventf(nil, "TestEvent", "test reason", "test message")
 
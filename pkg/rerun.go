@@ -0,0 +1,121 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import "errors"
+
+// The categories of the errors which stop a run of the election.
+const (
+	runErrorClient = "client"
+	runErrorLock   = "lock"
+	runErrorConfig = "config"
+	runErrorOther  = "other"
+)
+
+// runErrorCategories are the categories of run errors, in the order they are
+// reported.
+var runErrorCategories = []string{
+	runErrorClient,
+	runErrorLock,
+	runErrorConfig,
+	runErrorOther,
+}
+
+// runError is an error which stopped a run of the election, with the category
+// of the step of the run which failed.
+type runError struct {
+	category string
+	err      error
+}
+
+func (e *runError) Error() string {
+	return e.err.Error()
+}
+
+func (e *runError) Unwrap() error {
+	return e.err
+}
+
+// runErrorCategory gets the category of an error which stopped a run of the
+// election. Errors which were not categorized by the run are "other" errors.
+func runErrorCategory(err error) string {
+	var re *runError
+	if errors.As(err, &re) {
+		return re.category
+	}
+	return runErrorOther
+}
+
+// recordRerun counts a re-run of the election loop, which starts a new
+// election epoch, and records the reason for it.
+func (node *ElectorNode) recordRerun(reason string) {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.restarts++
+	node.restartReason = reason
+	node.leaderPayload = nil
+}
+
+// rerunReason describes why the last run of the election stopped without an
+// error, so the election is re-run. It must be called before the node waits
+// to re-join the election, since waiting clears the pause and demotion.
+func (node *ElectorNode) rerunReason() string {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	switch {
+	case node.paused:
+		return "election participation was paused"
+	case node.demoted:
+		return "the node stopped leading"
+	default:
+		return "the election stopped"
+	}
+}
+
+// electionEpoch gets the epoch of the election loop: the number of the
+// current run of the election, starting at 1 and incremented each time the
+// election is re-run. It correlates the node's logs and status with a run of
+// the election; an epoch which keeps increasing indicates an unstable
+// election.
+func (node *ElectorNode) electionEpoch() int {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.restarts + 1
+}
+
+// recordRunError counts an error which stopped a run of the election, by its
+// category.
+func (node *ElectorNode) recordRunError(err error) {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	if node.runErrors == nil {
+		node.runErrors = map[string]int{}
+	}
+	node.runErrors[runErrorCategory(err)]++
+}
+
+// rerunCounts gets the number of times the election loop has been re-run,
+// and the number of errors which stopped a run, by category.
+func (node *ElectorNode) rerunCounts() (reruns int, runErrors map[string]int) {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	runErrors = make(map[string]int, len(node.runErrors))
+	for category, count := range node.runErrors {
+		runErrors[category] = count
+	}
+	return node.restarts, runErrors
+}
@@ -0,0 +1,107 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunErrorCategory(t *testing.T) {
+	cases := []struct {
+		description string
+		err         error
+		expected    string
+	}{
+		{
+			description: "client error",
+			err:         &runError{category: runErrorClient, err: errors.New("test error")},
+			expected:    runErrorClient,
+		},
+		{
+			description: "wrapped lock error",
+			err:         fmt.Errorf("run failed: %w", &runError{category: runErrorLock, err: errors.New("test error")}),
+			expected:    runErrorLock,
+		},
+		{
+			description: "uncategorized error",
+			err:         errors.New("test error"),
+			expected:    runErrorOther,
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, runErrorCategory(c.err), c.description)
+	}
+}
+
+func TestRunError(t *testing.T) {
+	inner := errors.New("test error")
+	err := &runError{category: runErrorClient, err: inner}
+
+	// The category does not change the error message.
+	assert.EqualError(t, err, "test error")
+	assert.True(t, errors.Is(err, inner))
+}
+
+func TestElectorNode_rerunCounts(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+
+	reruns, runErrors := node.rerunCounts()
+	assert.Equal(t, 0, reruns)
+	assert.Empty(t, runErrors)
+
+	node.recordRerun("the election stopped")
+	node.recordRerun("the election stopped")
+	node.recordRunError(&runError{category: runErrorLock, err: errors.New("test error")})
+	node.recordRunError(errors.New("test error"))
+
+	reruns, runErrors = node.rerunCounts()
+	assert.Equal(t, 2, reruns)
+	assert.Equal(t, map[string]int{runErrorLock: 1, runErrorOther: 1}, runErrors)
+	assert.Equal(t, 2, node.statusSnapshot().Restarts)
+}
+
+func TestElectorNode_electionEpoch(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+	assert.Equal(t, 1, node.electionEpoch())
+	payload, err := node.cachedLeaderInfo()
+	assert.NoError(t, err)
+	assert.Contains(t, string(payload), `"election_epoch":1,`)
+
+	// A re-run starts a new epoch, which is reported at / right away.
+	node.recordRerun("the election stopped")
+	assert.Equal(t, 2, node.electionEpoch())
+	payload, err = node.cachedLeaderInfo()
+	assert.NoError(t, err)
+	assert.Contains(t, string(payload), `"election_epoch":2,`)
+	assert.Equal(t, 2, node.leaderInfo().ElectionEpoch)
+}
+
+func TestElectorNode_runUntilError_recordsRunError(t *testing.T) {
+	defer os.Unsetenv(EnvKubeConfigData)
+	os.Unsetenv(EnvKubeConfigData)
+	node := newTestStartupFailureNode(0)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.runUntilError()
+	}()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node did not stop")
+	}
+
+	_, runErrors := node.rerunCounts()
+	assert.Equal(t, map[string]int{runErrorClient: 1}, runErrors)
+}
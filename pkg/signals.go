@@ -0,0 +1,51 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handledSignals are the signals which the node handles: the termination
+// signals (SIGINT, SIGKILL, SIGTERM), and SIGUSR1 to dump its status.
+var handledSignals = []os.Signal{os.Interrupt, os.Kill, syscall.SIGTERM, syscall.SIGUSR1}
+
+// signalNotifier relays signals to a channel. It is the seam between the node
+// and the process's signals, so tests can drive the node's signal handling
+// without registering handlers for the signals of the test process.
+type signalNotifier interface {
+	// Notify relays the given signals to the channel.
+	Notify(c chan<- os.Signal, sig ...os.Signal)
+
+	// Stop stops relaying signals to the channel.
+	Stop(c chan<- os.Signal)
+}
+
+// osSignals relays the signals delivered to the process, with os/signal.
+type osSignals struct{}
+
+// Notify implements signalNotifier.
+func (osSignals) Notify(c chan<- os.Signal, sig ...os.Signal) {
+	signal.Notify(c, sig...)
+}
+
+// Stop implements signalNotifier.
+func (osSignals) Stop(c chan<- os.Signal) {
+	signal.Stop(c)
+}
@@ -0,0 +1,152 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// fakeSignals is a signalNotifier which does not relay the process's signals.
+// It records the signals the node registered for, and whether it stopped
+// relaying them.
+type fakeSignals struct {
+	mu       sync.Mutex
+	notified []os.Signal
+	stopped  int
+}
+
+func (s *fakeSignals) Notify(c chan<- os.Signal, sig ...os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notified = append([]os.Signal(nil), sig...)
+}
+
+func (s *fakeSignals) Stop(c chan<- os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped++
+}
+
+func (s *fakeSignals) state() ([]os.Signal, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notified, s.stopped
+}
+
+func TestElectorNode_listenForSignal_seams(t *testing.T) {
+	signals := &fakeSignals{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Logger: &testLogger{},
+	})
+	node.signals = signals
+	terminated := make(chan struct{})
+	node.terminate = func() {
+		close(terminated)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		node.listenForSignal()
+		close(done)
+	}()
+	node.quit <- syscall.SIGTERM
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "failed to stop listening for signals")
+	}
+
+	// The termination was handed to the injected action, so the node itself
+	// was not shut down.
+	select {
+	case <-terminated:
+	default:
+		assert.Fail(t, "termination action not run")
+	}
+	assert.NoError(t, node.ctx.Err())
+
+	notified, stopped := signals.state()
+	assert.Equal(t, handledSignals, notified)
+	assert.Equal(t, 1, stopped)
+	assert.Equal(t, "received termination signal terminated", node.stopReasonFor(nil))
+}
+
+func TestElectorNode_Run_gracefulShutdown(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		PodName:       "test-pod",
+		LockType:      resourcelock.LeasesResourceLock,
+		TTL:           1 * time.Second,
+		Address:       "127.0.0.1:0",
+		Client:        client,
+		Logger:        log,
+	})
+	signals := &fakeSignals{}
+	node.signals = signals
+
+	// The HTTP server is still serving when the termination action runs, so
+	// the endpoints stay up while the node steps down.
+	var servingAtShutdown bool
+	terminate := node.terminate
+	node.terminate = func() {
+		servingAtShutdown = node.HTTPAddr() != ""
+		terminate()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.Run()
+	}()
+	waitFor(t, 5*time.Second, func() bool {
+		return node.IsLeader() && node.HTTPAddr() != ""
+	})
+	addr := node.HTTPAddr()
+
+	node.quit <- syscall.SIGTERM
+	select {
+	case err := <-done:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node did not stop")
+	}
+	assert.True(t, servingAtShutdown)
+
+	// The leader stepped down: its Pod is labelled as a standby and the lease
+	// was released.
+	pod, err := client.CoreV1().Pods("test-ns").Get("test-pod", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusStandby, pod.Labels[PodLabelKey])
+	lease, err := client.CoordinationV1().Leases("test-ns").Get("test-election", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "", *lease.Spec.HolderIdentity)
+
+	// Once the node has stopped, so has its HTTP server.
+	waitFor(t, 5*time.Second, func() bool {
+		return node.HTTPAddr() == ""
+	})
+	_, err = http.Get(fmt.Sprintf("http://%s/", addr))
+	assert.Error(t, err)
+
+	// The node stopped relaying signals, and logged why it stopped.
+	notified, stopped := signals.state()
+	assert.Equal(t, handledSignals, notified)
+	assert.True(t, stopped > 0)
+	assert.Contains(t, log.String(), `reason="received termination signal terminated"`)
+}
@@ -0,0 +1,45 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"k8s.io/client-go/kubernetes"
+)
+
+// runSingleNode runs the node as the only node of its election.
+//
+// No election is run and no lock is used: the node is the leader as soon as it
+// starts, with the same side effects as acquiring leadership in an election,
+// and leads until it is stopped or withdraws. This lets the same deployment
+// run with one replica, without the overhead of an election, or with several.
+func (node *ElectorNode) runSingleNode(client kubernetes.Interface) error {
+	node.log.Info("running as a single node, without an election")
+
+	publishers := node.newPublishers(client)
+	node.setPublishers(publishers)
+	defer node.stopPublishers(publishers)
+
+	node.setLeader(node.config.ID)
+	node.recordFirstLeader(node.config.ID)
+	node.startedLeading(client, nil)
+
+	<-node.electionCtx.Done()
+
+	node.stoppedLeading()
+	node.setLeader("")
+	return nil
+}
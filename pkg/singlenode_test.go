@@ -0,0 +1,107 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestElectorNode_run_singleNode(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		PodName:       "test-pod",
+		LockType:      resourcelock.LeasesResourceLock,
+		TTL:           1 * time.Second,
+		Client:        client,
+		Logger:        &testLogger{},
+		SingleNode:    true,
+	})
+
+	podLabel := func() string {
+		pod, err := client.CoreV1().Pods("test-ns").Get("test-pod", metav1.GetOptions{})
+		if err != nil {
+			return ""
+		}
+		return pod.Labels[PodLabelKey]
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.run()
+	}()
+
+	waitFor(t, 5*time.Second, func() bool {
+		return podLabel() == StatusLeader
+	})
+	assert.True(t, node.IsLeader())
+	assert.Equal(t, "test-node-1", node.leader())
+	hasLed, acquisitions := node.leadership()
+	assert.True(t, hasLed)
+	assert.Equal(t, 1, acquisitions)
+
+	// No election lock is used.
+	_, err := client.CoordinationV1().Leases("test-ns").Get("test-election", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	node.Stop()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node did not stop")
+	}
+	assert.False(t, node.IsLeader())
+	assert.Equal(t, StatusStandby, podLabel())
+}
+
+func TestElectorNode_checkConfig_singleNode(t *testing.T) {
+	cases := []struct {
+		description string
+		outages     bool
+		history     bool
+		valid       bool
+	}{
+		{
+			description: "single node",
+			valid:       true,
+		},
+		{
+			description: "outages not supported",
+			outages:     true,
+			valid:       false,
+		},
+		{
+			description: "history not supported",
+			history:     true,
+			valid:       false,
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:            "test-node-1",
+			Name:          "test-election",
+			Namespace:     "test-ns",
+			LockType:      resourcelock.LeasesResourceLock,
+			SingleNode:    true,
+			RecordOutages: c.outages,
+			RecordHistory: c.history,
+			Logger:        &testLogger{},
+		})
+
+		err := node.checkConfig()
+		if c.valid {
+			assert.NoError(t, err, c.description)
+		} else {
+			assert.Error(t, err, c.description)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// clockSkewTimeout is the timeout for the request made to the API server to
+// measure clock skew.
+const clockSkewTimeout = 5 * time.Second
+
+// clockSkew estimates the skew between the local clock and the API server's
+// clock, from the Date header of a response to a request which was sent at
+// start and received at end.
+//
+// The server time is assumed to be halfway through the request. The Date
+// header only has a resolution of one second, so the server time is taken to
+// be halfway through the second it reports. A positive skew means the API
+// server's clock is ahead of the local clock.
+func clockSkew(start, end, date time.Time) time.Duration {
+	local := start.Add(end.Sub(start) / 2)
+	server := date.Add(500 * time.Millisecond)
+	return server.Sub(local)
+}
+
+// measureClockSkew measures the skew between the local clock and the clock of
+// the API server described by the given config. The request is abandoned if
+// the context is cancelled.
+func measureClockSkew(ctx context.Context, config *rest.Config) (time.Duration, error) {
+	transport, err := rest.TransportFor(config)
+	if err != nil {
+		return 0, err
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   clockSkewTimeout,
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(config.Host, "/")+"/version", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req.WithContext(ctx))
+	end := time.Now()
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Any response from the API server includes the Date header, so the
+	// status of the response does not matter.
+	header := resp.Header.Get("Date")
+	if header == "" {
+		return 0, errors.New("API server response has no Date header")
+	}
+	date, err := http.ParseTime(header)
+	if err != nil {
+		return 0, err
+	}
+	return clockSkew(start, end, date), nil
+}
+
+// checkClockSkew checks the skew between the local clock and the API server's
+// clock, logging a warning if it exceeds the configured maximum. Lease timings
+// are based on the clocks of the elector nodes, so skew between them can cause
+// the election to misbehave.
+//
+// The check never prevents the node from running, so failures are only logged.
+func (node *ElectorNode) checkClockSkew() {
+	if node.config.MaxClockSkew <= 0 {
+		return
+	}
+
+	config, err := node.buildClientConfig()
+	if err != nil {
+		node.log.Warningf("unable to check clock skew: %v", err)
+		return
+	}
+	skew, err := measureClockSkew(node.ctx, config)
+	if node.ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		node.log.Warningf("unable to check clock skew: %v", err)
+		return
+	}
+
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+	if abs > node.config.MaxClockSkew {
+		node.log.Warningf(
+			"clock skew with the API server (%v) exceeds the maximum of %v: the election may be unstable",
+			skew, node.config.MaxClockSkew,
+		)
+		return
+	}
+	node.log.Infof("clock skew with the API server: %v", skew)
+}
@@ -0,0 +1,148 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/rest"
+)
+
+func TestClockSkew(t *testing.T) {
+	start := time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		description string
+		end         time.Time
+		date        time.Time
+		expected    time.Duration
+	}{
+		{
+			description: "clocks agree",
+			end:         start.Add(1 * time.Second),
+			date:        start,
+			expected:    0,
+		},
+		{
+			description: "server clock is ahead",
+			end:         start.Add(1 * time.Second),
+			date:        start.Add(10 * time.Second),
+			expected:    10 * time.Second,
+		},
+		{
+			description: "server clock is behind",
+			end:         start.Add(1 * time.Second),
+			date:        start.Add(-10 * time.Second),
+			expected:    -10 * time.Second,
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, clockSkew(start, c.end, c.date), c.description)
+	}
+}
+
+// newSkewedServer creates an API server whose clock is offset by the given
+// skew.
+func newSkewedServer(skew time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(skew).UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+}
+
+func TestMeasureClockSkew(t *testing.T) {
+	server := newSkewedServer(1 * time.Hour)
+	defer server.Close()
+
+	skew, err := measureClockSkew(context.Background(), &rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+	assert.InDelta(t, float64(1*time.Hour), float64(skew), float64(2*time.Second))
+}
+
+func TestMeasureClockSkew_error(t *testing.T) {
+	server := newSkewedServer(0)
+	server.Close()
+
+	_, err := measureClockSkew(context.Background(), &rest.Config{Host: server.URL})
+	assert.Error(t, err)
+}
+
+// writeKubeConfig writes a kubeconfig file for the given server, returning
+// the path to the file.
+func writeKubeConfig(t *testing.T, server string) string {
+	f, err := ioutil.TempFile("", "kubeconfig")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: %s
+  name: test-cluster
+contexts:
+- context:
+    cluster: test-cluster
+  name: test
+current-context: test
+`, server)
+	assert.NoError(t, err)
+	return f.Name()
+}
+
+func TestElectorNode_checkClockSkew(t *testing.T) {
+	cases := []struct {
+		description string
+		skew        time.Duration
+		maxSkew     time.Duration
+		expected    string
+	}{
+		{
+			description: "skew within the maximum",
+			skew:        0,
+			maxSkew:     5 * time.Second,
+			expected:    "INFO clock skew with the API server",
+		},
+		{
+			description: "skew exceeds the maximum",
+			skew:        1 * time.Minute,
+			maxSkew:     5 * time.Second,
+			expected:    "WARNING clock skew with the API server",
+		},
+		{
+			description: "check disabled",
+			skew:        1 * time.Minute,
+			maxSkew:     0,
+			expected:    "",
+		},
+	}
+
+	for _, c := range cases {
+		server := newSkewedServer(c.skew)
+		kubeconfig := writeKubeConfig(t, server.URL)
+
+		buf := &testLogger{}
+		node := NewElectorNode(&ElectorConfig{
+			KubeConfig:   kubeconfig,
+			MaxClockSkew: c.maxSkew,
+			Logger:       buf,
+		})
+		node.checkClockSkew()
+
+		if c.expected == "" {
+			assert.Empty(t, buf.String(), c.description)
+		} else {
+			assert.Contains(t, buf.String(), c.expected, c.description)
+		}
+
+		server.Close()
+		os.Remove(kubeconfig)
+	}
+}
@@ -0,0 +1,109 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"fmt"
+	"time"
+)
+
+// staleExpiryFactor is the multiple of the stale threshold after which stale
+// leader info is no longer served, unless a stale expiry is configured.
+const staleExpiryFactor = 3
+
+// staleness describes how current the node's view of the leader is.
+type staleness int
+
+const (
+	// leaderFresh is a view of the leader which was confirmed by reading the
+	// election lock within the stale threshold.
+	leaderFresh staleness = iota
+
+	// leaderStale is a view of the leader which has not been confirmed
+	// within the stale threshold. It is still served, but flagged as stale.
+	leaderStale
+
+	// leaderExpired is a view of the leader which has not been confirmed
+	// within the stale expiry. The leader is no longer served.
+	leaderExpired
+)
+
+// staleThresholds gets the stale threshold and expiry of the node. Unless
+// they are configured, they are derived from the lease duration: leader info
+// is stale once the lock has not been read for a lease duration, and expires
+// after three times the stale threshold.
+func (node *ElectorNode) staleThresholds() (threshold, expiry time.Duration) {
+	threshold = node.config.StaleThreshold
+	if threshold == 0 {
+		threshold = node.timings().LeaseDuration
+	}
+	expiry = node.config.StaleExpiry
+	if expiry == 0 {
+		expiry = staleExpiryFactor * threshold
+	}
+	return threshold, expiry
+}
+
+// checkStaleThresholds checks that the configured stale threshold and expiry
+// are valid. The expiry must exceed the threshold, so stale leader info is
+// flagged before it stops being served.
+func (node *ElectorNode) checkStaleThresholds() error {
+	if node.config.StaleThreshold < 0 {
+		return fmt.Errorf("invalid stale threshold %v: must not be negative", node.config.StaleThreshold)
+	}
+	if node.config.StaleExpiry < 0 {
+		return fmt.Errorf("invalid stale expiry %v: must not be negative", node.config.StaleExpiry)
+	}
+	if node.config.StaleExpiry == 0 {
+		return nil
+	}
+	if threshold, expiry := node.staleThresholds(); expiry <= threshold {
+		return fmt.Errorf("invalid stale expiry %v: must be longer than the stale threshold (%v)", expiry, threshold)
+	}
+	return nil
+}
+
+// leaderStaleness gets how current the node's view of the leader is.
+func (node *ElectorNode) leaderStaleness() staleness {
+	threshold, expiry := node.staleThresholds()
+	now := node.clock.Now()
+
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.leaderStalenessLocked(now, threshold, expiry)
+}
+
+// leaderStalenessLocked gets how current the node's view of the leader is at
+// the given time, from how long it has been since the election lock was last
+// read. If the lock has not yet been read, or the node is the leader, the
+// view is current: a leader steps down once it can not renew its lease
+// within the renew deadline, well within a lease duration.
+//
+// The caller must hold the node's lock.
+func (node *ElectorNode) leaderStalenessLocked(now time.Time, threshold, expiry time.Duration) staleness {
+	if node.lastObserved.IsZero() || node.currentLeader == "" || node.currentLeader == node.config.ID {
+		return leaderFresh
+	}
+	switch since := now.Sub(node.lastObserved); {
+	case since > expiry:
+		return leaderExpired
+	case since > threshold:
+		return leaderStale
+	default:
+		return leaderFresh
+	}
+}
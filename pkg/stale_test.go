@@ -0,0 +1,214 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestElectorNode_staleThresholds(t *testing.T) {
+	cases := []struct {
+		description string
+		config      *ElectorConfig
+		threshold   time.Duration
+		expiry      time.Duration
+	}{
+		{
+			description: "derived from the lease duration",
+			config:      &ElectorConfig{TTL: 10 * time.Second},
+			threshold:   10 * time.Second,
+			expiry:      30 * time.Second,
+		},
+		{
+			description: "threshold set",
+			config:      &ElectorConfig{TTL: 10 * time.Second, StaleThreshold: 20 * time.Second},
+			threshold:   20 * time.Second,
+			expiry:      60 * time.Second,
+		},
+		{
+			description: "threshold and expiry set",
+			config:      &ElectorConfig{TTL: 10 * time.Second, StaleThreshold: 20 * time.Second, StaleExpiry: 25 * time.Second},
+			threshold:   20 * time.Second,
+			expiry:      25 * time.Second,
+		},
+	}
+
+	for _, c := range cases {
+		c.config.Logger = &testLogger{}
+		node := NewElectorNode(c.config)
+
+		threshold, expiry := node.staleThresholds()
+		assert.Equal(t, c.threshold, threshold, c.description)
+		assert.Equal(t, c.expiry, expiry, c.description)
+	}
+}
+
+func TestElectorNode_checkConfig_stale(t *testing.T) {
+	cases := []struct {
+		description string
+		threshold   time.Duration
+		expiry      time.Duration
+		err         string
+	}{
+		{
+			description: "defaults",
+		},
+		{
+			description: "expiry longer than the threshold",
+			threshold:   10 * time.Second,
+			expiry:      20 * time.Second,
+		},
+		{
+			description: "expiry longer than the default threshold",
+			expiry:      20 * time.Second,
+		},
+		{
+			description: "negative threshold",
+			threshold:   -time.Second,
+			err:         "invalid stale threshold -1s: must not be negative",
+		},
+		{
+			description: "negative expiry",
+			expiry:      -time.Second,
+			err:         "invalid stale expiry -1s: must not be negative",
+		},
+		{
+			description: "expiry not longer than the threshold",
+			threshold:   20 * time.Second,
+			expiry:      20 * time.Second,
+			err:         "invalid stale expiry 20s: must be longer than the stale threshold (20s)",
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			Name:           "test-election",
+			PodName:        "test-pod",
+			TTL:            10 * time.Second,
+			StaleThreshold: c.threshold,
+			StaleExpiry:    c.expiry,
+			Logger:         &testLogger{},
+		})
+
+		err := node.checkConfig()
+		if c.err == "" {
+			assert.NoError(t, err, c.description)
+		} else {
+			assert.EqualError(t, err, c.err, c.description)
+		}
+	}
+}
+
+func TestElectorNode_leaderStaleness(t *testing.T) {
+	cases := []struct {
+		description string
+		leader      string
+		observed    bool
+		since       time.Duration
+		expected    staleness
+	}{
+		{
+			description: "lock read within the threshold",
+			leader:      "test-node-2",
+			observed:    true,
+			since:       10 * time.Second,
+			expected:    leaderFresh,
+		},
+		{
+			description: "lock not read within the threshold",
+			leader:      "test-node-2",
+			observed:    true,
+			since:       11 * time.Second,
+			expected:    leaderStale,
+		},
+		{
+			description: "lock not read within the expiry",
+			leader:      "test-node-2",
+			observed:    true,
+			since:       31 * time.Second,
+			expected:    leaderExpired,
+		},
+		{
+			description: "lock never read",
+			leader:      "test-node-2",
+			since:       31 * time.Second,
+			expected:    leaderFresh,
+		},
+		{
+			description: "node is the leader",
+			leader:      "test-node-1",
+			observed:    true,
+			since:       31 * time.Second,
+			expected:    leaderFresh,
+		},
+		{
+			description: "no leader",
+			observed:    true,
+			since:       31 * time.Second,
+			expected:    leaderFresh,
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:     "test-node-1",
+			TTL:    10 * time.Second,
+			Logger: &testLogger{},
+		})
+		clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+		node.clock = clk
+		node.setLeader(c.leader)
+		if c.observed {
+			node.mu.Lock()
+			node.lastObserved = clk.Now()
+			node.mu.Unlock()
+		}
+
+		clk.Step(c.since)
+		assert.Equal(t, c.expected, node.leaderStaleness(), c.description)
+	}
+}
+
+func TestElectorNode_httpLeaderInfo_stale(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		TTL:    10 * time.Second,
+		Logger: &testLogger{},
+	})
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	node.clock = clk
+	node.setLeader("test-node-2")
+	node.mu.Lock()
+	node.lastObserved = clk.Now()
+	node.mu.Unlock()
+
+	info := getJSON(t, node, "/")
+	assert.Equal(t, "test-node-2", info["leader"])
+	assert.Equal(t, false, info["stale"])
+
+	// Once the lock has not been read within the threshold, the leader is
+	// still served, but flagged as stale.
+	clk.Step(15 * time.Second)
+	info = getJSON(t, node, "/")
+	assert.Equal(t, "test-node-2", info["leader"])
+	assert.Equal(t, true, info["stale"])
+	assert.True(t, node.leaderInfo().Stale)
+
+	// Once it has not been read within the expiry, the leader is no longer
+	// served.
+	clk.Step(30 * time.Second)
+	info = getJSON(t, node, "/")
+	assert.Equal(t, "", info["leader"])
+	assert.Equal(t, true, info["stale"])
+	assert.Equal(t, "", node.leaderInfo().Leader)
+
+	// Once the lock is read again, the leader is current.
+	node.mu.Lock()
+	node.lastObserved = clk.Now()
+	node.mu.Unlock()
+	info = getJSON(t, node, "/")
+	assert.Equal(t, "test-node-2", info["leader"])
+	assert.Equal(t, false, info["stale"])
+}
@@ -0,0 +1,97 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StatusInitializing is the state reported by a node which is running but
+// has not yet started its election, e.g. while it builds its Kubernetes
+// client, or after it failed to.
+const StatusInitializing = "initializing"
+
+// ErrTerminatedDuringStartup is returned by Run if the node was shut down,
+// e.g. by a termination signal, before its election started. It wraps
+// context.Canceled, as it is a graceful shutdown rather than a failure.
+var ErrTerminatedDuringStartup = fmt.Errorf("terminated during startup: %w", context.Canceled)
+
+// setInitializing sets whether the node is running but has not yet started
+// its election.
+func (node *ElectorNode) setInitializing(initializing bool) {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.initializing = initializing
+}
+
+// isInitializing checks whether the node is running but has not yet started
+// its election.
+func (node *ElectorNode) isInitializing() bool {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.initializing
+}
+
+// startupError gets the error which prevented the node's election from
+// starting, if any.
+func (node *ElectorNode) startupError() error {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	return node.startupErr
+}
+
+// failStartup handles an error which prevented the node's election from
+// starting, e.g. an invalid kubeconfig.
+//
+// The node stays up in the initializing state for the configured grace
+// period, with the error reported at /healthz, so the failure can be
+// inspected through the HTTP endpoints rather than only from the logs of a
+// restarting container. The error is returned once the grace period ends or
+// the node is stopped.
+func (node *ElectorNode) failStartup(err error) error {
+	node.mu.Lock()
+	node.startupErr = err
+	node.mu.Unlock()
+
+	grace := node.config.StartupFailureGracePeriod
+	if grace <= 0 {
+		return err
+	}
+	node.log.Errorf("failed to start the election: %v; exiting in %v", err, grace)
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-node.ctx.Done():
+	}
+	return err
+}
+
+// startupCancelled checks whether the node was shut down before its election
+// started, e.g. by a termination signal while it built its Kubernetes client.
+// The node holds no lease and has published no status then, so it stops
+// without standing for election.
+func (node *ElectorNode) startupCancelled() bool {
+	if node.ctx.Err() == nil {
+		return false
+	}
+	node.log.Info("shut down before the election started")
+	return true
+}
@@ -0,0 +1,329 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// newTestStartupFailureNode creates a node whose election fails to start,
+// since its kubeconfig does not exist.
+func newTestStartupFailureNode(grace time.Duration) *ElectorNode {
+	return NewElectorNode(&ElectorConfig{
+		ID:                        "test-node-1",
+		Name:                      "test-election",
+		Namespace:                 "test-ns",
+		PodName:                   "test-pod",
+		KubeConfig:                "/nonexistent/kubeconfig",
+		TTL:                       1 * time.Second,
+		Logger:                    &testLogger{},
+		StartupFailureGracePeriod: grace,
+	})
+}
+
+func TestElectorNode_Run_startupFailureGracePeriod(t *testing.T) {
+	defer os.Unsetenv(EnvKubeConfigData)
+	os.Unsetenv(EnvKubeConfigData)
+	node := newTestStartupFailureNode(time.Minute)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.Run()
+	}()
+
+	health := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		w := httptest.NewRecorder()
+		node.mux().ServeHTTP(w, req)
+		return w
+	}
+	waitFor(t, 5*time.Second, func() bool {
+		return health().Code == http.StatusServiceUnavailable
+	})
+
+	// While in the grace period, the node can be inspected.
+	data := getJSON(t, node, "/healthz")
+	assert.Equal(t, "failed", data["status"])
+	assert.Contains(t, data["startup_error"], "/nonexistent/kubeconfig")
+	assert.Equal(t, StatusInitializing, node.state())
+
+	data = getJSON(t, node, "/")
+	assert.Equal(t, "", data["leader"])
+
+	select {
+	case err := <-done:
+		assert.Fail(t, "node stopped in the grace period", "%v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Stopping the node ends the grace period, and the startup error is
+	// returned.
+	node.Stop()
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "/nonexistent/kubeconfig")
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node did not stop")
+	}
+}
+
+func TestElectorNode_Run_startupFailure(t *testing.T) {
+	cases := []struct {
+		description string
+		grace       time.Duration
+	}{
+		{
+			description: "no grace period",
+			grace:       0,
+		},
+		{
+			description: "grace period ends",
+			grace:       100 * time.Millisecond,
+		},
+	}
+
+	defer os.Unsetenv(EnvKubeConfigData)
+	os.Unsetenv(EnvKubeConfigData)
+	for _, c := range cases {
+		node := newTestStartupFailureNode(c.grace)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- node.Run()
+		}()
+
+		select {
+		case err := <-done:
+			assert.Error(t, err, c.description)
+			assert.Equal(t, err, node.startupError(), c.description)
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "node did not stop", c.description)
+		}
+	}
+}
+
+func TestElectorNode_healthInfo_startupError(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+	assert.Equal(t, HealthInfo{Status: "ok"}, node.healthInfo())
+
+	node.startupErr = errors.New("test error")
+	assert.Equal(t, HealthInfo{Status: "failed", StartupError: "test error"}, node.healthInfo())
+}
+
+func TestElectorNode_state_initializing(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+	assert.Equal(t, StatusUnknown, node.state())
+
+	node.setInitializing(true)
+	assert.Equal(t, StatusInitializing, node.state())
+
+	node.setInitializing(false)
+	node.setLeader("test-node-2")
+	assert.Equal(t, StatusStandby, node.state())
+}
+
+func TestElectorNode_run_signalBeforeElection(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		PodName:       "test-pod",
+		LockType:      resourcelock.LeasesResourceLock,
+		TTL:           1 * time.Second,
+		Client:        client,
+		Logger:        &testLogger{},
+	})
+
+	// The signal arrives before the election starts, e.g. while the node
+	// builds its Kubernetes client.
+	go node.listenForSignal()
+	node.quit <- syscall.SIGTERM
+	waitFor(t, 3*time.Second, func() bool {
+		return node.ctx.Err() != nil
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.run()
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "election did not stop")
+	}
+
+	// The node neither touched the lease nor labeled its Pod.
+	assert.Empty(t, client.Actions())
+}
+
+func TestElectorNode_Run_signalBeforeAcquisition(t *testing.T) {
+	// The lease is held by another node, so the node does not acquire it
+	// before the signal arrives.
+	holder := "test-node-2"
+	duration := int32(60)
+	now := metav1.NewMicroTime(time.Now())
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"), &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-election",
+			Namespace: "test-ns",
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			AcquireTime:          &now,
+			RenewTime:            &now,
+		},
+	})
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		PodName:       "test-pod",
+		LockType:      resourcelock.LeasesResourceLock,
+		TTL:           1 * time.Second,
+		Client:        client,
+		Logger:        &testLogger{},
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.Run()
+	}()
+	waitFor(t, 5*time.Second, func() bool {
+		return node.leader() == "test-node-2"
+	})
+
+	node.quit <- syscall.SIGTERM
+	select {
+	case err := <-done:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node did not stop")
+	}
+
+	// The node never led, so it did not step down or release the lease.
+	node.mu.RLock()
+	assert.False(t, node.demoted)
+	node.mu.RUnlock()
+	lease, err := client.CoordinationV1().Leases("test-ns").Get("test-election", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "test-node-2", *lease.Spec.HolderIdentity)
+}
+
+// newTestSetupNode creates a node which is shut down during its setup, before
+// its election starts.
+func newTestSetupNode(client *fake.Clientset) *ElectorNode {
+	return NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		PodName:       "test-pod",
+		LockType:      resourcelock.LeasesResourceLock,
+		TTL:           1 * time.Second,
+		Client:        client,
+		Logger:        &testLogger{},
+	})
+}
+
+// runUntilStopped runs the node, failing the test if it does not stop within
+// a second.
+func runUntilStopped(t *testing.T, node *ElectorNode) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- node.Run()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(time.Second):
+		assert.Fail(t, "node did not stop")
+		return nil
+	}
+}
+
+// assertNoLeaseActions checks that the node did not touch the election lease.
+func assertNoLeaseActions(t *testing.T, client *fake.Clientset) {
+	for _, action := range client.Actions() {
+		assert.NotEqual(t, "leases", action.GetResource().Resource, action.GetVerb())
+	}
+}
+
+func TestElectorNode_Run_signalBeforeRun(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := newTestSetupNode(client)
+
+	// The signal is delivered before the node is run; it is handled once the
+	// node's signal listener starts.
+	node.quit <- syscall.SIGTERM
+
+	err := runUntilStopped(t, node)
+	assert.True(t, errors.Is(err, context.Canceled), "%v", err)
+	assertNoLeaseActions(t, client)
+}
+
+func TestElectorNode_Run_cancelledBeforeRun(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := newTestSetupNode(client)
+	node.cancel()
+
+	assert.Equal(t, ErrTerminatedDuringStartup, runUntilStopped(t, node))
+	assert.Empty(t, client.Actions())
+}
+
+func TestElectorNode_Run_cancelledDuringClockSkewCheck(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	node := newTestSetupNode(client)
+
+	// The API server does not respond until the node gives up on it.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		node.cancel()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	node.config.KubeConfig = writeKubeConfig(t, server.URL)
+	defer os.Remove(node.config.KubeConfig)
+	node.config.MaxClockSkew = 5 * time.Second
+
+	assert.Equal(t, ErrTerminatedDuringStartup, runUntilStopped(t, node))
+	assert.Empty(t, client.Actions())
+}
+
+func TestElectorNode_Run_cancelledDuringNamespaceCheck(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		newTestPod("test-ns", "test-pod"),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}},
+	)
+	node := newTestSetupNode(client)
+	node.config.VerifyNamespace = true
+	client.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		node.cancel()
+		return false, nil, nil
+	})
+
+	assert.Equal(t, ErrTerminatedDuringStartup, runUntilStopped(t, node))
+	assertNoLeaseActions(t, client)
+}
@@ -0,0 +1,411 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"encoding/json"
+	"os"
+
+	"k8s.io/client-go/tools/leaderelection"
+)
+
+// StatusUnknown is the state reported by a node which has not yet observed
+// a leader for the election.
+const StatusUnknown = "unknown"
+
+// ConfigInfo describes the effective configuration of an elector node.
+//
+// Sensitive values, such as the HTTP auth token, are not included.
+type ConfigInfo struct {
+	ID                          string        `json:"id" description:"The ID of the elector node."`
+	IDLabelValue                string        `json:"id_label_value" description:"A form of the ID which is a valid Kubernetes label value. It is the ID itself, unless the ID is not a valid label value, in which case it is sanitized, truncated, and suffixed with a hash of the ID."`
+	Name                        string        `json:"election" description:"The name of the election."`
+	ElectionName                string        `json:"election_name" description:"The rendered name of the election loop, which identifies the node's election in the leader election client's logs."`
+	RoleName                    string        `json:"role_name" description:"The human-readable role name of the node, which is cosmetic and never used for election decisions."`
+	CanaryElection              string        `json:"canary_election" description:"The name of the canary election, if any."`
+	Namespace                   string        `json:"namespace" description:"The namespace of the elector's Pod."`
+	LockNamespace               string        `json:"lock_namespace" description:"The namespace of the election lock object."`
+	VerifyNamespace             bool          `json:"verify_namespace" description:"Whether the node checks that its namespaces exist when it starts."`
+	PodName                     string        `json:"pod_name" description:"The name of the Pod the elector runs in."`
+	PodIP                       string        `json:"pod_ip" description:"The IP of the Pod the elector runs in, if known."`
+	StructuredID                bool          `json:"structured_id" description:"Whether the default ID is a structured ID, as <hostname>/<pod IP>/<node name>."`
+	NodeName                    string        `json:"node_name" description:"The name of the Kubernetes node the elector's Pod runs on, if known."`
+	PodCache                    bool          `json:"pod_cache" description:"Whether reads of the elector's Pod are served from an informer which watches it."`
+	RespectForeignLabels        bool          `json:"respect_foreign_labels" description:"Whether a Pod status label set by something other than the elector is left alone, rather than overwritten."`
+	External                    bool          `json:"external" description:"Whether the elector runs outside of Kubernetes, with its Pod-coupled features disabled."`
+	PureElection                bool          `json:"pure_election" description:"Whether the elector runs a pure election, without side effects on the Pod or external systems."`
+	Address                     string        `json:"address" description:"The address the HTTP server listens on."`
+	AccessLog                   bool          `json:"access_log" description:"Whether HTTP access logging is enabled."`
+	InvertLeaderStatus          bool          `json:"invert_leader_status" description:"Whether the status codes of the leader status endpoint are inverted."`
+	EventIDHeader               string        `json:"event_id_header" description:"The header which carries the ID of the node's last leadership event on HTTP responses and webhook requests."`
+	MaxWatchers                 int           `json:"max_watchers" description:"The maximum number of streams which may watch the node's leadership transitions at once."`
+	WatchWriteTimeoutSeconds    Seconds       `json:"watch_write_timeout_seconds" description:"How long a watch stream may fall behind before it is closed, in seconds."`
+	WatchWriteTimeoutHuman      HumanDuration `json:"watch_write_timeout_human" description:"How long a watch stream may fall behind before it is closed, as a duration string."`
+	LockType                    string        `json:"lock_type" description:"The type of Kubernetes object used as the election lock."`
+	LockResource                string        `json:"lock_resource" description:"The resource of the election lock object, for the dynamic lock type."`
+	LockTypeMigrateFrom         string        `json:"lock_type_migrate_from" description:"The lock type the election lock is being migrated from, if any."`
+	KubeConfig                  string        `json:"kubeconfig" description:"The kubeconfig file used, if any."`
+	KubeConfigData              bool          `json:"kubeconfig_data" description:"Whether the kubeconfig was provided in the environment. Its content is never reported."`
+	ClientMaxIdleConns          int           `json:"client_max_idle_conns" description:"The maximum number of idle connections kept by the Kubernetes client, across all hosts."`
+	ClientMaxIdleConnsPerHost   int           `json:"client_max_idle_conns_per_host" description:"The maximum number of idle connections kept by the Kubernetes client for each host."`
+	TTLSeconds                  Seconds       `json:"ttl_seconds" description:"The TTL for the election, in seconds."`
+	TTLHuman                    HumanDuration `json:"ttl_human" description:"The TTL for the election, as a duration string."`
+	MaxClockSkewSeconds         Seconds       `json:"max_clock_skew_seconds" description:"The maximum tolerated skew between the node's clock and the API server's clock, in seconds."`
+	MaxClockSkewHuman           HumanDuration `json:"max_clock_skew_human" description:"The maximum tolerated skew between the node's clock and the API server's clock, as a duration string."`
+	AdoptExistingLeaseDuration  bool          `json:"adopt_existing_lease_duration" description:"Whether the lease duration of an existing lock is adopted."`
+	KeepLeaseOnShutdown         bool          `json:"keep_lease_on_shutdown" description:"Whether the leader keeps the election lock when it stops, rather than releasing it."`
+	RepairCorruptLock           bool          `json:"repair_corrupt_lock" description:"Whether a corrupt election lock record is overwritten once it has expired."`
+	SingleNode                  bool          `json:"single_node" description:"Whether the node runs without an election, as the only node."`
+	Chaos                       bool          `json:"chaos" description:"Whether the chaos hooks, which inject failures on request for resilience testing, are enabled."`
+	StartupFailureGraceSeconds  Seconds       `json:"startup_failure_grace_period_seconds" description:"How long the node stays up after its election fails to start, in seconds."`
+	StartupFailureGraceHuman    HumanDuration `json:"startup_failure_grace_period_human" description:"How long the node stays up after its election fails to start, as a duration string."`
+	StaleThresholdSeconds       Seconds       `json:"stale_threshold_seconds" description:"How long the node may go without reading the election lock before the leader it reports is flagged as stale, in seconds."`
+	StaleThresholdHuman         HumanDuration `json:"stale_threshold_human" description:"How long the node may go without reading the election lock before the leader it reports is flagged as stale, as a duration string."`
+	StaleExpirySeconds          Seconds       `json:"stale_expiry_seconds" description:"How long the node may go without reading the election lock before it stops reporting the stale leader, in seconds."`
+	StaleExpiryHuman            HumanDuration `json:"stale_expiry_human" description:"How long the node may go without reading the election lock before it stops reporting the stale leader, as a duration string."`
+	MetricsNamespace            string        `json:"metrics_namespace" description:"The prefix of the elector's metric names."`
+	MetricsIdentityLabel        bool          `json:"metrics_identity_label" description:"Whether the node identity is a label of the elector's metrics."`
+	OnElected                   string        `json:"on_elected" description:"The command run when the node becomes the leader."`
+	OnDemoted                   string        `json:"on_demoted" description:"The command run when the node stops being the leader."`
+	OnDemotedTimeoutSeconds     Seconds       `json:"on_demoted_timeout_seconds" description:"How long the on-demoted command may run before it is killed, in seconds. Zero if it is not bounded."`
+	OnDemotedTimeoutHuman       HumanDuration `json:"on_demoted_timeout_human" description:"How long the on-demoted command may run before it is killed, as a duration string."`
+	LogPrefix                   string        `json:"log_prefix" description:"The prefix added to elector log messages."`
+	LogRolePrefix               bool          `json:"log_role_prefix" description:"Whether elector log messages are prefixed with the node's current leadership role."`
+	LogRepeatEvery              int           `json:"log_repeat_every" description:"The number of occurrences after which a repeated error message is logged again. Zero if not suppressed by count."`
+	LogRepeatIntervalSeconds    Seconds       `json:"log_repeat_interval_seconds" description:"The interval after which a repeated error message is logged again, in seconds. Zero if not suppressed by interval."`
+	LogRepeatIntervalHuman      HumanDuration `json:"log_repeat_interval_human" description:"The interval after which a repeated error message is logged again, as a duration string."`
+	RecordOutages               bool          `json:"record_outages" description:"Whether leaderless windows are recorded to the outages ConfigMap."`
+	OutageThresholdSeconds      Seconds       `json:"outage_threshold_seconds" description:"The minimum duration of a leaderless window for it to be recorded, in seconds."`
+	OutageThresholdHuman        HumanDuration `json:"outage_threshold_human" description:"The minimum duration of a leaderless window for it to be recorded, as a duration string."`
+	RecordHistory               bool          `json:"record_history" description:"Whether leader transitions are recorded to the history annotation of the lock object."`
+	TerminationMessagePath      string        `json:"termination_message_path" description:"The file the termination message is written to, if any."`
+	ReconcileIntervalSeconds    Seconds       `json:"reconcile_interval_seconds" description:"The interval on which the leader verifies it still holds the election lock, in seconds."`
+	ReconcileIntervalHuman      HumanDuration `json:"reconcile_interval_human" description:"The interval on which the leader verifies it still holds the election lock, as a duration string."`
+	SlowRenewalFraction         float64       `json:"slow_renewal_fraction" description:"The fraction of the renew deadline a renewal may take before it is considered slow."`
+	PublishDebounceSeconds      Seconds       `json:"publish_debounce_seconds" description:"The debounce window for publishing the node's status, in seconds."`
+	PublishDebounceHuman        HumanDuration `json:"publish_debounce_human" description:"The debounce window for publishing the node's status, as a duration string."`
+	StatusObject                string        `json:"status_object" description:"The object whose status the leader maintains, as group/version/resource/name, if any."`
+	StatusObjectNamespace       string        `json:"status_object_namespace" description:"The namespace of the status object, if any."`
+	ExternalDNSService          string        `json:"external_dns_service" description:"The Service whose external-dns annotations and selector the leader maintains, if any."`
+	ExternalDNSHostname         string        `json:"external_dns_hostname" description:"The DNS name the leader annotates the external-dns Service with, if any."`
+	Publishers                  []string      `json:"publishers" description:"The names of the built-in publishers which publish the node's status."`
+	PostDemotionCooldownSeconds Seconds       `json:"post_demotion_cooldown_seconds" description:"The duration the node waits after demotion before re-joining the election, in seconds."`
+	PostDemotionCooldownHuman   HumanDuration `json:"post_demotion_cooldown_human" description:"The duration the node waits after demotion before re-joining the election, as a duration string."`
+	CandidacyCheck              bool          `json:"candidacy_check" description:"Whether a candidacy check gates the node standing for election."`
+	StepDownOnCandidacyLoss     bool          `json:"step_down_on_candidacy_loss" description:"Whether the leader steps down when its candidacy check stops passing."`
+	Timings                     TimingsInfo   `json:"timings" description:"The effective timings used by the election."`
+}
+
+// TimingsInfo describes the effective timings used by the election.
+type TimingsInfo struct {
+	LeaseDurationSeconds Seconds       `json:"lease_duration_seconds" description:"The duration non-leader candidates wait before force acquiring leadership, in seconds."`
+	LeaseDurationHuman   HumanDuration `json:"lease_duration_human" description:"The duration non-leader candidates wait before force acquiring leadership, as a duration string."`
+	RenewDeadlineSeconds Seconds       `json:"renew_deadline_seconds" description:"The duration the leader retries refreshing leadership before giving it up, in seconds."`
+	RenewDeadlineHuman   HumanDuration `json:"renew_deadline_human" description:"The duration the leader retries refreshing leadership before giving it up, as a duration string."`
+	RetryPeriodSeconds   Seconds       `json:"retry_period_seconds" description:"The duration nodes wait between election actions, in seconds."`
+	RetryPeriodHuman     HumanDuration `json:"retry_period_human" description:"The duration nodes wait between election actions, as a duration string."`
+}
+
+// TimingDetails describes the timings of the election in detail, including the
+// renewal deadlines of the leader.
+type TimingDetails struct {
+	LeaseDurationSeconds Seconds       `json:"lease_duration_seconds" description:"The duration non-leader candidates wait before force acquiring leadership, in seconds."`
+	LeaseDurationHuman   HumanDuration `json:"lease_duration_human" description:"The duration non-leader candidates wait before force acquiring leadership, as a duration string."`
+	RenewDeadlineSeconds Seconds       `json:"renew_deadline_seconds" description:"The duration the leader retries refreshing leadership before giving it up, in seconds."`
+	RenewDeadlineHuman   HumanDuration `json:"renew_deadline_human" description:"The duration the leader retries refreshing leadership before giving it up, as a duration string."`
+	RetryPeriodSeconds   Seconds       `json:"retry_period_seconds" description:"The duration nodes wait between election actions, in seconds."`
+	RetryPeriodHuman     HumanDuration `json:"retry_period_human" description:"The duration nodes wait between election actions, as a duration string."`
+	JitterFactor         float64       `json:"jitter_factor" description:"The factor by which the retry period of non-leader candidates is jittered."`
+	RejoinDelaySeconds   Seconds       `json:"rejoin_delay_seconds" description:"The duration the node waits before re-joining the election after it exits, in seconds. The post-demotion cooldown is added to this after a demotion."`
+	RejoinDelayHuman     HumanDuration `json:"rejoin_delay_human" description:"The duration the node waits before re-joining the election after it exits, as a duration string. The post-demotion cooldown is added to this after a demotion."`
+	LastRenew            Timestamp     `json:"last_renew" description:"The timestamp of the leader's last successful renewal. Empty if the node is not the leader."`
+	NextRenew            Timestamp     `json:"next_renew" description:"The timestamp of the leader's next expected renewal. Empty if the node is not the leader."`
+	RenewDeadlineAt      Timestamp     `json:"renew_deadline_at" description:"The timestamp by which the leader must renew before it gives up leadership. Empty if the node is not the leader."`
+	ObservedLeaseSeconds Seconds       `json:"observed_lease_duration_seconds" description:"The lease duration recorded by the holder of the election lock, which its lease expires by, in seconds. This is the node's own lease duration until a lock record is observed."`
+	ObservedLeaseHuman   HumanDuration `json:"observed_lease_duration_human" description:"The lease duration recorded by the holder of the election lock, which its lease expires by, as a duration string. This is the node's own lease duration until a lock record is observed."`
+	LeaseExpiresAt       Timestamp     `json:"lease_expires_at" description:"The timestamp at which the lease of the holder of the election lock expires unless renewed, as measured by the node. Empty until a held lock record is observed."`
+}
+
+// HealthInfo describes the health of the elector node.
+type HealthInfo struct {
+	Status             string `json:"status" description:"The health status of the node."`
+	SplitBrainDetected bool   `json:"split_brain_detected" description:"Whether the node found the election lock held by another identity while it believed it was the leader."`
+	LockCorrupt        bool   `json:"lock_corrupt" description:"Whether the election lock has a record which can not be parsed, so the leader is unknown."`
+	StartupError       string `json:"startup_error" description:"The error which prevented the election from starting, if any. The node is unhealthy if this is set."`
+	Paused             bool   `json:"paused" description:"Whether the node's participation in the election is paused."`
+	Serving            bool   `json:"serving" description:"Whether the node's HTTP server is serving."`
+	InFlightOperations int    `json:"in_flight_operations" description:"The number of side-effect operations, such as status publishes and election commands, which are in flight. The node waits for these to finish when it stops."`
+}
+
+// StatusSnapshot is a full snapshot of the elector node's status.
+type StatusSnapshot struct {
+	Config             ConfigInfo        `json:"config" description:"The effective configuration of the node."`
+	Leader             LeaderInfo        `json:"leader" description:"The leadership status of the node."`
+	State              string            `json:"state" description:"The state of the node (initializing, leader, standby, corrupt, or unknown)."`
+	StateSince         Timestamp         `json:"state_since" description:"The timestamp for when the node entered its current state."`
+	Restarts           int               `json:"restarts" description:"The number of times the election loop has been re-run."`
+	RestartReason      string            `json:"restart_reason,omitempty" description:"Why the election loop was last re-run. Not set if it has not been re-run."`
+	ServingHTTP        bool              `json:"serving_http" description:"Whether the HTTP server is serving."`
+	CollapsedPublishes int               `json:"collapsed_publishes" description:"The number of status changes which were collapsed by the publish debounce rather than published."`
+	SlowRenewals       int               `json:"slow_renewals" description:"The number of lease renewals which took longer than the configured fraction of the renew deadline."`
+	LockDeletions      int               `json:"lock_deletions" description:"The number of times the election lock object was found deleted externally. The election re-creates it."`
+	Publishers         []PublisherHealth `json:"publishers" description:"The health of the status publishers of the current run of the election."`
+}
+
+// PublisherHealth describes the health of a status publisher.
+type PublisherHealth struct {
+	Name      string `json:"name" description:"The name of the publisher."`
+	Healthy   bool   `json:"healthy" description:"Whether the last attempt to publish a status succeeded."`
+	LastError string `json:"last_error,omitempty" description:"The error of the last attempt to publish a status, if it failed."`
+	Published string `json:"published" description:"The status last published. Empty if none has been published yet."`
+	Pending   string `json:"pending" description:"The status waiting out the publish debounce, if any."`
+	Failures  int    `json:"failures" description:"The number of status changes which failed to publish."`
+	Collapsed int    `json:"collapsed" description:"The number of status changes which were collapsed rather than published."`
+}
+
+// healthInfo gets the health of the node.
+func (node *ElectorNode) healthInfo() HealthInfo {
+	health := HealthInfo{
+		Status:             "ok",
+		SplitBrainDetected: node.splitBrain(),
+		LockCorrupt:        node.isLockCorrupt(),
+		Paused:             node.isPaused(),
+		Serving:            node.isServingHTTP(),
+		InFlightOperations: node.operations.inFlight(),
+	}
+	if err := node.startupError(); err != nil {
+		health.Status = "failed"
+		health.StartupError = err.Error()
+	}
+	return health
+}
+
+// leaderInfo gets the leadership status of the node.
+func (node *ElectorNode) leaderInfo() LeaderInfo {
+	hasLed, acquisitions := node.leadership()
+	stale := node.leaderStaleness()
+	leader := node.leader()
+	if stale == leaderExpired {
+		leader = ""
+	}
+	return LeaderInfo{
+		Node:           node.config.ID,
+		Role:           node.config.role(),
+		NodeName:       node.config.NodeName,
+		Leader:         leader,
+		LeaderMeta:     parseLeaderMeta(leader),
+		PreviousLeader: node.previousLeaderID(),
+		Leaderless:     node.isLeaderless(),
+		Stale:          stale != leaderFresh,
+		EventID:        node.lastEventID(),
+		IsLeader:       node.IsLeader(),
+		HasLed:         hasLed,
+		Acquisitions:   acquisitions,
+		ElectionEpoch:  node.electionEpoch(),
+		LeaseMismatch:  node.leaseDurationMismatch(),
+		Renewals:       node.renewCount(),
+		Timestamp:      Timestamp(node.clock.Now()),
+	}
+}
+
+// configInfo gets the effective configuration of the node.
+func (node *ElectorNode) configInfo() ConfigInfo {
+	// The template is validated by checkConfig, so this only fails if the node
+	// has not been started, in which case the name is left empty.
+	electionName, _ := renderElectionName(node.config)
+	staleThreshold, staleExpiry := node.staleThresholds()
+
+	return ConfigInfo{
+		ID:                          node.config.ID,
+		IDLabelValue:                idLabelValue(node.config.ID),
+		Name:                        node.config.Name,
+		ElectionName:                electionName,
+		RoleName:                    node.config.role(),
+		CanaryElection:              node.config.CanaryElection,
+		Namespace:                   node.config.Namespace,
+		LockNamespace:               node.config.LockNamespace,
+		VerifyNamespace:             node.config.VerifyNamespace,
+		PodName:                     node.config.PodName,
+		PodIP:                       node.config.PodIP,
+		StructuredID:                node.config.StructuredID,
+		NodeName:                    node.config.NodeName,
+		PodCache:                    node.config.PodCache,
+		RespectForeignLabels:        node.config.RespectForeignLabels,
+		External:                    node.config.External,
+		PureElection:                node.config.PureElection,
+		Address:                     node.config.Address,
+		AccessLog:                   node.config.AccessLog,
+		InvertLeaderStatus:          node.config.InvertLeaderStatus,
+		EventIDHeader:               node.eventIDHeader(),
+		MaxWatchers:                 node.maxWatchers(),
+		WatchWriteTimeoutSeconds:    Seconds(node.watchWriteTimeout()),
+		WatchWriteTimeoutHuman:      HumanDuration(node.watchWriteTimeout()),
+		LockType:                    node.config.LockType,
+		LockResource:                lockResource(node.config),
+		LockTypeMigrateFrom:         node.config.LockTypeMigrateFrom,
+		KubeConfig:                  node.config.KubeConfig,
+		KubeConfigData:              os.Getenv(EnvKubeConfigData) != "",
+		ClientMaxIdleConns:          node.config.ClientMaxIdleConns,
+		ClientMaxIdleConnsPerHost:   node.config.ClientMaxIdleConnsPerHost,
+		TTLSeconds:                  Seconds(node.config.TTL),
+		TTLHuman:                    HumanDuration(node.config.TTL),
+		MaxClockSkewSeconds:         Seconds(node.config.MaxClockSkew),
+		MaxClockSkewHuman:           HumanDuration(node.config.MaxClockSkew),
+		AdoptExistingLeaseDuration:  node.config.AdoptExistingLeaseDuration,
+		KeepLeaseOnShutdown:         node.config.KeepLeaseOnShutdown,
+		RepairCorruptLock:           node.config.RepairCorruptLock,
+		SingleNode:                  node.config.SingleNode,
+		Chaos:                       node.config.Chaos,
+		StartupFailureGraceSeconds:  Seconds(node.config.StartupFailureGracePeriod),
+		StartupFailureGraceHuman:    HumanDuration(node.config.StartupFailureGracePeriod),
+		StaleThresholdSeconds:       Seconds(staleThreshold),
+		StaleThresholdHuman:         HumanDuration(staleThreshold),
+		StaleExpirySeconds:          Seconds(staleExpiry),
+		StaleExpiryHuman:            HumanDuration(staleExpiry),
+		MetricsNamespace:            node.config.MetricsNamespace,
+		MetricsIdentityLabel:        node.config.MetricsIdentityLabel,
+		OnElected:                   node.config.OnElected,
+		OnDemoted:                   node.config.OnDemoted,
+		OnDemotedTimeoutSeconds:     Seconds(node.config.OnDemotedTimeout),
+		OnDemotedTimeoutHuman:       HumanDuration(node.config.OnDemotedTimeout),
+		LogPrefix:                   node.config.LogPrefix,
+		LogRolePrefix:               node.config.LogRolePrefix,
+		LogRepeatEvery:              node.config.LogRepeatEvery,
+		LogRepeatIntervalSeconds:    Seconds(node.config.LogRepeatInterval),
+		LogRepeatIntervalHuman:      HumanDuration(node.config.LogRepeatInterval),
+		RecordOutages:               node.config.RecordOutages,
+		OutageThresholdSeconds:      Seconds(node.config.OutageThreshold),
+		OutageThresholdHuman:        HumanDuration(node.config.OutageThreshold),
+		RecordHistory:               node.config.RecordHistory,
+		TerminationMessagePath:      node.config.TerminationMessagePath,
+		ReconcileIntervalSeconds:    Seconds(node.config.ReconcileInterval),
+		ReconcileIntervalHuman:      HumanDuration(node.config.ReconcileInterval),
+		SlowRenewalFraction:         node.config.SlowRenewalFraction,
+		PublishDebounceSeconds:      Seconds(node.config.PublishDebounce),
+		PublishDebounceHuman:        HumanDuration(node.config.PublishDebounce),
+		StatusObject:                statusObject(node.config),
+		StatusObjectNamespace:       node.config.StatusObjectNamespace,
+		ExternalDNSService:          node.config.ExternalDNSService,
+		ExternalDNSHostname:         node.config.ExternalDNSHostname,
+		Publishers:                  node.config.activePublishers(),
+		PostDemotionCooldownSeconds: Seconds(node.config.PostDemotionCooldown),
+		PostDemotionCooldownHuman:   HumanDuration(node.config.PostDemotionCooldown),
+		CandidacyCheck:              node.config.CandidacyCheck != nil,
+		StepDownOnCandidacyLoss:     node.config.StepDownOnCandidacyLoss,
+		Timings:                     node.timingsInfo(),
+	}
+}
+
+// timingsInfo gets the effective timings used by the election.
+func (node *ElectorNode) timingsInfo() TimingsInfo {
+	timings := node.timings()
+	return TimingsInfo{
+		LeaseDurationSeconds: Seconds(timings.LeaseDuration),
+		LeaseDurationHuman:   HumanDuration(timings.LeaseDuration),
+		RenewDeadlineSeconds: Seconds(timings.RenewDeadline),
+		RenewDeadlineHuman:   HumanDuration(timings.RenewDeadline),
+		RetryPeriodSeconds:   Seconds(timings.RetryPeriod),
+		RetryPeriodHuman:     HumanDuration(timings.RetryPeriod),
+	}
+}
+
+// timingDetails gets the timings of the election in detail. If the node is the
+// leader, this includes the time of its last renewal and the deadlines for its
+// next one.
+func (node *ElectorNode) timingDetails() TimingDetails {
+	timings := node.timings()
+	details := TimingDetails{
+		LeaseDurationSeconds: Seconds(timings.LeaseDuration),
+		LeaseDurationHuman:   HumanDuration(timings.LeaseDuration),
+		RenewDeadlineSeconds: Seconds(timings.RenewDeadline),
+		RenewDeadlineHuman:   HumanDuration(timings.RenewDeadline),
+		RetryPeriodSeconds:   Seconds(timings.RetryPeriod),
+		RetryPeriodHuman:     HumanDuration(timings.RetryPeriod),
+		JitterFactor:         leaderelection.JitterFactor,
+		RejoinDelaySeconds:   Seconds(rejoinDelay),
+		RejoinDelayHuman:     HumanDuration(rejoinDelay),
+	}
+	observed, expiresAt := node.leaseExpiry()
+	details.ObservedLeaseSeconds = Seconds(observed)
+	details.ObservedLeaseHuman = HumanDuration(observed)
+	details.LeaseExpiresAt = Timestamp(expiresAt)
+
+	node.mu.RLock()
+	lock := node.lock
+	node.mu.RUnlock()
+	if lock == nil || !node.IsLeader() {
+		return details
+	}
+
+	// The leader renews every retry period. If it has not renewed within the
+	// renew deadline of its last renewal, it gives up leadership.
+	lastRenew := lock.lastRenewTime()
+	if lastRenew.IsZero() {
+		return details
+	}
+	details.LastRenew = Timestamp(lastRenew)
+	details.NextRenew = Timestamp(lastRenew.Add(timings.RetryPeriod))
+	details.RenewDeadlineAt = Timestamp(lastRenew.Add(timings.RenewDeadline))
+	return details
+}
+
+// state gets the current state of the node.
+func (node *ElectorNode) state() string {
+	switch {
+	case node.isInitializing():
+		return StatusInitializing
+	case node.IsLeader():
+		return StatusLeader
+	case node.isLockCorrupt():
+		return StatusCorrupt
+	case node.leader() != "":
+		return StatusStandby
+	default:
+		return StatusUnknown
+	}
+}
+
+// statusSnapshot gets a full snapshot of the node's status.
+func (node *ElectorNode) statusSnapshot() StatusSnapshot {
+	snapshot := StatusSnapshot{
+		Config:             node.configInfo(),
+		Leader:             node.leaderInfo(),
+		State:              node.state(),
+		ServingHTTP:        node.isServingHTTP(),
+		CollapsedPublishes: node.collapsedPublishCount(),
+		SlowRenewals:       node.slowRenewalCount(),
+		LockDeletions:      node.lockDeletionCount(),
+		Publishers:         node.publisherHealth(),
+	}
+
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	snapshot.StateSince = Timestamp(node.stateSince)
+	snapshot.Restarts = node.restarts
+	snapshot.RestartReason = node.restartReason
+	return snapshot
+}
+
+// logStatus logs a snapshot of the node's status.
+func (node *ElectorNode) logStatus() {
+	data, err := json.MarshalIndent(node.statusSnapshot(), "", "  ")
+	if err != nil {
+		node.log.Errorf("failed to build status snapshot: %v", err)
+		return
+	}
+	node.log.Infof("status snapshot:\n%s", data)
+}
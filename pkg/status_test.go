@@ -0,0 +1,136 @@
+package pkg
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestElectorNode_statusSnapshot(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:        "test-node-1",
+		Name:      "test-election",
+		AuthToken: "secret",
+		TTL:       5 * time.Second,
+	})
+	node.setLeader("test-node-2")
+
+	snapshot := node.statusSnapshot()
+
+	assert.Equal(t, "test-node-1", snapshot.Config.ID)
+	assert.Equal(t, "test-election", snapshot.Config.Name)
+	assert.Equal(t, HumanDuration(5*time.Second), snapshot.Config.TTLHuman)
+	assert.Equal(t, "test-node-2", snapshot.Leader.Leader)
+	assert.False(t, snapshot.Leader.IsLeader)
+	assert.Equal(t, StatusStandby, snapshot.State)
+	assert.NotEmpty(t, snapshot.StateSince)
+	assert.False(t, snapshot.ServingHTTP)
+	assert.Equal(t, "", snapshot.RestartReason)
+	assert.Empty(t, snapshot.Publishers)
+}
+
+func TestElectorNode_statusSnapshot_restartsAndPublishers(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Logger: &testLogger{},
+	})
+	node.recordRerun("the node stopped leading")
+
+	failing := newDebouncedPublisher(&testPublisher{err: errors.New("patch failed")}, clock.RealClock{}, 0, node.log, node.operations)
+	healthy := newDebouncedPublisher(&testPublisher{}, clock.RealClock{}, 0, node.log, node.operations)
+	node.setPublishers([]*debouncedPublisher{failing, healthy})
+	failing.update(StatusLeader)
+	healthy.update(StatusLeader)
+
+	snapshot := node.statusSnapshot()
+	assert.Equal(t, 1, snapshot.Restarts)
+	assert.Equal(t, "the node stopped leading", snapshot.RestartReason)
+	assert.Equal(t, []PublisherHealth{
+		{Name: "test", Healthy: false, LastError: "patch failed", Failures: 1},
+		{Name: "test", Healthy: true, Published: StatusLeader},
+	}, snapshot.Publishers)
+}
+
+func TestElectorNode_rerunReason(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Logger: &testLogger{},
+	})
+	assert.Equal(t, "the election stopped", node.rerunReason())
+
+	node.mu.Lock()
+	node.demoted = true
+	node.mu.Unlock()
+	assert.Equal(t, "the node stopped leading", node.rerunReason())
+
+	// A paused node re-runs the election once it is resumed.
+	assert.True(t, node.pause())
+	assert.Equal(t, "election participation was paused", node.rerunReason())
+}
+
+func TestElectorNode_state(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+	assert.Equal(t, StatusUnknown, node.state())
+
+	node.setLeader("test-node-2")
+	assert.Equal(t, StatusStandby, node.state())
+
+	node.setLeader("test-node-1")
+	assert.Equal(t, StatusLeader, node.state())
+}
+
+func TestElectorNode_setLeader_stateSince(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID: "test-node-1",
+	})
+
+	node.setLeader("test-node-2")
+	since := node.stateSince
+	assert.False(t, since.IsZero())
+
+	// A new leader which is not this node does not change the node's state.
+	node.setLeader("test-node-3")
+	assert.Equal(t, since, node.stateSince)
+
+	node.setLeader("test-node-1")
+	assert.NotEqual(t, since, node.stateSince)
+}
+
+func TestElectorNode_listenForSignal_statusDump(t *testing.T) {
+	buf := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:        "test-node-1",
+		Name:      "test-election",
+		AuthToken: "secret",
+		Logger:    buf,
+	})
+	node.setLeader("test-node-1")
+
+	done := make(chan struct{})
+	go func() {
+		node.listenForSignal()
+		close(done)
+	}()
+
+	node.quit <- syscall.SIGUSR1
+	node.quit <- syscall.SIGTERM
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "failed to stop listening for signals")
+	}
+
+	out := buf.String()
+	assert.Contains(t, out, "status snapshot")
+	assert.Contains(t, out, `"election": "test-election"`)
+	assert.Contains(t, out, `"state": "leader"`)
+	assert.NotContains(t, out, "secret")
+	assert.Error(t, node.ctx.Err())
+}
@@ -0,0 +1,221 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/dynamic"
+)
+
+// applyFieldManager is the field manager which owns the fields the elector
+// writes with server-side apply, e.g. to the status of the status object.
+const applyFieldManager = "k8s-elector"
+
+// statusObjectRetryInterval is how often the leader retries publishing its
+// status to a status object which does not exist.
+const statusObjectRetryInterval = 1 * time.Minute
+
+// ParseStatusObject parses the status object in the form
+// "group/version/resource/name", or "version/resource/name" for resources in
+// the core group (e.g. "apps.example.com/v1/elections/my-election").
+func ParseStatusObject(s string) (schema.GroupVersionResource, string, error) {
+	i := strings.LastIndex(s, "/")
+	if i < 0 || s[i+1:] == "" {
+		return schema.GroupVersionResource{}, "", fmt.Errorf("invalid status object %q: expected group/version/resource/name or version/resource/name", s)
+	}
+	gvr, err := ParseGroupVersionResource(s[:i])
+	if err != nil {
+		return schema.GroupVersionResource{}, "", fmt.Errorf("invalid status object %q: %v", s, err)
+	}
+	return gvr, s[i+1:], nil
+}
+
+// statusObject gets the status object for reporting, in the form parsed by
+// ParseStatusObject. It is empty if no status object is configured.
+func statusObject(conf *ElectorConfig) string {
+	if conf.StatusObjectName == "" {
+		return ""
+	}
+	return formatGroupVersionResource(conf.StatusObjectResource) + "/" + conf.StatusObjectName
+}
+
+// StatusObjectStatus is the status which the leader maintains on the status
+// object.
+type StatusObjectStatus struct {
+	Leader       string    `json:"leader"`
+	Since        Timestamp `json:"since"`
+	Participants []string  `json:"participants"`
+}
+
+// statusObjectPublisher publishes the leadership of the elector node to the
+// status of an existing object, e.g. a custom resource watched by GitOps
+// tooling, via the dynamic client.
+//
+// Only the leader writes the status; the next leader overwrites it once it is
+// elected. The status is written with server-side apply, so the fields of
+// other managers are left alone. The object is never created: if it does not
+// exist, that is logged once and the publish is retried occasionally.
+type statusObjectPublisher struct {
+	node   *ElectorNode
+	client dynamic.Interface
+
+	mu      sync.Mutex
+	missing bool
+	retry   clock.Timer
+}
+
+func (p *statusObjectPublisher) name() string {
+	return "status object"
+}
+
+func (p *statusObjectPublisher) publish(status string) error {
+	if status != StatusLeader {
+		return nil
+	}
+
+	err := p.apply()
+	if apierrors.IsNotFound(err) {
+		p.objectMissing(err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.missing {
+		p.missing = false
+		p.node.log.Infof("status object %s found, published leadership to it", p.describe())
+	}
+	return nil
+}
+
+// describe describes the status object for logging.
+func (p *statusObjectPublisher) describe() string {
+	conf := p.node.config
+	return fmt.Sprintf("%s %s/%s", conf.StatusObjectResource.String(), conf.StatusObjectNamespace, conf.StatusObjectName)
+}
+
+// apply writes the node's leadership to the status of the status object.
+//
+// The object is read first, since server-side apply needs its kind, which
+// can not be derived from its resource.
+func (p *statusObjectPublisher) apply() error {
+	conf := p.node.config
+	objects := p.client.Resource(conf.StatusObjectResource).Namespace(conf.StatusObjectNamespace)
+	obj, err := objects.Get(conf.StatusObjectName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"apiVersion": obj.GetAPIVersion(),
+		"kind":       obj.GetKind(),
+		"metadata": map[string]string{
+			"name":      conf.StatusObjectName,
+			"namespace": conf.StatusObjectNamespace,
+		},
+		"status": p.node.statusObjectStatus(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The elector owns the fields it applies, so it takes them over from any
+	// other manager rather than failing on a conflict.
+	force := true
+	_, err = objects.Patch(conf.StatusObjectName, types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: applyFieldManager,
+		Force:        &force,
+	}, "status")
+	return err
+}
+
+// objectMissing handles a status object which does not exist. It is logged
+// the first time, and the publish is retried after the retry interval while
+// the node is the leader.
+func (p *statusObjectPublisher) objectMissing(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.missing {
+		p.missing = true
+		p.node.log.Warningf("status object %s does not exist, retrying every %v: %v", p.describe(), statusObjectRetryInterval, err)
+	}
+	if p.retry != nil {
+		return
+	}
+
+	timer := p.node.clock.NewTimer(statusObjectRetryInterval)
+	p.retry = timer
+	go func() {
+		select {
+		case <-timer.C():
+		case <-p.node.ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		p.mu.Lock()
+		p.retry = nil
+		p.mu.Unlock()
+		if !p.node.IsLeader() {
+			return
+		}
+		if err := p.publish(StatusLeader); err != nil {
+			p.node.log.repeatedErrorf("failed to publish %s status (%s): %v", StatusLeader, p.name(), err)
+		}
+	}()
+}
+
+// statusObjectStatus gets the status of the node's leadership for the status
+// object. The participants are the identities the node has observed as the
+// leader, along with its own.
+func (node *ElectorNode) statusObjectStatus() StatusObjectStatus {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+
+	seen := map[string]bool{node.config.ID: true}
+	for change := range node.leaderChanges {
+		if change.From != "" {
+			seen[change.From] = true
+		}
+		seen[change.To] = true
+	}
+	participants := make([]string, 0, len(seen))
+	for identity := range seen {
+		participants = append(participants, identity)
+	}
+	sort.Strings(participants)
+
+	return StatusObjectStatus{
+		Leader:       node.currentLeader,
+		Since:        Timestamp(node.stateSince),
+		Participants: participants,
+	}
+}
@@ -0,0 +1,190 @@
+package pkg
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var testStatusResource = schema.GroupVersionResource{Group: "apps.example.com", Version: "v1", Resource: "elections"}
+
+// newTestElection creates an object of the testStatusResource for use as a
+// status object.
+func newTestElection(namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("apps.example.com/v1")
+	obj.SetKind("Election")
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+// statusPatches records the patches made to the status of status objects.
+type statusPatches struct {
+	mu      sync.Mutex
+	patches []k8stesting.PatchAction
+}
+
+// react records a patch of the status subresource. The fake dynamic client
+// does not support server-side apply, so the patch is not applied.
+func (s *statusPatches) react(action k8stesting.Action) (bool, runtime.Object, error) {
+	patch := action.(k8stesting.PatchAction)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.patches = append(s.patches, patch)
+	return true, newTestElection(patch.GetNamespace(), patch.GetName()), nil
+}
+
+func (s *statusPatches) list() []k8stesting.PatchAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]k8stesting.PatchAction(nil), s.patches...)
+}
+
+// newTestStatusObjectNode creates a node which is the leader and maintains
+// the status of the my-election status object.
+func newTestStatusObjectNode(objects ...runtime.Object) (*ElectorNode, *statusObjectPublisher, *statusPatches, *testLogger) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...)
+	patches := &statusPatches{}
+	client.PrependReactor("patch", "elections", patches.react)
+
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:                    "test-node-1",
+		Name:                  "test-election",
+		StatusObjectResource:  testStatusResource,
+		StatusObjectName:      "my-election",
+		StatusObjectNamespace: "test-ns",
+		Logger:                log,
+	})
+	node.setLeader("test-node-2")
+	node.setLeader("test-node-1")
+	return node, &statusObjectPublisher{node: node, client: client}, patches, log
+}
+
+func TestParseStatusObject(t *testing.T) {
+	cases := []struct {
+		description string
+		value       string
+		resource    schema.GroupVersionResource
+		name        string
+	}{
+		{
+			description: "group, version, resource, and name",
+			value:       "apps.example.com/v1/elections/my-election",
+			resource:    testStatusResource,
+			name:        "my-election",
+		},
+		{
+			description: "core group",
+			value:       "v1/configmaps/my-election",
+			resource:    schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			name:        "my-election",
+		},
+	}
+
+	for _, c := range cases {
+		gvr, name, err := ParseStatusObject(c.value)
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, c.resource, gvr, c.description)
+		assert.Equal(t, c.name, name, c.description)
+		assert.Equal(t, c.value, statusObject(&ElectorConfig{StatusObjectResource: gvr, StatusObjectName: name}), c.description)
+	}
+}
+
+func TestParseStatusObject_error(t *testing.T) {
+	for _, value := range []string{"", "my-election", "v1/configmaps/", "elections/my-election", "a/b/c/d/e"} {
+		_, _, err := ParseStatusObject(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestStatusObjectPublisher_publish(t *testing.T) {
+	node, p, patches, _ := newTestStatusObjectNode(newTestElection("test-ns", "my-election"))
+
+	assert.NoError(t, p.publish(StatusLeader))
+	actions := patches.list()
+	if !assert.Len(t, actions, 1) {
+		return
+	}
+	action := actions[0]
+	assert.Equal(t, types.ApplyPatchType, action.GetPatchType())
+	assert.Equal(t, "status", action.GetSubresource())
+	assert.Equal(t, "test-ns", action.GetNamespace())
+	assert.Equal(t, "my-election", action.GetName())
+
+	var body struct {
+		APIVersion string            `json:"apiVersion"`
+		Kind       string            `json:"kind"`
+		Metadata   map[string]string `json:"metadata"`
+		Status     StatusObjectStatus
+	}
+	assert.NoError(t, json.Unmarshal(action.GetPatch(), &body))
+	assert.Equal(t, "apps.example.com/v1", body.APIVersion)
+	assert.Equal(t, "Election", body.Kind)
+	assert.Equal(t, map[string]string{"name": "my-election", "namespace": "test-ns"}, body.Metadata)
+	assert.Equal(t, "test-node-1", body.Status.Leader)
+	assert.Equal(t, []string{"test-node-1", "test-node-2"}, body.Status.Participants)
+	node.mu.RLock()
+	assert.True(t, node.stateSince.Equal(time.Time(body.Status.Since)))
+	node.mu.RUnlock()
+
+	// Only the leader writes the status.
+	assert.NoError(t, p.publish(StatusStandby))
+	assert.Len(t, patches.list(), 1)
+}
+
+func TestStatusObjectPublisher_missing(t *testing.T) {
+	node, p, patches, log := newTestStatusObjectNode()
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 0, 0, 0, time.UTC))
+	node.clock = clk
+	defer node.cancel()
+
+	// A missing object is not an error, and is only logged once.
+	assert.NoError(t, p.publish(StatusLeader))
+	assert.NoError(t, p.publish(StatusLeader))
+	assert.Empty(t, patches.list())
+	assert.Equal(t, 1, strings.Count(log.String(), "does not exist"))
+
+	// Once the object exists, the retry publishes to it.
+	_, err := p.client.Resource(testStatusResource).Namespace("test-ns").Create(newTestElection("test-ns", "my-election"), metav1.CreateOptions{})
+	assert.NoError(t, err)
+	waitFor(t, 5*time.Second, func() bool {
+		return clk.HasWaiters()
+	})
+	clk.Step(statusObjectRetryInterval)
+	waitFor(t, 5*time.Second, func() bool {
+		return len(patches.list()) == 1
+	})
+	assert.Contains(t, log.String(), "found")
+}
+
+func TestElectorNode_newPublishers_statusObject(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:                   "test-node-1",
+		Name:                 "test-election",
+		StatusObjectResource: testStatusResource,
+		StatusObjectName:     "my-election",
+		DynamicClient:        dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+		Logger:               &testLogger{},
+	})
+
+	var names []string
+	for _, p := range node.newPublishers(nil) {
+		names = append(names, p.publisher.name())
+	}
+	assert.Equal(t, []string{"pod label", "subscriptions", "watchers", "status object"}, names)
+	assert.Equal(t, "apps.example.com/v1/elections/my-election", node.configInfo().StatusObject)
+}
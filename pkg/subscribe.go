@@ -0,0 +1,306 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// maxSubscriptions is the maximum number of subscriptions to leadership
+	// transitions the elector node holds at once.
+	maxSubscriptions = 10
+
+	// maxSubscriptionFailures is the number of consecutive failed deliveries
+	// after which a subscription is deleted.
+	maxSubscriptionFailures = 3
+
+	// subscriptionTimeout is the timeout for delivering a leadership transition
+	// to a subscriber.
+	subscriptionTimeout = 5 * time.Second
+)
+
+// errSubscriptionLimit is returned when subscribing while the elector node
+// already holds the maximum number of subscriptions.
+var errSubscriptionLimit = fmt.Errorf("subscription limit reached (%d)", maxSubscriptions)
+
+// Subscription is a callback URL which leadership transitions are delivered to.
+type Subscription struct {
+	ID       string `json:"id" description:"The ID of the subscription, used to delete it."`
+	URL      string `json:"url" description:"The URL leadership transitions are POSTed to."`
+	Failures int    `json:"failures" description:"The number of consecutive failed deliveries to the subscription."`
+}
+
+// SubscriptionList is the response for the endpoint which lists subscriptions.
+type SubscriptionList struct {
+	Subscriptions []Subscription `json:"subscriptions" description:"The subscriptions to leadership transitions."`
+}
+
+// SubscribeRequest is the request body for the endpoint which subscribes a
+// callback URL to leadership transitions.
+type SubscribeRequest struct {
+	URL string `json:"url"`
+}
+
+// LeadershipEvent is the payload delivered to subscribers when the leadership
+// status of the elector node changes.
+type LeadershipEvent struct {
+	ID             string    `json:"event_id"`
+	Node           string    `json:"node"`
+	Election       string    `json:"election"`
+	Role           string    `json:"role"`
+	Status         string    `json:"status"`
+	Leader         string    `json:"leader"`
+	PreviousLeader string    `json:"previous_leader"`
+	Timestamp      Timestamp `json:"timestamp"`
+}
+
+// subscriptions holds the elector node's subscriptions to leadership
+// transitions. Subscriptions are held in memory only, so they do not survive
+// a restart of the elector.
+type subscriptions struct {
+	mu     sync.Mutex
+	nextID int
+	subs   []*Subscription
+}
+
+// add adds a subscription for the URL. If the maximum number of subscriptions
+// is already held, errSubscriptionLimit is returned.
+func (s *subscriptions) add(callback string) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.subs) >= maxSubscriptions {
+		return Subscription{}, errSubscriptionLimit
+	}
+	s.nextID++
+	sub := &Subscription{ID: strconv.Itoa(s.nextID), URL: callback}
+	s.subs = append(s.subs, sub)
+	return *sub, nil
+}
+
+// remove deletes the subscription with the ID. If there is no such
+// subscription, false is returned.
+func (s *subscriptions) remove(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, sub := range s.subs {
+		if sub.ID == id {
+			s.subs = append(s.subs[:i], s.subs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// list gets a copy of the subscriptions, in the order they were added.
+func (s *subscriptions) list() []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := make([]Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, *sub)
+	}
+	return subs
+}
+
+// delivered records the result of a delivery to the subscription with the ID.
+// Once the subscription has failed maxSubscriptionFailures consecutive times,
+// it is deleted and true is returned.
+func (s *subscriptions) delivered(id string, err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, sub := range s.subs {
+		if sub.ID != id {
+			continue
+		}
+		if err == nil {
+			sub.Failures = 0
+			return false
+		}
+		sub.Failures++
+		if sub.Failures >= maxSubscriptionFailures {
+			s.subs = append(s.subs[:i], s.subs[i+1:]...)
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+// validateCallbackURL checks that a subscription callback URL is an absolute
+// HTTP(S) URL.
+func validateCallbackURL(callback string) error {
+	u, err := url.Parse(callback)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("url scheme must be http or https")
+	}
+	if u.Host == "" {
+		return errors.New("url must have a host")
+	}
+	return nil
+}
+
+// subscriptionPublisher publishes the status of the elector node to the
+// callback URLs subscribed to leadership transitions.
+type subscriptionPublisher struct {
+	node   *ElectorNode
+	client *http.Client
+}
+
+// newSubscriptionPublisher creates a publisher which delivers the node's status
+// to its subscriptions.
+func newSubscriptionPublisher(node *ElectorNode) *subscriptionPublisher {
+	return &subscriptionPublisher{
+		node:   node,
+		client: &http.Client{Timeout: subscriptionTimeout},
+	}
+}
+
+func (p *subscriptionPublisher) name() string {
+	return "subscriptions"
+}
+
+// publish delivers the status to each subscription concurrently. A failed
+// delivery only affects its own subscription, so no error is returned.
+func (p *subscriptionPublisher) publish(status string) error {
+	subs := p.node.subscriptions.list()
+	if len(subs) == 0 {
+		return nil
+	}
+
+	event := p.node.leadershipEvent(status)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub Subscription) {
+			defer wg.Done()
+			err := p.deliver(sub.URL, event.ID, payload)
+			if err != nil {
+				p.node.log.repeatedWarningf("failed to deliver leadership transition to subscription %s (%s): %v", sub.ID, sub.URL, err)
+			}
+			if p.node.subscriptions.delivered(sub.ID, err) {
+				p.node.log.Warningf("deleted subscription %s (%s) after %d consecutive failures", sub.ID, sub.URL, maxSubscriptionFailures)
+			}
+		}(sub)
+	}
+	wg.Wait()
+	return nil
+}
+
+// deliver POSTs the payload of the event with the ID to the callback URL.
+// Any non-2xx response is treated as a failure.
+func (p *subscriptionPublisher) deliver(callback, id string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, callback, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if id != "" {
+		req.Header.Set(p.node.eventIDHeader(), id)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status: %s", res.Status)
+	}
+	return nil
+}
+
+// httpListSubscriptions is the handler for the endpoint which lists the
+// subscriptions to leadership transitions.
+func (node *ElectorNode) httpListSubscriptions(res http.ResponseWriter, req *http.Request) {
+	node.writeJSON(res, http.StatusOK, SubscriptionList{
+		Subscriptions: node.subscriptions.list(),
+	})
+}
+
+// httpSubscribe is the handler for the endpoint which subscribes a callback
+// URL to leadership transitions.
+func (node *ElectorNode) httpSubscribe(res http.ResponseWriter, req *http.Request) {
+	// Subscriptions would never be delivered to, so they are not accepted.
+	if !node.config.publisherEnabled(PublisherSubscriptions) {
+		node.writeJSON(res, http.StatusConflict, MessageResponse{
+			Message: "subscriptions are disabled",
+		})
+		return
+	}
+
+	var body SubscribeRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		node.writeJSON(res, http.StatusBadRequest, MessageResponse{
+			Message: fmt.Sprintf("invalid request body: %v", err),
+		})
+		return
+	}
+	if err := validateCallbackURL(body.URL); err != nil {
+		node.writeJSON(res, http.StatusBadRequest, MessageResponse{
+			Message: fmt.Sprintf("invalid url: %v", err),
+		})
+		return
+	}
+
+	sub, err := node.subscriptions.add(body.URL)
+	if err != nil {
+		node.writeJSON(res, http.StatusConflict, MessageResponse{
+			Message: err.Error(),
+		})
+		return
+	}
+	node.log.Infof("added subscription %s (%s) from %s", sub.ID, sub.URL, req.RemoteAddr)
+	node.writeJSON(res, http.StatusCreated, sub)
+}
+
+// httpUnsubscribe is the handler for the endpoint which deletes a subscription
+// to leadership transitions.
+func (node *ElectorNode) httpUnsubscribe(res http.ResponseWriter, req *http.Request) {
+	id := req.URL.Query().Get("id")
+	if !node.subscriptions.remove(id) {
+		node.writeJSON(res, http.StatusNotFound, MessageResponse{
+			Message: fmt.Sprintf("no subscription with id %q", id),
+		})
+		return
+	}
+	node.log.Infof("deleted subscription %s from %s", id, req.RemoteAddr)
+	node.writeJSON(res, http.StatusOK, MessageResponse{
+		Message: "subscription deleted",
+	})
+}
@@ -0,0 +1,243 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testSubscriber is an HTTP server which records the leadership events
+// delivered to it.
+type testSubscriber struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	status int
+	events []LeadershipEvent
+}
+
+func newTestSubscriber() *testSubscriber {
+	s := &testSubscriber{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var event LeadershipEvent
+		if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.events = append(s.events, event)
+		res.WriteHeader(s.status)
+	}))
+	return s
+}
+
+func (s *testSubscriber) setStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+func (s *testSubscriber) received() []LeadershipEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]LeadershipEvent(nil), s.events...)
+}
+
+// doSubscribeRequest makes an authenticated request to the subscribe endpoint.
+func doSubscribeRequest(node *ElectorNode, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, req)
+	return w
+}
+
+func TestElectorNode_httpSubscribe(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:        "test-node-1",
+		Name:      "test-election",
+		AuthToken: "secret",
+		Logger:    &testLogger{},
+	})
+	subscriber := newTestSubscriber()
+	defer subscriber.Close()
+
+	w := doSubscribeRequest(node, http.MethodPost, "/subscribe", fmt.Sprintf(`{"url": %q}`, subscriber.URL))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var sub Subscription
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&sub))
+	assert.Equal(t, "1", sub.ID)
+	assert.Equal(t, subscriber.URL, sub.URL)
+
+	w = doSubscribeRequest(node, http.MethodGet, "/subscribe", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list SubscriptionList
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	assert.Equal(t, []Subscription{sub}, list.Subscriptions)
+
+	// Force a transition, which is delivered to the subscriber.
+	node.setPublishers([]*debouncedPublisher{
+		newDebouncedPublisher(newSubscriptionPublisher(node), node.clock, 0, node.log, node.operations),
+	})
+	node.setLeader("test-node-1")
+	node.publishStatus(StatusLeader)
+
+	events := subscriber.received()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "test-node-1", events[0].Node)
+		assert.Equal(t, "test-election", events[0].Election)
+		assert.Equal(t, StatusLeader, events[0].Status)
+		assert.Equal(t, "test-node-1", events[0].Leader)
+	}
+
+	w = doSubscribeRequest(node, http.MethodDelete, "/subscribe?id=1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doSubscribeRequest(node, http.MethodDelete, "/subscribe?id=1", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Once deleted, transitions are no longer delivered.
+	node.setLeader("test-node-2")
+	node.publishStatus(StatusStandby)
+	assert.Len(t, subscriber.received(), 1)
+}
+
+func TestElectorNode_httpSubscribe_errors(t *testing.T) {
+	cases := []struct {
+		description string
+		method      string
+		body        string
+		auth        bool
+		status      int
+	}{
+		{
+			description: "unauthenticated",
+			method:      http.MethodPost,
+			body:        `{"url": "http://127.0.0.1:9090/leadership"}`,
+			status:      http.StatusUnauthorized,
+		},
+		{
+			description: "unauthenticated list",
+			method:      http.MethodGet,
+			status:      http.StatusUnauthorized,
+		},
+		{
+			description: "invalid body",
+			method:      http.MethodPost,
+			body:        `{`,
+			auth:        true,
+			status:      http.StatusBadRequest,
+		},
+		{
+			description: "invalid scheme",
+			method:      http.MethodPost,
+			body:        `{"url": "ftp://127.0.0.1/leadership"}`,
+			auth:        true,
+			status:      http.StatusBadRequest,
+		},
+		{
+			description: "no host",
+			method:      http.MethodPost,
+			body:        `{"url": "http:///leadership"}`,
+			auth:        true,
+			status:      http.StatusBadRequest,
+		},
+		{
+			description: "unsupported method",
+			method:      http.MethodPut,
+			auth:        true,
+			status:      http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:        "test-node-1",
+			AuthToken: "secret",
+			Logger:    &testLogger{},
+		})
+
+		req := httptest.NewRequest(c.method, "/subscribe", strings.NewReader(c.body))
+		if c.auth {
+			req.Header.Set("Authorization", "Bearer secret")
+		}
+		w := httptest.NewRecorder()
+		node.mux().ServeHTTP(w, req)
+		assert.Equal(t, c.status, w.Code, c.description)
+		assert.Empty(t, node.subscriptions.list(), c.description)
+	}
+}
+
+func TestElectorNode_allowMethods(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{ID: "test-node-1", AuthToken: "secret"})
+
+	req := httptest.NewRequest(http.MethodPut, "/subscribe", nil)
+	w := httptest.NewRecorder()
+	node.mux().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "DELETE, GET, POST", w.Header().Get("Allow"))
+}
+
+func TestSubscriptions_limit(t *testing.T) {
+	var subs subscriptions
+	for i := 0; i < maxSubscriptions; i++ {
+		_, err := subs.add(fmt.Sprintf("http://127.0.0.1:%d/leadership", 9000+i))
+		assert.NoError(t, err)
+	}
+
+	_, err := subs.add("http://127.0.0.1:9999/leadership")
+	assert.Equal(t, errSubscriptionLimit, err)
+
+	assert.True(t, subs.remove("1"))
+	_, err = subs.add("http://127.0.0.1:9999/leadership")
+	assert.NoError(t, err)
+	assert.Len(t, subs.list(), maxSubscriptions)
+}
+
+func TestSubscriptionPublisher_eviction(t *testing.T) {
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{ID: "test-node-1", Logger: log})
+	failing := newTestSubscriber()
+	defer failing.Close()
+	failing.setStatus(http.StatusInternalServerError)
+	healthy := newTestSubscriber()
+	defer healthy.Close()
+
+	_, err := node.subscriptions.add(failing.URL)
+	assert.NoError(t, err)
+	_, err = node.subscriptions.add(healthy.URL)
+	assert.NoError(t, err)
+
+	p := newSubscriptionPublisher(node)
+	for i := 0; i < maxSubscriptionFailures-1; i++ {
+		assert.NoError(t, p.publish(StatusStandby))
+	}
+	subs := node.subscriptions.list()
+	if assert.Len(t, subs, 2) {
+		assert.Equal(t, maxSubscriptionFailures-1, subs[0].Failures)
+		assert.Equal(t, 0, subs[1].Failures)
+	}
+
+	// A successful delivery resets the consecutive failures.
+	failing.setStatus(http.StatusOK)
+	assert.NoError(t, p.publish(StatusLeader))
+	assert.Equal(t, 0, node.subscriptions.list()[0].Failures)
+
+	// Once a subscription fails enough consecutive times, it is deleted.
+	failing.setStatus(http.StatusInternalServerError)
+	for i := 0; i < maxSubscriptionFailures; i++ {
+		assert.NoError(t, p.publish(StatusStandby))
+	}
+	subs = node.subscriptions.list()
+	if assert.Len(t, subs, 1) {
+		assert.Equal(t, healthy.URL, subs[0].URL)
+	}
+	assert.Len(t, healthy.received(), 2*maxSubscriptionFailures)
+	assert.Contains(t, log.String(), "deleted subscription 1")
+}
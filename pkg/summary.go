@@ -0,0 +1,92 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"fmt"
+	"time"
+)
+
+// ShutdownSummary summarizes the life of an elector node, for a quick review
+// of what it did once it stops.
+type ShutdownSummary struct {
+	Node                string        `json:"node" description:"The ID of the node."`
+	UptimeSeconds       Seconds       `json:"uptime_seconds" description:"How long the node has been running, in seconds."`
+	UptimeHuman         HumanDuration `json:"uptime_human" description:"How long the node has been running, as a duration string."`
+	Acquisitions        int           `json:"acquisitions" description:"The number of times the node acquired leadership."`
+	TimeAsLeaderSeconds Seconds       `json:"time_as_leader_seconds" description:"The total time the node was the leader, in seconds."`
+	TimeAsLeaderHuman   HumanDuration `json:"time_as_leader_human" description:"The total time the node was the leader, as a duration string."`
+	Transitions         int           `json:"transitions" description:"The number of leader transitions the node observed."`
+	PatchErrors         int           `json:"patch_errors" description:"The number of times the node failed to patch its Pod label."`
+	Reason              string        `json:"reason" description:"Why the node is stopping."`
+}
+
+// String formats the summary as a single line of key=value pairs.
+func (s ShutdownSummary) String() string {
+	return fmt.Sprintf(
+		"node=%s uptime=%v acquisitions=%d time_as_leader=%v transitions=%d patch_errors=%d reason=%q",
+		s.Node,
+		time.Duration(s.UptimeSeconds),
+		s.Acquisitions,
+		time.Duration(s.TimeAsLeaderSeconds),
+		s.Transitions,
+		s.PatchErrors,
+		s.Reason,
+	)
+}
+
+// shutdownSummary builds the summary of the node's life so far, for the node
+// stopping for the given reason.
+func (node *ElectorNode) shutdownSummary(reason string) ShutdownSummary {
+	uptime := node.clock.Since(node.started)
+	leaderTime := node.leaderTime()
+	_, acquisitions := node.leadership()
+
+	node.mu.RLock()
+	transitions := node.transitionCount
+	node.mu.RUnlock()
+
+	return ShutdownSummary{
+		Node:                node.config.ID,
+		UptimeSeconds:       Seconds(uptime),
+		UptimeHuman:         HumanDuration(uptime),
+		Acquisitions:        acquisitions,
+		TimeAsLeaderSeconds: Seconds(leaderTime),
+		TimeAsLeaderHuman:   HumanDuration(leaderTime),
+		Transitions:         transitions,
+		PatchErrors:         node.patchErrorCount(),
+		Reason:              reason,
+	}
+}
+
+// logShutdownSummary logs the summary of the node's life as it stops with the
+// given error. It is the last line the node logs.
+func (node *ElectorNode) logShutdownSummary(err error) {
+	node.log.Infof("shutdown summary: %v", node.shutdownSummary(node.stopReasonFor(err)))
+}
+
+// leaderTime gets the total time the node has been the leader, including its
+// current term if it is the leader.
+func (node *ElectorNode) leaderTime() time.Duration {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	total := node.ledFor
+	if node.currentLeader == node.config.ID && !node.stateSince.IsZero() {
+		total += node.clock.Since(node.stateSince)
+	}
+	return total
+}
@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestShutdownSummary_String(t *testing.T) {
+	summary := ShutdownSummary{
+		Node:                "test-node-1",
+		UptimeSeconds:       Seconds(90 * time.Minute),
+		UptimeHuman:         HumanDuration(90 * time.Minute),
+		Acquisitions:        2,
+		TimeAsLeaderSeconds: Seconds(45 * time.Second),
+		TimeAsLeaderHuman:   HumanDuration(45 * time.Second),
+		Transitions:         3,
+		PatchErrors:         1,
+		Reason:              "received termination signal terminated",
+	}
+	assert.Equal(t,
+		`node=test-node-1 uptime=1h30m0s acquisitions=2 time_as_leader=45s transitions=3 patch_errors=1 reason="received termination signal terminated"`,
+		summary.String(),
+	)
+}
+
+func TestElectorNode_leaderTime(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2019, 5, 2, 18, 28, 51, 0, time.UTC))
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Logger: &testLogger{},
+	})
+	node.clock = clk
+	node.started = clk.Now()
+	assert.Zero(t, node.leaderTime())
+
+	// The current term counts while the node leads.
+	node.setLeader("test-node-1")
+	clk.Step(10 * time.Second)
+	assert.Equal(t, 10*time.Second, node.leaderTime())
+
+	// Once another node leads, the term is kept, but no longer grows.
+	node.setLeader("test-node-2")
+	clk.Step(10 * time.Second)
+	assert.Equal(t, 10*time.Second, node.leaderTime())
+
+	summary := node.shutdownSummary("test")
+	assert.Equal(t, Seconds(20*time.Second), summary.UptimeSeconds)
+	assert.Equal(t, Seconds(10*time.Second), summary.TimeAsLeaderSeconds)
+}
+
+func TestElectorNode_shutdownSummary(t *testing.T) {
+	client := fake.NewSimpleClientset(newTestPod("test-ns", "test-pod"))
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:            "test-node-1",
+		Name:          "test-election",
+		Namespace:     "test-ns",
+		LockNamespace: "test-ns",
+		PodName:       "test-pod",
+		LockType:      resourcelock.LeasesResourceLock,
+		TTL:           1 * time.Second,
+		Client:        client,
+		Logger:        log,
+		Chaos:         true,
+	})
+
+	// The node's Pod label can not be patched while it leads.
+	node.chaos.failPatches(time.Now().Add(time.Hour))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- node.runUntilError()
+	}()
+	waitFor(t, 5*time.Second, func() bool {
+		return node.IsLeader()
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	node.Stop()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "node did not stop")
+	}
+
+	summary := node.shutdownSummary(node.stopReasonFor(err))
+	assert.Equal(t, "test-node-1", summary.Node)
+	assert.Equal(t, 1, summary.Acquisitions)
+	assert.Equal(t, 1, summary.Transitions)
+	assert.True(t, summary.PatchErrors > 0)
+	assert.True(t, time.Duration(summary.TimeAsLeaderSeconds) >= 50*time.Millisecond)
+	assert.True(t, summary.UptimeSeconds >= summary.TimeAsLeaderSeconds)
+	assert.Equal(t, "Stop() called", summary.Reason)
+
+	node.logShutdownSummary(err)
+	assert.Contains(t, log.String(), `shutdown summary: node=test-node-1 `)
+	assert.Contains(t, log.String(), ` acquisitions=1 `)
+	assert.Contains(t, log.String(), ` reason="Stop() called"`)
+}
+
+func TestElectorNode_stopReasonFor(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Logger: &testLogger{},
+	})
+	assert.Equal(t, "election error: lock failure", node.stopReasonFor(errors.New("lock failure")))
+
+	// The reason recorded for the stop takes precedence over the error.
+	node.setStopReason("received termination signal %v", "interrupt")
+	assert.Equal(t, "received termination signal interrupt", node.stopReasonFor(errors.New("lock failure")))
+}
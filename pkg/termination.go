@@ -0,0 +1,105 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// DefaultTerminationMessagePath is the path Kubernetes reads a container's
+// termination message from, unless the container spec sets another path.
+const DefaultTerminationMessagePath = "/dev/termination-log"
+
+// TerminationMessage is written to the termination message file when the
+// elector node stops, describing its leadership state at termination.
+type TerminationMessage struct {
+	Node      string    `json:"node"`
+	Election  string    `json:"election"`
+	WasLeader bool      `json:"was_leader"`
+	Leader    string    `json:"leader"`
+	Reason    string    `json:"reason"`
+	Timestamp Timestamp `json:"timestamp"`
+}
+
+// setStopReason records why the node is stopping. Only the first reason is
+// kept, since later ones are typically consequences of it.
+func (node *ElectorNode) setStopReason(format string, args ...interface{}) {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	if node.stopReason == "" {
+		node.stopReason = fmt.Sprintf(format, args...)
+	}
+}
+
+// stopReasonFor gets why the node stopped with the given error. The reason
+// recorded for the stop is preferred; otherwise it is derived from the error.
+func (node *ElectorNode) stopReasonFor(err error) string {
+	node.mu.RLock()
+	reason := node.stopReason
+	node.mu.RUnlock()
+	if reason != "" {
+		return reason
+	}
+
+	switch err {
+	case nil:
+		return "election stopped"
+	case context.Canceled:
+		return "context cancelled"
+	case ErrTerminatedDuringStartup:
+		return "terminated during startup"
+	default:
+		return fmt.Sprintf("election error: %v", err)
+	}
+}
+
+// terminationMessage builds the termination message for the node, which
+// stopped with the given error.
+func (node *ElectorNode) terminationMessage(err error) TerminationMessage {
+	return TerminationMessage{
+		Node:      node.config.ID,
+		Election:  node.config.Name,
+		WasLeader: node.IsLeader(),
+		Leader:    node.leader(),
+		Reason:    node.stopReasonFor(err),
+		Timestamp: Timestamp(node.clock.Now()),
+	}
+}
+
+// writeTerminationMessage writes the termination message for the node, which
+// stopped with the given error, to the configured termination message file.
+// Kubernetes shows the message as the container's last state, so which node
+// was the leader at termination can be seen with 'kubectl describe pod'.
+//
+// If no termination message path is configured, nothing is written.
+func (node *ElectorNode) writeTerminationMessage(err error) {
+	if node.config.TerminationMessagePath == "" {
+		return
+	}
+
+	data, e := json.Marshal(node.terminationMessage(err))
+	if e != nil {
+		node.log.Errorf("failed to build termination message: %v", e)
+		return
+	}
+	if e := ioutil.WriteFile(node.config.TerminationMessagePath, data, 0644); e != nil {
+		node.log.Errorf("failed to write termination message: %v", e)
+	}
+}
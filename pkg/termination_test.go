@@ -0,0 +1,121 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElectorNode_terminationMessage(t *testing.T) {
+	cases := []struct {
+		description string
+		reasons     []string
+		leader      string
+		err         error
+		wasLeader   bool
+		reason      string
+	}{
+		{
+			description: "leader stopped by signal",
+			reasons:     []string{"received termination signal terminated"},
+			leader:      "test-id",
+			err:         context.Canceled,
+			wasLeader:   true,
+			reason:      "received termination signal terminated",
+		},
+		{
+			description: "first reason is kept",
+			reasons:     []string{"shutdown requested over HTTP by 127.0.0.1", "received termination signal terminated"},
+			leader:      "other",
+			err:         context.Canceled,
+			reason:      "shutdown requested over HTTP by 127.0.0.1",
+		},
+		{
+			description: "cancelled without a reason",
+			leader:      "other",
+			err:         context.Canceled,
+			reason:      "context cancelled",
+		},
+		{
+			description: "election error",
+			err:         errors.New("lock failure"),
+			reason:      "election error: lock failure",
+		},
+		{
+			description: "no error",
+			reason:      "election stopped",
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(&ElectorConfig{
+			ID:     "test-id",
+			Name:   "test-election",
+			Logger: &testLogger{},
+		})
+		node.setLeader(c.leader)
+		for _, r := range c.reasons {
+			node.setStopReason(r)
+		}
+
+		msg := node.terminationMessage(c.err)
+		assert.Equal(t, "test-id", msg.Node, c.description)
+		assert.Equal(t, "test-election", msg.Election, c.description)
+		assert.Equal(t, c.wasLeader, msg.WasLeader, c.description)
+		assert.Equal(t, c.leader, msg.Leader, c.description)
+		assert.Equal(t, c.reason, msg.Reason, c.description)
+		assert.NotEmpty(t, msg.Timestamp, c.description)
+	}
+}
+
+func TestElectorNode_writeTerminationMessage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "termination")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "termination-log")
+
+	node := NewElectorNode(&ElectorConfig{
+		ID:                     "test-id",
+		Name:                   "test-election",
+		TerminationMessagePath: path,
+		Logger:                 &testLogger{},
+	})
+	node.setLeader("test-id")
+	node.setStopReason("received termination signal %v", "terminated")
+
+	node.writeTerminationMessage(context.Canceled)
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	var msg TerminationMessage
+	assert.NoError(t, json.Unmarshal(data, &msg))
+	assert.True(t, msg.WasLeader)
+	assert.Equal(t, "test-id", msg.Leader)
+	assert.Equal(t, "received termination signal terminated", msg.Reason)
+}
+
+func TestElectorNode_writeTerminationMessage_noPath(t *testing.T) {
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{ID: "test-id", Logger: log})
+
+	node.writeTerminationMessage(nil)
+	assert.Empty(t, log.String())
+}
+
+func TestElectorNode_writeTerminationMessage_error(t *testing.T) {
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{
+		ID:                     "test-id",
+		TerminationMessagePath: "/nonexistent/dir/termination-log",
+		Logger:                 log,
+	})
+
+	node.writeTerminationMessage(nil)
+	assert.Contains(t, log.String(), "failed to write termination message")
+}
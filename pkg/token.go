@@ -0,0 +1,205 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// The reasons the API server rejected an operation on the election lock as
+// an authentication or authorization failure.
+const (
+	lockAuthUnauthorized = "unauthorized"
+	lockAuthForbidden    = "forbidden"
+)
+
+// lockAuthReasons are the reasons of lock auth errors, in the order they are
+// reported.
+var lockAuthReasons = []string{
+	lockAuthUnauthorized,
+	lockAuthForbidden,
+}
+
+// lockAuthReason gets the reason the API server rejected an operation with
+// the given error, if it was rejected as unauthorized (401) or forbidden
+// (403). Otherwise, it is empty.
+func lockAuthReason(err error) string {
+	switch {
+	case apierrors.IsUnauthorized(err):
+		return lockAuthUnauthorized
+	case apierrors.IsForbidden(err):
+		return lockAuthForbidden
+	}
+	return ""
+}
+
+// observeAuthErrors is a lockObserver which counts the operations on the
+// election lock which were rejected as unauthorized or forbidden, and logs
+// them distinctly from other lock errors, so auth problems can be told apart
+// from connectivity problems at a glance.
+func (node *ElectorNode) observeAuthErrors(op string, ler resourcelock.LeaderElectionRecord, took time.Duration, err error) {
+	reason := lockAuthReason(err)
+	if reason == "" {
+		return
+	}
+
+	node.mu.Lock()
+	if node.lockAuthErrors == nil {
+		node.lockAuthErrors = map[string]int{}
+	}
+	node.lockAuthErrors[reason]++
+	node.mu.Unlock()
+
+	switch reason {
+	case lockAuthUnauthorized:
+		node.log.repeatedErrorf("election lock %s rejected as unauthorized (401): the service account token may be expired or being rotated: %v", op, err)
+	case lockAuthForbidden:
+		node.log.repeatedErrorf("election lock %s rejected as forbidden (403): the service account may lack permission on the lock: %v", op, err)
+	}
+}
+
+// lockAuthErrorCounts gets the number of operations on the election lock which
+// were rejected as unauthorized or forbidden, by reason.
+func (node *ElectorNode) lockAuthErrorCounts() map[string]int {
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	counts := make(map[string]int, len(node.lockAuthErrors))
+	for reason, count := range node.lockAuthErrors {
+		counts[reason] = count
+	}
+	return counts
+}
+
+// tokenRefreshTransport retries requests rejected as unauthorized with the
+// token re-read from the bearer token file, for clusters which rotate the
+// service account token.
+//
+// client-go re-reads the token file only periodically, so for a while after
+// the token is rotated it may keep sending a token which is no longer
+// accepted. Once a request is rejected, the token file is read again, and if
+// the token has changed, the request is retried with it. Later requests which
+// carry the rejected token are sent with the new token instead, until
+// client-go picks it up.
+type tokenRefreshTransport struct {
+	path string
+	rt   http.RoundTripper
+	log  logger
+
+	mu       sync.Mutex
+	rejected string
+	fresh    string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *tokenRefreshTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = t.withFreshToken(req)
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// Requests with a body can only be retried if the body can be read again.
+	sent := bearerToken(req)
+	if sent == "" || (req.Body != nil && req.GetBody == nil) {
+		return resp, err
+	}
+	token, e := readToken(t.path)
+	if e != nil || token == "" || token == sent {
+		return resp, err
+	}
+	retry, e := withToken(req, token)
+	if e != nil {
+		return resp, err
+	}
+
+	t.mu.Lock()
+	t.rejected = sent
+	t.fresh = token
+	t.mu.Unlock()
+
+	t.log.Warningf("request rejected as unauthorized: the service account token was rotated, retrying with the new token")
+	resp.Body.Close()
+	return t.rt.RoundTrip(retry)
+}
+
+// withFreshToken gets the request with the fresh token in place of the token
+// which was last rejected, if it carries that token.
+func (t *tokenRefreshTransport) withFreshToken(req *http.Request) *http.Request {
+	t.mu.Lock()
+	rejected, fresh := t.rejected, t.fresh
+	t.mu.Unlock()
+
+	if fresh == "" || bearerToken(req) != rejected {
+		return req
+	}
+	if retry, err := withToken(req, fresh); err == nil {
+		return retry
+	}
+	return req
+}
+
+// bearerToken gets the bearer token of the request, if any.
+func bearerToken(req *http.Request) string {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return ""
+	}
+	return strings.TrimPrefix(auth, "Bearer ")
+}
+
+// withToken copies the request with the given bearer token. The request's body,
+// if any, is read again for the copy.
+func withToken(req *http.Request, token string) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	clone.Header.Set("Authorization", "Bearer "+token)
+	return clone, nil
+}
+
+// readToken reads the bearer token from the token file.
+func readToken(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// refreshToken wraps the transport of the Kubernetes client to retry requests
+// rejected as unauthorized with the token re-read from the given token file.
+func (node *ElectorNode) refreshToken(path string) func(http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &tokenRefreshTransport{
+			path: path,
+			rt:   rt,
+			log:  node.log,
+		}
+	}
+}
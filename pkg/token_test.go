@@ -0,0 +1,259 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// rotatingTokenServer is an API server which only accepts the current token,
+// and serves a Pod to requests which carry it. The token it accepts can be
+// rotated, along with the token file clients read it from.
+type rotatingTokenServer struct {
+	*httptest.Server
+	path string
+
+	mu       sync.Mutex
+	token    string
+	accepted []string
+	rejected []string
+}
+
+// newRotatingTokenServer starts an API server which accepts the given token,
+// and writes the token to a token file.
+func newRotatingTokenServer(t *testing.T, token string) *rotatingTokenServer {
+	dir, err := ioutil.TempDir("", "token")
+	assert.NoError(t, err)
+
+	s := &rotatingTokenServer{path: filepath.Join(dir, "token")}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	s.rotate(t, token)
+	return s
+}
+
+// rotate rotates the token accepted by the server and in the token file.
+func (s *rotatingTokenServer) rotate(t *testing.T, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token
+	assert.NoError(t, ioutil.WriteFile(s.path, []byte(token+"\n"), 0600))
+}
+
+func (s *rotatingTokenServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	token := bearerToken(r)
+	if token != s.token {
+		s.rejected = append(s.rejected, token)
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Unauthorized","code":401}`)
+		return
+	}
+	s.accepted = append(s.accepted, token)
+	fmt.Fprint(w, `{"kind":"Pod","apiVersion":"v1","metadata":{"name":"test-pod","namespace":"test-ns"}}`)
+}
+
+// requests gets the tokens of the requests which the server accepted and
+// rejected.
+func (s *rotatingTokenServer) requests() (accepted, rejected []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.accepted...), append([]string(nil), s.rejected...)
+}
+
+func (s *rotatingTokenServer) cleanup() {
+	s.Close()
+	os.RemoveAll(filepath.Dir(s.path))
+}
+
+// newTokenFileClient creates a client for the server which reads its token
+// from the server's token file, with its transport wrapped by the node.
+func newTokenFileClient(t *testing.T, node *ElectorNode, s *rotatingTokenServer) kubernetes.Interface {
+	cfg := &rest.Config{Host: s.URL, BearerTokenFile: s.path}
+	node.wrapClientConfig(cfg)
+	client, err := kubernetes.NewForConfig(cfg)
+	assert.NoError(t, err)
+	return client
+}
+
+func TestTokenRefreshTransport_rotated(t *testing.T) {
+	server := newRotatingTokenServer(t, "token-1")
+	defer server.cleanup()
+
+	log := &testLogger{}
+	node := NewElectorNode(&ElectorConfig{Logger: log})
+	client := newTokenFileClient(t, node, server)
+
+	getPod := func() error {
+		_, err := client.CoreV1().Pods("test-ns").Get("test-pod", metav1.GetOptions{})
+		return err
+	}
+	assert.NoError(t, getPod())
+
+	// client-go keeps sending the token it cached after it is rotated, so the
+	// request is rejected, then retried with the new token.
+	server.rotate(t, "token-2")
+	assert.NoError(t, getPod())
+	assert.Contains(t, log.String(), "the service account token was rotated, retrying with the new token")
+
+	// Later requests are sent with the new token, without being rejected.
+	assert.NoError(t, getPod())
+
+	accepted, rejected := server.requests()
+	assert.Equal(t, []string{"token-1", "token-2", "token-2"}, accepted)
+	assert.Equal(t, []string{"token-1"}, rejected)
+}
+
+func TestTokenRefreshTransport_notRotated(t *testing.T) {
+	server := newRotatingTokenServer(t, "token-1")
+	defer server.cleanup()
+
+	node := NewElectorNode(&ElectorConfig{Logger: &testLogger{}})
+	client := newTokenFileClient(t, node, server)
+
+	// The server no longer accepts the token in the file, so the request is
+	// not retried.
+	server.mu.Lock()
+	server.token = "token-2"
+	server.mu.Unlock()
+
+	_, err := client.CoreV1().Pods("test-ns").Get("test-pod", metav1.GetOptions{})
+	assert.True(t, apierrors.IsUnauthorized(err))
+	_, rejected := server.requests()
+	assert.Equal(t, []string{"token-1"}, rejected)
+}
+
+func TestTokenRefreshTransport_body(t *testing.T) {
+	server := newRotatingTokenServer(t, "token-1")
+	defer server.cleanup()
+
+	node := NewElectorNode(&ElectorConfig{Logger: &testLogger{}})
+	client := newTokenFileClient(t, node, server)
+	server.rotate(t, "token-2")
+
+	// Requests with a body are retried with the body read again.
+	_, err := client.CoreV1().Pods("test-ns").Create(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"},
+	})
+	assert.NoError(t, err)
+	accepted, _ := server.requests()
+	assert.Equal(t, []string{"token-2"}, accepted)
+}
+
+func TestElectorNode_wrapClientConfig(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{})
+
+	// Without a token file, the transport is only pooled.
+	cfg := &rest.Config{BearerToken: "token"}
+	node.wrapClientConfig(cfg)
+	assert.IsType(t, &http.Transport{}, cfg.WrapTransport(&http.Transport{}))
+
+	cfg = &rest.Config{BearerTokenFile: "/var/run/secrets/kubernetes.io/serviceaccount/token"}
+	node.wrapClientConfig(cfg)
+	rt := cfg.WrapTransport(&http.Transport{})
+	if assert.IsType(t, &tokenRefreshTransport{}, rt) {
+		assert.IsType(t, &http.Transport{}, rt.(*tokenRefreshTransport).rt)
+	}
+}
+
+func TestElectorNode_kubeClient_reused(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		KubeConfig: "./testdata/config",
+	})
+
+	first, err := node.kubeClient()
+	assert.NoError(t, err)
+	second, err := node.kubeClient()
+	assert.NoError(t, err)
+	assert.True(t, first == second)
+
+	firstDynamic, err := node.dynamicClient()
+	assert.NoError(t, err)
+	secondDynamic, err := node.dynamicClient()
+	assert.NoError(t, err)
+	assert.True(t, firstDynamic == secondDynamic)
+}
+
+func TestElectorNode_observeAuthErrors(t *testing.T) {
+	cases := []struct {
+		description string
+		err         error
+		reason      string
+		message     string
+	}{
+		{
+			description: "unauthorized",
+			err:         apierrors.NewUnauthorized("token expired"),
+			reason:      lockAuthUnauthorized,
+			message:     "election lock get rejected as unauthorized (401): the service account token may be expired or being rotated: token expired",
+		},
+		{
+			description: "forbidden",
+			err:         apierrors.NewForbidden(corev1.Resource("leases"), "test-election", errors.New("no RBAC")),
+			reason:      lockAuthForbidden,
+			message:     "election lock get rejected as forbidden (403): the service account may lack permission on the lock",
+		},
+		{
+			description: "other error",
+			err:         errors.New("connection refused"),
+		},
+		{
+			description: "no error",
+		},
+	}
+
+	for _, c := range cases {
+		log := &testLogger{}
+		node := NewElectorNode(&ElectorConfig{
+			ID:     "test-node-1",
+			Logger: log,
+		})
+
+		node.observeAuthErrors(lockOpGet, resourcelock.LeaderElectionRecord{}, 0, c.err)
+		if c.reason == "" {
+			assert.Empty(t, node.lockAuthErrorCounts(), c.description)
+			assert.Empty(t, log.String(), c.description)
+			continue
+		}
+		assert.Equal(t, map[string]int{c.reason: 1}, node.lockAuthErrorCounts(), c.description)
+		assert.Contains(t, log.String(), c.message, c.description)
+	}
+}
+
+func TestElectorNode_registerMetrics_lockAuthErrors(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	node := newTestMetricsNode(&ElectorConfig{
+		Registerer: registry,
+	})
+	assert.NoError(t, node.registerMetrics())
+
+	node.observeAuthErrors(lockOpUpdate, resourcelock.LeaderElectionRecord{}, 0, apierrors.NewUnauthorized("token expired"))
+	node.observeAuthErrors(lockOpGet, resourcelock.LeaderElectionRecord{}, 0, apierrors.NewUnauthorized("token expired"))
+
+	err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP elector_lock_auth_errors_total The number of operations on the election lock which the API server rejected as unauthorized or forbidden, by reason (unauthorized, forbidden).
+# TYPE elector_lock_auth_errors_total counter
+elector_lock_auth_errors_total{election="test-election",reason="forbidden"} 0
+elector_lock_auth_errors_total{election="test-election",reason="unauthorized"} 2
+`), "elector_lock_auth_errors_total")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,71 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"net/http"
+	"runtime"
+)
+
+// DefaultClientMaxIdleConns is the default maximum number of idle connections
+// kept in the connection pool of the node's Kubernetes client, across all
+// hosts.
+const DefaultClientMaxIdleConns = 100
+
+// minClientMaxIdleConnsPerHost is the lower bound of the default maximum
+// number of idle connections kept for each host. It matches the net/http
+// default, so small GOMAXPROCS values never shrink the pool.
+const minClientMaxIdleConnsPerHost = http.DefaultMaxIdleConnsPerHost
+
+// defaultClientMaxIdleConnsPerHost gets the default maximum number of idle
+// connections kept in the connection pool of the node's Kubernetes client for
+// each host. A client typically talks to a single API server, so the pool is
+// scaled to the number of goroutines which may make requests concurrently.
+func defaultClientMaxIdleConnsPerHost() int {
+	if n := runtime.GOMAXPROCS(0); n > minClientMaxIdleConnsPerHost {
+		return n
+	}
+	return minClientMaxIdleConnsPerHost
+}
+
+// poolTransport sizes the connection pool of the Kubernetes client's
+// transport from the node's config. It is used to wrap the transport of the
+// client's rest.Config.
+//
+// client-go caches and shares its transports (and falls back to
+// http.DefaultTransport), so the transport is cloned rather than modified.
+// Transports which are not an *http.Transport are returned unchanged.
+func (node *ElectorNode) poolTransport(rt http.RoundTripper) http.RoundTripper {
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return rt
+	}
+
+	maxIdle := node.config.ClientMaxIdleConns
+	if maxIdle <= 0 {
+		maxIdle = DefaultClientMaxIdleConns
+	}
+	maxIdlePerHost := node.config.ClientMaxIdleConnsPerHost
+	if maxIdlePerHost <= 0 {
+		maxIdlePerHost = defaultClientMaxIdleConnsPerHost()
+	}
+
+	pooled := t.Clone()
+	pooled.MaxIdleConns = maxIdle
+	pooled.MaxIdleConnsPerHost = maxIdlePerHost
+	return pooled
+}
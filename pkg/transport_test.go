@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"net/http"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultClientMaxIdleConnsPerHost(t *testing.T) {
+	cases := []struct {
+		description string
+		procs       int
+		expected    int
+	}{
+		{
+			description: "one proc uses the minimum",
+			procs:       1,
+			expected:    2,
+		},
+		{
+			description: "procs at the minimum",
+			procs:       2,
+			expected:    2,
+		},
+		{
+			description: "procs above the minimum",
+			procs:       8,
+			expected:    8,
+		},
+	}
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	for _, c := range cases {
+		runtime.GOMAXPROCS(c.procs)
+		assert.Equal(t, c.expected, defaultClientMaxIdleConnsPerHost(), c.description)
+	}
+}
+
+func TestElectorNode_poolTransport(t *testing.T) {
+	cases := []struct {
+		description    string
+		config         *ElectorConfig
+		maxIdle        int
+		maxIdlePerHost int
+	}{
+		{
+			description:    "defaults",
+			config:         &ElectorConfig{},
+			maxIdle:        DefaultClientMaxIdleConns,
+			maxIdlePerHost: defaultClientMaxIdleConnsPerHost(),
+		},
+		{
+			description: "configured",
+			config: &ElectorConfig{
+				ClientMaxIdleConns:        20,
+				ClientMaxIdleConnsPerHost: 10,
+			},
+			maxIdle:        20,
+			maxIdlePerHost: 10,
+		},
+	}
+
+	for _, c := range cases {
+		node := NewElectorNode(c.config)
+		base := &http.Transport{}
+
+		rt := node.poolTransport(base)
+		if assert.IsType(t, &http.Transport{}, rt, c.description) {
+			pooled := rt.(*http.Transport)
+			assert.Equal(t, c.maxIdle, pooled.MaxIdleConns, c.description)
+			assert.Equal(t, c.maxIdlePerHost, pooled.MaxIdleConnsPerHost, c.description)
+		}
+
+		// The base transport may be shared, so it is never modified.
+		assert.Zero(t, base.MaxIdleConns, c.description)
+		assert.Zero(t, base.MaxIdleConnsPerHost, c.description)
+	}
+}
+
+// roundTripperFunc is an http.RoundTripper which is not an *http.Transport.
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestElectorNode_poolTransport_notHTTPTransport(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{})
+
+	var rt http.RoundTripper = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, nil
+	})
+	assert.IsType(t, roundTripperFunc(nil), node.poolTransport(rt))
+}
+
+func TestElectorNode_buildClientConfig_pool(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		KubeConfig:                "./testdata/config",
+		ClientMaxIdleConns:        20,
+		ClientMaxIdleConnsPerHost: 10,
+	})
+
+	cfg, err := node.buildClientConfig()
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg) && assert.NotNil(t, cfg.WrapTransport) {
+		rt := cfg.WrapTransport(&http.Transport{})
+		if assert.IsType(t, &http.Transport{}, rt) {
+			assert.Equal(t, 20, rt.(*http.Transport).MaxIdleConns)
+			assert.Equal(t, 10, rt.(*http.Transport).MaxIdleConnsPerHost)
+		}
+	}
+}
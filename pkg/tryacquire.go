@@ -0,0 +1,187 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// TryAcquire makes a single, non-blocking attempt to acquire the lock of an
+// election for the given identity, for short-lived jobs (e.g. CronJobs) which
+// want to do some work only if no one else holds the lock.
+//
+// The lock is acquired if it does not exist, is not held, is already held by
+// the identity, or the holder's lease has expired. It is recorded with a lease
+// duration of holdFor, which must be at least a second, and other TryAcquire
+// attempts do not take it until that has passed. The lock is not renewed, so
+// the job should finish its work within holdFor.
+//
+// This is cooperative with long-running elector nodes in the same election:
+// a lock held by a node is not taken while the node renews it. As with the
+// leader election client, a lease is only expired once its record has gone
+// unchanged for its lease duration, as measured with the local clock, so
+// clock skew with the holder does not matter. A lease which looks expired by
+// its renew time is watched for its lease duration before it is taken, so the
+// attempt only blocks then. Elector nodes do not respect holdFor, though: the
+// leader election client expires a lease with the node's own lease duration,
+// so a node may take the lock once its lease duration has passed since it
+// observed the lock being acquired. The job should not rely on holding the
+// lock for longer than the nodes' lease duration.
+//
+// If the lock was acquired, held is true and Release releases the lock by
+// clearing its holder, if it is still held by the identity. Releasing the lock
+// is best effort; if it fails, the lock is left to expire. If the lock is held
+// by someone else, or another attempt acquired it first, held is false and no
+// error is returned.
+func TryAcquire(ctx context.Context, client kubernetes.Interface, lockType, namespace, name, identity string, holdFor time.Duration) (Release func(), held bool, err error) {
+	if identity == "" {
+		return nil, false, errors.New("an identity is required to acquire the lock")
+	}
+	if holdFor < time.Second {
+		return nil, false, errors.New("the lock must be held for at least a second")
+	}
+
+	lock, err := resourcelock.New(
+		lockType,
+		namespace,
+		name,
+		client.CoreV1(),
+		client.CoordinationV1(),
+		resourcelock.ResourceLockConfig{Identity: identity},
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	existing, raw, err := lock.Get()
+	switch {
+	case apierrors.IsNotFound(err):
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+		if err := lock.Create(newTryAcquireRecord(identity, holdFor)); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				// Another attempt created the lock first.
+				return nil, false, nil
+			}
+			return nil, false, err
+		}
+		return releaseFunc(lock, identity), true, nil
+
+	case err != nil:
+		return nil, false, err
+	}
+
+	if existing.HolderIdentity != "" && existing.HolderIdentity != identity {
+		expired, err := leaseExpired(ctx, lock, existing, raw)
+		if err != nil || !expired {
+			return nil, false, err
+		}
+	}
+
+	record := newTryAcquireRecord(identity, holdFor)
+	if existing.HolderIdentity == identity {
+		record.AcquireTime = existing.AcquireTime
+		record.LeaderTransitions = existing.LeaderTransitions
+	} else {
+		record.LeaderTransitions = existing.LeaderTransitions + 1
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	// The lock is updated at the version it was last read at, so if it
+	// changed since (e.g. another attempt acquired it), the update conflicts.
+	if err := lock.Update(record); err != nil {
+		if apierrors.IsConflict(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return releaseFunc(lock, identity), true, nil
+}
+
+// newTryAcquireRecord creates the lock record for the identity holding the
+// lock for the given duration.
+func newTryAcquireRecord(identity string, holdFor time.Duration) resourcelock.LeaderElectionRecord {
+	now := metav1.Now()
+	return resourcelock.LeaderElectionRecord{
+		HolderIdentity:       identity,
+		LeaseDurationSeconds: int(holdFor / time.Second),
+		AcquireTime:          now,
+		RenewTime:            now,
+	}
+}
+
+// leaseExpired checks whether the lease of the lock record read from the lock
+// has expired.
+//
+// A lease which has not expired by its renew time is held. Since the renew
+// time was written with the holder's clock, a lease which looks expired may
+// still be renewed by a holder with a clock behind the local one, so the lock
+// is read again after the lease duration: the lease has only expired if the
+// record did not change in the meantime.
+func leaseExpired(ctx context.Context, lock resourcelock.Interface, record *resourcelock.LeaderElectionRecord, raw []byte) (bool, error) {
+	duration := time.Duration(record.LeaseDurationSeconds) * time.Second
+	if record.RenewTime.Add(duration).After(time.Now()) {
+		return false, nil
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-timer.C:
+	}
+
+	_, observed, err := lock.Get()
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(raw, observed), nil
+}
+
+// releaseFunc creates the function which releases a lock acquired by
+// TryAcquire. The lock is only released if it is still held by the identity,
+// so a lock taken over after its lease expired is left alone.
+func releaseFunc(lock resourcelock.Interface, identity string) func() {
+	return func() {
+		existing, _, err := lock.Get()
+		if err != nil || existing.HolderIdentity != identity {
+			return
+		}
+
+		// Releasing the lock follows the leader election client's own release:
+		// the record is cleared, keeping only the leader transitions. A lock
+		// without a holder is acquired by the next node to try.
+		_ = lock.Update(resourcelock.LeaderElectionRecord{
+			LeaderTransitions: existing.LeaderTransitions,
+		})
+	}
+}
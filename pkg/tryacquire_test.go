@@ -0,0 +1,223 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// newTestLease creates a lease held by the holder, renewed at the given time.
+func newTestLease(holder string, renewed time.Time, duration int32) *coordinationv1.Lease {
+	renewTime := metav1.NewMicroTime(renewed)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-ns",
+			Name:      "test-election",
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			AcquireTime:          &renewTime,
+			RenewTime:            &renewTime,
+		},
+	}
+}
+
+// testLeaseHolder gets the holder of the test lease.
+func testLeaseHolder(t *testing.T, client *fake.Clientset) string {
+	lease, err := client.CoordinationV1().Leases("test-ns").Get("test-election", metav1.GetOptions{})
+	assert.NoError(t, err)
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+func TestTryAcquire(t *testing.T) {
+	cases := []struct {
+		description string
+		lease       *coordinationv1.Lease
+		held        bool
+		holder      string
+	}{
+		{
+			description: "lock does not exist",
+			held:        true,
+			holder:      "test-job",
+		},
+		{
+			description: "lock released",
+			lease:       newTestLease("", time.Now(), 1),
+			held:        true,
+			holder:      "test-job",
+		},
+		{
+			description: "lock held by another node",
+			lease:       newTestLease("test-node-1", time.Now(), 15),
+			held:        false,
+			holder:      "test-node-1",
+		},
+		{
+			description: "lock held by another node has expired",
+			lease:       newTestLease("test-node-1", time.Now().Add(-time.Minute), 1),
+			held:        true,
+			holder:      "test-job",
+		},
+		{
+			description: "lock already held by the identity",
+			lease:       newTestLease("test-job", time.Now(), 15),
+			held:        true,
+			holder:      "test-job",
+		},
+	}
+
+	for _, c := range cases {
+		client := fake.NewSimpleClientset()
+		if c.lease != nil {
+			client = fake.NewSimpleClientset(c.lease)
+		}
+
+		release, held, err := TryAcquire(context.Background(), client, resourcelock.LeasesResourceLock, "test-ns", "test-election", "test-job", 30*time.Second)
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, c.held, held, c.description)
+		assert.Equal(t, c.holder, testLeaseHolder(t, client), c.description)
+
+		if !held {
+			assert.Nil(t, release, c.description)
+			continue
+		}
+		lease, err := client.CoordinationV1().Leases("test-ns").Get("test-election", metav1.GetOptions{})
+		assert.NoError(t, err, c.description)
+		assert.Equal(t, int32(30), *lease.Spec.LeaseDurationSeconds, c.description)
+
+		// Releasing the lock clears the holder.
+		release()
+		assert.Equal(t, "", testLeaseHolder(t, client), c.description)
+	}
+}
+
+func TestTryAcquire_leaderTransitions(t *testing.T) {
+	lease := newTestLease("test-node-1", time.Now().Add(-time.Minute), 1)
+	transitions := int32(3)
+	lease.Spec.LeaseTransitions = &transitions
+	client := fake.NewSimpleClientset(lease)
+
+	_, held, err := TryAcquire(context.Background(), client, resourcelock.LeasesResourceLock, "test-ns", "test-election", "test-job", 30*time.Second)
+	assert.NoError(t, err)
+	assert.True(t, held)
+
+	lease, err = client.CoordinationV1().Leases("test-ns").Get("test-election", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), *lease.Spec.LeaseTransitions)
+}
+
+func TestTryAcquire_renewedWhileExpired(t *testing.T) {
+	// The lease looks expired by its renew time, e.g. since the holder's
+	// clock is behind, but the holder still renews it.
+	client := fake.NewSimpleClientset(newTestLease("test-node-1", time.Now().Add(-time.Minute), 1))
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_, err := client.CoordinationV1().Leases("test-ns").Update(newTestLease("test-node-1", time.Now().Add(-time.Minute+time.Second), 1))
+		assert.NoError(t, err)
+	}()
+
+	release, held, err := TryAcquire(context.Background(), client, resourcelock.LeasesResourceLock, "test-ns", "test-election", "test-job", 30*time.Second)
+	assert.NoError(t, err)
+	assert.False(t, held)
+	assert.Nil(t, release)
+	assert.Equal(t, "test-node-1", testLeaseHolder(t, client))
+}
+
+func TestTryAcquire_releaseTakenOver(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	release, held, err := TryAcquire(context.Background(), client, resourcelock.LeasesResourceLock, "test-ns", "test-election", "test-job", 30*time.Second)
+	assert.NoError(t, err)
+	assert.True(t, held)
+
+	// Once the lock has been taken over, releasing it leaves the new holder.
+	_, err = client.CoordinationV1().Leases("test-ns").Update(newTestLease("test-node-1", time.Now(), 15))
+	assert.NoError(t, err)
+	release()
+	assert.Equal(t, "test-node-1", testLeaseHolder(t, client))
+}
+
+func TestTryAcquire_concurrent(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	var acquired int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, held, err := TryAcquire(context.Background(), client, resourcelock.LeasesResourceLock, "test-ns", "test-election", fmt.Sprintf("test-job-%d", i), 30*time.Second)
+			assert.NoError(t, err)
+			if held {
+				atomic.AddInt32(&acquired, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Only one of the attempts holds the lock.
+	assert.Equal(t, int32(1), acquired)
+	assert.NotEqual(t, "", testLeaseHolder(t, client))
+}
+
+func TestTryAcquire_error(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cases := []struct {
+		description string
+		ctx         context.Context
+		lockType    string
+		identity    string
+		holdFor     time.Duration
+	}{
+		{
+			description: "no identity",
+			ctx:         context.Background(),
+			lockType:    resourcelock.LeasesResourceLock,
+			holdFor:     30 * time.Second,
+		},
+		{
+			description: "hold for less than a second",
+			ctx:         context.Background(),
+			lockType:    resourcelock.LeasesResourceLock,
+			identity:    "test-job",
+			holdFor:     500 * time.Millisecond,
+		},
+		{
+			description: "invalid lock type",
+			ctx:         context.Background(),
+			lockType:    "invalid",
+			identity:    "test-job",
+			holdFor:     30 * time.Second,
+		},
+		{
+			description: "context cancelled",
+			ctx:         cancelled,
+			lockType:    resourcelock.LeasesResourceLock,
+			identity:    "test-job",
+			holdFor:     30 * time.Second,
+		},
+	}
+
+	for _, c := range cases {
+		client := fake.NewSimpleClientset()
+		release, held, err := TryAcquire(c.ctx, client, c.lockType, "test-ns", "test-election", c.identity, c.holdFor)
+		assert.Error(t, err, c.description)
+		assert.False(t, held, c.description)
+		assert.Nil(t, release, c.description)
+	}
+}
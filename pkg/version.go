@@ -0,0 +1,47 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// BuildInfo describes the build of the application running the elector node.
+// It is reported at /version and by the elector_build_info metric.
+type BuildInfo struct {
+	Version   string `json:"version" description:"The version of the build."`
+	Commit    string `json:"commit" description:"The commit the build was made from."`
+	Tag       string `json:"tag" description:"The tag the build was made from."`
+	GoVersion string `json:"go" description:"The Go version the build was made with."`
+	OS        string `json:"os" description:"The operating system the build targets."`
+	Arch      string `json:"arch" description:"The architecture the build targets."`
+	BuildDate string `json:"date" description:"The date of the build."`
+}
+
+// String formats the build info as a single line of fields, for logging at
+// startup.
+func (b BuildInfo) String() string {
+	return fmt.Sprintf("version=%q commit=%q tag=%q go=%q os=%q arch=%q date=%q",
+		b.Version, b.Commit, b.Tag, b.GoVersion, b.OS, b.Arch, b.BuildDate)
+}
+
+// httpVersion is the handler for the endpoint which provides the build info
+// of the application running the elector node.
+func (node *ElectorNode) httpVersion(res http.ResponseWriter, req *http.Request) {
+	node.writeJSON(res, http.StatusOK, node.config.Build)
+}
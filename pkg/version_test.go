@@ -0,0 +1,36 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildInfo_String(t *testing.T) {
+	build := BuildInfo{
+		Version:   "1.2.3",
+		Commit:    "abc123",
+		GoVersion: "go1.13",
+		OS:        "linux",
+		Arch:      "amd64",
+	}
+	assert.Equal(t, `version="1.2.3" commit="abc123" tag="" go="go1.13" os="linux" arch="amd64" date=""`, build.String())
+}
+
+func TestElectorNode_httpVersion(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:    "test-node-1",
+		Build: BuildInfo{Version: "1.2.3", Commit: "abc123", OS: "linux", Arch: "amd64"},
+	})
+
+	data := getJSON(t, node, "/version")
+	assert.Equal(t, map[string]interface{}{
+		"version": "1.2.3",
+		"commit":  "abc123",
+		"tag":     "",
+		"go":      "",
+		"os":      "linux",
+		"arch":    "amd64",
+		"date":    "",
+	}, data)
+}
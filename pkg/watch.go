@@ -0,0 +1,265 @@
+// k8s-elector
+// Copyright (c) 2019 Vapor IO
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// watchBuffer is the number of leadership events buffered for each watcher.
+// Events are dropped for a watcher which falls this far behind, rather than
+// holding up the other watchers.
+const watchBuffer = 16
+
+const (
+	// DefaultMaxWatchers is the default maximum number of streams which may
+	// watch the node's leadership transitions at once.
+	DefaultMaxWatchers = 100
+
+	// DefaultWatchWriteTimeout is the default time a watcher's buffer may stay
+	// full before the watcher is dropped.
+	DefaultWatchWriteTimeout = 30 * time.Second
+)
+
+// watchEventName is the name of the server-sent events which carry leadership
+// events on the watch endpoint.
+const watchEventName = "leadership"
+
+// watcher is a stream watching the node's leadership transitions.
+type watcher struct {
+	// events receives the leadership events for the watcher.
+	events chan LeadershipEvent
+
+	// evicted is closed once the watcher is dropped for falling behind.
+	evicted chan struct{}
+
+	// behindSince is when the watcher's buffer was found full, or zero if it
+	// has not been full since it last received an event.
+	behindSince time.Time
+}
+
+// watchers holds the streams watching the node's leadership transitions.
+//
+// The memory held for watchers is bounded: the number of watchers is capped,
+// each has a fixed buffer, and a watcher whose buffer stays full for the
+// write timeout is dropped.
+type watchers struct {
+	mu   sync.Mutex
+	subs map[*watcher]struct{}
+}
+
+// add adds a watcher, which receives leadership events until it is removed.
+// If there are already as many watchers as the limit, no watcher is added and
+// false is returned.
+func (w *watchers) add(limit int) (*watcher, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.subs == nil {
+		w.subs = map[*watcher]struct{}{}
+	}
+	if len(w.subs) >= limit {
+		return nil, false
+	}
+	sub := &watcher{
+		events:  make(chan LeadershipEvent, watchBuffer),
+		evicted: make(chan struct{}),
+	}
+	w.subs[sub] = struct{}{}
+	return sub, true
+}
+
+// remove removes a watcher.
+func (w *watchers) remove(sub *watcher) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.subs, sub)
+}
+
+// count gets the number of watchers.
+func (w *watchers) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.subs)
+}
+
+// broadcast sends the event to each watcher without blocking.
+//
+// A watcher whose buffer is full misses the event. Once its buffer has been
+// full for longer than the timeout, the watcher is evicted: it is removed,
+// and its stream is closed. The number of watchers which missed the event,
+// and the number which were evicted, are returned.
+func (w *watchers) broadcast(event LeadershipEvent, now time.Time, timeout time.Duration) (dropped, evicted int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for sub := range w.subs {
+		select {
+		case sub.events <- event:
+			sub.behindSince = time.Time{}
+			continue
+		default:
+		}
+
+		if sub.behindSince.IsZero() {
+			sub.behindSince = now
+		}
+		if now.Sub(sub.behindSince) > timeout {
+			delete(w.subs, sub)
+			close(sub.evicted)
+			evicted++
+			continue
+		}
+		dropped++
+	}
+	return dropped, evicted
+}
+
+// maxWatchers gets the maximum number of streams which may watch the node's
+// leadership transitions at once.
+func (node *ElectorNode) maxWatchers() int {
+	if node.config.MaxWatchers <= 0 {
+		return DefaultMaxWatchers
+	}
+	return node.config.MaxWatchers
+}
+
+// watchWriteTimeout gets the time a watcher's buffer may stay full before
+// the watcher is dropped.
+func (node *ElectorNode) watchWriteTimeout() time.Duration {
+	if node.config.WatchWriteTimeout <= 0 {
+		return DefaultWatchWriteTimeout
+	}
+	return node.config.WatchWriteTimeout
+}
+
+// broadcastWatchers sends the event to the streams watching the node's
+// leadership transitions, logging those which have fallen behind.
+func (node *ElectorNode) broadcastWatchers(event LeadershipEvent) {
+	dropped, evicted := node.watchers.broadcast(event, node.clock.Now(), node.watchWriteTimeout())
+	if dropped > 0 {
+		node.log.Warningf("dropped leadership event for %d watchers which have fallen behind", dropped)
+	}
+	if evicted > 0 {
+		node.log.Warningf("closed %d watchers which fell behind for longer than %v", evicted, node.watchWriteTimeout())
+	}
+}
+
+// leadershipEvent builds the leadership event for the node's status.
+func (node *ElectorNode) leadershipEvent(status string) LeadershipEvent {
+	return LeadershipEvent{
+		ID:             node.lastEventID(),
+		Node:           node.config.ID,
+		Election:       node.config.Name,
+		Role:           node.config.role(),
+		Status:         status,
+		Leader:         node.leader(),
+		PreviousLeader: node.previousLeaderID(),
+		Timestamp:      Timestamp(node.clock.Now()),
+	}
+}
+
+// status gets the leadership status of the node.
+func (node *ElectorNode) status() string {
+	if node.IsLeader() {
+		return StatusLeader
+	}
+	return StatusStandby
+}
+
+// watchPublisher publishes the status of the elector node to the streams
+// watching its leadership transitions.
+type watchPublisher struct {
+	node *ElectorNode
+}
+
+func (p *watchPublisher) name() string {
+	return "watchers"
+}
+
+// publish sends the status to each watcher. A watcher which has fallen behind
+// misses the event, so no error is returned.
+func (p *watchPublisher) publish(status string) error {
+	p.node.broadcastWatchers(p.node.leadershipEvent(status))
+	return nil
+}
+
+// writeWatchEvent writes a leadership event as a server-sent event.
+func writeWatchEvent(w io.Writer, event LeadershipEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", watchEventName, data)
+	return err
+}
+
+// httpWatch is the handler for the endpoint which streams the node's
+// leadership transitions as server-sent events.
+//
+// The node's current status is sent as soon as the stream opens, followed by
+// an event for each transition. The stream is open until the client goes
+// away, the HTTP server shuts down, or the client falls behind for longer
+// than the watch write timeout. Once the maximum number of streams are open,
+// new streams are rejected.
+func (node *ElectorNode) httpWatch(res http.ResponseWriter, req *http.Request) {
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		node.writeJSON(res, http.StatusInternalServerError, MessageResponse{
+			Message: "streaming is not supported",
+		})
+		return
+	}
+
+	sub, ok := node.watchers.add(node.maxWatchers())
+	if !ok {
+		node.writeJSON(res, http.StatusServiceUnavailable, MessageResponse{
+			Message: "too many watchers",
+		})
+		return
+	}
+	defer node.watchers.remove(sub)
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.WriteHeader(http.StatusOK)
+
+	event := node.leadershipEvent(node.status())
+	for {
+		if err := writeWatchEvent(res, event); err != nil {
+			node.log.Warningf("failed to write leadership event to watcher %s: %v", req.RemoteAddr, err)
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-req.Context().Done():
+			return
+		case <-node.httpCtx.Done():
+			return
+		case <-sub.evicted:
+			node.log.Warningf("closing watch stream of %s: it fell behind for longer than %v", req.RemoteAddr, node.watchWriteTimeout())
+			return
+		case event = <-sub.events:
+		}
+	}
+}
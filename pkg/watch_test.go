@@ -0,0 +1,213 @@
+package pkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchers_broadcast(t *testing.T) {
+	var w watchers
+	now := time.Now()
+	a, _ := w.add(DefaultMaxWatchers)
+	b, _ := w.add(DefaultMaxWatchers)
+	assert.Equal(t, 2, w.count())
+
+	// A watcher which has fallen behind misses events without holding up
+	// the others.
+	for i := 0; i < watchBuffer; i++ {
+		dropped, evicted := w.broadcast(LeadershipEvent{Status: StatusLeader}, now, time.Minute)
+		assert.Equal(t, 0, dropped)
+		assert.Equal(t, 0, evicted)
+		<-a.events
+	}
+	dropped, evicted := w.broadcast(LeadershipEvent{Status: StatusStandby}, now, time.Minute)
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, 0, evicted)
+	assert.Equal(t, StatusStandby, (<-a.events).Status)
+
+	w.remove(b)
+	assert.Equal(t, 1, w.count())
+	dropped, evicted = w.broadcast(LeadershipEvent{Status: StatusLeader}, now, time.Minute)
+	assert.Equal(t, 0, dropped)
+	assert.Equal(t, 0, evicted)
+}
+
+func TestWatchers_broadcast_evict(t *testing.T) {
+	var w watchers
+	now := time.Now()
+	slow, _ := w.add(DefaultMaxWatchers)
+	fast, _ := w.add(DefaultMaxWatchers)
+	for i := 0; i < watchBuffer; i++ {
+		w.broadcast(LeadershipEvent{Status: StatusLeader}, now, time.Minute)
+		<-fast.events
+	}
+
+	// The slow watcher is kept while it has been behind for less than the
+	// timeout. A watcher which catches up is no longer behind.
+	dropped, evicted := w.broadcast(LeadershipEvent{Status: StatusStandby}, now, time.Minute)
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, 0, evicted)
+	<-fast.events
+	dropped, evicted = w.broadcast(LeadershipEvent{Status: StatusStandby}, now.Add(time.Minute), time.Minute)
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, 0, evicted)
+	<-fast.events
+
+	// Once it has been behind for longer than the timeout, the slow watcher
+	// is evicted, and its stream is told to close.
+	dropped, evicted = w.broadcast(LeadershipEvent{Status: StatusLeader}, now.Add(time.Minute+time.Second), time.Minute)
+	assert.Equal(t, 0, dropped)
+	assert.Equal(t, 1, evicted)
+	assert.Equal(t, 1, w.count())
+	select {
+	case <-slow.evicted:
+	default:
+		assert.Fail(t, "slow watcher not evicted")
+	}
+	select {
+	case <-fast.evicted:
+		assert.Fail(t, "fast watcher evicted")
+	default:
+	}
+}
+
+func TestWatchers_add_limit(t *testing.T) {
+	var w watchers
+	for i := 0; i < 3; i++ {
+		_, ok := w.add(3)
+		assert.True(t, ok)
+	}
+	sub, ok := w.add(3)
+	assert.False(t, ok)
+	assert.Nil(t, sub)
+	assert.Equal(t, 3, w.count())
+}
+
+// readWatchEvent reads the next leadership event from a watch stream.
+func readWatchEvent(t *testing.T, r *bufio.Reader) LeadershipEvent {
+	var event LeadershipEvent
+	for {
+		line, err := r.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return event
+		}
+		if strings.HasPrefix(line, "data: ") {
+			assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+			return event
+		}
+	}
+}
+
+func TestElectorNode_httpWatch(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:        "test-node-1",
+		Name:      "test-election",
+		AccessLog: true,
+		Logger:    &testLogger{},
+	})
+	server := httptest.NewServer(node.handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/watch")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	r := bufio.NewReader(resp.Body)
+
+	// The current status is sent when the stream opens.
+	event := readWatchEvent(t, r)
+	assert.Equal(t, StatusStandby, event.Status)
+	assert.Equal(t, "test-node-1", event.Node)
+	assert.Equal(t, "", event.Leader)
+
+	waitFor(t, 5*time.Second, func() bool {
+		return node.watchers.count() == 1
+	})
+	node.setLeader("test-node-1")
+	assert.NoError(t, (&watchPublisher{node: node}).publish(StatusLeader))
+
+	event = readWatchEvent(t, r)
+	assert.Equal(t, StatusLeader, event.Status)
+	assert.Equal(t, "test-node-1", event.Leader)
+
+	// The stream ends when the HTTP server shuts down, after the blank line
+	// which closes the last event.
+	node.httpCancel()
+	rest, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "\n", string(rest))
+	waitFor(t, 5*time.Second, func() bool {
+		return node.watchers.count() == 0
+	})
+}
+
+func TestElectorNode_httpWatch_limit(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:          "test-node-1",
+		Name:        "test-election",
+		MaxWatchers: 1,
+		Logger:      &testLogger{},
+	})
+	server := httptest.NewServer(node.handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/watch")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	readWatchEvent(t, bufio.NewReader(resp.Body))
+
+	// Once the maximum number of streams are open, new streams are rejected.
+	rejected, err := http.Get(server.URL + "/watch")
+	if !assert.NoError(t, err) {
+		return
+	}
+	rejected.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, rejected.StatusCode)
+	assert.Equal(t, 1, node.watchers.count())
+}
+
+func TestElectorNode_httpWatch_evicted(t *testing.T) {
+	node := NewElectorNode(&ElectorConfig{
+		ID:     "test-node-1",
+		Name:   "test-election",
+		Logger: &testLogger{},
+	})
+	server := httptest.NewServer(node.handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/watch")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	r := bufio.NewReader(resp.Body)
+	readWatchEvent(t, r)
+	waitFor(t, 5*time.Second, func() bool {
+		return node.watchers.count() == 1
+	})
+
+	// Once the watcher is evicted for falling behind, its stream is closed.
+	node.watchers.mu.Lock()
+	for sub := range node.watchers.subs {
+		delete(node.watchers.subs, sub)
+		close(sub.evicted)
+	}
+	node.watchers.mu.Unlock()
+
+	_, err = ioutil.ReadAll(r)
+	assert.NoError(t, err)
+}